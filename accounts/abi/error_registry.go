@@ -0,0 +1,228 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// registeredError is a custom error known to an ErrorRegistry.
+type registeredError struct {
+	Error
+	named bool // Whether the inputs are named, false for bare signatures
+}
+
+// ErrorRegistry decodes revert data into human readable errors. Besides the
+// builtin Error(string) and Panic(uint256) reverts, it decodes the custom errors
+// of the ABIs and signatures registered with it.
+//
+// A nil registry decodes the builtin reverts only.
+type ErrorRegistry struct {
+	errors map[[4]byte][]*registeredError // Custom errors by selector
+	lock   sync.RWMutex
+}
+
+// NewErrorRegistry creates an empty error registry.
+func NewErrorRegistry() *ErrorRegistry {
+	return &ErrorRegistry{errors: make(map[[4]byte][]*registeredError)}
+}
+
+// Len returns the number of custom errors known to the registry.
+func (r *ErrorRegistry) Len() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var n int
+	for _, errs := range r.errors {
+		n += len(errs)
+	}
+	return n
+}
+
+// add registers a custom error, unless an error with the same signature is
+// already known.
+func (r *ErrorRegistry) add(err Error, named bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	id := [4]byte(err.ID[:4])
+	for _, known := range r.errors[id] {
+		if known.Sig == err.Sig {
+			// Prefer the definitions carrying the names of the inputs
+			if named && !known.named {
+				known.Error, known.named = err, true
+			}
+			return
+		}
+	}
+	r.errors[id] = append(r.errors[id], &registeredError{Error: err, named: named})
+}
+
+// AddABI registers the custom errors of a contract ABI.
+func (r *ErrorRegistry) AddABI(abi *ABI) {
+	for _, err := range abi.Errors {
+		r.add(err, true)
+	}
+}
+
+// AddSignature registers a custom error by its signature, e.g.
+// InsufficientBalance(uint256,uint256).
+func (r *ErrorRegistry) AddSignature(sig string) error {
+	selector, err := ParseSelector(sig)
+	if err != nil {
+		return err
+	}
+	args := make(Arguments, len(selector.Inputs))
+	for i, input := range selector.Inputs {
+		typ, err := NewType(input.Type, input.InternalType, input.Components)
+		if err != nil {
+			return err
+		}
+		args[i] = Argument{Type: typ}
+	}
+	r.add(NewError(selector.Name, args), false)
+	return nil
+}
+
+// LoadDir registers the custom errors of the contract ABIs stored in the JSON
+// files of a directory. A file either holds an ABI, or a compiler artifact with
+// the ABI in its abi field, as output by solc, Hardhat or Foundry.
+func (r *ErrorRegistry) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 && data[0] == '{' {
+			var artifact struct {
+				ABI json.RawMessage `json:"abi"`
+			}
+			if err := json.Unmarshal(data, &artifact); err != nil {
+				return fmt.Errorf("invalid artifact %s: %v", file, err)
+			}
+			if artifact.ABI == nil {
+				return fmt.Errorf("artifact %s has no abi", file)
+			}
+			data = artifact.ABI
+		}
+		abi, err := JSON(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("invalid abi %s: %v", file, err)
+		}
+		r.AddABI(&abi)
+	}
+	return nil
+}
+
+// LoadSignatures registers the custom errors listed in a file, one signature per
+// line, e.g. InsufficientBalance(uint256,uint256). Empty lines and lines starting
+// with # are ignored.
+//
+// Only error signatures belong into the file. Function signature databases, like
+// the 4byte database of clef, don't tell errors from functions sharing the
+// selector space, and would make reverts decode as unrelated signatures.
+func (r *ErrorRegistry) LoadSignatures(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := r.AddSignature(line); err != nil {
+			return fmt.Errorf("invalid error signature %q at %s:%d: %v", line, path, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Decode decodes revert data into a human readable error. The builtin reverts
+// are decoded into their reason, custom errors into their signature with the
+// values of their inputs, e.g. InsufficientBalance(available: 1, required: 2).
+func (r *ErrorRegistry) Decode(data []byte) (string, error) {
+	if reason, err := UnpackRevert(data); err == nil {
+		return reason, nil
+	}
+	if r == nil || len(data) < 4 {
+		return "", errors.New("invalid data for unpacking")
+	}
+	r.lock.RLock()
+	candidates := r.errors[[4]byte(data[:4])]
+	r.lock.RUnlock()
+
+	for _, candidate := range candidates {
+		values, err := candidate.Inputs.Unpack(data[4:])
+		if err != nil {
+			continue
+		}
+		// Reject the data stuffed beyond the inputs, which likely belongs to a
+		// different error sharing the selector
+		if packed, err := candidate.Inputs.Pack(values...); err != nil || !bytes.Equal(packed, data[4:]) {
+			continue
+		}
+		args := make([]string, len(values))
+		for i, value := range values {
+			args[i] = formatErrorArg(value)
+			if candidate.named {
+				args[i] = candidate.Inputs[i].Name + ": " + args[i]
+			}
+		}
+		return fmt.Sprintf("%s(%s)", candidate.Name, strings.Join(args, ", ")), nil
+	}
+	return "", fmt.Errorf("unknown error selector %#x", data[:4])
+}
+
+// formatErrorArg formats the value of an error input.
+func formatErrorArg(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return hexutil.Encode(v)
+	case common.Address:
+		return v.Hex()
+	case *big.Int:
+		return v.String()
+	}
+	// Fixed size byte arrays are shown in hex too
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return hexutil.Encode(b)
+	}
+	return fmt.Sprint(value)
+}
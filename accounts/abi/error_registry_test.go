@@ -0,0 +1,116 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestErrorRegistry(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		abiJSON = `[{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}]`
+		owner   = common.HexToAddress("0xabc")
+	)
+	// Load an ABI, an artifact and a signature list
+	if err := os.WriteFile(filepath.Join(dir, "Token.json"), []byte(abiJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+	artifact := `{"contractName":"Vault","abi":[{"type":"error","name":"Unauthorized","inputs":[{"name":"caller","type":"address"}]}]}`
+	if err := os.WriteFile(filepath.Join(dir, "Vault.json"), []byte(artifact), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sigs := filepath.Join(t.TempDir(), "errors.txt")
+	if err := os.WriteFile(sigs, []byte("# Errors of the registry\n\nExpired(uint64,bytes32)\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewErrorRegistry()
+	if err := registry.LoadDir(dir); err != nil {
+		t.Fatalf("failed to load abis: %v", err)
+	}
+	if err := registry.LoadSignatures(sigs); err != nil {
+		t.Fatalf("failed to load signatures: %v", err)
+	}
+	if n := registry.Len(); n != 3 {
+		t.Fatalf("error count mismatch: have %d, want 3", n)
+	}
+	// Signature lists must only hold valid signatures
+	invalid := filepath.Join(t.TempDir(), "invalid.txt")
+	if err := os.WriteFile(invalid, []byte("Expired(uint64,bytes32)\nnot a signature\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewErrorRegistry().LoadSignatures(invalid); err == nil {
+		t.Fatal("invalid signature list loaded")
+	}
+	encode := func(sig string, args ...interface{}) []byte {
+		t.Helper()
+		selector, err := ParseSelector(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs := make(Arguments, len(selector.Inputs))
+		for i, input := range selector.Inputs {
+			inputs[i].Type, _ = NewType(input.Type, "", nil)
+		}
+		packed, err := inputs.Pack(args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(crypto.Keccak256([]byte(sig))[:4], packed...)
+	}
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{encode("Error(string)", "not enough"), "not enough"},
+		{encode("Panic(uint256)", big.NewInt(0x11)), "arithmetic underflow or overflow"},
+		{encode("InsufficientBalance(uint256,uint256)", big.NewInt(1), big.NewInt(2)), "InsufficientBalance(available: 1, required: 2)"},
+		{encode("Unauthorized(address)", owner), "Unauthorized(caller: " + owner.Hex() + ")"},
+		{encode("Expired(uint64,bytes32)", uint64(5), [32]byte{0xff}), "Expired(5, 0xff00000000000000000000000000000000000000000000000000000000000000)"},
+	}
+	for i, tt := range tests {
+		have, err := registry.Decode(tt.data)
+		if err != nil {
+			t.Errorf("test %d: failed to decode: %v", i, err)
+			continue
+		}
+		if have != tt.want {
+			t.Errorf("test %d: decoded error mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+	// Unknown selectors and data not matching the inputs are not decoded
+	if _, err := registry.Decode(encode("Unknown(uint256)", big.NewInt(1))); err == nil {
+		t.Error("unknown error decoded")
+	}
+	if _, err := registry.Decode(append(encode("Unauthorized(address)", owner), 1)); err == nil {
+		t.Error("stuffed error decoded")
+	}
+	// A nil registry decodes the builtin reverts only
+	var empty *ErrorRegistry
+	if have, err := empty.Decode(tests[0].data); err != nil || have != tests[0].want {
+		t.Errorf("builtin revert mismatch: have %q, %v", have, err)
+	}
+	if _, err := empty.Decode(tests[2].data); err == nil {
+		t.Error("custom error decoded without registry")
+	}
+}
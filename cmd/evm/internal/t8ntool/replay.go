@@ -0,0 +1,121 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+// Replay re-executes the bad block bundle written by geth into the directory
+// given as argument, and reports how the results compare to the ones claimed by
+// the block and the ones computed by the node which rejected it. It fails if the
+// results don't match the block.
+func Replay(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		return NewError(ErrorConfig, errors.New("usage: evm replay [flags] <bundle directory>"))
+	}
+	bundle := ctx.Args().First()
+
+	baseDir, err := createBasedir(ctx)
+	if err != nil {
+		return NewError(ErrorIO, fmt.Errorf("failed creating output basedir: %v", err))
+	}
+	var (
+		report   core.BadBlockReport
+		prestate Prestate
+	)
+	if err := readFile(filepath.Join(bundle, core.BadBlockReportFile), "bundle", &report); err != nil {
+		return err
+	}
+	if err := readFile(filepath.Join(bundle, core.BadBlockAllocFile), "alloc", &prestate.Pre); err != nil {
+		return err
+	}
+	if err := readFile(filepath.Join(bundle, core.BadBlockEnvFile), "env", &prestate.Env); err != nil {
+		return err
+	}
+	if report.ChainConfig == nil {
+		return NewError(ErrorConfig, errors.New("bundle without chain configuration"))
+	}
+	txIt, err := loadTransactions(filepath.Join(bundle, core.BadBlockTxsFile), nil, report.ChainConfig)
+	if err != nil {
+		return err
+	}
+	s, result, body, err := prestate.Apply(vm.Config{}, report.ChainConfig, txIt, blockReward(report.ChainConfig, &prestate.Env), tracerFactory(ctx, baseDir))
+	if err != nil {
+		return err
+	}
+	// Only the results independent of the state outside of the bundle can be
+	// compared, the state root covers the whole state
+	replayed := core.BadBlockResult{
+		ReceiptRoot: result.ReceiptRoot,
+		Bloom:       result.Bloom,
+		GasUsed:     hexutil.Uint64(result.GasUsed),
+	}
+	fmt.Fprintf(os.Stderr, "Block %d (%s)\nRejected with: %s\nRejected on: %s\n\n", report.Number, report.Hash.Hex(), report.Error, report.Platform)
+	fmt.Fprintf(os.Stderr, "%-14s %-68s %-68s %s\n", "", "expected", "rejecting node", "replay")
+	fmt.Fprintf(os.Stderr, "%-14s %-68s %-68s %s\n", "receiptsRoot", report.Expected.ReceiptRoot.Hex(), report.Computed.ReceiptRoot.Hex(), replayed.ReceiptRoot.Hex())
+	fmt.Fprintf(os.Stderr, "%-14s %-68d %-68d %d\n", "gasUsed", report.Expected.GasUsed, report.Computed.GasUsed, replayed.GasUsed)
+	fmt.Fprintf(os.Stderr, "%-14s %-68s %-68s %s\n", "logsBloom", "", matching(report.Computed.Bloom == report.Expected.Bloom), matching(replayed.Bloom == report.Expected.Bloom))
+	for _, rejected := range result.Rejected {
+		fmt.Fprintf(os.Stderr, "Rejected transaction %d: %s\n", rejected.Index, rejected.Err)
+	}
+	collector := make(Alloc)
+	s.DumpToCollector(collector, nil)
+	if err := dispatchOutput(ctx, baseDir, result, collector, body); err != nil {
+		return err
+	}
+	if replayed.ReceiptRoot != report.Expected.ReceiptRoot || replayed.GasUsed != report.Expected.GasUsed || replayed.Bloom != report.Expected.Bloom || len(result.Rejected) > 0 {
+		return NewError(ErrorEVM, errors.New("replayed block results mismatch"))
+	}
+	return nil
+}
+
+// matching describes whether a result matches the expected one.
+func matching(match bool) string {
+	if match {
+		return "match"
+	}
+	return "mismatch"
+}
+
+// blockReward returns the reward of the miner of the block in wei, or -1 if it
+// is not rewarded.
+func blockReward(config *params.ChainConfig, env *stEnv) int64 {
+	if config.Clique != nil || env.Difficulty == nil || env.Difficulty.Sign() == 0 {
+		return -1
+	}
+	number := new(big.Int).SetUint64(env.Number)
+	switch {
+	case config.IsConstantinople(number):
+		return int64(ethash.ConstantinopleBlockReward.Uint64())
+	case config.IsByzantium(number):
+		return int64(ethash.ByzantiumBlockReward.Uint64())
+	default:
+		return int64(ethash.FrontierBlockReward.Uint64())
+	}
+}
@@ -138,6 +138,21 @@ var (
 		Usage:    "enable return data output",
 		Category: flags.VMCategory,
 	}
+	ProfileFlag = &cli.BoolFlag{
+		Name:     "profile",
+		Usage:    "profile the gas usage by contract, function and opcode",
+		Category: flags.VMCategory,
+	}
+	ProfileFoldedFlag = &cli.StringFlag{
+		Name:     "profile.folded",
+		Usage:    "File to write the gas profile to as folded stacks, for flamegraph tools",
+		Category: flags.VMCategory,
+	}
+	ProfileSourceMapFlag = &cli.StringFlag{
+		Name:     "profile.sourcemap",
+		Usage:    "JSON file with the solc source map of the receiver code ({sourceMap, sources})",
+		Category: flags.VMCategory,
+	}
 )
 
 var stateTransitionCommand = &cli.Command{
@@ -178,6 +193,26 @@ var transactionCommand = &cli.Command{
 	},
 }
 
+var replayCommand = &cli.Command{
+	Name:      "replay",
+	Usage:     "Re-executes a bad block bundle written by geth",
+	ArgsUsage: "<bundle directory>",
+	Action:    t8ntool.Replay,
+	Flags: []cli.Flag{
+		t8ntool.TraceFlag,
+		t8ntool.TraceTracerFlag,
+		t8ntool.TraceTracerConfigFlag,
+		t8ntool.TraceEnableMemoryFlag,
+		t8ntool.TraceDisableStackFlag,
+		t8ntool.TraceEnableReturnDataFlag,
+		t8ntool.TraceEnableCallFramesFlag,
+		t8ntool.OutputBasedir,
+		t8ntool.OutputAllocFlag,
+		t8ntool.OutputResultFlag,
+		t8ntool.OutputBodyFlag,
+	},
+}
+
 var blockBuilderCommand = &cli.Command{
 	Name:    "block-builder",
 	Aliases: []string{"b11r"},
@@ -220,6 +255,9 @@ var traceFlags = []cli.Flag{
 	DisableStackFlag,
 	DisableStorageFlag,
 	DisableReturnDataFlag,
+	ProfileFlag,
+	ProfileFoldedFlag,
+	ProfileSourceMapFlag,
 }
 
 var app = flags.NewApp("the evm command line interface")
@@ -235,6 +273,7 @@ func init() {
 		stateTransitionCommand,
 		transactionCommand,
 		blockBuilderCommand,
+		replayCommand,
 	}
 	app.Before = func(ctx *cli.Context) error {
 		flags.MigrateGlobalFlags(ctx)
@@ -0,0 +1,130 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/urfave/cli/v2"
+)
+
+// profileTop is the number of entries of each kind printed in the summary.
+const profileTop = 10
+
+// newProfiler creates the gas profiler of the run command, applying the source
+// map of the receiver if one is given.
+func newProfiler(ctx *cli.Context, receiver common.Address) (*tracers.Tracer, error) {
+	if ctx.Bool(MachineFlag.Name) || ctx.Bool(DebugFlag.Name) || ctx.Bool(BenchFlag.Name) {
+		return nil, errors.New("--profile cannot be combined with --json, --debug or --bench")
+	}
+	var config native.GasProfilerConfig
+	if file := ctx.String(ProfileSourceMapFlag.Name); file != "" {
+		if ctx.Bool(CreateFlag.Name) {
+			return nil, errors.New("--profile.sourcemap applies to runtime code, not to --create")
+		}
+		m, err := readSourceMap(file)
+		if err != nil {
+			return nil, err
+		}
+		config.SourceMaps = map[common.Address]*native.SourceMap{receiver: m}
+	}
+	cfg, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return tracers.DefaultDirectory.New("gasProfiler", new(tracers.Context), cfg)
+}
+
+// readSourceMap reads a source map file. If the contents of the sources are not
+// included, they are read from the source files relative to the source map, so
+// locations can be reported as lines.
+func readSourceMap(file string) (*native.SourceMap, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read source map: %w", err)
+	}
+	var m native.SourceMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid source map: %w", err)
+	}
+	if len(m.Contents) > 0 {
+		return &m, nil
+	}
+	contents := make([]string, 0, len(m.Sources))
+	for _, source := range m.Sources {
+		path := source
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			// Fall back to byte offsets unless all of the sources are available
+			return &m, nil
+		}
+		contents = append(contents, string(content))
+	}
+	m.Contents = contents
+	return &m, nil
+}
+
+// writeProfile prints a summary of the gas profile, and writes the folded stacks
+// to a file if requested.
+func writeProfile(ctx *cli.Context, w io.Writer, profiler *tracers.Tracer) error {
+	res, err := profiler.GetResult()
+	if err != nil {
+		return err
+	}
+	var profile native.GasProfile
+	if err := json.Unmarshal(res, &profile); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "#### GAS PROFILE ####")
+	fmt.Fprintf(w, "gas used: %d (intrinsic %d, execution %d)\n", profile.GasUsed, profile.IntrinsicGas, profile.ExecutionGas)
+
+	fmt.Fprintf(w, "\n%-42s %12s %12s %8s %12s\n", "contract", "self gas", "gas", "calls", "self time")
+	for _, entry := range profile.Contracts[:min(len(profile.Contracts), profileTop)] {
+		fmt.Fprintf(w, "%-42s %12d %12d %8d %12d\n", entry.Address.Hex(), entry.SelfGas, entry.Gas, entry.Calls, entry.SelfTimeNs)
+	}
+	fmt.Fprintf(w, "\n%-42s %-12s %12s %12s %8s\n", "contract", "function", "self gas", "gas", "calls")
+	for _, entry := range profile.Functions[:min(len(profile.Functions), profileTop)] {
+		fmt.Fprintf(w, "%-42s %-12s %12d %12d %8d\n", entry.Address.Hex(), entry.Selector, entry.SelfGas, entry.Gas, entry.Calls)
+	}
+	fmt.Fprintf(w, "\n%-16s %12s %8s %12s\n", "opcode", "gas", "count", "time")
+	for _, entry := range profile.Opcodes[:min(len(profile.Opcodes), profileTop)] {
+		fmt.Fprintf(w, "%-16s %12d %8d %12d\n", entry.Op, entry.Gas, entry.Count, entry.TimeNs)
+	}
+	if len(profile.Sources) > 0 {
+		fmt.Fprintf(w, "\n%-40s %12s %8s %12s\n", "source", "gas", "count", "time")
+		for _, entry := range profile.Sources[:min(len(profile.Sources), profileTop)] {
+			fmt.Fprintf(w, "%-40s %12d %8d %12d\n", entry.Location, entry.Gas, entry.Count, entry.TimeNs)
+		}
+	}
+	if file := ctx.String(ProfileFoldedFlag.Name); file != "" {
+		if err := os.WriteFile(file, []byte(profile.Folded), 0644); err != nil {
+			return fmt.Errorf("could not write folded stacks: %w", err)
+		}
+	}
+	return nil
+}
@@ -683,3 +683,47 @@ func cmpJson(a, b []byte) (bool, error) {
 	}
 	return reflect.DeepEqual(j2, j), nil
 }
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+	tt := new(testT8n)
+	tt.TestCmd = cmdtest.NewTestCmd(t, tt)
+
+	// Replay the bundle of a block with a corrupted state root, the results
+	// must match the block
+	base := "./testdata/replay"
+	tt.Run("evm-test", "replay", "--output.result", "stdout", "--output.alloc", "stdout", "--output.body", "", base)
+	want, err := os.ReadFile(filepath.Join(base, "exp.json"))
+	if err != nil {
+		t.Fatalf("could not read expected output: %v", err)
+	}
+	have := tt.Output()
+	if ok, err := cmpJson(have, want); err != nil {
+		t.Fatalf("json parsing failed: %v", err)
+	} else if !ok {
+		t.Fatalf("output wrong, have \n%v\nwant\n%v\n", string(have), string(want))
+	}
+	tt.WaitExit()
+	if have := tt.ExitStatus(); have != 0 {
+		t.Fatalf("wrong exit code, have %d, want 0", have)
+	}
+	// Replaying a bundle claiming different results must fail
+	dir := t.TempDir()
+	for _, name := range []string{"bundle.json", "alloc.json", "env.json", "txs.rlp"} {
+		data, err := os.ReadFile(filepath.Join(base, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "bundle.json" {
+			data = []byte(strings.Replace(string(data), `"gasUsed": "0x683e"`, `"gasUsed": "0x683f"`, 1))
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tt.Run("evm-test", "replay", "--output.result", "", "--output.alloc", "", "--output.body", "", dir)
+	tt.WaitExit()
+	if have := tt.ExitStatus(); have == 0 {
+		t.Fatalf("mismatching replay succeeded")
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -283,6 +282,16 @@ var (
 		Value:    ethconfig.Defaults.TransactionHistory,
 		Category: flags.StateCategory,
 	}
+	LogNoIndexFlag = &cli.BoolFlag{
+		Name:     "history.logs.noindex",
+		Usage:    "Disable the log index, searching logs with the legacy bloombits only",
+		Category: flags.StateCategory,
+	}
+	LogNoBloomBitsFlag = &cli.BoolFlag{
+		Name:     "history.logs.nobloombits",
+		Usage:    "Drop the legacy bloombits log index once the log index covers the entire chain",
+		Category: flags.StateCategory,
+	}
 	// Beacon client light sync settings
 	BeaconApiFlag = &cli.StringSliceFlag{
 		Name:     "beacon.api",
@@ -541,12 +550,17 @@ var (
 	}
 	VMTraceFlag = &cli.StringFlag{
 		Name:     "vmtrace",
-		Usage:    "Name of tracer which should record internal VM operations (costly)",
+		Usage:    "Comma separated tracers which should record internal VM operations, as names or id=name pairs (costly)",
 		Category: flags.VMCategory,
 	}
 	VMTraceJsonConfigFlag = &cli.StringFlag{
 		Name:     "vmtrace.jsonconfig",
-		Usage:    "Tracer configuration (JSON)",
+		Usage:    "Tracer configuration (JSON), an object keyed by tracer id if several are given",
+		Category: flags.VMCategory,
+	}
+	VMTraceAttachFlag = &cli.BoolFlag{
+		Name:     "vmtrace.attach",
+		Usage:    "Allow attaching any live tracer at runtime through the admin API, including opcode level ones (costly)",
 		Category: flags.VMCategory,
 	}
 	// API options.
@@ -568,6 +582,51 @@ var (
 		Value:    ethconfig.Defaults.RPCTxFeeCap,
 		Category: flags.APICategory,
 	}
+	RPCResponseCacheFlag = &cli.IntFlag{
+		Name:     "rpc.cache",
+		Usage:    "Megabytes of memory allocated to caching RPC responses about finalized blocks (0 = disabled)",
+		Value:    ethconfig.Defaults.RPCResponseCache,
+		Category: flags.APICategory,
+	}
+	RPCResponseCacheDiskFlag = &cli.BoolFlag{
+		Name:     "rpc.cache.disk",
+		Usage:    fmt.Sprintf("Persist cached RPC responses about finalized blocks on disk, using up to %d times the --rpc.cache allowance", ethapi.ResponseCacheDiskFactor),
+		Category: flags.APICategory,
+	}
+	RPCTraceConcurrencyFlag = &cli.IntFlag{
+		Name:     "rpc.trace.concurrency",
+		Usage:    "Maximum number of blocks and transactions re-executed concurrently for tracing (0 = number of CPUs)",
+		Value:    ethconfig.Defaults.RPCTraceConcurrency,
+		Category: flags.APICategory,
+	}
+	RPCTraceQueueFlag = &cli.IntFlag{
+		Name:     "rpc.trace.queue",
+		Usage:    "Maximum number of trace requests waiting for execution, further ones are rejected",
+		Value:    ethconfig.Defaults.RPCTraceQueue,
+		Category: flags.APICategory,
+	}
+	RPCTraceCacheFlag = &cli.IntFlag{
+		Name:     "rpc.trace.cache",
+		Usage:    "Megabytes of memory allocated to caching trace results (0 = disabled)",
+		Value:    ethconfig.Defaults.RPCTraceCache,
+		Category: flags.APICategory,
+	}
+	RPCTraceStatesFlag = &cli.IntFlag{
+		Name:     "rpc.trace.states",
+		Usage:    "Number of historical states regenerated for tracing retained for later requests (hash scheme only)",
+		Value:    ethconfig.Defaults.RPCTraceStates,
+		Category: flags.APICategory,
+	}
+	RPCErrorABIsFlag = &flags.DirectoryFlag{
+		Name:     "rpc.errors.abis",
+		Usage:    "Directory of contract ABIs or compiler artifacts whose custom errors are decoded in reverts",
+		Category: flags.APICategory,
+	}
+	RPCErrorSignaturesFlag = &cli.StringFlag{
+		Name:     "rpc.errors.signatures",
+		Usage:    "File of custom error signatures decoded in reverts, one per line (e.g. InsufficientBalance(uint256,uint256))",
+		Category: flags.APICategory,
+	}
 	// Authenticated RPC HTTP settings
 	AuthListenFlag = &cli.StringFlag{
 		Name:     "authrpc.addr",
@@ -604,6 +663,11 @@ var (
 		Usage:    "Disables db compaction after import",
 		Category: flags.LoggingCategory,
 	}
+	BadBlockDirFlag = &cli.StringFlag{
+		Name:     "badblock.dir",
+		Usage:    "Directory to write bundles for re-executing bad blocks with evm replay into (disabled if empty)",
+		Category: flags.LoggingCategory,
+	}
 	CollectWitnessFlag = &cli.BoolFlag{
 		Name:     "collectwitness",
 		Usage:    "Enable state witness generation during block execution. Work in progress flag, don't use.",
@@ -687,6 +751,24 @@ var (
 		Value:    strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 		Category: flags.APICategory,
 	}
+	GraphQLMaxDepthFlag = &cli.IntFlag{
+		Name:     "graphql.maxdepth",
+		Usage:    "Maximum nesting depth of GraphQL queries (0 = no limit)",
+		Value:    node.DefaultConfig.GraphQLMaxDepth,
+		Category: flags.APICategory,
+	}
+	GraphQLMaxCostFlag = &cli.Uint64Flag{
+		Name:     "graphql.maxcost",
+		Usage:    "Maximum estimated cost of GraphQL queries (0 = no limit)",
+		Value:    node.DefaultConfig.GraphQLMaxCost,
+		Category: flags.APICategory,
+	}
+	GraphQLTimeoutFlag = &cli.DurationFlag{
+		Name:     "graphql.timeout",
+		Usage:    "Maximum execution time of GraphQL queries (0 = HTTP write timeout)",
+		Value:    node.DefaultConfig.GraphQLTimeout,
+		Category: flags.APICategory,
+	}
 	WSEnabledFlag = &cli.BoolFlag{
 		Name:     "ws",
 		Usage:    "Enable the WS-RPC server",
@@ -749,6 +831,12 @@ var (
 		Value:    node.DefaultConfig.BatchResponseMaxSize,
 		Category: flags.APICategory,
 	}
+	StreamResponseMaxSize = &cli.IntFlag{
+		Name:     "rpc.stream-response-max-size",
+		Usage:    "Maximum number of bytes returned from a single streamed call (0 = no limit)",
+		Value:    node.DefaultConfig.StreamResponseMaxSize,
+		Category: flags.APICategory,
+	}
 	EnablePersonal = &cli.BoolFlag{
 		Name:     "rpc.enabledeprecatedpersonal",
 		Usage:    "Enables the (deprecated) personal namespace",
@@ -870,6 +958,12 @@ var (
 		Value:    ethconfig.Defaults.GPO.IgnorePrice.Int64(),
 		Category: flags.GasPriceCategory,
 	}
+	GpoBlobBlocksFlag = &cli.IntFlag{
+		Name:     "gpo.blobblocks",
+		Usage:    "Number of recent blocks to derive the trend of blob fees from",
+		Value:    ethconfig.Defaults.GPO.BlobBlocks,
+		Category: flags.GasPriceCategory,
+	}
 
 	// Metrics flags
 	MetricsEnabledFlag = &cli.BoolFlag{
@@ -1190,6 +1284,10 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(BatchResponseMaxSize.Name) {
 		cfg.BatchResponseMaxSize = ctx.Int(BatchResponseMaxSize.Name)
 	}
+
+	if ctx.IsSet(StreamResponseMaxSize.Name) {
+		cfg.StreamResponseMaxSize = ctx.Int(StreamResponseMaxSize.Name)
+	}
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -1201,6 +1299,15 @@ func setGraphQL(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(GraphQLVirtualHostsFlag.Name) {
 		cfg.GraphQLVirtualHosts = SplitAndTrim(ctx.String(GraphQLVirtualHostsFlag.Name))
 	}
+	if ctx.IsSet(GraphQLMaxDepthFlag.Name) {
+		cfg.GraphQLMaxDepth = ctx.Int(GraphQLMaxDepthFlag.Name)
+	}
+	if ctx.IsSet(GraphQLMaxCostFlag.Name) {
+		cfg.GraphQLMaxCost = ctx.Uint64(GraphQLMaxCostFlag.Name)
+	}
+	if ctx.IsSet(GraphQLTimeoutFlag.Name) {
+		cfg.GraphQLTimeout = ctx.Duration(GraphQLTimeoutFlag.Name)
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -1505,6 +1612,9 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
 	if ctx.IsSet(GpoIgnoreGasPriceFlag.Name) {
 		cfg.IgnorePrice = big.NewInt(ctx.Int64(GpoIgnoreGasPriceFlag.Name))
 	}
+	if ctx.IsSet(GpoBlobBlocksFlag.Name) {
+		cfg.BlobBlocks = ctx.Int(GpoBlobBlocksFlag.Name)
+	}
 }
 
 func setTxPool(ctx *cli.Context, cfg *legacypool.Config) {
@@ -1724,6 +1834,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 		log.Warn("The flag --txlookuplimit is deprecated and will be removed, please use --history.transactions")
 		cfg.TransactionHistory = ctx.Uint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.IsSet(LogNoIndexFlag.Name) {
+		cfg.LogNoIndex = ctx.Bool(LogNoIndexFlag.Name)
+	}
+	if ctx.IsSet(LogNoBloomBitsFlag.Name) {
+		cfg.LogNoBloomBits = ctx.Bool(LogNoBloomBitsFlag.Name)
+	}
 	if ctx.String(GCModeFlag.Name) == "archive" && cfg.TransactionHistory != 0 {
 		cfg.TransactionHistory = 0
 		log.Warn("Disabled transaction unindexing for archive node")
@@ -1783,6 +1899,30 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.Float64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.IsSet(RPCResponseCacheFlag.Name) {
+		cfg.RPCResponseCache = ctx.Int(RPCResponseCacheFlag.Name)
+	}
+	if ctx.IsSet(RPCResponseCacheDiskFlag.Name) {
+		cfg.RPCResponseCacheDisk = ctx.Bool(RPCResponseCacheDiskFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceConcurrencyFlag.Name) {
+		cfg.RPCTraceConcurrency = ctx.Int(RPCTraceConcurrencyFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceQueueFlag.Name) {
+		cfg.RPCTraceQueue = ctx.Int(RPCTraceQueueFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceCacheFlag.Name) {
+		cfg.RPCTraceCache = ctx.Int(RPCTraceCacheFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceStatesFlag.Name) {
+		cfg.RPCTraceStates = ctx.Int(RPCTraceStatesFlag.Name)
+	}
+	if ctx.IsSet(RPCErrorABIsFlag.Name) {
+		cfg.RPCErrorABIs = ctx.String(RPCErrorABIsFlag.Name)
+	}
+	if ctx.IsSet(RPCErrorSignaturesFlag.Name) {
+		cfg.RPCErrorSignatures = ctx.String(RPCErrorSignaturesFlag.Name)
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.IsSet(DNSDiscoveryFlag.Name) {
@@ -1921,6 +2061,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 			cfg.VMTraceJsonConfig = config
 		}
 	}
+	if ctx.IsSet(VMTraceAttachFlag.Name) {
+		cfg.VMTraceAttach = ctx.Bool(VMTraceAttachFlag.Name)
+	}
+	if ctx.IsSet(BadBlockDirFlag.Name) {
+		cfg.BadBlockDir = ctx.String(BadBlockDirFlag.Name)
+	}
 }
 
 // SetDNSDiscoveryDefaults configures DNS discovery with the given URL if
@@ -1956,7 +2102,14 @@ func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url strin
 
 // RegisterGraphQLService adds the GraphQL API to the node.
 func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cfg *node.Config) {
-	err := graphql.New(stack, backend, filterSystem, cfg.GraphQLCors, cfg.GraphQLVirtualHosts)
+	err := graphql.NewWithConfig(stack, backend, filterSystem, &graphql.Config{
+		Cors:         cfg.GraphQLCors,
+		VirtualHosts: cfg.GraphQLVirtualHosts,
+		MaxDepth:     cfg.GraphQLMaxDepth,
+		MaxCost:      cfg.GraphQLMaxCost,
+		Timeout:      cfg.GraphQLTimeout,
+		FieldCosts:   cfg.GraphQLFieldCosts,
+	})
 	if err != nil {
 		Fatalf("Failed to register the GraphQL service: %v", err)
 	}
@@ -1965,11 +2118,12 @@ func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, filterSyst
 // RegisterFilterAPI adds the eth log filtering RPC API to the node.
 func RegisterFilterAPI(stack *node.Node, backend ethapi.Backend, ethcfg *ethconfig.Config) *filters.FilterSystem {
 	filterSystem := filters.NewFilterSystem(backend, filters.Config{
-		LogCacheSize: ethcfg.FilterLogCacheSize,
+		LogCacheSize:  ethcfg.FilterLogCacheSize,
+		ResponseCache: backend.ResponseCache(),
 	})
 	stack.RegisterAPIs([]rpc.API{{
 		Namespace: "eth",
-		Service:   filters.NewFilterAPI(filterSystem),
+		Service:   filters.NewFilterService(filterSystem),
 	}})
 	return filterSystem
 }
@@ -2179,6 +2333,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 		Preimages:           ctx.Bool(CachePreimagesFlag.Name),
 		StateScheme:         scheme,
 		StateHistory:        ctx.Uint64(StateHistoryFlag.Name),
+		BadBlockDir:         ctx.String(BadBlockDirFlag.Name),
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
@@ -2204,15 +2359,15 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 	}
 	if ctx.IsSet(VMTraceFlag.Name) {
 		if name := ctx.String(VMTraceFlag.Name); name != "" {
-			var config json.RawMessage
+			var config string
 			if ctx.IsSet(VMTraceJsonConfigFlag.Name) {
-				config = json.RawMessage(ctx.String(VMTraceJsonConfigFlag.Name))
+				config = ctx.String(VMTraceJsonConfigFlag.Name)
 			}
-			t, err := tracers.LiveDirectory.New(name, config)
-			if err != nil {
-				Fatalf("Failed to create tracer %q: %v", name, err)
+			mux := tracers.NewLiveMux(false)
+			if err := mux.AttachConfigured(name, config); err != nil {
+				Fatalf("Failed to create tracers: %v", err)
 			}
-			vmcfg.Tracer = t
+			vmcfg.Tracer = mux.Hooks()
 		}
 	}
 	// Disable transaction indexing/unindexing by default.
@@ -0,0 +1,479 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Files of a bad block bundle. The alloc, env and transactions are in the input
+// format of evm t8n.
+const (
+	BadBlockReportFile = "bundle.json"
+	BadBlockAllocFile  = "alloc.json"
+	BadBlockEnvFile    = "env.json"
+	BadBlockTxsFile    = "txs.rlp"
+)
+
+const (
+	badBlockQueue   = 16 // Maximum number of bad blocks waiting for their bundle
+	badBlockBundles = 16 // Maximum number of bundles kept in the bundle directory
+)
+
+// BadBlockReport is the manifest of a bad block bundle, describing the block, the
+// chain it was executed on and the results of the execution.
+type BadBlockReport struct {
+	Number      uint64              `json:"number"`
+	Hash        common.Hash         `json:"hash"`
+	Error       string              `json:"error"`
+	Platform    string              `json:"platform"`
+	ChainConfig *params.ChainConfig `json:"chainConfig"`
+
+	Expected BadBlockResult `json:"expected"` // Results claimed by the block header
+	Computed BadBlockResult `json:"computed"` // Results of the local execution
+	Receipts types.Receipts `json:"receipts"` // Receipts of the local execution
+}
+
+// BadBlockResult is the outcome of the execution of a block.
+type BadBlockResult struct {
+	StateRoot   common.Hash    `json:"stateRoot"`
+	ReceiptRoot common.Hash    `json:"receiptsRoot"`
+	Bloom       types.Bloom    `json:"logsBloom"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+}
+
+// badBlockEnv is the block environment of a bad block bundle, in the format of
+// evm t8n.
+type badBlockEnv struct {
+	Coinbase              common.Address         `json:"currentCoinbase"`
+	Difficulty            *hexutil.Big           `json:"currentDifficulty"`
+	Random                *hexutil.Big           `json:"currentRandom,omitempty"`
+	ParentDifficulty      *hexutil.Big           `json:"parentDifficulty"`
+	ParentBaseFee         *hexutil.Big           `json:"parentBaseFee,omitempty"`
+	ParentGasUsed         hexutil.Uint64         `json:"parentGasUsed"`
+	ParentGasLimit        hexutil.Uint64         `json:"parentGasLimit"`
+	GasLimit              hexutil.Uint64         `json:"currentGasLimit"`
+	Number                hexutil.Uint64         `json:"currentNumber"`
+	Timestamp             hexutil.Uint64         `json:"currentTimestamp"`
+	ParentTimestamp       hexutil.Uint64         `json:"parentTimestamp"`
+	BlockHashes           map[string]common.Hash `json:"blockHashes"`
+	Ommers                []badBlockOmmer        `json:"ommers,omitempty"`
+	Withdrawals           []*types.Withdrawal    `json:"withdrawals,omitempty"`
+	BaseFee               *hexutil.Big           `json:"currentBaseFee,omitempty"`
+	ParentUncleHash       common.Hash            `json:"parentUncleHash"`
+	ExcessBlobGas         *hexutil.Uint64        `json:"currentExcessBlobGas,omitempty"`
+	ParentExcessBlobGas   *hexutil.Uint64        `json:"parentExcessBlobGas,omitempty"`
+	ParentBlobGasUsed     *hexutil.Uint64        `json:"parentBlobGasUsed,omitempty"`
+	ParentBeaconBlockRoot *common.Hash           `json:"parentBeaconBlockRoot,omitempty"`
+}
+
+type badBlockOmmer struct {
+	Delta   uint64         `json:"delta"`
+	Address common.Address `json:"address"`
+}
+
+// badBlockJob is a bad block waiting for its bundle to be written.
+type badBlockJob struct {
+	block  *types.Block
+	reason error
+}
+
+// queueBadBlockBundle schedules writing the bundle of a bad block. Bad blocks
+// are dropped if too many are already waiting, as re-executing them is costly.
+func (bc *BlockChain) queueBadBlockBundle(block *types.Block, reason error) {
+	select {
+	case bc.badBlocks <- badBlockJob{block: block, reason: reason}:
+	default:
+		log.Warn("Too many bad blocks pending, skipping bundle", "number", block.Number(), "hash", block.Hash())
+	}
+}
+
+// badBlockLoop writes the bundles of bad blocks in the background, keeping the
+// most recent ones in the bundle directory.
+func (bc *BlockChain) badBlockLoop() {
+	defer bc.wg.Done()
+
+	for {
+		select {
+		case job := <-bc.badBlocks:
+			dir, err := bc.writeBadBlockBundle(job.block, job.reason)
+			if err != nil {
+				log.Warn("Failed to write bad block bundle", "number", job.block.Number(), "hash", job.block.Hash(), "err", err)
+				continue
+			}
+			log.Error("Wrote bad block bundle", "number", job.block.Number(), "hash", job.block.Hash(), "dir", dir)
+			if err := pruneBadBlockBundles(bc.cacheConfig.BadBlockDir, badBlockBundles); err != nil {
+				log.Warn("Failed to prune bad block bundles", "err", err)
+			}
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// pruneBadBlockBundles deletes the oldest bundles of a bundle directory, keeping
+// the given number of them.
+func pruneBadBlockBundles(root string, keep int) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	type bundle struct {
+		path    string
+		written time.Time
+	}
+	var bundles []bundle
+	for _, entry := range entries {
+		// Only touch the directories named like bundles
+		if !entry.IsDir() || !strings.Contains(entry.Name(), "_0x") || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Deleted meanwhile
+		}
+		bundles = append(bundles, bundle{path: filepath.Join(root, entry.Name()), written: info.ModTime()})
+	}
+	if len(bundles) <= keep {
+		return nil
+	}
+	slices.SortFunc(bundles, func(a, b bundle) int { return a.written.Compare(b.written) })
+	for _, b := range bundles[:len(bundles)-keep] {
+		if err := os.RemoveAll(b.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBadBlockBundle re-executes a bad block on top of its parent state and
+// writes everything needed to reproduce the execution elsewhere into a new
+// directory of the configured bundle directory, returning its path.
+func (bc *BlockChain) writeBadBlockBundle(block *types.Block, reason error) (string, error) {
+	dir := filepath.Join(bc.cacheConfig.BadBlockDir, fmt.Sprintf("%d_%s", block.NumberU64(), block.Hash().Hex()))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil // Bundle written on an earlier import of the block
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return "", errors.New("unknown parent")
+	}
+	if !bc.HasState(parent.Root) {
+		return "", errors.New("missing parent state")
+	}
+	// Execute the block without snapshot so that all reads go through the
+	// recording tries
+	recorder := newPrestateRecorder(bc.stateCache)
+	statedb, err := state.New(parent.Root, recorder, nil)
+	if err != nil {
+		return "", err
+	}
+	vmConfig := bc.vmConfig
+	vmConfig.Tracer = nil
+	receipts, _, usedGas, _ := bc.processor.Process(block, statedb, vmConfig)
+
+	report := &BadBlockReport{
+		Number:      block.NumberU64(),
+		Hash:        block.Hash(),
+		Error:       reason.Error(),
+		Platform:    badBlockPlatform(),
+		ChainConfig: bc.chainConfig,
+		Expected: BadBlockResult{
+			StateRoot:   block.Root(),
+			ReceiptRoot: block.ReceiptHash(),
+			Bloom:       block.Bloom(),
+			GasUsed:     hexutil.Uint64(block.GasUsed()),
+		},
+		Computed: BadBlockResult{
+			StateRoot:   statedb.IntermediateRoot(bc.chainConfig.IsEIP158(block.Number())),
+			ReceiptRoot: types.DeriveSha(receipts, trie.NewStackTrie(nil)),
+			Bloom:       types.CreateBloom(receipts),
+			GasUsed:     hexutil.Uint64(usedGas),
+		},
+		Receipts: receipts,
+	}
+	alloc, err := recorder.alloc()
+	if err != nil {
+		return "", err
+	}
+	txs, err := rlp.EncodeToBytes(block.Transactions())
+	if err != nil {
+		return "", err
+	}
+	// Assemble the bundle in a temporary directory, so that only complete bundles
+	// show up in the bundle directory
+	tmp := dir + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string]interface{}{
+		BadBlockReportFile: report,
+		BadBlockAllocFile:  alloc,
+		BadBlockEnvFile:    bc.badBlockEnv(block, parent),
+		BadBlockTxsFile:    hexutil.Bytes(txs),
+	}
+	for name, content := range files {
+		data, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(tmp, name), data, 0644); err != nil {
+			return "", err
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// badBlockEnv assembles the execution environment of a block, including the
+// hashes of the ancestors accessible through BLOCKHASH.
+func (bc *BlockChain) badBlockEnv(block *types.Block, parent *types.Header) *badBlockEnv {
+	header := block.Header()
+	env := &badBlockEnv{
+		Coinbase:              header.Coinbase,
+		Difficulty:            (*hexutil.Big)(header.Difficulty),
+		ParentDifficulty:      (*hexutil.Big)(parent.Difficulty),
+		ParentBaseFee:         (*hexutil.Big)(parent.BaseFee),
+		ParentGasUsed:         hexutil.Uint64(parent.GasUsed),
+		ParentGasLimit:        hexutil.Uint64(parent.GasLimit),
+		GasLimit:              hexutil.Uint64(header.GasLimit),
+		Number:                hexutil.Uint64(header.Number.Uint64()),
+		Timestamp:             hexutil.Uint64(header.Time),
+		ParentTimestamp:       hexutil.Uint64(parent.Time),
+		BlockHashes:           make(map[string]common.Hash),
+		Withdrawals:           block.Withdrawals(),
+		BaseFee:               (*hexutil.Big)(header.BaseFee),
+		ParentUncleHash:       parent.UncleHash,
+		ExcessBlobGas:         (*hexutil.Uint64)(header.ExcessBlobGas),
+		ParentExcessBlobGas:   (*hexutil.Uint64)(parent.ExcessBlobGas),
+		ParentBlobGasUsed:     (*hexutil.Uint64)(parent.BlobGasUsed),
+		ParentBeaconBlockRoot: header.ParentBeaconRoot,
+	}
+	if header.Difficulty.Sign() == 0 {
+		env.Random = (*hexutil.Big)(new(big.Int).SetBytes(header.MixDigest[:]))
+	}
+	for _, uncle := range block.Uncles() {
+		env.Ommers = append(env.Ommers, badBlockOmmer{
+			Delta:   header.Number.Uint64() - uncle.Number.Uint64(),
+			Address: uncle.Coinbase,
+		})
+	}
+	for ancestor := parent; ancestor != nil && len(env.BlockHashes) < 256; {
+		env.BlockHashes[strconv.FormatUint(ancestor.Number.Uint64(), 10)] = ancestor.Hash()
+		if ancestor.Number.Sign() == 0 {
+			break
+		}
+		ancestor = bc.GetHeader(ancestor.ParentHash, ancestor.Number.Uint64()-1)
+	}
+	return env
+}
+
+// prestateRecorder is a state database recording the state read through its
+// tries before being modified, i.e. the part of the pre-state accessed during
+// the execution of a block. The storage tries of different accounts are hashed
+// concurrently, so the records are protected by a lock.
+type prestateRecorder struct {
+	state.Database
+
+	lock     sync.Mutex
+	accounts map[common.Address]*types.StateAccount // Read accounts, nil if non-existent
+	storage  map[common.Address]map[common.Hash]common.Hash
+	written  map[common.Address]bool
+	modified map[common.Address]map[common.Hash]bool
+}
+
+func newPrestateRecorder(db state.Database) *prestateRecorder {
+	return &prestateRecorder{
+		Database: db,
+		accounts: make(map[common.Address]*types.StateAccount),
+		storage:  make(map[common.Address]map[common.Hash]common.Hash),
+		written:  make(map[common.Address]bool),
+		modified: make(map[common.Address]map[common.Hash]bool),
+	}
+}
+
+// OpenTrie opens the main account trie, recording the accounts read.
+func (db *prestateRecorder) OpenTrie(root common.Hash) (state.Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingTrie{Trie: tr, db: db}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account, recording the slots read.
+func (db *prestateRecorder) OpenStorageTrie(stateRoot common.Hash, address common.Address, root common.Hash, tr state.Trie) (state.Trie, error) {
+	if rec, ok := tr.(*recordingTrie); ok {
+		tr = rec.Trie
+	}
+	st, err := db.Database.OpenStorageTrie(stateRoot, address, root, tr)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingTrie{Trie: st, db: db}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *prestateRecorder) CopyTrie(tr state.Trie) state.Trie {
+	if rec, ok := tr.(*recordingTrie); ok {
+		return &recordingTrie{Trie: db.Database.CopyTrie(rec.Trie), db: db}
+	}
+	return db.Database.CopyTrie(tr)
+}
+
+// alloc returns the recorded pre-state in genesis format.
+func (db *prestateRecorder) alloc() (types.GenesisAlloc, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	alloc := make(types.GenesisAlloc)
+	for addr, acc := range db.accounts {
+		if acc == nil {
+			continue
+		}
+		account := types.Account{
+			Balance: acc.Balance.ToBig(),
+			Nonce:   acc.Nonce,
+		}
+		if codeHash := common.BytesToHash(acc.CodeHash); codeHash != types.EmptyCodeHash {
+			code, err := db.ContractCode(addr, codeHash)
+			if err != nil {
+				return nil, err
+			}
+			account.Code = code
+		}
+		for slot, value := range db.storage[addr] {
+			if value == (common.Hash{}) {
+				continue
+			}
+			if account.Storage == nil {
+				account.Storage = make(map[common.Hash]common.Hash)
+			}
+			account.Storage[slot] = value
+		}
+		alloc[addr] = account
+	}
+	return alloc, nil
+}
+
+// recordingTrie is a trie reporting the first reads of the original values to a
+// prestateRecorder.
+type recordingTrie struct {
+	state.Trie
+	db *prestateRecorder
+}
+
+func (t *recordingTrie) GetAccount(address common.Address) (*types.StateAccount, error) {
+	acc, err := t.Trie.GetAccount(address)
+	if err != nil {
+		return nil, err
+	}
+	t.db.lock.Lock()
+	defer t.db.lock.Unlock()
+
+	if _, ok := t.db.accounts[address]; !ok && !t.db.written[address] {
+		if acc != nil {
+			acc = acc.Copy()
+		}
+		t.db.accounts[address] = acc
+	}
+	return acc, nil
+}
+
+func (t *recordingTrie) GetStorage(addr common.Address, key []byte) ([]byte, error) {
+	value, err := t.Trie.GetStorage(addr, key)
+	if err != nil {
+		return nil, err
+	}
+	t.db.lock.Lock()
+	defer t.db.lock.Unlock()
+
+	slot := common.BytesToHash(key)
+	if t.db.modified[addr][slot] {
+		return value, nil
+	}
+	if t.db.storage[addr] == nil {
+		t.db.storage[addr] = make(map[common.Hash]common.Hash)
+	}
+	if _, ok := t.db.storage[addr][slot]; !ok {
+		t.db.storage[addr][slot] = common.BytesToHash(value)
+	}
+	return value, nil
+}
+
+func (t *recordingTrie) UpdateAccount(address common.Address, account *types.StateAccount) error {
+	t.db.write(address)
+	return t.Trie.UpdateAccount(address, account)
+}
+
+func (t *recordingTrie) DeleteAccount(address common.Address) error {
+	t.db.write(address)
+	return t.Trie.DeleteAccount(address)
+}
+
+func (t *recordingTrie) UpdateStorage(addr common.Address, key, value []byte) error {
+	t.db.modify(addr, common.BytesToHash(key))
+	return t.Trie.UpdateStorage(addr, key, value)
+}
+
+func (t *recordingTrie) DeleteStorage(addr common.Address, key []byte) error {
+	t.db.modify(addr, common.BytesToHash(key))
+	return t.Trie.DeleteStorage(addr, key)
+}
+
+func (db *prestateRecorder) write(addr common.Address) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.written[addr] = true
+}
+
+func (db *prestateRecorder) modify(addr common.Address, slot common.Hash) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.modified[addr] == nil {
+		db.modified[addr] = make(map[common.Hash]bool)
+	}
+	db.modified[addr][slot] = true
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestBadBlockBundle(t *testing.T) {
+	testBadBlockBundle(t, rawdb.HashScheme)
+	testBadBlockBundle(t, rawdb.PathScheme)
+}
+
+func testBadBlockBundle(t *testing.T, scheme string) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		other    = common.HexToAddress("0xc0df")
+		funds    = big.NewInt(params.Ether)
+	)
+	// The contract increments slot 0 and logs the hash of the previous block
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.ADD), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH1), 1, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.LOG0), byte(vm.STOP),
+	}
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			sender:   {Balance: funds},
+			contract: {Balance: common.Big0, Code: code, Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0x05")}},
+			other:    {Balance: common.Big0, Code: code, Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0x07")}},
+		},
+	}
+	signer := types.LatestSigner(gspec.Config)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 2, func(i int, b *BlockGen) {
+		// Change the storage of two contracts, whose storage tries are hashed
+		// concurrently
+		if i == 1 {
+			for nonce, to := range []common.Address{contract, other} {
+				to := to
+				b.AddTx(types.MustSignNewTx(key, signer, &types.LegacyTx{
+					Nonce:    uint64(nonce),
+					To:       &to,
+					Gas:      100000,
+					GasPrice: b.header.BaseFee,
+				}))
+			}
+		}
+	})
+	// Corrupt the state root of the second block
+	header := blocks[1].Header()
+	header.Root = common.Hash{0x01}
+	bad := blocks[1].WithSeal(header)
+
+	cacheConfig := DefaultCacheConfigWithScheme(scheme)
+	cacheConfig.BadBlockDir = t.TempDir()
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), cacheConfig, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:1]); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	if _, err := chain.InsertChain(types.Blocks{bad}); err == nil {
+		t.Fatalf("bad block accepted")
+	}
+	// The bundle is written in the background
+	dir := filepath.Join(cacheConfig.BadBlockDir, fmt.Sprintf("%d_%s", bad.NumberU64(), bad.Hash().Hex()))
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("bundle not written")
+		}
+	}
+	read := func(name string, v interface{}) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read bundle file: %v", err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("failed to decode %s: %v", name, err)
+		}
+	}
+	var report BadBlockReport
+	read(BadBlockReportFile, &report)
+	if report.Number != 2 || report.Hash != bad.Hash() || report.ChainConfig == nil || report.Error == "" {
+		t.Errorf("report mismatch: %+v", report)
+	}
+	if report.Expected.StateRoot != header.Root || report.Computed.StateRoot != blocks[1].Root() {
+		t.Errorf("state roots mismatch: expected %x, computed %x", report.Expected.StateRoot, report.Computed.StateRoot)
+	}
+	if report.Computed.ReceiptRoot != bad.ReceiptHash() || uint64(report.Computed.GasUsed) != bad.GasUsed() || len(report.Receipts) != 2 {
+		t.Errorf("computed results mismatch: %+v", report.Computed)
+	}
+	// The pre-state holds the accessed accounts and slots as of the parent
+	var alloc types.GenesisAlloc
+	read(BadBlockAllocFile, &alloc)
+	if acc, ok := alloc[sender]; !ok || acc.Balance.Cmp(funds) != 0 || acc.Nonce != 0 {
+		t.Errorf("sender pre-state mismatch: %+v", acc)
+	}
+	if acc, ok := alloc[contract]; !ok || string(acc.Code) != string(code) || acc.Storage[common.Hash{}] != common.HexToHash("0x05") {
+		t.Errorf("contract pre-state mismatch: %+v", acc)
+	}
+	if acc, ok := alloc[other]; !ok || string(acc.Code) != string(code) || acc.Storage[common.Hash{}] != common.HexToHash("0x07") {
+		t.Errorf("other contract pre-state mismatch: %+v", acc)
+	}
+	var env struct {
+		Number      hexutil.Uint64         `json:"currentNumber"`
+		BlockHashes map[string]common.Hash `json:"blockHashes"`
+	}
+	read(BadBlockEnvFile, &env)
+	if env.Number != 2 || len(env.BlockHashes) != 2 || env.BlockHashes["1"] != blocks[0].Hash() {
+		t.Errorf("env mismatch: %+v", env)
+	}
+	var enc hexutil.Bytes
+	read(BadBlockTxsFile, &enc)
+	var txs types.Transactions
+	if err := rlp.DecodeBytes(enc, &txs); err != nil || len(txs) != 2 || txs[1].Hash() != bad.Transactions()[1].Hash() {
+		t.Errorf("transactions mismatch: %v %v", txs, err)
+	}
+}
+
+func TestPruneBadBlockBundles(t *testing.T) {
+	root := t.TempDir()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("%d_%s", i, common.Hash{byte(i)}.Hex()))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		written := start.Add(time.Duration(i) * time.Second)
+		if err := os.Chtimes(dir, written, written); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Unrelated files are left alone
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneBadBlockBundles(root, 2); err != nil {
+		t.Fatalf("failed to prune bundles: %v", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{
+		fmt.Sprintf("3_%s", common.Hash{3}.Hex()),
+		fmt.Sprintf("4_%s", common.Hash{4}.Hex()),
+		"notes.txt",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("remaining entries mismatch: have %v, want %v", names, want)
+	}
+}
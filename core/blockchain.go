@@ -140,6 +140,7 @@ type CacheConfig struct {
 	Preimages           bool          // Whether to store preimage of trie key to the disk
 	StateHistory        uint64        // Number of blocks from head whose state histories are reserved.
 	StateScheme         string        // Scheme used to store ethereum states and merkle tree nodes on top
+	BadBlockDir         string        // Directory to write re-execution bundles of bad blocks into, disabled if empty
 
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
@@ -259,6 +260,8 @@ type BlockChain struct {
 	forker     *ForkChoice
 	vmConfig   vm.Config
 	logger     *tracing.Hooks
+
+	badBlocks chan badBlockJob // Bad blocks waiting for their bundle, nil if disabled
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -464,6 +467,12 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	if txLookupLimit != nil {
 		bc.txIndexer = newTxIndexer(*txLookupLimit, bc)
 	}
+	// Start writing the bundles of bad blocks if enabled.
+	if bc.cacheConfig.BadBlockDir != "" {
+		bc.badBlocks = make(chan badBlockJob, badBlockQueue)
+		bc.wg.Add(1)
+		go bc.badBlockLoop()
+	}
 	return bc, nil
 }
 
@@ -2480,10 +2489,15 @@ func (bc *BlockChain) skipBlock(err error, it *insertIterator) bool {
 	return false
 }
 
-// reportBlock logs a bad block error.
+// reportBlock logs a bad block error, and schedules writing a bundle for
+// reproducing it if enabled.
 func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
 	rawdb.WriteBadBlock(bc.db, block)
 	log.Error(summarizeBadBlock(block, receipts, bc.Config(), err))
+
+	if bc.badBlocks != nil {
+		bc.queueBadBlockBundle(block, err)
+	}
 }
 
 // summarizeBadBlock returns a string summarizing the bad block and other
@@ -2495,10 +2509,9 @@ func summarizeBadBlock(block *types.Block, receipts []*types.Receipt, config *pa
 			i, receipt.CumulativeGasUsed, receipt.GasUsed, receipt.ContractAddress.Hex(),
 			receipt.Status, receipt.TxHash.Hex(), receipt.Logs, receipt.Bloom, receipt.PostState)
 	}
-	version, vcs := version.Info()
-	platform := fmt.Sprintf("%s %s %s %s", version, runtime.Version(), runtime.GOARCH, runtime.GOOS)
-	if vcs != "" {
-		vcs = fmt.Sprintf("\nVCS: %s", vcs)
+	platform, vcs := badBlockPlatform(), ""
+	if _, info := version.Info(); info != "" {
+		vcs = fmt.Sprintf("\nVCS: %s", info)
 	}
 	return fmt.Sprintf(`
 ########## BAD BLOCK #########
@@ -2511,6 +2524,13 @@ Receipts: %v
 `, block.Number(), block.Hash(), err, platform, vcs, config, receiptString)
 }
 
+// badBlockPlatform returns the version and platform of the node, as reported
+// along with bad blocks.
+func badBlockPlatform() string {
+	version, _ := version.Info()
+	return fmt.Sprintf("%s %s %s %s", version, runtime.Version(), runtime.GOARCH, runtime.GOOS)
+}
+
 // InsertHeaderChain attempts to insert the given header chain in to the local
 // chain, possibly creating a reorg. If an error is returned, it will return the
 // index number of the failing header as well an error describing what went wrong.
@@ -0,0 +1,359 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package logindex implements a compact index of the log values (addresses and
+// topics) emitted by the canonical chain.
+//
+// The chain is split into epochs of a fixed number of blocks. For every address
+// and topic value appearing in the logs of an epoch, the index stores a row
+// listing the blocks of the epoch containing the value. Completed epochs are
+// sealed into the database, while the rows of the epoch at the chain head are
+// kept in memory and rebuilt from the receipts on startup.
+package logindex
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	retryDelayMin = time.Second     // Delay before retrying the first failed update
+	retryDelayMax = 5 * time.Minute // Maximum delay between retries of failed updates
+)
+
+// errClosed is returned if an index update is interrupted by closing the indexer.
+var errClosed = errors.New("log indexer closed")
+
+// Chain is the blockchain the log index is maintained for.
+type Chain interface {
+	// CurrentBlock retrieves the head of the canonical chain.
+	CurrentBlock() *types.Header
+
+	// SubscribeChainHeadEvent subscribes to updates of the chain head.
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// Indexer maintains the log index of the canonical chain, following its head
+// through imports and reorganisations.
+type Indexer struct {
+	db        ethdb.Database
+	epochSize uint64
+
+	updateLock sync.Mutex // Serialises index updates
+
+	lock       sync.RWMutex
+	sealed     uint64              // Number of epochs sealed in the database
+	sealedHead common.Hash         // Hash of the last block of the last sealed epoch
+	rows       map[uint64][]uint16 // Block offsets of the log values within the head epoch
+	hashes     []common.Hash       // Hashes of the indexed blocks of the head epoch
+
+	synced     chan struct{} // Closed once the index caught up with the chain head
+	syncedOnce sync.Once
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewIndexer creates a log indexer on top of the given database, loading the
+// sealed epochs of a previous run. The epoch size may not exceed 65536 blocks.
+func NewIndexer(db ethdb.Database, epochSize uint64) *Indexer {
+	if epochSize == 0 || epochSize > 1<<16 {
+		panic(fmt.Sprintf("invalid log index epoch size %d", epochSize))
+	}
+	idx := &Indexer{
+		db:        db,
+		epochSize: epochSize,
+		sealed:    rawdb.ReadLogIndexSealed(db),
+		rows:      make(map[uint64][]uint16),
+		synced:    make(chan struct{}),
+		quit:      make(chan struct{}),
+	}
+	if idx.sealed > 0 {
+		idx.sealedHead = rawdb.ReadLogIndexEpochHead(db, idx.sealed-1)
+	}
+	return idx
+}
+
+// Start launches the background goroutine keeping the index in line with the
+// head of the given chain.
+func (idx *Indexer) Start(chain Chain) {
+	idx.wg.Add(1)
+	go idx.loop(chain)
+}
+
+// Close stops the background indexing, waiting for it to terminate.
+func (idx *Indexer) Close() {
+	close(idx.quit)
+	idx.wg.Wait()
+}
+
+// Indexed returns the number of blocks covered by the index, which is also the
+// number of the first block not indexed yet.
+func (idx *Indexer) Indexed() uint64 {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	return idx.sealed*idx.epochSize + uint64(len(idx.hashes))
+}
+
+// Synced returns a channel which is closed once the index first caught up with
+// the head of the chain.
+func (idx *Indexer) Synced() <-chan struct{} {
+	return idx.synced
+}
+
+// loop keeps the index updated whenever the chain head changes.
+func (idx *Indexer) loop(chain Chain) {
+	defer idx.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, 10)
+	sub := chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	// Process the current head, then follow its updates. Updating always indexes
+	// up to the latest head, so queued events can be skipped. Failed updates are
+	// retried with growing delays, as the chain data missing for them (e.g. the
+	// receipts during snap sync) usually takes a while to arrive.
+	var (
+		delay time.Duration    // Delay of the last scheduled retry
+		retry <-chan time.Time // Fires when a failed update is to be retried
+	)
+	update := func() bool {
+		switch err := idx.Update(chain.CurrentBlock()); {
+		case err == nil:
+			delay, retry = 0, nil
+		case err == errClosed:
+			return false
+		default:
+			delay = min(max(2*delay, retryDelayMin), retryDelayMax)
+			retry = time.After(delay)
+			log.Warn("Failed to update log index", "retry", common.PrettyDuration(delay), "err", err)
+		}
+		return true
+	}
+	if !update() {
+		return
+	}
+	for {
+		select {
+		case <-heads:
+			for len(heads) > 0 {
+				<-heads
+			}
+			if retry != nil {
+				continue // wait for the retry of the failed update
+			}
+			if !update() {
+				return
+			}
+		case <-retry:
+			if !update() {
+				return
+			}
+		case <-sub.Err():
+			return
+		case <-idx.quit:
+			return
+		}
+	}
+}
+
+// Update brings the index in line with the canonical chain up to the given head,
+// reverting blocks which are no longer canonical and indexing the new ones.
+func (idx *Indexer) Update(head *types.Header) error {
+	idx.updateLock.Lock()
+	defer idx.updateLock.Unlock()
+
+	var (
+		number   = head.Number.Uint64()
+		start    = time.Now()
+		logged   = time.Now()
+		indexed  uint64
+		reported bool
+	)
+	idx.revert(number)
+	for next := idx.Indexed(); next <= number; next = idx.Indexed() {
+		select {
+		case <-idx.quit:
+			return errClosed
+		default:
+		}
+		hash := rawdb.ReadCanonicalHash(idx.db, next)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("canonical hash #%d missing", next)
+		}
+		header := rawdb.ReadHeader(idx.db, hash, next)
+		if header == nil {
+			return fmt.Errorf("header #%d [%x..] missing", next, hash[:4])
+		}
+		// If the canonical chain changed since the last indexed block, revert it
+		// and continue from the new fork point.
+		if next > 0 && header.ParentHash != idx.lastHash() {
+			if idx.revert(number); idx.Indexed() == next {
+				return fmt.Errorf("block #%d [%x..] does not extend the index", next, hash[:4])
+			}
+			continue
+		}
+		var logs [][]*types.Log
+		if header.Bloom != (types.Bloom{}) {
+			if logs = rawdb.ReadLogs(idx.db, hash, next); logs == nil {
+				return fmt.Errorf("receipts of block #%d [%x..] missing", next, hash[:4])
+			}
+		}
+		idx.add(hash, logs)
+		indexed++
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing logs", "blocks", indexed, "head", next, "total", number+1, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged, reported = time.Now(), true
+		}
+	}
+	if reported {
+		log.Info("Indexed logs", "blocks", indexed, "head", number, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	idx.syncedOnce.Do(func() { close(idx.synced) })
+	return nil
+}
+
+// lastHash returns the hash of the last indexed block.
+func (idx *Indexer) lastHash() common.Hash {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	if len(idx.hashes) > 0 {
+		return idx.hashes[len(idx.hashes)-1]
+	}
+	return idx.sealedHead
+}
+
+// add indexes the logs of the next block, sealing the head epoch if complete.
+func (idx *Indexer) add(hash common.Hash, logs [][]*types.Log) {
+	idx.lock.Lock()
+	offset := uint16(len(idx.hashes))
+	for _, txlogs := range logs {
+		for _, l := range txlogs {
+			idx.addValue(addressValue(l.Address), offset)
+			for _, topic := range l.Topics {
+				idx.addValue(topicValue(topic), offset)
+			}
+		}
+	}
+	idx.hashes = append(idx.hashes, hash)
+	complete := uint64(len(idx.hashes)) == idx.epochSize
+	idx.lock.Unlock()
+
+	if complete {
+		idx.seal()
+	}
+}
+
+// addValue adds a block offset to the row of a log value in the head epoch.
+func (idx *Indexer) addValue(value uint64, offset uint16) {
+	row := idx.rows[value]
+	if n := len(row); n > 0 && row[n-1] == offset {
+		return
+	}
+	idx.rows[value] = append(row, offset)
+}
+
+// seal writes the completed head epoch into the database.
+func (idx *Indexer) seal() {
+	idx.lock.RLock()
+	var (
+		epoch = idx.sealed
+		head  = idx.hashes[len(idx.hashes)-1]
+		batch = idx.db.NewBatch()
+	)
+	for value, offsets := range idx.rows {
+		rawdb.WriteLogIndexRow(batch, epoch, value, encodeRow(offsets))
+	}
+	idx.lock.RUnlock()
+
+	rawdb.WriteLogIndexEpochHead(batch, epoch, head)
+	rawdb.WriteLogIndexSealed(batch, epoch+1)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to seal log index epoch", "epoch", epoch, "err", err)
+	}
+	idx.lock.Lock()
+	idx.sealed, idx.sealedHead = epoch+1, head
+	idx.rows, idx.hashes = make(map[uint64][]uint16), nil
+	idx.lock.Unlock()
+
+	log.Debug("Sealed log index epoch", "epoch", epoch, "head", head)
+}
+
+// revert removes all indexed blocks which are not part of the canonical chain
+// anymore or are above the given head.
+func (idx *Indexer) revert(head uint64) {
+	canonical := func(number uint64, hash common.Hash) bool {
+		return number <= head && rawdb.ReadCanonicalHash(idx.db, number) == hash
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	// Drop the non-canonical tail of the head epoch. The indexed blocks form a
+	// chain, so everything before a canonical block is canonical too.
+	first := idx.sealed * idx.epochSize
+	keep := len(idx.hashes)
+	for keep > 0 && !canonical(first+uint64(keep)-1, idx.hashes[keep-1]) {
+		keep--
+	}
+	if keep < len(idx.hashes) {
+		idx.truncate(keep)
+	}
+	if keep > 0 {
+		return
+	}
+	// The head epoch is empty, unseal epochs until the last one is canonical
+	for idx.sealed > 0 && !canonical(idx.sealed*idx.epochSize-1, idx.sealedHead) {
+		idx.sealed--
+		log.Debug("Unsealing log index epoch", "epoch", idx.sealed)
+
+		rawdb.DeleteLogIndexEpoch(idx.db, idx.sealed)
+		rawdb.WriteLogIndexSealed(idx.db, idx.sealed)
+		if idx.sealed > 0 {
+			idx.sealedHead = rawdb.ReadLogIndexEpochHead(idx.db, idx.sealed-1)
+		} else {
+			idx.sealedHead = common.Hash{}
+		}
+	}
+}
+
+// truncate drops all blocks of the head epoch from the given offset on. The
+// lock must be held by the caller.
+func (idx *Indexer) truncate(offset int) {
+	for value, row := range idx.rows {
+		n := len(row)
+		for n > 0 && int(row[n-1]) >= offset {
+			n--
+		}
+		if n == 0 {
+			delete(idx.rows, value)
+		} else {
+			idx.rows[value] = row[:n]
+		}
+	}
+	idx.hashes = idx.hashes[:offset]
+}
@@ -0,0 +1,242 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package logindex
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testLoggers = []common.Address{{0xaa}, {0xbb}}
+
+	// Contract emitting a log with its calldata as single topic
+	testLoggerCode = []byte{0x60, 0x00, 0x35, 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00}
+)
+
+// newTestChain creates a blockchain whose blocks emit logs chosen by the given
+// function, which returns the logger index and topic per block, or -1 for none.
+// Besides the chain and its database, the database used to generate the blocks
+// is returned for creating forks.
+func newTestChain(t *testing.T, n int, choose func(i int) (int, byte)) (ethdb.Database, *core.BlockChain, ethdb.Database, []*types.Block) {
+	gspec := &core.Genesis{
+		Config:  params.TestChainConfig,
+		Alloc:   types.GenesisAlloc{testAddr: {Balance: big.NewInt(params.Ether)}},
+		BaseFee: big.NewInt(params.InitialBaseFee),
+	}
+	for _, addr := range testLoggers {
+		gspec.Alloc[addr] = types.Account{Code: testLoggerCode}
+	}
+	genDb, blocks, _ := core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), n, testLogBlocks(choose))
+
+	db := rawdb.NewMemoryDatabase()
+	chain, err := core.NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	return db, chain, genDb, blocks
+}
+
+// testLogBlocks returns a block generator emitting the logs chosen by choose.
+func testLogBlocks(choose func(i int) (int, byte)) func(int, *core.BlockGen) {
+	signer := types.LatestSigner(params.TestChainConfig)
+	return func(i int, b *core.BlockGen) {
+		logger, topic := choose(i)
+		if logger < 0 {
+			return
+		}
+		tx, _ := types.SignNewTx(testKey, signer, &types.LegacyTx{
+			Nonce:    b.TxNonce(testAddr),
+			To:       &testLoggers[logger],
+			Gas:      50000,
+			GasPrice: b.BaseFee(),
+			Data:     common.Hash{topic}.Bytes(),
+		})
+		b.AddTx(tx)
+	}
+}
+
+// checkIndex verifies that searching the index for every logger and topic yields
+// exactly the blocks of the canonical chain emitting them.
+func checkIndex(t *testing.T, idx *Indexer, chain *core.BlockChain) {
+	t.Helper()
+
+	head := chain.CurrentBlock().Number.Uint64()
+	if indexed := idx.Indexed(); indexed != head+1 {
+		t.Fatalf("wrong number of indexed blocks: have %d, want %d", indexed, head+1)
+	}
+	want := make(map[any][]uint64)
+	for n := uint64(1); n <= head; n++ {
+		block := chain.GetBlockByNumber(n)
+		for _, receipt := range chain.GetReceiptsByHash(block.Hash()) {
+			for _, l := range receipt.Logs {
+				want[l.Address] = append(want[l.Address], n)
+				want[l.Topics[0]] = append(want[l.Topics[0]], n)
+			}
+		}
+	}
+	search := func(addresses []common.Address, topics [][]common.Hash) []uint64 {
+		var found []uint64
+		next, err := idx.Search(context.Background(), 0, head, addresses, topics, func(number uint64) error {
+			found = append(found, number)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if next != head+1 {
+			t.Fatalf("wrong search end: have %d, want %d", next, head+1)
+		}
+		return found
+	}
+	for _, addr := range testLoggers {
+		if have := search([]common.Address{addr}, nil); !slices.Equal(have, want[addr]) {
+			t.Errorf("wrong blocks for address %x: have %v, want %v", addr, have, want[addr])
+		}
+	}
+	for i := 0; i < 4; i++ {
+		topic := common.Hash{byte(i)}
+		if have := search(nil, [][]common.Hash{{topic}}); !slices.Equal(have, want[topic]) {
+			t.Errorf("wrong blocks for topic %x: have %v, want %v", topic, have, want[topic])
+		}
+	}
+}
+
+func TestIndexer(t *testing.T) {
+	db, chain, _, _ := newTestChain(t, 30, func(i int) (int, byte) {
+		if i%3 == 2 {
+			return -1, 0
+		}
+		return i % 2, byte(i % 4)
+	})
+	defer chain.Stop()
+
+	idx := NewIndexer(db, 8)
+	if err := idx.Update(chain.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, idx, chain)
+	if sealed := rawdb.ReadLogIndexSealed(db); sealed != 3 {
+		t.Fatalf("wrong number of sealed epochs: have %d, want 3", sealed)
+	}
+	// Conjunctions of addresses and topics
+	var found []uint64
+	if _, err := idx.Search(context.Background(), 5, 20, testLoggers[:1], [][]common.Hash{{{2}}}, func(number uint64) error {
+		found = append(found, number)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{7, 11, 19}; !slices.Equal(found, want) {
+		t.Fatalf("wrong conjunction matches: have %v, want %v", found, want)
+	}
+	// Unrestricted criteria and ranges beyond the index are not served
+	if next, _ := idx.Search(context.Background(), 5, 20, nil, [][]common.Hash{{}}, nil); next != 5 {
+		t.Fatalf("unrestricted search served by index up to %d", next)
+	}
+	if next, _ := idx.Search(context.Background(), 20, 100, testLoggers, nil, func(uint64) error { return nil }); next != 31 {
+		t.Fatalf("wrong search end beyond index: have %d, want 31", next)
+	}
+	// Reopening the index rebuilds the head epoch
+	idx = NewIndexer(db, 8)
+	if indexed := idx.Indexed(); indexed != 24 {
+		t.Fatalf("wrong number of indexed blocks after restart: have %d, want 24", indexed)
+	}
+	if err := idx.Update(chain.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, idx, chain)
+}
+
+func TestIndexerReorg(t *testing.T) {
+	db, chain, genDb, blocks := newTestChain(t, 30, func(i int) (int, byte) {
+		return i % 2, byte(i % 4)
+	})
+	defer chain.Stop()
+
+	idx := NewIndexer(db, 8)
+	if err := idx.Update(chain.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	// Reorg to a longer fork within the head epoch
+	fork, _ := core.GenerateChain(params.TestChainConfig, blocks[26], ethash.NewFaker(), genDb, 5, testLogBlocks(func(i int) (int, byte) {
+		return 1, 3
+	}))
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Update(chain.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, idx, chain)
+
+	// Reorg to a longer fork reaching back into sealed epochs
+	fork, _ = core.GenerateChain(params.TestChainConfig, blocks[5], ethash.NewFaker(), genDb, 30, testLogBlocks(func(i int) (int, byte) {
+		return 0, byte(i % 3)
+	}))
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Update(chain.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, idx, chain)
+
+	// Rewinding the chain drops the blocks above the new head
+	if err := chain.SetHead(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Update(chain.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	checkIndex(t, idx, chain)
+}
+
+func TestRowEncoding(t *testing.T) {
+	for _, offsets := range [][]uint16{nil, {0}, {5}, {0, 1, 2, 3}, {1, 200, 4095}} {
+		have, err := decodeRow(encodeRow(offsets), 4096)
+		if err != nil {
+			t.Fatalf("%v: failed to decode: %v", offsets, err)
+		}
+		if !slices.Equal(have, offsets) {
+			t.Fatalf("wrong decoded offsets: have %v, want %v", have, offsets)
+		}
+	}
+	if _, err := decodeRow([]byte{0x01, 0x00}, 4096); err != errCorruptRow {
+		t.Fatalf("repeated offset accepted: %v", err)
+	}
+	if _, err := decodeRow(encodeRow([]uint16{4096}), 4096); err != errCorruptRow {
+		t.Fatalf("offset beyond epoch accepted: %v", err)
+	}
+}
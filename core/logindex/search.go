@@ -0,0 +1,226 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package logindex
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// errCorruptRow is returned if a row read from the database can not be decoded.
+var errCorruptRow = errors.New("corrupt log index row")
+
+// addressValue returns the index value of a log address.
+func addressValue(addr common.Address) uint64 {
+	return binary.BigEndian.Uint64(crypto.Keccak256(addr.Bytes())[:8])
+}
+
+// topicValue returns the index value of a log topic.
+func topicValue(topic common.Hash) uint64 {
+	return binary.BigEndian.Uint64(crypto.Keccak256(topic.Bytes())[:8])
+}
+
+// Search calls fn with the number of every block within [begin, end] which may
+// contain logs matching the given addresses and topics, in ascending order. The
+// candidates are a superset of the matching blocks, callers need to check the
+// actual logs of the blocks.
+//
+// The returned number is the first block past the searched range, which is less
+// than end+1 if the index does not cover the whole range. If the criteria does
+// not restrict the log values at all, the index is of no use and begin is
+// returned without searching.
+func (idx *Indexer) Search(ctx context.Context, begin, end uint64, addresses []common.Address, topics [][]common.Hash, fn func(number uint64) error) (uint64, error) {
+	// Convert the criteria into a conjunction of value disjunctions
+	var clauses [][]uint64
+	if len(addresses) > 0 {
+		clause := make([]uint64, len(addresses))
+		for i, addr := range addresses {
+			clause[i] = addressValue(addr)
+		}
+		clauses = append(clauses, clause)
+	}
+	for _, sub := range topics {
+		if len(sub) == 0 {
+			continue // wildcard
+		}
+		clause := make([]uint64, len(sub))
+		for i, topic := range sub {
+			clause[i] = topicValue(topic)
+		}
+		clauses = append(clauses, clause)
+	}
+	if len(clauses) == 0 || begin > end {
+		return begin, nil
+	}
+	// Search the epochs one by one up to the end of the index
+	if indexed := idx.Indexed(); end >= indexed {
+		if indexed <= begin {
+			return begin, nil
+		}
+		end = indexed - 1
+	}
+	for epoch := begin / idx.epochSize; epoch <= end/idx.epochSize; epoch++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		offsets, err := idx.epochMatches(epoch, clauses)
+		if err != nil {
+			return 0, err
+		}
+		for _, offset := range offsets {
+			number := epoch*idx.epochSize + uint64(offset)
+			if number < begin || number > end {
+				continue
+			}
+			if err := fn(number); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return end + 1, nil
+}
+
+// epochMatches returns the block offsets within an epoch matching all clauses.
+func (idx *Indexer) epochMatches(epoch uint64, clauses [][]uint64) ([]uint16, error) {
+	var matches []uint16
+	for i, clause := range clauses {
+		var union []uint16
+		for _, value := range clause {
+			row, err := idx.row(epoch, value)
+			if err != nil {
+				return nil, err
+			}
+			union = mergeRows(union, row)
+		}
+		if i == 0 {
+			matches = union
+		} else {
+			matches = intersectRows(matches, union)
+		}
+		if len(matches) == 0 {
+			return nil, nil
+		}
+	}
+	return matches, nil
+}
+
+// row retrieves the block offsets of a log value within an epoch, either from
+// the database or from the in-memory head epoch.
+func (idx *Indexer) row(epoch uint64, value uint64) ([]uint16, error) {
+	idx.lock.RLock()
+	if epoch >= idx.sealed {
+		var row []uint16
+		if epoch == idx.sealed {
+			row = append(row, idx.rows[value]...)
+		}
+		idx.lock.RUnlock()
+		return row, nil
+	}
+	idx.lock.RUnlock()
+
+	blob := rawdb.ReadLogIndexRow(idx.db, epoch, value)
+	if blob == nil {
+		return nil, nil
+	}
+	row, err := decodeRow(blob, idx.epochSize)
+	if err != nil {
+		return nil, fmt.Errorf("epoch %d, value %016x: %w", epoch, value, err)
+	}
+	return row, nil
+}
+
+// encodeRow encodes a sorted list of block offsets as varint deltas.
+func encodeRow(offsets []uint16) []byte {
+	var (
+		blob = make([]byte, 0, len(offsets))
+		prev uint16
+	)
+	for i, offset := range offsets {
+		delta := offset
+		if i > 0 {
+			delta = offset - prev
+		}
+		blob = binary.AppendUvarint(blob, uint64(delta))
+		prev = offset
+	}
+	return blob
+}
+
+// decodeRow decodes a list of block offsets encoded by encodeRow.
+func decodeRow(blob []byte, epochSize uint64) ([]uint16, error) {
+	var (
+		offsets []uint16
+		offset  uint64
+	)
+	for len(blob) > 0 {
+		delta, n := binary.Uvarint(blob)
+		if n <= 0 || (len(offsets) > 0 && delta == 0) {
+			return nil, errCorruptRow
+		}
+		if offset += delta; offset >= epochSize {
+			return nil, errCorruptRow
+		}
+		offsets = append(offsets, uint16(offset))
+		blob = blob[n:]
+	}
+	return offsets, nil
+}
+
+// mergeRows returns the sorted union of two sorted offset lists.
+func mergeRows(a, b []uint16) []uint16 {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	merged := make([]uint16, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			merged, a = append(merged, a[0]), a[1:]
+		case a[0] > b[0]:
+			merged, b = append(merged, b[0]), b[1:]
+		default:
+			merged, a, b = append(merged, a[0]), a[1:], b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// intersectRows returns the sorted intersection of two sorted offset lists.
+func intersectRows(a, b []uint16) []uint16 {
+	var shared []uint16
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			shared, a, b = append(shared, a[0]), a[1:], b[1:]
+		}
+	}
+	return shared
+}
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -179,3 +180,100 @@ func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
 		log.Crit("Failed to delete bloom bits", "err", it.Error())
 	}
 }
+
+// DeleteBloomBitsIndex removes all bloom bits vectors along with the progress
+// metadata of their chain indexer from the database.
+func DeleteBloomBitsIndex(db ethdb.KeyValueStore) {
+	batch := db.NewBatch()
+	for _, prefix := range [][]byte{bloomBitsPrefix, BloomBitsIndexPrefix} {
+		it := db.NewIterator(prefix, nil)
+		for it.Next() {
+			if bytes.Equal(prefix, bloomBitsPrefix) && len(it.Key()) != len(bloomBitsPrefix)+2+8+32 {
+				continue
+			}
+			batch.Delete(it.Key())
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Crit("Failed to delete bloom bits", "err", err)
+				}
+				batch.Reset()
+			}
+		}
+		if it.Error() != nil {
+			log.Crit("Failed to delete bloom bits", "err", it.Error())
+		}
+		it.Release()
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete bloom bits", "err", err)
+	}
+}
+
+// ReadLogIndexSealed retrieves the number of log index epochs sealed in the
+// database.
+func ReadLogIndexSealed(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(logIndexSealedKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteLogIndexSealed stores the number of log index epochs sealed in the
+// database.
+func WriteLogIndexSealed(db ethdb.KeyValueWriter, sealed uint64) {
+	if err := db.Put(logIndexSealedKey, encodeBlockNumber(sealed)); err != nil {
+		log.Crit("Failed to store the number of sealed log index epochs", "err", err)
+	}
+}
+
+// ReadLogIndexRow retrieves the encoded block offsets of a log value within a
+// log index epoch.
+func ReadLogIndexRow(db ethdb.KeyValueReader, epoch uint64, value uint64) []byte {
+	data, _ := db.Get(logIndexRowKey(epoch, value))
+	return data
+}
+
+// WriteLogIndexRow stores the encoded block offsets of a log value within a log
+// index epoch.
+func WriteLogIndexRow(db ethdb.KeyValueWriter, epoch uint64, value uint64, row []byte) {
+	if err := db.Put(logIndexRowKey(epoch, value), row); err != nil {
+		log.Crit("Failed to store log index row", "err", err)
+	}
+}
+
+// ReadLogIndexEpochHead retrieves the hash of the last block of a sealed log
+// index epoch.
+func ReadLogIndexEpochHead(db ethdb.KeyValueReader, epoch uint64) common.Hash {
+	data, _ := db.Get(logIndexEpochKey(epoch))
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteLogIndexEpochHead stores the hash of the last block of a sealed log index
+// epoch.
+func WriteLogIndexEpochHead(db ethdb.KeyValueWriter, epoch uint64, hash common.Hash) {
+	if err := db.Put(logIndexEpochKey(epoch), hash.Bytes()); err != nil {
+		log.Crit("Failed to store log index epoch head", "err", err)
+	}
+}
+
+// DeleteLogIndexEpoch removes all rows and the head of a log index epoch.
+func DeleteLogIndexEpoch(db ethdb.KeyValueStore, epoch uint64) {
+	batch := db.NewBatch()
+	it := db.NewIterator(append(logIndexRowPrefix, encodeBlockNumber(epoch)...), nil)
+	defer it.Release()
+
+	for it.Next() {
+		batch.Delete(it.Key())
+	}
+	if it.Error() != nil {
+		log.Crit("Failed to iterate log index epoch", "err", it.Error())
+	}
+	batch.Delete(logIndexEpochKey(epoch))
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete log index epoch", "err", err)
+	}
+}
@@ -79,6 +79,9 @@ var (
 	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
 	txIndexTailKey = []byte("TransactionIndexTail")
 
+	// logIndexSealedKey tracks the number of log index epochs sealed in the database.
+	logIndexSealedKey = []byte("LogIndexSealed")
+
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	// This flag is deprecated, it's kept to avoid reporting errors when inspect
 	// database.
@@ -141,6 +144,9 @@ var (
 
 	CliqueSnapshotPrefix = []byte("clique-")
 
+	logIndexRowPrefix   = []byte("logIndex-r") // logIndexRowPrefix + epoch (uint64 big endian) + value (uint64 big endian) -> block offsets
+	logIndexEpochPrefix = []byte("logIndex-e") // logIndexEpochPrefix + epoch (uint64 big endian) -> hash of the last block of the epoch
+
 	BestUpdateKey         = []byte("update-")    // bigEndian64(syncPeriod) -> RLP(types.LightClientUpdate)  (nextCommittee only referenced by root hash)
 	FixedCommitteeRootKey = []byte("fixedRoot-") // bigEndian64(syncPeriod) -> committee root hash
 	SyncCommitteeKey      = []byte("committee-") // bigEndian64(syncPeriod) -> serialized committee
@@ -233,6 +239,20 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexRowKey = logIndexRowPrefix + epoch (uint64 big endian) + value (uint64 big endian)
+func logIndexRowKey(epoch uint64, value uint64) []byte {
+	key := make([]byte, len(logIndexRowPrefix)+16)
+	copy(key, logIndexRowPrefix)
+	binary.BigEndian.PutUint64(key[len(logIndexRowPrefix):], epoch)
+	binary.BigEndian.PutUint64(key[len(logIndexRowPrefix)+8:], value)
+	return key
+}
+
+// logIndexEpochKey = logIndexEpochPrefix + epoch (uint64 big endian)
+func logIndexEpochKey(epoch uint64) []byte {
+	return append(logIndexEpochPrefix, encodeBlockNumber(epoch)...)
+}
+
 // skeletonHeaderKey = skeletonHeaderPrefix + num (uint64 big endian)
 func skeletonHeaderKey(number uint64) []byte {
 	return append(skeletonHeaderPrefix, encodeBlockNumber(number)...)
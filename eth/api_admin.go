@@ -18,6 +18,7 @@ package eth
 
 import (
 	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -27,8 +28,13 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpctypes"
 )
 
+// errLiveTracingDisabled is returned by the live tracer methods if the node was
+// started without live tracing.
+var errLiveTracingDisabled = errors.New("live tracing disabled, enable it with --vmtrace or --vmtrace.attach")
+
 // AdminAPI is the collection of Ethereum full node related APIs for node
 // administration.
 type AdminAPI struct {
@@ -141,3 +147,37 @@ func (api *AdminAPI) ImportChain(file string) (bool, error) {
 	}
 	return true, nil
 }
+
+// FlushResponseCache drops all RPC responses cached for finalized chain data.
+func (api *AdminAPI) FlushResponseCache() error {
+	if api.eth.responseCache == nil {
+		return errors.New("rpc response cache is disabled")
+	}
+	return api.eth.responseCache.Flush()
+}
+
+// AttachLiveTracer attaches the live tracer of the given name and configuration
+// under the given id. If a block is being imported, the tracer starts with the
+// next one.
+func (api *AdminAPI) AttachLiveTracer(id string, name string, config json.RawMessage) error {
+	if api.eth.liveTracer == nil {
+		return errLiveTracingDisabled
+	}
+	return api.eth.liveTracer.Attach(id, name, config)
+}
+
+// DetachLiveTracer detaches and closes the live tracer with the given id.
+func (api *AdminAPI) DetachLiveTracer(id string) error {
+	if api.eth.liveTracer == nil {
+		return errLiveTracingDisabled
+	}
+	return api.eth.liveTracer.Detach(id)
+}
+
+// LiveTracers returns the attached live tracers and the time spent in each.
+func (api *AdminAPI) LiveTracers() ([]rpctypes.LiveTracerInfo, error) {
+	if api.eth.liveTracer == nil {
+		return nil, errLiveTracingDisabled
+	}
+	return api.eth.liveTracer.Tracers(), nil
+}
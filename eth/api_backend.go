@@ -24,11 +24,14 @@ import (
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/logindex"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/txpool"
@@ -38,10 +41,16 @@ import (
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
 )
 
+// rpcStateReexec is the maximum number of blocks re-executed to regenerate the
+// historical state needed by RPC calls positioned inside a block.
+const rpcStateReexec = uint64(128)
+
 // EthAPIBackend implements ethapi.Backend and tracers.Backend for full nodes
 type EthAPIBackend struct {
 	extRPCEnabled       bool
@@ -234,6 +243,23 @@ func (b *EthAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 }
 
+// StateAndHeaderAtTransaction returns the state right before the transaction with
+// the given index of the block is executed, regenerating it if needed.
+func (b *EthAPIBackend) StateAndHeaderAtTransaction(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, txIndex int) (*state.StateDB, *types.Header, func(), error) {
+	block, err := b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if block == nil {
+		return nil, nil, nil, errors.New("block not found")
+	}
+	_, _, statedb, release, err := b.eth.stateAtTransaction(ctx, block, txIndex, rpcStateReexec)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return statedb, block.Header(), release, nil
+}
+
 func (b *EthAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	return b.eth.blockchain.GetReceiptsByHash(hash), nil
 }
@@ -362,10 +388,65 @@ func (b *EthAPIBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error)
 	return b.gpo.SuggestTipCap(ctx)
 }
 
-func (b *EthAPIBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (firstBlock *big.Int, reward [][]*big.Int, baseFee []*big.Int, gasUsedRatio []float64, baseFeePerBlobGas []*big.Int, blobGasUsedRatio []float64, err error) {
+func (b *EthAPIBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (firstBlock *big.Int, reward [][]*big.Int, baseFee []*big.Int, gasUsedRatio []float64, baseFeePerBlobGas []*big.Int, blobGasUsedRatio []float64, blobReward [][]*big.Int, err error) {
 	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
 }
 
+func (b *EthAPIBackend) SuggestBlobFeeCap(ctx context.Context, horizon uint64) (*big.Int, error) {
+	return b.gpo.SuggestBlobFeeCap(ctx, horizon)
+}
+
+func (b *EthAPIBackend) FeePrediction(ctx context.Context, blocks uint64) (*rpctypes.FeePredictionResult, error) {
+	// The pending demand is only gathered if no prediction was made for the head
+	prediction, err := b.gpo.PredictFees(ctx, blocks, func() []gasprice.Demand {
+		var demand []gasprice.Demand
+		for _, txs := range b.eth.txPool.Pending(txpool.PendingFilter{}) {
+			for _, tx := range txs {
+				demand = append(demand, gasprice.Demand{
+					GasFeeCap: tx.GasFeeCap.ToBig(),
+					GasTipCap: tx.GasTipCap.ToBig(),
+					Gas:       tx.Gas,
+					BlobGas:   tx.BlobGas,
+				})
+			}
+		}
+		return demand
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &rpctypes.FeePredictionResult{
+		Blocks:         make([]rpctypes.BlockPredictionResult, len(prediction.Blocks)),
+		PendingGas:     make([]rpctypes.TipBucketResult, len(prediction.PendingByTip)),
+		UnderpricedGas: hexutil.Uint64(prediction.UnderpricedGas),
+	}
+	for i, block := range prediction.Blocks {
+		result.Blocks[i] = rpctypes.BlockPredictionResult{
+			Number:           hexutil.Uint64(block.Number),
+			BaseFee:          newFeeBandResult(block.BaseFee),
+			BlobBaseFee:      newFeeBandResult(block.BlobBaseFee),
+			GasUsedRatio:     block.GasUsedRatio,
+			BlobGasUsedRatio: block.BlobGasUsedRatio,
+		}
+	}
+	for i, bucket := range prediction.PendingByTip {
+		result.PendingGas[i] = rpctypes.TipBucketResult{
+			MinTip: (*hexutil.Big)(bucket.MinTip),
+			Gas:    hexutil.Uint64(bucket.Gas),
+			Txs:    hexutil.Uint(bucket.Txs),
+		}
+	}
+	return result, nil
+}
+
+func newFeeBandResult(band gasprice.FeeBand) rpctypes.FeeBandResult {
+	return rpctypes.FeeBandResult{
+		Low:      (*hexutil.Big)(band.Low),
+		Expected: (*hexutil.Big)(band.Expected),
+		High:     (*hexutil.Big)(band.High),
+	}
+}
+
 func (b *EthAPIBackend) BlobBaseFee(ctx context.Context) *big.Int {
 	if excess := b.CurrentHeader().ExcessBlobGas; excess != nil {
 		return eip4844.CalcBlobFee(*excess)
@@ -405,7 +486,26 @@ func (b *EthAPIBackend) RPCTxFeeCap() float64 {
 	return b.eth.config.RPCTxFeeCap
 }
 
+func (b *EthAPIBackend) ResponseCache() *ethapi.ResponseCache {
+	return b.eth.responseCache
+}
+
+func (b *EthAPIBackend) TraceScheduler() *tracers.Scheduler {
+	return b.eth.traceScheduler
+}
+
+func (b *EthAPIBackend) ErrorRegistry() *abi.ErrorRegistry {
+	return b.eth.errorRegistry
+}
+
+func (b *EthAPIBackend) LogIndex() *logindex.Indexer {
+	return b.eth.logIndexer
+}
+
 func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
+	if b.eth.bloomDropped.Load() {
+		return params.BloomBitsBlocks, 0
+	}
 	sections, _, _ := b.eth.bloomIndexer.Sections()
 	return params.BloomBitsBlocks, sections
 }
@@ -18,18 +18,20 @@
 package eth
 
 import (
-	"encoding/json"
 	"fmt"
 	"math/big"
 	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/logindex"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/txpool"
@@ -76,6 +78,12 @@ type Ethereum struct {
 
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
+	cacheDb ethdb.Database // Persistent RPC response cache, nil if disabled
+
+	responseCache  *ethapi.ResponseCache // Cache of finalized-data RPC responses
+	traceScheduler *tracers.Scheduler    // Limits and caches the re-executions of tracing
+	traceStates    *stateCache           // States regenerated for tracing, nil if disabled
+	errorRegistry  *abi.ErrorRegistry    // Custom errors decoded in reverts, nil if none
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -84,6 +92,12 @@ type Ethereum struct {
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
+	bloomDropped      atomic.Bool    // Whether the bloombits were dropped in favour of the log index
+	bloomDropWg       sync.WaitGroup // Tracks the deletion of the dropped bloombits
+
+	logIndexer *logindex.Indexer // Log index maintained during block imports, nil if disabled
+
+	liveTracer *tracers.LiveMux // Live tracers of block imports, nil if disabled
 
 	APIBackend *EthAPIBackend
 
@@ -164,6 +178,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		p2pServer:         stack.Server(),
 		shutdownTracker:   shutdowncheck.NewShutdownTracker(chainDb),
 	}
+	if !config.LogNoIndex {
+		eth.logIndexer = logindex.NewIndexer(chainDb, params.LogIndexEpochBlocks)
+	}
 	bcVersion := rawdb.ReadDatabaseVersion(chainDb)
 	var dbVer = "<nil>"
 	if bcVersion != nil {
@@ -196,18 +213,17 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			Preimages:           config.Preimages,
 			StateHistory:        config.StateHistory,
 			StateScheme:         scheme,
+			BadBlockDir:         config.BadBlockDir,
 		}
 	)
-	if config.VMTrace != "" {
-		var traceConfig json.RawMessage
-		if config.VMTraceJsonConfig != "" {
-			traceConfig = json.RawMessage(config.VMTraceJsonConfig)
-		}
-		t, err := tracers.LiveDirectory.New(config.VMTrace, traceConfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create tracer %s: %v", config.VMTrace, err)
+	if config.VMTrace != "" || config.VMTraceAttach {
+		eth.liveTracer = tracers.NewLiveMux(config.VMTraceAttach)
+		if config.VMTrace != "" {
+			if err := eth.liveTracer.AttachConfigured(config.VMTrace, config.VMTraceJsonConfig); err != nil {
+				return nil, err
+			}
 		}
-		vmConfig.Tracer = t
+		vmConfig.Tracer = eth.liveTracer.Hooks()
 	}
 	// Override the chain config with provided settings.
 	var overrides core.ChainOverrides
@@ -225,7 +241,18 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
-	eth.bloomIndexer.Start(eth.blockchain)
+	// The legacy bloombits can only be dropped in favour of an enabled log index
+	if eth.logIndexer != nil {
+		eth.logIndexer.Start(eth.blockchain)
+	} else if config.LogNoBloomBits {
+		log.Warn("Keeping the legacy bloombits, as the log index is disabled")
+	}
+	if config.LogNoBloomBits && eth.logIndexer != nil {
+		eth.bloomDropWg.Add(1)
+		go eth.dropBloomBits()
+	} else {
+		eth.bloomIndexer.Start(eth.blockchain)
+	}
 
 	if config.BlobPool.Datadir != "" {
 		config.BlobPool.Datadir = stack.ResolvePath(config.BlobPool.Datadir)
@@ -260,6 +287,40 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	eth.miner = miner.New(eth, config.Miner, eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
+	// Set up the RPC response cache for finalized chain data if requested
+	if config.RPCResponseCache > 0 {
+		if config.RPCResponseCacheDisk {
+			eth.cacheDb, err = stack.OpenDatabase("rpccache", 16, 16, "eth/db/rpccache/", false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		eth.responseCache = ethapi.NewResponseCache(uint64(config.RPCResponseCache)*1024*1024, eth.cacheDb)
+		log.Info("Enabled RPC response cache", "size", common.StorageSize(config.RPCResponseCache)*1024*1024, "disk", config.RPCResponseCacheDisk)
+	}
+	eth.traceScheduler = tracers.NewScheduler(tracers.SchedulerConfig{
+		Concurrency: config.RPCTraceConcurrency,
+		Queue:       config.RPCTraceQueue,
+		CacheSize:   uint64(config.RPCTraceCache) * 1024 * 1024,
+	})
+	if config.RPCTraceStates > 0 && eth.blockchain.TrieDB().Scheme() == rawdb.HashScheme {
+		eth.traceStates = newStateCache(config.RPCTraceStates)
+	}
+	// Load the custom errors to decode in reverts returned over RPC
+	if config.RPCErrorABIs != "" || config.RPCErrorSignatures != "" {
+		eth.errorRegistry = abi.NewErrorRegistry()
+		if config.RPCErrorABIs != "" {
+			if err := eth.errorRegistry.LoadDir(config.RPCErrorABIs); err != nil {
+				return nil, fmt.Errorf("failed to load error abis: %v", err)
+			}
+		}
+		if config.RPCErrorSignatures != "" {
+			if err := eth.errorRegistry.LoadSignatures(config.RPCErrorSignatures); err != nil {
+				return nil, fmt.Errorf("failed to load error signatures: %v", err)
+			}
+		}
+		log.Info("Loaded custom errors for decoding reverts", "errors", eth.errorRegistry.Len())
+	}
 	eth.APIBackend = &EthAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, eth, nil}
 	if eth.APIBackend.allowUnprotectedTxs {
 		log.Info("Unprotected transactions allowed")
@@ -359,6 +420,7 @@ func (s *Ethereum) Synced() bool                       { return s.handler.synced
 func (s *Ethereum) SetSynced()                         { s.handler.enableSyncedFeatures() }
 func (s *Ethereum) ArchiveMode() bool                  { return s.config.NoPruning }
 func (s *Ethereum) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
+func (s *Ethereum) LogIndexer() *logindex.Indexer      { return s.logIndexer }
 
 // Protocols returns all the currently configured
 // network protocols to start.
@@ -405,6 +467,10 @@ func (s *Ethereum) Stop() error {
 	// Then stop everything else.
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
+	s.bloomDropWg.Wait()
 	s.txPool.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
@@ -413,6 +479,9 @@ func (s *Ethereum) Stop() error {
 	s.shutdownTracker.Stop()
 
 	s.chainDb.Close()
+	if s.cacheDb != nil {
+		s.cacheDb.Close()
+	}
 	s.eventMux.Stop()
 
 	return nil
@@ -19,8 +19,10 @@ package eth
 import (
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/bitutil"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 const (
@@ -72,3 +74,20 @@ func (eth *Ethereum) startBloomHandlers(sectionSize uint64) {
 		}()
 	}
 }
+
+// dropBloomBits waits for the log index to cover the entire chain, then stops
+// serving bloombits lookups and deletes the legacy bloombits from the database.
+func (eth *Ethereum) dropBloomBits() {
+	defer eth.bloomDropWg.Done()
+
+	select {
+	case <-eth.logIndexer.Synced():
+	case <-eth.closeBloomHandler:
+		return
+	}
+	eth.bloomDropped.Store(true)
+
+	start := time.Now()
+	rawdb.DeleteBloomBitsIndex(eth.chainDb)
+	log.Info("Dropped legacy bloombits", "elapsed", common.PrettyDuration(time.Since(start)))
+}
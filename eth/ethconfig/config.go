@@ -42,6 +42,7 @@ var FullNodeGPO = gasprice.Config{
 	Percentile:       60,
 	MaxHeaderHistory: 1024,
 	MaxBlockHistory:  1024,
+	BlobBlocks:       20,
 	MaxPrice:         gasprice.DefaultMaxPrice,
 	IgnorePrice:      gasprice.DefaultIgnorePrice,
 }
@@ -67,6 +68,9 @@ var Defaults = Config{
 	RPCEVMTimeout:      5 * time.Second,
 	GPO:                FullNodeGPO,
 	RPCTxFeeCap:        1, // 1 ether
+	RPCTraceQueue:      64,
+	RPCTraceCache:      64,
+	RPCTraceStates:     8,
 }
 
 //go:generate go run github.com/fjl/gencodec -type Config -formats toml -out gen_config.go
@@ -94,6 +98,8 @@ type Config struct {
 	TxLookupLimit      uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	TransactionHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 	StateHistory       uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state histories are reserved.
+	LogNoIndex         bool   `toml:",omitempty"` // Whether to disable the log index and search the legacy bloombits only.
+	LogNoBloomBits     bool   `toml:",omitempty"` // Whether to drop the legacy bloombits once the log index is complete.
 
 	// State scheme represents the scheme used to store ethereum states and trie
 	// nodes on top. It can be 'hash', 'path', or none which means use the scheme
@@ -124,6 +130,7 @@ type Config struct {
 	TrieTimeout    time.Duration
 	SnapshotCache  int
 	Preimages      bool
+	BadBlockDir    string // Directory to write bad block bundles into, disabled if empty
 
 	// This is the number of blocks for which logs will be cached in the filter system.
 	FilterLogCacheSize int
@@ -147,6 +154,7 @@ type Config struct {
 	// Enables VM tracing
 	VMTrace           string
 	VMTraceJsonConfig string
+	VMTraceAttach     bool // Allows attaching any live tracer at runtime
 
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
@@ -161,6 +169,39 @@ type Config struct {
 	// send-transaction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// RPCResponseCache is the memory allowance (in megabytes) for caching RPC
+	// responses about finalized chain data. Zero disables the cache.
+	RPCResponseCache int
+
+	// RPCResponseCacheDisk enables persisting cached RPC responses on disk. The
+	// persisted responses are limited to ethapi.ResponseCacheDiskFactor times
+	// the memory allowance.
+	RPCResponseCacheDisk bool
+
+	// RPCTraceConcurrency is the maximum number of re-executions done for
+	// tracing concurrently. Zero means the number of CPUs.
+	RPCTraceConcurrency int
+
+	// RPCTraceQueue is the maximum number of trace requests waiting for an
+	// execution slot, further ones are rejected.
+	RPCTraceQueue int
+
+	// RPCTraceCache is the memory allowance (in megabytes) for caching trace
+	// results. Zero disables the cache.
+	RPCTraceCache int
+
+	// RPCTraceStates is the number of historical states regenerated for tracing
+	// which are retained for later requests. Zero disables retention.
+	RPCTraceStates int
+
+	// RPCErrorABIs is the directory of contract ABIs, or compiler artifacts,
+	// whose custom errors are decoded in revert errors returned over RPC.
+	RPCErrorABIs string `toml:",omitempty"`
+
+	// RPCErrorSignatures is a file listing custom error signatures, one per line,
+	// which are decoded in revert errors returned over RPC.
+	RPCErrorSignatures string `toml:",omitempty"`
+
 	// OverrideCancun (TODO: remove after the fork)
 	OverrideCancun *uint64 `toml:",omitempty"`
 
@@ -27,6 +27,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxLookupLimit           uint64                 `toml:",omitempty"`
 		TransactionHistory      uint64                 `toml:",omitempty"`
 		StateHistory            uint64                 `toml:",omitempty"`
+		LogNoIndex              bool                   `toml:",omitempty"`
+		LogNoBloomBits          bool                   `toml:",omitempty"`
 		StateScheme             string                 `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
@@ -44,6 +46,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TrieTimeout             time.Duration
 		SnapshotCache           int
 		Preimages               bool
+		BadBlockDir             string
 		FilterLogCacheSize      int
 		Miner                   miner.Config
 		TxPool                  legacypool.Config
@@ -53,10 +56,19 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		EnableWitnessCollection bool `toml:"-"`
 		VMTrace                 string
 		VMTraceJsonConfig       string
+		VMTraceAttach           bool
 		DocRoot                 string `toml:"-"`
 		RPCGasCap               uint64
 		RPCEVMTimeout           time.Duration
 		RPCTxFeeCap             float64
+		RPCResponseCache        int
+		RPCResponseCacheDisk    bool
+		RPCTraceConcurrency     int
+		RPCTraceQueue           int
+		RPCTraceCache           int
+		RPCTraceStates          int
+		RPCErrorABIs            string  `toml:",omitempty"`
+		RPCErrorSignatures      string  `toml:",omitempty"`
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
 	}
@@ -71,6 +83,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxLookupLimit = c.TxLookupLimit
 	enc.TransactionHistory = c.TransactionHistory
 	enc.StateHistory = c.StateHistory
+	enc.LogNoIndex = c.LogNoIndex
+	enc.LogNoBloomBits = c.LogNoBloomBits
 	enc.StateScheme = c.StateScheme
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
@@ -88,6 +102,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
 	enc.Preimages = c.Preimages
+	enc.BadBlockDir = c.BadBlockDir
 	enc.FilterLogCacheSize = c.FilterLogCacheSize
 	enc.Miner = c.Miner
 	enc.TxPool = c.TxPool
@@ -97,10 +112,19 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.EnableWitnessCollection = c.EnableWitnessCollection
 	enc.VMTrace = c.VMTrace
 	enc.VMTraceJsonConfig = c.VMTraceJsonConfig
+	enc.VMTraceAttach = c.VMTraceAttach
 	enc.DocRoot = c.DocRoot
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCResponseCache = c.RPCResponseCache
+	enc.RPCResponseCacheDisk = c.RPCResponseCacheDisk
+	enc.RPCTraceConcurrency = c.RPCTraceConcurrency
+	enc.RPCTraceQueue = c.RPCTraceQueue
+	enc.RPCTraceCache = c.RPCTraceCache
+	enc.RPCTraceStates = c.RPCTraceStates
+	enc.RPCErrorABIs = c.RPCErrorABIs
+	enc.RPCErrorSignatures = c.RPCErrorSignatures
 	enc.OverrideCancun = c.OverrideCancun
 	enc.OverrideVerkle = c.OverrideVerkle
 	return &enc, nil
@@ -119,6 +143,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxLookupLimit           *uint64                `toml:",omitempty"`
 		TransactionHistory      *uint64                `toml:",omitempty"`
 		StateHistory            *uint64                `toml:",omitempty"`
+		LogNoIndex              *bool                  `toml:",omitempty"`
+		LogNoBloomBits          *bool                  `toml:",omitempty"`
 		StateScheme             *string                `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
@@ -136,6 +162,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TrieTimeout             *time.Duration
 		SnapshotCache           *int
 		Preimages               *bool
+		BadBlockDir             *string
 		FilterLogCacheSize      *int
 		Miner                   *miner.Config
 		TxPool                  *legacypool.Config
@@ -145,10 +172,19 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		EnableWitnessCollection *bool `toml:"-"`
 		VMTrace                 *string
 		VMTraceJsonConfig       *string
+		VMTraceAttach           *bool
 		DocRoot                 *string `toml:"-"`
 		RPCGasCap               *uint64
 		RPCEVMTimeout           *time.Duration
 		RPCTxFeeCap             *float64
+		RPCResponseCache        *int
+		RPCResponseCacheDisk    *bool
+		RPCTraceConcurrency     *int
+		RPCTraceQueue           *int
+		RPCTraceCache           *int
+		RPCTraceStates          *int
+		RPCErrorABIs            *string `toml:",omitempty"`
+		RPCErrorSignatures      *string `toml:",omitempty"`
 		OverrideCancun          *uint64 `toml:",omitempty"`
 		OverrideVerkle          *uint64 `toml:",omitempty"`
 	}
@@ -186,6 +222,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.StateHistory != nil {
 		c.StateHistory = *dec.StateHistory
 	}
+	if dec.LogNoIndex != nil {
+		c.LogNoIndex = *dec.LogNoIndex
+	}
+	if dec.LogNoBloomBits != nil {
+		c.LogNoBloomBits = *dec.LogNoBloomBits
+	}
 	if dec.StateScheme != nil {
 		c.StateScheme = *dec.StateScheme
 	}
@@ -237,6 +279,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
+	if dec.BadBlockDir != nil {
+		c.BadBlockDir = *dec.BadBlockDir
+	}
 	if dec.FilterLogCacheSize != nil {
 		c.FilterLogCacheSize = *dec.FilterLogCacheSize
 	}
@@ -264,6 +309,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.VMTraceJsonConfig != nil {
 		c.VMTraceJsonConfig = *dec.VMTraceJsonConfig
 	}
+	if dec.VMTraceAttach != nil {
+		c.VMTraceAttach = *dec.VMTraceAttach
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -276,6 +324,30 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCResponseCache != nil {
+		c.RPCResponseCache = *dec.RPCResponseCache
+	}
+	if dec.RPCResponseCacheDisk != nil {
+		c.RPCResponseCacheDisk = *dec.RPCResponseCacheDisk
+	}
+	if dec.RPCTraceConcurrency != nil {
+		c.RPCTraceConcurrency = *dec.RPCTraceConcurrency
+	}
+	if dec.RPCTraceQueue != nil {
+		c.RPCTraceQueue = *dec.RPCTraceQueue
+	}
+	if dec.RPCTraceCache != nil {
+		c.RPCTraceCache = *dec.RPCTraceCache
+	}
+	if dec.RPCTraceStates != nil {
+		c.RPCTraceStates = *dec.RPCTraceStates
+	}
+	if dec.RPCErrorABIs != nil {
+		c.RPCErrorABIs = *dec.RPCErrorABIs
+	}
+	if dec.RPCErrorSignatures != nil {
+		c.RPCErrorSignatures = *dec.RPCErrorSignatures
+	}
 	if dec.OverrideCancun != nil {
 		c.OverrideCancun = dec.OverrideCancun
 	}
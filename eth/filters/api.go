@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"sync"
 	"time"
 
@@ -29,7 +30,9 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -39,6 +42,12 @@ var (
 	errInvalidBlockRange      = errors.New("invalid block range params")
 	errPendingLogsUnsupported = errors.New("pending logs are not supported")
 	errExceedMaxTopics        = errors.New("exceed max topics")
+	errInvalidPageLimit       = errors.New("invalid log page limit")
+	errInvalidCursor          = errors.New("invalid log cursor")
+	errCursorReorged          = errors.New("log cursor invalidated by chain reorganisation")
+
+	// errPageFull is used internally to stop searching once a page is complete.
+	errPageFull = errors.New("log page full")
 )
 
 // The maximum number of topic criteria allowed, vm.LOG4 - vm.LOG0
@@ -47,6 +56,9 @@ const maxTopics = 4
 // The maximum number of allowed topics within a topic criteria
 const maxSubTopics = 1000
 
+// The maximum number of logs returned in a single page, larger limits are capped
+const maxLogPageLimit = 10000
+
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
 type filter struct {
@@ -83,6 +95,32 @@ func NewFilterAPI(system *FilterSystem) *FilterAPI {
 	return api
 }
 
+// filterService is the RPC service of FilterAPI. It serves the same methods, but
+// streams log results to the client as they are found, instead of collecting
+// them first.
+type filterService struct {
+	*FilterAPI
+}
+
+// NewFilterService returns the RPC service of a new FilterAPI instance.
+func NewFilterService(system *FilterSystem) interface{} {
+	return &filterService{NewFilterAPI(system)}
+}
+
+// GetLogs streams the logs matching the given argument. If page options are
+// given, a single page of logs is returned instead, see LogPageOptions.
+func (s *filterService) GetLogs(ctx context.Context, crit FilterCriteria, page *LogPageOptions) (interface{}, error) {
+	if page != nil {
+		return s.getLogsPage(ctx, crit, *page)
+	}
+	return s.streamLogs(ctx, crit)
+}
+
+// GetFilterLogs streams the logs for the filter with the given id.
+func (s *filterService) GetFilterLogs(ctx context.Context, id rpc.ID) (rpc.Stream[*types.Log], error) {
+	return s.streamFilterLogs(ctx, id)
+}
+
 // timeoutLoop runs at the interval set by 'timeout' and deletes filters
 // that have not been recently used. It is started when the API is created.
 func (api *FilterAPI) timeoutLoop(timeout time.Duration) {
@@ -251,11 +289,18 @@ func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 }
 
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
-func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
+//
+// If subscription options are given, the subscription is resumable: it starts
+// delivering LogEvents from the given block or cursor, backfilling historical
+// logs before following the chain, and announces reorganisations explicitly.
+func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria, opts *LogSubscriptionOptions) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	if opts != nil {
+		return api.resumableLogs(ctx, notifier, crit, opts)
+	}
 
 	var (
 		rpcSub      = notifier.CreateSubscription()
@@ -285,6 +330,26 @@ func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subsc
 	return rpcSub, nil
 }
 
+// resumableLogs creates a resumable log subscription.
+func (api *FilterAPI) resumableLogs(ctx context.Context, notifier *rpc.Notifier, crit FilterCriteria, opts *LogSubscriptionOptions) (*rpc.Subscription, error) {
+	follower, err := newLogFollower(ctx, api.sys, crit, opts)
+	if err != nil {
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+	follower.send = func(ev *LogEvent) error {
+		return notifier.Notify(rpcSub.ID, ev)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-rpcSub.Err() // client unsubscribed or connection closed
+		cancel()
+	}()
+	go follower.run(ctx)
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 // Same as ethereum.FilterQuery but with UnmarshalJSON() method.
 type FilterCriteria ethereum.FilterQuery
@@ -332,37 +397,212 @@ func (api *FilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
 	return logsSub.ID, nil
 }
 
+// LogPageOptions requests a single page of results from eth_getLogs. The page
+// holds at most Limit logs, capped at maxLogPageLimit, and a cursor to request
+// the next page with. The query is continued from the block of the last
+// delivered log, which must still be part of the canonical chain.
+type LogPageOptions struct {
+	Limit  hexutil.Uint64 `json:"limit"`  // Maximum number of logs to return
+	Cursor hexutil.Bytes  `json:"cursor"` // Continuation token of the previous page, if any
+}
+
+// LogPage is a page of eth_getLogs results.
+type LogPage struct {
+	Logs   []*types.Log  `json:"logs"`
+	Cursor hexutil.Bytes `json:"cursor,omitempty"` // Continuation token, absent on the last page
+}
+
+// logCursor is the decoded continuation token of a log page, identifying the
+// last log delivered and the criteria of the query.
+type logCursor struct {
+	Number uint64      // Block number of the last delivered log
+	Hash   common.Hash // Block hash of the last delivered log
+	Index  uint        // Index of the last delivered log within its block
+	Filter common.Hash // Hash of the address and topic criteria
+}
+
 // GetLogs returns logs matching the given argument that are stored within the state.
 func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	stream, err := api.streamLogs(ctx, crit)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := stream.Collect()
+	return returnLogs(logs), err
+}
+
+// streamLogs returns a stream of the logs matching the given argument.
+func (api *FilterAPI) streamLogs(ctx context.Context, crit FilterCriteria) (rpc.Stream[*types.Log], error) {
 	if len(crit.Topics) > maxTopics {
 		return nil, errExceedMaxTopics
 	}
-	var filter *Filter
-	if crit.BlockHash != nil {
-		// Block filter requested, construct a single-shot filter
-		filter = api.sys.NewBlockFilter(*crit.BlockHash, crit.Addresses, crit.Topics)
-	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if crit.FromBlock != nil {
-			begin = crit.FromBlock.Int64()
+	// Serve the request from the response cache if it covers finalized blocks only
+	var (
+		cache = api.sys.cfg.ResponseCache
+		key   common.Hash
+	)
+	if cache != nil {
+		key = logsCacheKey(crit)
+		var cached []*types.Log
+		if blob, ok := cache.Get(key); ok && json.Unmarshal(blob, &cached) == nil {
+			return rpc.SliceStream(cached), nil
 		}
-		end := rpc.LatestBlockNumber.Int64()
-		if crit.ToBlock != nil {
-			end = crit.ToBlock.Int64()
+	}
+	filter, err := api.newFilter(crit)
+	if err != nil {
+		return nil, err
+	}
+	// Run the filter and stream back all the logs
+	stream, err := filter.Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key == (common.Hash{}) || !api.immutableRange(ctx, crit) {
+		return stream, nil
+	}
+	// Gather the logs while streaming them, to store once the search is done
+	return func(yield func(*types.Log) error) error {
+		logs := []*types.Log{}
+		err := stream(func(log *types.Log) error {
+			logs = append(logs, log)
+			return yield(log)
+		})
+		if err == nil {
+			cache.Put(key, logs)
 		}
-		if begin > 0 && end > 0 && begin > end {
-			return nil, errInvalidBlockRange
+		return err
+	}, nil
+}
+
+// getLogsPage returns a single page of the logs matching the given criteria.
+func (api *FilterAPI) getLogsPage(ctx context.Context, crit FilterCriteria, page LogPageOptions) (*LogPage, error) {
+	if len(crit.Topics) > maxTopics {
+		return nil, errExceedMaxTopics
+	}
+	if page.Limit == 0 {
+		return nil, errInvalidPageLimit
+	}
+	page.Limit = min(page.Limit, maxLogPageLimit)
+	var (
+		hash   = logsFilterHash(crit)
+		cursor *logCursor
+	)
+	if len(page.Cursor) > 0 {
+		cursor = new(logCursor)
+		if err := rlp.DecodeBytes(page.Cursor, cursor); err != nil || cursor.Filter != hash {
+			return nil, errInvalidCursor
+		}
+		// Resume from the block of the last delivered log, if it's still canonical
+		header, err := api.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(cursor.Number))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil || header.Hash() != cursor.Hash {
+			return nil, errCursorReorged
+		}
+		if crit.BlockHash == nil {
+			crit.FromBlock = new(big.Int).SetUint64(cursor.Number)
 		}
-		// Construct the range filter
-		filter = api.sys.NewRangeFilter(begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
+	filter, err := api.newFilter(crit)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), err
+	stream, err := filter.Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &LogPage{Logs: []*types.Log{}}
+	err = stream(func(log *types.Log) error {
+		if cursor != nil && log.BlockNumber == cursor.Number && log.Index <= cursor.Index {
+			return nil // delivered in a previous page
+		}
+		if uint64(len(result.Logs)) == uint64(page.Limit) {
+			return errPageFull
+		}
+		result.Logs = append(result.Logs, log)
+		return nil
+	})
+	switch {
+	case errors.Is(err, errPageFull):
+		last := result.Logs[len(result.Logs)-1]
+		result.Cursor, _ = rlp.EncodeToBytes(&logCursor{
+			Number: last.BlockNumber,
+			Hash:   last.BlockHash,
+			Index:  last.Index,
+			Filter: hash,
+		})
+	case err != nil:
+		return nil, err
+	}
+	return result, nil
+}
+
+// newFilter creates the filter searching the logs matching the given criteria.
+func (api *FilterAPI) newFilter(crit FilterCriteria) (*Filter, error) {
+	if crit.BlockHash != nil {
+		// Block filter requested, construct a single-shot filter
+		return api.sys.NewBlockFilter(*crit.BlockHash, crit.Addresses, crit.Topics), nil
+	}
+	// Convert the RPC block numbers into internal representations
+	begin := rpc.LatestBlockNumber.Int64()
+	if crit.FromBlock != nil {
+		begin = crit.FromBlock.Int64()
+	}
+	end := rpc.LatestBlockNumber.Int64()
+	if crit.ToBlock != nil {
+		end = crit.ToBlock.Int64()
+	}
+	if begin > 0 && end > 0 && begin > end {
+		return nil, errInvalidBlockRange
+	}
+	// Construct the range filter
+	return api.sys.NewRangeFilter(begin, end, crit.Addresses, crit.Topics), nil
+}
+
+// logsFilterHash derives the hash of the address and topic criteria of a log
+// query, binding page cursors to the query they were issued for.
+func logsFilterHash(crit FilterCriteria) common.Hash {
+	var block common.Hash
+	if crit.BlockHash != nil {
+		block = *crit.BlockHash
+	}
+	blob, _ := rlp.EncodeToBytes([]interface{}{block, crit.Addresses, crit.Topics})
+	return crypto.Keccak256Hash(blob)
+}
+
+// logsCacheKey derives the response cache key of a log query. Queries which are
+// relative to the chain head are not cacheable and yield the zero hash.
+func logsCacheKey(crit FilterCriteria) common.Hash {
+	// Address and topic positions are OR-filters, so their order does not matter
+	addresses := slices.Clone(crit.Addresses)
+	slices.SortFunc(addresses, func(a, b common.Address) int { return a.Cmp(b) })
+
+	topics := make([][]common.Hash, len(crit.Topics))
+	for i, sub := range crit.Topics {
+		topics[i] = slices.Clone(sub)
+		slices.SortFunc(topics[i], func(a, b common.Hash) int { return a.Cmp(b) })
+	}
+	if crit.BlockHash != nil {
+		return ethapi.ResponseCacheKey("eth_getLogs", *crit.BlockHash, addresses, topics)
+	}
+	if crit.FromBlock == nil || crit.ToBlock == nil || crit.FromBlock.Sign() < 0 || crit.ToBlock.Sign() < 0 {
+		return common.Hash{}
+	}
+	return ethapi.ResponseCacheKey("eth_getLogs", crit.FromBlock.Uint64(), crit.ToBlock.Uint64(), addresses, topics)
+}
+
+// immutableRange reports whether all blocks queried by crit are finalized.
+func (api *FilterAPI) immutableRange(ctx context.Context, crit FilterCriteria) bool {
+	cache := api.sys.cfg.ResponseCache
+	if crit.BlockHash != nil {
+		header, err := api.sys.backend.HeaderByHash(ctx, *crit.BlockHash)
+		if err != nil || header == nil {
+			return false
+		}
+		return cache.Immutable(ctx, api.sys.backend, header.Number.Uint64(), header.Hash())
+	}
+	return cache.Immutable(ctx, api.sys.backend, crit.ToBlock.Uint64(), common.Hash{})
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -383,6 +623,16 @@ func (api *FilterAPI) UninstallFilter(id rpc.ID) bool {
 // GetFilterLogs returns the logs for the filter with the given id.
 // If the filter could not be found an empty array of logs is returned.
 func (api *FilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+	stream, err := api.streamFilterLogs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := stream.Collect()
+	return returnLogs(logs), err
+}
+
+// streamFilterLogs returns a stream of the logs for the filter with the given id.
+func (api *FilterAPI) streamFilterLogs(ctx context.Context, id rpc.ID) (rpc.Stream[*types.Log], error) {
 	api.filtersMu.Lock()
 	f, found := api.filters[id]
 	api.filtersMu.Unlock()
@@ -408,12 +658,8 @@ func (api *FilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Lo
 		// Construct the range filter
 		filter = api.sys.NewRangeFilter(begin, end, f.crit.Addresses, f.crit.Topics)
 	}
-	// Run the filter and return all the logs
-	logs, err := filter.Logs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return returnLogs(logs), nil
+	// Run the filter and stream back all the logs
+	return filter.Stream(ctx)
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
@@ -24,6 +24,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/logindex"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -96,6 +97,18 @@ func newFilter(sys *FilterSystem, addresses []common.Address, topics [][]common.
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
+	stream, err := f.Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stream.Collect()
+}
+
+// Stream is like Logs, but delivers the matching log entries one by one while the
+// search progresses, instead of gathering them in memory. Errors resolving the
+// search range are returned immediately, errors during the search terminate the
+// stream.
+func (f *Filter) Stream(ctx context.Context) (rpc.Stream[*types.Log], error) {
 	// If we're doing singleton block filtering, execute and return
 	if f.block != nil {
 		header, err := f.sys.backend.HeaderByHash(ctx, *f.block)
@@ -105,7 +118,18 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		if header == nil {
 			return nil, errors.New("unknown block")
 		}
-		return f.blockLogs(ctx, header)
+		return func(yield func(*types.Log) error) error {
+			found, err := f.blockLogs(ctx, header)
+			if err != nil {
+				return err
+			}
+			for _, log := range found {
+				if err := yield(log); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
 	}
 
 	// Disallow pending logs.
@@ -147,63 +171,64 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end, err = resolveSpecial(f.end); err != nil {
 		return nil, err
 	}
-
-	logChan, errChan := f.rangeLogsAsync(ctx)
-	var logs []*types.Log
-	for {
-		select {
-		case log := <-logChan:
-			logs = append(logs, log)
-		case err := <-errChan:
-			return logs, err
-		}
-	}
+	return f.rangeLogs(ctx), nil
 }
 
-// rangeLogsAsync retrieves block-range logs that match the filter criteria asynchronously,
-// it creates and returns two channels: one for delivering log data, and one for reporting errors.
-func (f *Filter) rangeLogsAsync(ctx context.Context) (chan *types.Log, chan error) {
-	var (
-		logChan = make(chan *types.Log)
-		errChan = make(chan error)
-	)
-
-	go func() {
-		defer func() {
-			close(errChan)
-			close(logChan)
-		}()
-
-		// Gather all indexed logs, and finish with non indexed ones
-		var (
-			end            = uint64(f.end)
-			size, sections = f.sys.backend.BloomStatus()
-			err            error
-		)
-		if indexed := sections * size; indexed > uint64(f.begin) {
+// rangeLogs returns a stream of the block-range logs that match the filter criteria.
+func (f *Filter) rangeLogs(ctx context.Context) rpc.Stream[*types.Log] {
+	return func(yield func(*types.Log) error) error {
+		// Gather all logs covered by the log index first
+		end := uint64(f.end)
+		if index := f.sys.backend.LogIndex(); index != nil && f.begin <= f.end {
+			if err := f.logIndexLogs(ctx, index, end, yield); err != nil {
+				return err
+			}
+		}
+		// Continue with the legacy bloombits, and finish with non indexed logs
+		size, sections := f.sys.backend.BloomStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) && f.begin <= f.end {
 			if indexed > end {
 				indexed = end + 1
 			}
-			if err = f.indexedLogs(ctx, indexed-1, logChan); err != nil {
-				errChan <- err
-				return
+			if err := f.indexedLogs(ctx, indexed-1, yield); err != nil {
+				return err
 			}
 		}
+		return f.unindexedLogs(ctx, end, yield)
+	}
+}
 
-		if err := f.unindexedLogs(ctx, end, logChan); err != nil {
-			errChan <- err
-			return
+// logIndexLogs returns the logs matching the filter criteria based on the log
+// index, up to the end of the range or the last indexed block.
+func (f *Filter) logIndexLogs(ctx context.Context, index *logindex.Indexer, end uint64, yield func(*types.Log) error) error {
+	next, err := index.Search(ctx, uint64(f.begin), end, f.addresses, f.topics, func(number uint64) error {
+		// Retrieve the suggested block and pull any truly matching logs
+		header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if header == nil || err != nil {
+			return err // block rewound since the search started, or lookup failure
 		}
-
-		errChan <- nil
-	}()
-
-	return logChan, errChan
+		found, err := f.checkMatches(ctx, header)
+		if err != nil {
+			return err
+		}
+		for _, log := range found {
+			if err := yield(log); err != nil {
+				return err
+			}
+		}
+		f.begin = int64(number) + 1
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.begin = int64(next)
+	return nil
 }
 
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
-func (f *Filter) indexedLogs(ctx context.Context, end uint64, logChan chan *types.Log) error {
+func (f *Filter) indexedLogs(ctx context.Context, end uint64, yield func(*types.Log) error) error {
 	// Create a matcher session and request servicing from the backend
 	matches := make(chan uint64, 64)
 
@@ -238,7 +263,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64, logChan chan *type
 				return err
 			}
 			for _, log := range found {
-				logChan <- log
+				if err := yield(log); err != nil {
+					return err
+				}
 			}
 
 		case <-ctx.Done():
@@ -249,8 +276,11 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64, logChan chan *type
 
 // unindexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64, logChan chan *types.Log) error {
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, yield func(*types.Log) error) error {
 	for ; f.begin <= int64(end); f.begin++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
 			return err
@@ -260,10 +290,8 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64, logChan chan *ty
 			return err
 		}
 		for _, log := range found {
-			select {
-			case logChan <- log:
-			case <-ctx.Done():
-				return ctx.Err()
+			if err := yield(log); err != nil {
+				return err
 			}
 		}
 	}
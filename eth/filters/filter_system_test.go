@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/logindex"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -42,6 +43,7 @@ import (
 type testBackend struct {
 	db              ethdb.Database
 	sections        uint64
+	logIndex        *logindex.Indexer
 	txFeed          event.Feed
 	logsFeed        event.Feed
 	rmLogsFeed      event.Feed
@@ -141,6 +143,10 @@ func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
 
+func (b *testBackend) LogIndex() *logindex.Indexer {
+	return b.logIndex
+}
+
 func (b *testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	requests := make(chan chan *bloombits.Retrieval)
 
@@ -628,3 +634,31 @@ func TestPendingTxFilterDeadlock(t *testing.T) {
 		}
 	}
 }
+
+// TestFilterService tests that the RPC service serves the methods of the filter
+// API, including the streamed ones.
+func TestFilterService(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		_, sys = newTestFilterSystem(t, db, Config{})
+		server = rpc.NewServer()
+	)
+	defer server.Stop()
+	if err := server.RegisterName("eth", NewFilterService(sys)); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var logs []*types.Log
+	err := client.Call(&logs, "eth_getLogs", map[string]interface{}{"fromBlock": "0x2", "toBlock": "0x1"})
+	if err == nil || err.Error() != errInvalidBlockRange.Error() {
+		t.Errorf("wrong error for invalid range: %v", err)
+	}
+	var id rpc.ID
+	if err := client.Call(&id, "eth_newBlockFilter"); err != nil {
+		t.Errorf("failed to call unstreamed method: %v", err)
+	}
+}
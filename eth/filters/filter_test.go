@@ -26,13 +26,17 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/logindex"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/triedb"
 )
@@ -387,3 +391,376 @@ func TestFilters(t *testing.T) {
 		}
 	})
 }
+
+// logTestChain is a chain of blocks with two logger contracts emitting their
+// calldata as single topic, indexed by a log index with epochs of 64 blocks.
+type logTestChain struct {
+	backend *testBackend
+	sys     *FilterSystem
+	chain   *core.BlockChain
+	genDb   ethdb.Database
+	loggers []common.Address
+	blocks  []*types.Block
+}
+
+var logTestKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// newLogTestChain creates a log test chain of 300 blocks.
+func newLogTestChain(t *testing.T) *logTestChain {
+	var (
+		db           = rawdb.NewMemoryDatabase()
+		backend, sys = newTestFilterSystem(t, db, Config{})
+		loggers      = []common.Address{{0xaa}, {0xbb}}
+		gspec        = &core.Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   types.GenesisAlloc{crypto.PubkeyToAddress(logTestKey.PublicKey): {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+	)
+	for _, logger := range loggers {
+		gspec.Alloc[logger] = types.Account{Code: []byte{0x60, 0x00, 0x35, 0x60, 0x00, 0x60, 0x00, 0xa1, 0x00}}
+	}
+	c := &logTestChain{backend: backend, sys: sys, loggers: loggers}
+	c.genDb, c.blocks, _ = core.GenerateChainWithGenesis(gspec, ethash.NewFaker(), 300, c.generate(0))
+
+	bc, err := core.NewBlockChain(db, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bc.Stop)
+	if _, err := bc.InsertChain(c.blocks); err != nil {
+		t.Fatal(err)
+	}
+	c.chain = bc
+
+	backend.logIndex = logindex.NewIndexer(db, 64)
+	if err := backend.logIndex.Update(bc.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// generate returns a block generator emitting i%3 logs in the i-th block, with
+// the salt shifting the topics.
+func (c *logTestChain) generate(salt int) func(int, *core.BlockGen) {
+	var (
+		addr   = crypto.PubkeyToAddress(logTestKey.PublicKey)
+		signer = types.LatestSigner(params.TestChainConfig)
+	)
+	return func(i int, gen *core.BlockGen) {
+		for j := 0; j < i%3; j++ {
+			tx, _ := types.SignNewTx(logTestKey, signer, &types.LegacyTx{
+				Nonce:    gen.TxNonce(addr),
+				To:       &c.loggers[(i+j)%2],
+				Gas:      50000,
+				GasPrice: gen.BaseFee(),
+				Data:     common.Hash{byte((i + salt) % 5)}.Bytes(),
+			})
+			gen.AddTx(tx)
+		}
+	}
+}
+
+// extend inserts n blocks on top of the given parent, updating the log index
+// and notifying about the new head.
+func (c *logTestChain) extend(t *testing.T, parent *types.Block, n int, salt int) []*types.Block {
+	blocks, _ := core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), c.genDb, n, c.generate(salt))
+	if _, err := c.chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.backend.logIndex.Update(c.chain.CurrentBlock()); err != nil {
+		t.Fatal(err)
+	}
+	c.backend.chainFeed.Send(core.ChainEvent{})
+	return blocks
+}
+
+func TestLogIndexFilter(t *testing.T) {
+	var (
+		c       = newLogTestChain(t)
+		backend = c.backend
+		index   = backend.logIndex
+		loggers = c.loggers
+	)
+
+	for i, crit := range []FilterCriteria{
+		{Addresses: loggers[:1]},
+		{Topics: [][]common.Hash{{{1}, {3}}}},
+		{Addresses: loggers[1:], Topics: [][]common.Hash{{{4}}}},
+		{FromBlock: big.NewInt(50), ToBlock: big.NewInt(250), Addresses: loggers},
+		{FromBlock: big.NewInt(64), ToBlock: big.NewInt(64), Topics: [][]common.Hash{{{4}}}},
+		{Topics: [][]common.Hash{{{0xff}}}},
+		{FromBlock: big.NewInt(290), Topics: [][]common.Hash{{}}},
+	} {
+		logs := func(indexed bool) []*types.Log {
+			if indexed {
+				backend.logIndex = index
+			} else {
+				backend.logIndex = nil
+			}
+			begin, end := rpc.EarliestBlockNumber.Int64(), rpc.LatestBlockNumber.Int64()
+			if crit.FromBlock != nil {
+				begin = crit.FromBlock.Int64()
+			}
+			if crit.ToBlock != nil {
+				end = crit.ToBlock.Int64()
+			}
+			logs, err := c.sys.NewRangeFilter(begin, end, crit.Addresses, crit.Topics).Logs(context.Background())
+			if err != nil {
+				t.Fatalf("test %d: filter failed: %v", i, err)
+			}
+			return logs
+		}
+		want, _ := json.Marshal(logs(false))
+		have, _ := json.Marshal(logs(true))
+		if string(have) != string(want) {
+			t.Errorf("test %d: indexed logs mismatch\nhave: %s\nwant: %s", i, have, want)
+		}
+	}
+}
+
+func TestGetLogsPagination(t *testing.T) {
+	var (
+		c    = newLogTestChain(t)
+		api  = NewFilterAPI(c.sys)
+		crit = FilterCriteria{FromBlock: big.NewInt(10), Addresses: c.loggers, Topics: [][]common.Hash{{{1}, {2}}}}
+	)
+	all, err := c.sys.NewRangeFilter(10, rpc.LatestBlockNumber.Int64(), crit.Addresses, crit.Topics).Logs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Collect all logs page by page
+	var (
+		paged  []*types.Log
+		cursor hexutil.Bytes
+		pages  int
+	)
+	for {
+		page, err := api.getLogsPage(context.Background(), crit, LogPageOptions{Limit: 7, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		if len(page.Logs) > 7 {
+			t.Fatalf("page %d: too many logs: %d", pages, len(page.Logs))
+		}
+		paged = append(paged, page.Logs...)
+		pages++
+		if page.Cursor == nil {
+			break
+		}
+		cursor = page.Cursor
+	}
+	have, _ := json.Marshal(paged)
+	want, _ := json.Marshal(all)
+	if string(have) != string(want) {
+		t.Fatalf("paged logs mismatch\nhave: %s\nwant: %s", have, want)
+	}
+	if want := (len(all) + 6) / 7; pages != want {
+		t.Errorf("wrong number of pages: have %d, want %d", pages, want)
+	}
+	// Cursors are bound to their query and to the canonical chain
+	first, _ := api.getLogsPage(context.Background(), crit, LogPageOptions{Limit: 1})
+
+	other := crit
+	other.Topics = [][]common.Hash{{{1}}}
+	if _, err := api.getLogsPage(context.Background(), other, LogPageOptions{Limit: 1, Cursor: first.Cursor}); err != errInvalidCursor {
+		t.Errorf("cursor of another query accepted: %v", err)
+	}
+	forged, _ := rlp.EncodeToBytes(&logCursor{
+		Number: first.Logs[0].BlockNumber,
+		Hash:   c.blocks[0].Hash(),
+		Filter: logsFilterHash(crit),
+	})
+	if _, err := api.getLogsPage(context.Background(), crit, LogPageOptions{Limit: 1, Cursor: forged}); err != errCursorReorged {
+		t.Errorf("non-canonical cursor accepted: %v", err)
+	}
+	if _, err := api.getLogsPage(context.Background(), crit, LogPageOptions{}); err != errInvalidPageLimit {
+		t.Errorf("zero page limit accepted: %v", err)
+	}
+	// Pages are served by eth_getLogs, with limits beyond the maximum capped
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", NewFilterService(c.sys)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	rpcCrit := map[string]interface{}{"fromBlock": "0xa", "address": crit.Addresses, "topics": crit.Topics}
+	var page LogPage
+	if err := client.Call(&page, "eth_getLogs", rpcCrit, map[string]interface{}{"limit": "0x7"}); err != nil {
+		t.Fatalf("failed to get page over RPC: %v", err)
+	}
+	if len(page.Logs) != 7 || page.Cursor == nil {
+		t.Errorf("wrong page over RPC: %d logs, cursor %x", len(page.Logs), page.Cursor)
+	}
+	if err := client.Call(&page, "eth_getLogs", rpcCrit, map[string]interface{}{"limit": "0xffffffffffffffff"}); err != nil {
+		t.Fatalf("failed to get page with large limit: %v", err)
+	}
+	if len(page.Logs) != len(all) {
+		t.Errorf("wrong page for large limit: have %d logs, want %d", len(page.Logs), len(all))
+	}
+}
+
+func TestResumableLogSubscription(t *testing.T) {
+	var (
+		c    = newLogTestChain(t)
+		crit = map[string]interface{}{"address": c.loggers[:1]}
+		srv  = rpc.NewServer()
+	)
+	defer srv.Stop()
+	if err := srv.RegisterName("eth", NewFilterAPI(c.sys)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(srv)
+	defer client.Close()
+
+	subscribe := func(opts map[string]interface{}) (chan *LogEvent, *rpc.ClientSubscription) {
+		ch := make(chan *LogEvent, 1024)
+		sub, err := client.EthSubscribe(context.Background(), ch, "logs", crit, opts)
+		if err != nil {
+			t.Fatalf("failed to subscribe: %v", err)
+		}
+		return ch, sub
+	}
+	collect := func(ch chan *LogEvent, n int) []*LogEvent {
+		var events []*LogEvent
+		for len(events) < n {
+			select {
+			case ev := <-ch:
+				events = append(events, ev)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timeout after %d of %d events", len(events), n)
+			}
+		}
+		select {
+		case ev := <-ch:
+			t.Fatalf("unexpected event: %+v", ev)
+		case <-time.After(100 * time.Millisecond):
+		}
+		return events
+	}
+	expect := func(events []*LogEvent, from, to uint64) {
+		t.Helper()
+		want, err := c.sys.NewRangeFilter(int64(from), int64(to), c.loggers[:1], nil).Logs(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var have []*types.Log
+		for _, ev := range events {
+			if ev.Type != LogEventLog {
+				t.Fatalf("unexpected event type %q", ev.Type)
+			}
+			have = append(have, ev.Log)
+		}
+		haveJSON, _ := json.Marshal(have)
+		wantJSON, _ := json.Marshal(want)
+		if string(haveJSON) != string(wantJSON) {
+			t.Fatalf("wrong logs for blocks %d-%d\nhave: %s\nwant: %s", from, to, haveJSON, wantJSON)
+		}
+	}
+	count := func(from, to uint64) int {
+		logs, _ := c.sys.NewRangeFilter(int64(from), int64(to), c.loggers[:1], nil).Logs(context.Background())
+		return len(logs)
+	}
+	// Backfill from a past block
+	ch, sub := subscribe(map[string]interface{}{"fromBlock": "0xfa"})
+	events := collect(ch, count(250, 300))
+	expect(events, 250, 300)
+	sub.Unsubscribe()
+
+	// Resume from the cursor of an event in the middle
+	k := len(events) / 2
+	ch, sub = subscribe(map[string]interface{}{"cursor": events[k].Cursor})
+	defer sub.Unsubscribe()
+	resumed := collect(ch, len(events)-k-1)
+	if have, want := eventsJSON(resumed), eventsJSON(events[k+1:]); have != want {
+		t.Fatalf("wrong resumed events\nhave: %s\nwant: %s", have, want)
+	}
+
+	// Follow the chain through a reorganisation
+	confCh, confSub := subscribe(map[string]interface{}{"fromBlock": "0x0", "confirmations": "0xa"})
+	defer confSub.Unsubscribe()
+	expect(collect(confCh, count(1, 290)), 1, 290)
+
+	c.extend(t, c.blocks[289], 15, 1)
+	events = collect(ch, 1+count(291, 305))
+	if ev := events[0]; ev.Type != LogEventReorg || ev.Ancestor == nil || uint64(ev.Ancestor.Number) != 290 || ev.Ancestor.Hash != c.blocks[289].Hash() {
+		t.Fatalf("wrong reorg event: %+v", ev)
+	}
+	expect(events[1:], 291, 305)
+
+	// The confirmed subscription is not affected by the shallow reorg
+	expect(collect(confCh, count(291, 295)), 291, 295)
+
+	// Cursors are bound to their criteria
+	_, err := client.EthSubscribe(context.Background(), make(chan *LogEvent), "logs", map[string]interface{}{}, map[string]interface{}{"cursor": events[1].Cursor})
+	if err == nil || err.Error() != errInvalidCursor.Error() {
+		t.Fatalf("cursor of another criteria accepted: %v", err)
+	}
+	_, err = client.EthSubscribe(context.Background(), make(chan *LogEvent), "logs", crit, map[string]interface{}{"cursor": events[1].Cursor, "fromBlock": "0x1"})
+	if err == nil || err.Error() != errCursorAndFromBlock.Error() {
+		t.Fatalf("cursor and fromBlock accepted: %v", err)
+	}
+}
+
+// Tests that log followers postpone the delivery of logs if the searched range
+// doesn't extend the last delivered block or isn't available yet, instead of
+// retrying immediately.
+func TestLogFollowerChainChanged(t *testing.T) {
+	c := newLogTestChain(t)
+	f, err := newLogFollower(context.Background(), c.sys, FilterCriteria{Addresses: c.loggers[:1]}, &LogSubscriptionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.send = func(*LogEvent) error {
+		t.Fatal("unexpected delivery")
+		return nil
+	}
+	f.number, f.block = 10, c.blocks[9].Hash()
+
+	for _, r := range [][2]uint64{{12, 20}, {11, 400}, {301, 310}} {
+		if err := f.deliverRange(context.Background(), r[0], r[1]); err != errChainChanged {
+			t.Errorf("range %d-%d: wrong error: have %v, want %v", r[0], r[1], err, errChainChanged)
+		}
+		if f.number != 10 || f.block != c.blocks[9].Hash() {
+			t.Fatalf("range %d-%d: follower advanced to %d", r[0], r[1], f.number)
+		}
+	}
+}
+
+// Tests that log followers end the subscription with an error event if they fail.
+func TestLogFollowerFailure(t *testing.T) {
+	c := newLogTestChain(t)
+	f, err := newLogFollower(context.Background(), c.sys, FilterCriteria{Addresses: c.loggers[:1]}, &LogSubscriptionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*LogEvent
+	f.send = func(ev *LogEvent) error {
+		events = append(events, ev)
+		return nil
+	}
+	// Position the follower on a block unknown to the chain
+	f.number, f.block = 10, common.Hash{1}
+
+	done := make(chan struct{})
+	go func() {
+		f.run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("follower didn't stop")
+	}
+	if len(events) != 1 || events[0].Type != LogEventError || events[0].Error == "" {
+		t.Fatalf("wrong events: %s", eventsJSON(events))
+	}
+}
+
+// eventsJSON returns the JSON encoding of log events, for comparing them.
+func eventsJSON(events []*LogEvent) string {
+	blob, _ := json.Marshal(events)
+	return string(blob)
+}
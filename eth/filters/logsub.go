@@ -0,0 +1,392 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// logFollowerBatch is the maximum number of blocks searched at once while a
+	// resumable log subscription catches up with the chain.
+	logFollowerBatch = 2048
+
+	// blockDelivered is the log index of cursors marking all logs of their block
+	// as delivered.
+	blockDelivered = math.MaxUint32
+)
+
+var (
+	errCursorAndFromBlock   = errors.New("cannot specify both cursor and fromBlock")
+	errFinalizedAndConfirms = errors.New("cannot specify both finalized and confirmations")
+	errSubscriptionRange    = errors.New("block range criteria not supported for resumable subscriptions, use the fromBlock option")
+
+	// errChainChanged is used internally to postpone the delivery of logs until
+	// the next chain head, if the chain changed or isn't fully available yet.
+	errChainChanged = errors.New("chain changed during log search")
+)
+
+// Types of the events delivered by resumable log subscriptions.
+const (
+	LogEventLog   = "log"   // A log of a newly delivered block
+	LogEventReorg = "reorg" // All logs above the ancestor block were removed
+	LogEventError = "error" // The subscription failed, no more events follow
+)
+
+// LogSubscriptionOptions turns a log subscription into a resumable one, which
+// backfills the logs of past blocks before following the chain.
+type LogSubscriptionOptions struct {
+	FromBlock     *rpc.BlockNumber `json:"fromBlock"`     // First block to deliver the logs of
+	Cursor        hexutil.Bytes    `json:"cursor"`        // Cursor of the last event delivered before
+	Confirmations *hexutil.Uint64  `json:"confirmations"` // Number of blocks to wait for before delivering logs
+	Finalized     bool             `json:"finalized"`     // Whether to deliver the logs of finalized blocks only
+}
+
+// LogEvent is a notification of a resumable log subscription.
+//
+// Every event carries a cursor which restarts the subscription right after the
+// event. Reorg events announce that all logs delivered for blocks above the
+// ancestor are no longer part of the canonical chain. Error events end the
+// subscription, their cursor resumes it after the last delivered event.
+type LogEvent struct {
+	Type     string        `json:"type"`
+	Log      *types.Log    `json:"log,omitempty"`
+	Ancestor *LogAncestor  `json:"ancestor,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Cursor   hexutil.Bytes `json:"cursor"`
+}
+
+// LogAncestor identifies the common ancestor of a chain reorganisation.
+type LogAncestor struct {
+	Number hexutil.Uint64 `json:"number"`
+	Hash   common.Hash    `json:"hash"`
+}
+
+// logFollower delivers the logs of the canonical chain matching a criteria in
+// order, announcing reorganisations of already delivered blocks.
+type logFollower struct {
+	sys  *FilterSystem
+	crit FilterCriteria
+	opts *LogSubscriptionOptions
+	hash common.Hash // Hash of the criteria, binding cursors to the subscription
+	send func(*LogEvent) error
+
+	// Position of the last delivered event
+	number uint64      // Number of the last block with delivered logs
+	block  common.Hash // Hash of the last block with delivered logs
+	index  uint        // Index of the last delivered log within the block
+}
+
+// newLogFollower creates a log follower, resolving its starting position from
+// the subscription options.
+func newLogFollower(ctx context.Context, sys *FilterSystem, crit FilterCriteria, opts *LogSubscriptionOptions) (*logFollower, error) {
+	if len(crit.Topics) > maxTopics {
+		return nil, errExceedMaxTopics
+	}
+	if crit.BlockHash != nil || crit.FromBlock != nil || crit.ToBlock != nil {
+		return nil, errSubscriptionRange
+	}
+	if opts.FromBlock != nil && len(opts.Cursor) > 0 {
+		return nil, errCursorAndFromBlock
+	}
+	if opts.Finalized && opts.Confirmations != nil {
+		return nil, errFinalizedAndConfirms
+	}
+	f := &logFollower{
+		sys:   sys,
+		crit:  crit,
+		opts:  opts,
+		hash:  logsFilterHash(crit),
+		index: blockDelivered,
+	}
+	var (
+		start *types.Header
+		err   error
+	)
+	switch {
+	case len(opts.Cursor) > 0:
+		cursor := new(logCursor)
+		if err := rlp.DecodeBytes(opts.Cursor, cursor); err != nil || cursor.Filter != f.hash {
+			return nil, errInvalidCursor
+		}
+		if header, _ := sys.backend.HeaderByHash(ctx, cursor.Hash); header == nil || header.Number.Uint64() != cursor.Number {
+			return nil, errInvalidCursor
+		}
+		// Resume right after the event, even if its block was reorged since
+		f.number, f.block, f.index = cursor.Number, cursor.Hash, cursor.Index
+		return f, nil
+
+	case opts.FromBlock != nil:
+		if *opts.FromBlock == rpc.PendingBlockNumber {
+			return nil, errPendingLogsUnsupported
+		}
+		if start, err = sys.backend.HeaderByNumber(ctx, *opts.FromBlock); err != nil {
+			return nil, err
+		}
+		if start == nil {
+			return nil, fmt.Errorf("block %d not found", *opts.FromBlock)
+		}
+		// Start from the parent, so the logs of the first block are delivered
+		if start.Number.Uint64() > 0 {
+			if start, err = sys.backend.HeaderByHash(ctx, start.ParentHash); err != nil {
+				return nil, err
+			}
+			if start == nil {
+				return nil, errors.New("parent of first block not found")
+			}
+		}
+
+	default:
+		// Only deliver the logs of blocks becoming available in the future
+		if start, err = f.target(ctx); err != nil {
+			return nil, err
+		}
+		if start == nil {
+			start = sys.backend.CurrentHeader()
+		}
+	}
+	f.number, f.block = start.Number.Uint64(), start.Hash()
+	return f, nil
+}
+
+// target retrieves the last block whose logs may be delivered, or nil if there
+// is none yet.
+func (f *logFollower) target(ctx context.Context) (*types.Header, error) {
+	if f.opts.Finalized {
+		// Finalized blocks might not exist yet, which is not an error
+		header, _ := f.sys.backend.HeaderByNumber(ctx, rpc.FinalizedBlockNumber)
+		return header, nil
+	}
+	head := f.sys.backend.CurrentHeader()
+	if f.opts.Confirmations == nil || *f.opts.Confirmations == 0 {
+		return head, nil
+	}
+	confirms := uint64(*f.opts.Confirmations)
+	if head.Number.Uint64() < confirms {
+		return nil, nil
+	}
+	return f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(head.Number.Uint64()-confirms))
+}
+
+// run delivers the logs of the chain whenever its head changes, until the
+// context is cancelled or the events can't be delivered anymore. If the follower
+// fails otherwise, an error event ends the subscription.
+func (f *logFollower) run(ctx context.Context) {
+	heads := make(chan core.ChainEvent, 16)
+	sub := f.sys.backend.SubscribeChainEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		if err := f.sync(ctx); err != nil {
+			if ctx.Err() == nil {
+				f.fail(err)
+			}
+			return
+		}
+		select {
+		case <-heads:
+			for len(heads) > 0 {
+				<-heads
+			}
+		case err := <-sub.Err():
+			if err == nil {
+				err = errors.New("chain event subscription closed")
+			}
+			f.fail(err)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fail reports the failure of the follower to the subscriber.
+func (f *logFollower) fail(err error) {
+	log.Debug("Resumable log subscription failed", "err", err)
+	f.send(&LogEvent{Type: LogEventError, Error: err.Error(), Cursor: f.cursor()})
+}
+
+// sync delivers all logs up to the current target block.
+func (f *logFollower) sync(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Announce the removal of delivered logs which are not canonical anymore
+		if err := f.checkReorg(ctx); err != nil {
+			return err
+		}
+		target, err := f.target(ctx)
+		if err != nil {
+			return err
+		}
+		if target == nil || target.Number.Uint64() < f.number {
+			return nil
+		}
+		// Deliver the rest of a partially delivered block
+		if f.index != blockDelivered {
+			logs, err := f.sys.NewBlockFilter(f.block, f.crit.Addresses, f.crit.Topics).Logs(ctx)
+			if err != nil {
+				return err
+			}
+			for _, l := range logs {
+				if l.Index > f.index {
+					if err := f.deliver(l); err != nil {
+						return err
+					}
+				}
+			}
+			f.index = blockDelivered
+		}
+		if target.Number.Uint64() == f.number {
+			return nil
+		}
+		// Deliver the logs of the next batch of blocks
+		to := min(target.Number.Uint64(), f.number+logFollowerBatch)
+		if err := f.deliverRange(ctx, f.number+1, to); err != nil {
+			if errors.Is(err, errChainChanged) {
+				return nil // retry on the next chain head
+			}
+			return err
+		}
+	}
+}
+
+// checkReorg checks whether the block of the last delivered event is still
+// canonical, announcing the common ancestor with the canonical chain if not.
+func (f *logFollower) checkReorg(ctx context.Context) error {
+	canonical := func(header *types.Header) (bool, error) {
+		canon, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(header.Number.Uint64()))
+		if err != nil {
+			return false, err
+		}
+		return canon != nil && canon.Hash() == header.Hash(), nil
+	}
+	header, err := f.sys.backend.HeaderByHash(ctx, f.block)
+	if err != nil {
+		return err
+	}
+	for {
+		if header == nil {
+			return fmt.Errorf("ancestor of reorged block %d [%x..] not found", f.number, f.block[:4])
+		}
+		ok, err := canonical(header)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if header, err = f.sys.backend.HeaderByHash(ctx, header.ParentHash); err != nil {
+			return err
+		}
+	}
+	if header.Hash() == f.block {
+		return nil
+	}
+	f.number, f.block, f.index = header.Number.Uint64(), header.Hash(), blockDelivered
+	return f.send(&LogEvent{
+		Type:     LogEventReorg,
+		Ancestor: &LogAncestor{Number: hexutil.Uint64(f.number), Hash: f.block},
+		Cursor:   f.cursor(),
+	})
+}
+
+// deliverRange delivers the logs of the given range of blocks extending the last
+// delivered one. Nothing is delivered and errChainChanged is returned if the
+// chain changes during the search, or the range isn't fully available yet.
+func (f *logFollower) deliverRange(ctx context.Context, from, to uint64) error {
+	first, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(from))
+	if err != nil {
+		return err
+	}
+	if first == nil || first.ParentHash != f.block {
+		return errChainChanged // reorg is handled on the next chain head
+	}
+	last, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(to))
+	if err != nil {
+		return err
+	}
+	if last == nil {
+		return errChainChanged
+	}
+	logs, err := f.sys.NewRangeFilter(int64(from), int64(to), f.crit.Addresses, f.crit.Topics).Logs(ctx)
+	if err != nil {
+		return err
+	}
+	// Make sure all logs are from the same chain segment
+	canon := map[uint64]common.Hash{from: first.Hash(), to: last.Hash()}
+	for _, l := range logs {
+		hash, ok := canon[l.BlockNumber]
+		if !ok {
+			header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(l.BlockNumber))
+			if err != nil {
+				return err
+			}
+			if header == nil {
+				return errChainChanged
+			}
+			hash = header.Hash()
+			canon[l.BlockNumber] = hash
+		}
+		if l.BlockHash != hash {
+			return errChainChanged // log index lags behind the canonical chain
+		}
+	}
+	header, err := f.sys.backend.HeaderByNumber(ctx, rpc.BlockNumber(to))
+	if err != nil {
+		return err
+	}
+	if header == nil || header.Hash() != last.Hash() {
+		return errChainChanged
+	}
+	for _, l := range logs {
+		if err := f.deliver(l); err != nil {
+			return err
+		}
+	}
+	f.number, f.block, f.index = to, last.Hash(), blockDelivered
+	return nil
+}
+
+// deliver sends a log event and advances the position of the follower.
+func (f *logFollower) deliver(l *types.Log) error {
+	f.number, f.block, f.index = l.BlockNumber, l.BlockHash, l.Index
+	return f.send(&LogEvent{Type: LogEventLog, Log: l, Cursor: f.cursor()})
+}
+
+// cursor encodes the position of the follower.
+func (f *logFollower) cursor() hexutil.Bytes {
+	blob, _ := rlp.EncodeToBytes(&logCursor{
+		Number: f.number,
+		Hash:   f.block,
+		Index:  f.index,
+		Filter: f.hash,
+	})
+	return blob
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasestimator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// maxAccessListTrials is the maximum number of access list entries tried to be
+// pruned by OptimizeAccessList, each trial being a full gas estimation.
+const maxAccessListTrials = 32
+
+// Breakdown is the composition of the gas required by a transaction.
+type Breakdown struct {
+	Gas           uint64 // Lowest gas limit the transaction succeeds with
+	UsedGas       uint64 // Gas charged for the transaction, after refunds
+	IntrinsicGas  uint64 // Gas charged before execution, including calldata and access list
+	CalldataGas   uint64 // Part of the intrinsic gas charged for the calldata (or init code)
+	AccessListGas uint64 // Part of the intrinsic gas charged for the access list
+	ExecutionGas  uint64 // Gas consumed by the execution, before refunds
+	RefundedGas   uint64 // Gas refunded after execution
+	BlobGas       uint64 // Blob gas consumed, charged separately from the gas
+
+	// LimitingFrame is the call frame failing first if the gas limit is lowered
+	// below the estimate, i.e. the frame which determines the gas limit. It is
+	// nil if the intrinsic gas determines it.
+	LimitingFrame *Frame
+}
+
+// Frame is a call frame of an execution.
+type Frame struct {
+	Depth   int            // Depth of the frame, zero for the transaction itself
+	Type    vm.OpCode      // Opcode entering the frame
+	From    common.Address // Caller of the frame
+	To      common.Address // Callee of the frame
+	Gas     uint64         // Gas available to the frame
+	GasUsed uint64         // Gas used by the frame
+	Err     error          // Error the frame failed with
+}
+
+// EstimateBreakdown estimates the lowest possible gas limit of the transaction
+// like Estimate, and breaks the gas charged for it down into its components. As
+// the breakdown is supposed to explain the gas limit, the estimation is exact
+// regardless of the allowed error ratio.
+func EstimateBreakdown(ctx context.Context, call *core.Message, opts *Options, gasCap uint64) (*Breakdown, []byte, error) {
+	exact := *opts
+	exact.ErrorRatio = 0
+
+	gas, revert, err := Estimate(ctx, call, &exact, gasCap)
+	if err != nil {
+		return nil, revert, err
+	}
+	// Execute the transaction with the estimated gas limit and with one gas less,
+	// finding the frame behaving differently due to the lack of gas.
+	pass := new(frameTracer)
+	_, result, err := execute(ctx, call, &exact, gas, pass.hooks())
+	if err != nil {
+		return nil, nil, err
+	}
+	if result == nil {
+		return nil, nil, errors.New("transaction failed with estimated gas limit")
+	}
+	var limit *Frame
+	fail := new(frameTracer)
+	if failed, _, err := execute(ctx, call, &exact, gas-1, fail.hooks()); err == nil && failed {
+		limit = fail.firstFailure(pass)
+	}
+	// Split the intrinsic gas into its components
+	var (
+		blockCtx = exact.blockContext()
+		rules    = exact.Config.Rules(blockCtx.BlockNumber, blockCtx.Random != nil, blockCtx.Time)
+		create   = call.To == nil
+	)
+	intrinsic := func(data []byte, accessList types.AccessList) uint64 {
+		gas, _ := core.IntrinsicGas(data, accessList, create, rules.IsHomestead, rules.IsIstanbul, rules.IsShanghai)
+		return gas
+	}
+	base := intrinsic(nil, nil)
+	breakdown := &Breakdown{
+		Gas:           gas,
+		UsedGas:       result.UsedGas,
+		IntrinsicGas:  intrinsic(call.Data, call.AccessList),
+		CalldataGas:   intrinsic(call.Data, nil) - base,
+		AccessListGas: intrinsic(nil, call.AccessList) - base,
+		RefundedGas:   result.RefundedGas,
+		BlobGas:       uint64(len(call.BlobHashes)) * params.BlobTxBlobGasPerBlob,
+		LimitingFrame: limit,
+	}
+	breakdown.ExecutionGas = result.UsedGas + result.RefundedGas - breakdown.IntrinsicGas
+	return breakdown, nil, nil
+}
+
+// OptimizeAccessList returns the subset of the candidate access list with which
+// the transaction requires the lowest gas limit, along with that gas limit.
+//
+// Accessing an account or slot in the access list is cheaper than accessing it
+// cold, but the entries themselves are charged for, so entries are only kept if
+// they pay off. The entries are pruned greedily, one account at a time. As every
+// trial runs a gas estimation, only the first maxAccessListTrials entries are
+// tried, the rest are kept.
+func OptimizeAccessList(ctx context.Context, call *core.Message, opts *Options, gasCap uint64, candidate types.AccessList) (types.AccessList, uint64, []byte, error) {
+	defer func(accessList types.AccessList) { call.AccessList = accessList }(call.AccessList)
+
+	exact := *opts
+	exact.ErrorRatio = 0
+
+	estimate := func(accessList types.AccessList) (uint64, []byte, error) {
+		call.AccessList = accessList
+		return Estimate(ctx, call, &exact, gasCap)
+	}
+	best := candidate
+	bestGas, revert, err := estimate(best)
+	if err != nil {
+		return nil, 0, revert, err
+	}
+	for i, trials := 0, 0; i < len(best) && trials < maxAccessListTrials; trials++ {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, nil, err
+		}
+		trial := slices.Delete(slices.Clone(best), i, i+1)
+		if gas, _, err := estimate(trial); err == nil && gas < bestGas {
+			best, bestGas = trial, gas
+			continue
+		}
+		i++
+	}
+	return best, bestGas, nil, nil
+}
+
+// frameTracer records the call frames of an execution.
+type frameTracer struct {
+	frames []*Frame // Frames in the order of entering
+	stack  []int    // Indices of the frames being executed
+	failed []int    // Indices of the failed frames in the order of exiting
+}
+
+func (t *frameTracer) hooks() *tracing.Hooks {
+	return &tracing.Hooks{
+		OnEnter: t.onEnter,
+		OnExit:  t.onExit,
+	}
+}
+
+func (t *frameTracer) onEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.stack = append(t.stack, len(t.frames))
+	t.frames = append(t.frames, &Frame{Depth: depth, Type: vm.OpCode(typ), From: from, To: to, Gas: gas})
+}
+
+func (t *frameTracer) onExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	index := t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+
+	t.frames[index].GasUsed = gasUsed
+	if err != nil {
+		t.frames[index].Err = err
+		t.failed = append(t.failed, index)
+	}
+}
+
+// firstFailure returns the first frame failing in this execution which did not
+// fail in the reference execution. As both executions are identical until the
+// first diverging frame, frames are matched by the order of entering.
+func (t *frameTracer) firstFailure(reference *frameTracer) *Frame {
+	for _, index := range t.failed {
+		if index >= len(reference.frames) || reference.frames[index].Err == nil {
+			return t.frames[index]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// DefaultBlobFeeHorizon is the number of blocks within which blob transactions
+	// are expected to be included if no horizon is requested.
+	DefaultBlobFeeHorizon = 6
+
+	// MaxBlobFeeHorizon is the maximum number of blocks blob fees are predicted for.
+	MaxBlobFeeHorizon = 128
+)
+
+// blobTrend is the trend of the excess blob gas at a given head.
+type blobTrend struct {
+	head common.Hash
+	next uint64 // Excess blob gas of the block after the head
+	rate int64  // Average change of the excess blob gas per block
+}
+
+// SuggestBlobFeeCap returns a blob fee cap so that blob transactions can be
+// included in any of the given number of blocks following the head. A zero
+// horizon selects the default one.
+//
+// The blob base fee of future blocks is predicted by extrapolating the trend of
+// the excess blob gas over the recent blocks. As the blob base fee of the next
+// block is known, the suggestion never falls below it.
+func (oracle *Oracle) SuggestBlobFeeCap(ctx context.Context, horizon uint64) (*big.Int, error) {
+	if horizon == 0 {
+		horizon = DefaultBlobFeeHorizon
+	}
+	horizon = min(horizon, MaxBlobFeeHorizon)
+
+	trend, err := oracle.blobTrend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Extrapolate the excess blob gas of the last block within the horizon
+	excess := int64(trend.next) + trend.rate*int64(horizon-1)
+	if excess < 0 {
+		excess = 0
+	}
+	next := eip4844.CalcBlobFee(trend.next)
+	if fee := eip4844.CalcBlobFee(uint64(excess)); fee.Cmp(next) > 0 {
+		return fee, nil
+	}
+	return next, nil
+}
+
+// blobTrend returns the trend of the excess blob gas at the current head.
+func (oracle *Oracle) blobTrend(ctx context.Context) (*blobTrend, error) {
+	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	oracle.cacheLock.RLock()
+	trend := oracle.lastBlobTrend
+	oracle.cacheLock.RUnlock()
+	if trend != nil && trend.head == head.Hash() {
+		return trend, nil
+	}
+	trend = &blobTrend{head: head.Hash()}
+	if head.ExcessBlobGas == nil {
+		return trend, nil // blob transactions not yet enabled, minimum fee
+	}
+	trend.next = eip4844.CalcExcessBlobGas(*head.ExcessBlobGas, *head.BlobGasUsed)
+
+	// Find the oldest block within the sampled range that has blob fees
+	var (
+		oldest = head
+		span   int64
+	)
+	for span < int64(oracle.blobBlocks) && oldest.Number.Sign() > 0 {
+		parent, err := oracle.backend.HeaderByNumber(ctx, rpc.BlockNumber(oldest.Number.Int64()-1))
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil || parent.ExcessBlobGas == nil {
+			break
+		}
+		oldest, span = parent, span+1
+	}
+	if span > 0 {
+		// The excess blob gas can change by a bounded amount per block only
+		rate := (int64(trend.next) - int64(*oldest.ExcessBlobGas)) / (span + 1)
+		trend.rate = min(max(rate, -params.BlobTxTargetBlobGasPerBlock), params.MaxBlobGasPerBlock-params.BlobTxTargetBlobGasPerBlock)
+	}
+	oracle.cacheLock.Lock()
+	oracle.lastBlobTrend = trend
+	oracle.cacheLock.Unlock()
+	return trend, nil
+}
+
+// blobFeeCapPercentiles returns the blob fee caps of the blob transactions in a
+// block at the given percentiles, weighted by the blob gas of the transactions.
+func blobFeeCapPercentiles(txs types.Transactions, percentiles []float64) []*big.Int {
+	type txBlobFeeCap struct {
+		blobGas uint64
+		feeCap  *big.Int
+	}
+	var (
+		sorter  []txBlobFeeCap
+		blobGas uint64
+	)
+	for _, tx := range txs {
+		if tx.Type() == types.BlobTxType {
+			sorter = append(sorter, txBlobFeeCap{blobGas: tx.BlobGas(), feeCap: tx.BlobGasFeeCap()})
+			blobGas += tx.BlobGas()
+		}
+	}
+	result := make([]*big.Int, len(percentiles))
+	if len(sorter) == 0 {
+		// return an all zero row if there are no blob transactions
+		for i := range result {
+			result[i] = new(big.Int)
+		}
+		return result
+	}
+	slices.SortStableFunc(sorter, func(a, b txBlobFeeCap) int {
+		return a.feeCap.Cmp(b.feeCap)
+	})
+	var (
+		txIndex    int
+		sumBlobGas = sorter[0].blobGas
+	)
+	for i, p := range percentiles {
+		threshold := uint64(float64(blobGas) * p / 100)
+		for sumBlobGas < threshold && txIndex < len(sorter)-1 {
+			txIndex++
+			sumBlobGas += sorter[txIndex].blobGas
+		}
+		result[i] = sorter[txIndex].feeCap
+	}
+	return result
+}
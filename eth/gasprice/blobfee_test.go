@@ -0,0 +1,111 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+func TestSuggestBlobFeeCap(t *testing.T) {
+	// Blocks after Cancun are filled with the maximum number of blobs, so the
+	// excess blob gas grows by the target per block.
+	backend := newTestBackend(t, big.NewInt(0), big.NewInt(28), false)
+	defer backend.teardown()
+	oracle := NewOracle(backend, Config{BlobBlocks: 20})
+
+	head := backend.chain.GetHeaderByNumber(testHead)
+	next := eip4844.CalcExcessBlobGas(*head.ExcessBlobGas, *head.BlobGasUsed)
+
+	var cases = []struct {
+		horizon uint64
+		excess  uint64
+	}{
+		{0, next + (DefaultBlobFeeHorizon-1)*params.BlobTxTargetBlobGasPerBlock},
+		{1, next},
+		{10, next + 9*params.BlobTxTargetBlobGasPerBlock},
+		{1000, next + (MaxBlobFeeHorizon-1)*params.BlobTxTargetBlobGasPerBlock},
+	}
+	for i, c := range cases {
+		got, err := oracle.SuggestBlobFeeCap(context.Background(), c.horizon)
+		if err != nil {
+			t.Fatalf("test %d: failed to suggest blob fee cap: %v", i, err)
+		}
+		if want := eip4844.CalcBlobFee(c.excess); got.Cmp(want) != 0 {
+			t.Errorf("test %d: blob fee cap mismatch, want %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestSuggestBlobFeeCapPreCancun(t *testing.T) {
+	backend := newTestBackend(t, big.NewInt(0), nil, false)
+	defer backend.teardown()
+	oracle := NewOracle(backend, Config{})
+
+	got, err := oracle.SuggestBlobFeeCap(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("failed to suggest blob fee cap: %v", err)
+	}
+	if got.Cmp(big.NewInt(params.BlobTxMinBlobGasprice)) != 0 {
+		t.Fatalf("blob fee cap mismatch, want %d, got %v", params.BlobTxMinBlobGasprice, got)
+	}
+}
+
+func TestBlobFeeCapPercentiles(t *testing.T) {
+	blobTx := func(feeCap uint64, blobs int) *types.Transaction {
+		return types.NewTx(&types.BlobTx{
+			BlobFeeCap: uint256.NewInt(feeCap),
+			BlobHashes: make([]common.Hash, blobs),
+		})
+	}
+	var cases = []struct {
+		txs         types.Transactions
+		percentiles []float64
+		want        []int64
+	}{
+		// No blob transactions
+		{
+			types.Transactions{types.NewTx(&types.DynamicFeeTx{})},
+			[]float64{0, 50, 100},
+			[]int64{0, 0, 0},
+		},
+		// Fee caps are weighted by the blob gas, not by the transactions
+		{
+			types.Transactions{blobTx(30, 1), blobTx(10, 4), types.NewTx(&types.DynamicFeeTx{}), blobTx(20, 1)},
+			[]float64{0, 50, 70, 90, 100},
+			[]int64{10, 10, 20, 30, 30},
+		},
+	}
+	for i, c := range cases {
+		got := blobFeeCapPercentiles(c.txs, c.percentiles)
+		if len(got) != len(c.want) {
+			t.Fatalf("test %d: result length mismatch, want %d, got %d", i, len(c.want), len(got))
+		}
+		for j := range got {
+			if got[j].Int64() != c.want[j] {
+				t.Errorf("test %d: percentile %v mismatch, want %d, got %v", i, c.percentiles[j], c.want[j], got[j])
+			}
+		}
+	}
+}
@@ -45,6 +45,7 @@ type Config struct {
 	Percentile       int
 	MaxHeaderHistory uint64
 	MaxBlockHistory  uint64
+	BlobBlocks       int      // Number of blocks the trend of blob fees is derived from
 	Default          *big.Int `toml:",omitempty"`
 	MaxPrice         *big.Int `toml:",omitempty"`
 	IgnorePrice      *big.Int `toml:",omitempty"`
@@ -71,9 +72,16 @@ type Oracle struct {
 	cacheLock   sync.RWMutex
 	fetchLock   sync.Mutex
 
+	lastPredictionHead common.Hash    // Head of the cached fee prediction
+	lastPrediction     *FeePrediction // Fee prediction of the maximum number of blocks
+	predictLock        sync.Mutex     // Serialises fee predictions
+
 	checkBlocks, percentile           int
 	maxHeaderHistory, maxBlockHistory uint64
 
+	blobBlocks    int
+	lastBlobTrend *blobTrend
+
 	historyCache *lru.Cache[cacheKey, processedFees]
 }
 
@@ -115,6 +123,11 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 		maxBlockHistory = 1
 		log.Warn("Sanitizing invalid gasprice oracle max block history", "provided", params.MaxBlockHistory, "updated", maxBlockHistory)
 	}
+	blobBlocks := params.BlobBlocks
+	if blobBlocks < 1 {
+		blobBlocks = 1
+		log.Warn("Sanitizing invalid gasprice oracle blob sample blocks", "provided", params.BlobBlocks, "updated", blobBlocks)
+	}
 
 	cache := lru.NewCache[cacheKey, processedFees](2048)
 	headEvent := make(chan core.ChainHeadEvent, 1)
@@ -138,6 +151,7 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 		percentile:       percent,
 		maxHeaderHistory: maxHeaderHistory,
 		maxBlockHistory:  maxBlockHistory,
+		blobBlocks:       blobBlocks,
 		historyCache:     cache,
 	}
 }
@@ -0,0 +1,315 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// MaxPredictionBlocks is the maximum number of future blocks fees are predicted for.
+const MaxPredictionBlocks = 64
+
+var errPredictionBlocks = errors.New("invalid number of blocks to predict")
+
+// tipBuckets are the lower bounds of the buckets the pending demand is grouped
+// into by the effective tip.
+var tipBuckets = []*big.Int{
+	big.NewInt(0),
+	big.NewInt(params.GWei / 10),
+	big.NewInt(params.GWei / 2),
+	big.NewInt(params.GWei),
+	big.NewInt(2 * params.GWei),
+	big.NewInt(5 * params.GWei),
+	big.NewInt(10 * params.GWei),
+	big.NewInt(100 * params.GWei),
+}
+
+// Demand is a pending transaction competing for inclusion.
+type Demand struct {
+	GasFeeCap *big.Int // Maximum fee per gas the transaction may consume
+	GasTipCap *big.Int // Maximum miner tip per gas the transaction can pay
+	Gas       uint64   // Amount of gas required by the transaction
+	BlobGas   uint64   // Amount of blob gas required by the transaction
+}
+
+// FeeBand is a predicted fee along with its confidence band, spanning one
+// standard deviation of the recent block fullness.
+type FeeBand struct {
+	Low      *big.Int
+	Expected *big.Int
+	High     *big.Int
+}
+
+// BlockPrediction is the predicted fee market of a future block.
+type BlockPrediction struct {
+	Number           uint64
+	BaseFee          FeeBand
+	BlobBaseFee      FeeBand // Zero before Cancun
+	GasUsedRatio     float64 // Expected fullness of the block
+	BlobGasUsedRatio float64 // Expected blob fullness of the block
+}
+
+// TipBucket is the pending demand paying at least a given effective tip at the
+// base fee of the next block.
+type TipBucket struct {
+	MinTip *big.Int
+	Gas    uint64
+	Txs    int
+}
+
+// FeePrediction is the predicted fee market of the blocks following the head.
+type FeePrediction struct {
+	Blocks         []BlockPrediction
+	PendingByTip   []TipBucket // Pending demand includable in the next block
+	UnderpricedGas uint64      // Pending gas not includable at the base fee of the next block
+}
+
+// PredictFees predicts the base fee and blob base fee of the given number of
+// blocks following the head, by applying the EIP-1559 and EIP-4844 update rules
+// to the projected fullness of the blocks.
+//
+// The prediction is made once per head for the maximum number of blocks, with
+// the pending demand returned by the given function at that time. The returned
+// prediction is shared and must not be modified.
+//
+// The fullness of the recent blocks is taken as the rate of new demand, on top of
+// which the pending demand is included while it affords the base fee. The bands
+// of the predictions are projected with the recent fullness one standard
+// deviation lower and higher than on average. Pending blob demand is included
+// regardless of its blob fee cap.
+func (oracle *Oracle) PredictFees(ctx context.Context, blocks uint64, pending func() []Demand) (*FeePrediction, error) {
+	if blocks < 1 || blocks > MaxPredictionBlocks {
+		return nil, errPredictionBlocks
+	}
+	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	// Predict the fees of the head if not yet done, by only one caller at a time
+	oracle.cacheLock.RLock()
+	lastHead, prediction := oracle.lastPredictionHead, oracle.lastPrediction
+	oracle.cacheLock.RUnlock()
+	if prediction == nil || lastHead != head.Hash() {
+		oracle.predictLock.Lock()
+		defer oracle.predictLock.Unlock()
+
+		oracle.cacheLock.RLock()
+		lastHead, prediction = oracle.lastPredictionHead, oracle.lastPrediction
+		oracle.cacheLock.RUnlock()
+		if prediction == nil || lastHead != head.Hash() {
+			if prediction, err = oracle.predictFees(ctx, head, pending()); err != nil {
+				return nil, err
+			}
+			oracle.cacheLock.Lock()
+			oracle.lastPredictionHead, oracle.lastPrediction = head.Hash(), prediction
+			oracle.cacheLock.Unlock()
+		}
+	}
+	return &FeePrediction{
+		Blocks:         prediction.Blocks[:blocks:blocks],
+		PendingByTip:   prediction.PendingByTip,
+		UnderpricedGas: prediction.UnderpricedGas,
+	}, nil
+}
+
+// predictFees predicts the fees of the maximum number of blocks following the
+// given head.
+func (oracle *Oracle) predictFees(ctx context.Context, head *types.Header, pending []Demand) (*FeePrediction, error) {
+	blocks := uint64(MaxPredictionBlocks)
+	config := oracle.backend.ChainConfig()
+	if !config.IsLondon(new(big.Int).Add(head.Number, big.NewInt(1))) {
+		return nil, errors.New("fee prediction unavailable before London")
+	}
+	gasRate, gasDev, err := oracle.recentFullness(ctx, head, oracle.checkBlocks, func(h *types.Header) (float64, bool) {
+		return float64(h.GasUsed) / float64(h.GasLimit), true
+	})
+	if err != nil {
+		return nil, err
+	}
+	blobRate, blobDev, err := oracle.recentFullness(ctx, head, oracle.blobBlocks, func(h *types.Header) (float64, bool) {
+		if h.BlobGasUsed == nil {
+			return 0, false
+		}
+		return float64(*h.BlobGasUsed) / params.MaxBlobGasPerBlock, true
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Order the pending demand by fee cap for finding the includable part of it
+	slices.SortFunc(pending, func(a, b Demand) int {
+		return b.GasFeeCap.Cmp(a.GasFeeCap)
+	})
+	var pendingBlobGas uint64
+	for _, d := range pending {
+		pendingBlobGas += d.BlobGas
+	}
+	// Project the blocks for the expected fullness and the bands around it
+	var (
+		expected = oracle.projectFees(config, head, blocks, pending, pendingBlobGas, gasRate, blobRate)
+		low      = oracle.projectFees(config, head, blocks, pending, pendingBlobGas, max(gasRate-gasDev, 0), max(blobRate-blobDev, 0))
+		high     = oracle.projectFees(config, head, blocks, pending, pendingBlobGas, min(gasRate+gasDev, 1), min(blobRate+blobDev, 1))
+	)
+	prediction := &FeePrediction{Blocks: make([]BlockPrediction, blocks)}
+	for i := range prediction.Blocks {
+		prediction.Blocks[i] = BlockPrediction{
+			Number:           head.Number.Uint64() + uint64(i) + 1,
+			BaseFee:          FeeBand{Low: low[i].baseFee, Expected: expected[i].baseFee, High: high[i].baseFee},
+			BlobBaseFee:      FeeBand{Low: low[i].blobBaseFee, Expected: expected[i].blobBaseFee, High: high[i].blobBaseFee},
+			GasUsedRatio:     expected[i].gasUsedRatio,
+			BlobGasUsedRatio: expected[i].blobGasUsedRatio,
+		}
+	}
+	prediction.PendingByTip, prediction.UnderpricedGas = bucketDemand(pending, expected[0].baseFee)
+	return prediction, nil
+}
+
+// recentFullness returns the mean and the standard deviation of a fullness
+// ratio of the given number of blocks up to the head. Blocks for which the ratio
+// is undefined end the sampled range.
+func (oracle *Oracle) recentFullness(ctx context.Context, head *types.Header, blocks int, ratio func(*types.Header) (float64, bool)) (float64, float64, error) {
+	var samples []float64
+	for header := head; header != nil && len(samples) < blocks; {
+		r, ok := ratio(header)
+		if !ok {
+			break
+		}
+		samples = append(samples, r)
+		if header.Number.Sign() == 0 {
+			break
+		}
+		var err error
+		if header, err = oracle.backend.HeaderByNumber(ctx, rpc.BlockNumber(header.Number.Int64()-1)); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(samples) == 0 {
+		return 0, 0, nil
+	}
+	var mean, variance float64
+	for _, s := range samples {
+		mean += s
+	}
+	mean /= float64(len(samples))
+	for _, s := range samples {
+		variance += (s - mean) * (s - mean)
+	}
+	variance /= float64(len(samples))
+	return mean, math.Sqrt(variance), nil
+}
+
+// projectedBlock is a future block projected by projectFees.
+type projectedBlock struct {
+	baseFee          *big.Int
+	blobBaseFee      *big.Int
+	gasUsedRatio     float64
+	blobGasUsedRatio float64
+}
+
+// projectFees projects the fees of the blocks following the head, for new demand
+// arriving at the given rates relative to the capacity of the blocks. Pending
+// demand is included on top of the new demand while it affords the base fee.
+func (oracle *Oracle) projectFees(config *params.ChainConfig, head *types.Header, blocks uint64, pending []Demand, pendingBlobGas uint64, gasRate, blobRate float64) []projectedBlock {
+	var (
+		projected = make([]projectedBlock, blocks)
+		parent    = types.CopyHeader(head)
+
+		includedGas     uint64 // Pending gas included by the projected blocks
+		includedBlobGas uint64 // Pending blob gas included by the projected blocks
+	)
+	for i := range projected {
+		baseFee := eip1559.CalcBaseFee(config, parent)
+
+		// Include the pending demand affording the base fee on top of the new one
+		var affordable uint64
+		for _, d := range pending {
+			if d.GasFeeCap.Cmp(baseFee) < 0 {
+				break
+			}
+			affordable += d.Gas
+		}
+		var (
+			arriving = uint64(gasRate * float64(parent.GasLimit))
+			backlog  = affordable - min(includedGas, affordable)
+			gasUsed  = min(arriving+backlog, parent.GasLimit)
+		)
+		includedGas += gasUsed - min(arriving, gasUsed)
+
+		header := &types.Header{
+			Number:   new(big.Int).Add(parent.Number, big.NewInt(1)),
+			GasLimit: parent.GasLimit,
+			GasUsed:  gasUsed,
+			BaseFee:  baseFee,
+		}
+		projected[i] = projectedBlock{
+			baseFee:      baseFee,
+			blobBaseFee:  new(big.Int),
+			gasUsedRatio: float64(gasUsed) / float64(header.GasLimit),
+		}
+		if parent.ExcessBlobGas != nil {
+			excess := eip4844.CalcExcessBlobGas(*parent.ExcessBlobGas, *parent.BlobGasUsed)
+			var (
+				arriving = uint64(blobRate*params.MaxBlobGasPerBlock) / params.BlobTxBlobGasPerBlob * params.BlobTxBlobGasPerBlob
+				backlog  = pendingBlobGas - min(includedBlobGas, pendingBlobGas)
+				blobUsed = min(arriving+backlog, params.MaxBlobGasPerBlock)
+			)
+			includedBlobGas += blobUsed - min(arriving, blobUsed)
+
+			header.ExcessBlobGas, header.BlobGasUsed = &excess, &blobUsed
+			projected[i].blobBaseFee = eip4844.CalcBlobFee(excess)
+			projected[i].blobGasUsedRatio = float64(blobUsed) / params.MaxBlobGasPerBlock
+		}
+		parent = header
+	}
+	return projected
+}
+
+// bucketDemand groups the pending demand by the effective tip paid at the given
+// base fee, returning separately the gas not affording the base fee.
+func bucketDemand(pending []Demand, baseFee *big.Int) ([]TipBucket, uint64) {
+	buckets := make([]TipBucket, len(tipBuckets))
+	for i, tip := range tipBuckets {
+		buckets[i].MinTip = tip
+	}
+	var underpriced uint64
+	for _, d := range pending {
+		if d.GasFeeCap.Cmp(baseFee) < 0 {
+			underpriced += d.Gas
+			continue
+		}
+		tip := new(big.Int).Sub(d.GasFeeCap, baseFee)
+		if tip.Cmp(d.GasTipCap) > 0 {
+			tip = d.GasTipCap
+		}
+		i, found := slices.BinarySearchFunc(tipBuckets, tip, func(a, b *big.Int) int { return a.Cmp(b) })
+		if !found {
+			i--
+		}
+		buckets[i].Gas += d.Gas
+		buckets[i].Txs++
+	}
+	return buckets, underpriced
+}
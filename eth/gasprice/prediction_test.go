@@ -0,0 +1,153 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestPredictFees(t *testing.T) {
+	backend := newTestBackend(t, big.NewInt(0), big.NewInt(28), false)
+	defer backend.teardown()
+	oracle := NewOracle(backend, Config{Blocks: 20, BlobBlocks: 20})
+
+	head := backend.chain.GetHeaderByNumber(testHead)
+	next := eip1559.CalcBaseFee(backend.ChainConfig(), head)
+	nextBlob := eip4844.CalcBlobFee(eip4844.CalcExcessBlobGas(*head.ExcessBlobGas, *head.BlobGasUsed))
+
+	for _, blocks := range []uint64{0, MaxPredictionBlocks + 1} {
+		if _, err := oracle.PredictFees(context.Background(), blocks, nil); !errors.Is(err, errPredictionBlocks) {
+			t.Fatalf("%d blocks: error mismatch, want %v, got %v", blocks, errPredictionBlocks, err)
+		}
+	}
+	var cases = []struct {
+		pending []Demand
+		rising  bool // Whether the base fee is expected to rise
+	}{
+		// The test blocks are mostly empty, so the base fee falls
+		{nil, false},
+		// Pending demand not affording the base fee doesn't fill the blocks
+		{[]Demand{{GasFeeCap: big.NewInt(1), GasTipCap: big.NewInt(1), Gas: 100_000_000}}, false},
+		// Affordable pending demand fills blocks until it is included
+		{[]Demand{{GasFeeCap: big.NewInt(1000 * params.GWei), GasTipCap: big.NewInt(params.GWei), Gas: 100_000_000, BlobGas: 100 * params.BlobTxBlobGasPerBlob}}, true},
+	}
+	for i, c := range cases {
+		// Predictions are cached per head, so each case needs its own oracle
+		oracle := NewOracle(backend, Config{Blocks: 20, BlobBlocks: 20})
+		pending := c.pending
+		prediction, err := oracle.PredictFees(context.Background(), 8, func() []Demand { return pending })
+		if err != nil {
+			t.Fatalf("test %d: failed to predict fees: %v", i, err)
+		}
+		if len(prediction.Blocks) != 8 {
+			t.Fatalf("test %d: prediction length mismatch, want %d, got %d", i, 8, len(prediction.Blocks))
+		}
+		first := prediction.Blocks[0]
+		if first.Number != testHead+1 {
+			t.Errorf("test %d: first block number mismatch, want %d, got %d", i, testHead+1, first.Number)
+		}
+		for _, fee := range []*big.Int{first.BaseFee.Low, first.BaseFee.Expected, first.BaseFee.High} {
+			if fee.Cmp(next) != 0 {
+				t.Errorf("test %d: next base fee mismatch, want %v, got %v", i, next, fee)
+			}
+		}
+		for _, fee := range []*big.Int{first.BlobBaseFee.Low, first.BlobBaseFee.Expected, first.BlobBaseFee.High} {
+			if fee.Cmp(nextBlob) != 0 {
+				t.Errorf("test %d: next blob base fee mismatch, want %v, got %v", i, nextBlob, fee)
+			}
+		}
+		for j, block := range prediction.Blocks {
+			if block.BaseFee.Low.Cmp(block.BaseFee.Expected) > 0 || block.BaseFee.Expected.Cmp(block.BaseFee.High) > 0 {
+				t.Errorf("test %d, block %d: base fee band out of order: %v, %v, %v", i, j, block.BaseFee.Low, block.BaseFee.Expected, block.BaseFee.High)
+			}
+			if block.BlobBaseFee.Low.Cmp(block.BlobBaseFee.Expected) > 0 || block.BlobBaseFee.Expected.Cmp(block.BlobBaseFee.High) > 0 {
+				t.Errorf("test %d, block %d: blob base fee band out of order: %v, %v, %v", i, j, block.BlobBaseFee.Low, block.BlobBaseFee.Expected, block.BlobBaseFee.High)
+			}
+		}
+		last := prediction.Blocks[len(prediction.Blocks)-1]
+		if rising := last.BaseFee.Expected.Cmp(next) > 0; rising != c.rising {
+			t.Errorf("test %d: base fee trend mismatch, want rising %v, got %v -> %v", i, c.rising, next, last.BaseFee.Expected)
+		}
+	}
+}
+
+func TestPredictFeesCache(t *testing.T) {
+	backend := newTestBackend(t, big.NewInt(0), big.NewInt(28), false)
+	defer backend.teardown()
+	oracle := NewOracle(backend, Config{Blocks: 20, BlobBlocks: 20})
+
+	var calls int
+	pending := func() []Demand {
+		calls++
+		return []Demand{{GasFeeCap: big.NewInt(1000 * params.GWei), GasTipCap: big.NewInt(params.GWei), Gas: 100_000_000}}
+	}
+	full, err := oracle.PredictFees(context.Background(), MaxPredictionBlocks, pending)
+	if err != nil {
+		t.Fatalf("failed to predict fees: %v", err)
+	}
+	short, err := oracle.PredictFees(context.Background(), 4, pending)
+	if err != nil {
+		t.Fatalf("failed to predict fees: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("pending demand gathered %d times for the same head", calls)
+	}
+	if len(short.Blocks) != 4 {
+		t.Fatalf("prediction length mismatch, want %d, got %d", 4, len(short.Blocks))
+	}
+	for i, block := range short.Blocks {
+		if block.BaseFee.Expected.Cmp(full.Blocks[i].BaseFee.Expected) != 0 {
+			t.Errorf("block %d: cached prediction mismatch, want %v, got %v", i, full.Blocks[i].BaseFee.Expected, block.BaseFee.Expected)
+		}
+	}
+}
+
+func TestBucketDemand(t *testing.T) {
+	baseFee := big.NewInt(10 * params.GWei)
+	pending := []Demand{
+		{GasFeeCap: big.NewInt(5 * params.GWei), GasTipCap: big.NewInt(params.GWei), Gas: 21000},           // underpriced
+		{GasFeeCap: big.NewInt(10 * params.GWei), GasTipCap: big.NewInt(params.GWei), Gas: 30000},          // no tip left
+		{GasFeeCap: big.NewInt(11 * params.GWei), GasTipCap: big.NewInt(2 * params.GWei), Gas: 40000},      // capped by the fee cap
+		{GasFeeCap: big.NewInt(100 * params.GWei), GasTipCap: big.NewInt(3 * params.GWei), Gas: 50000},     // full tip
+		{GasFeeCap: big.NewInt(1000 * params.GWei), GasTipCap: big.NewInt(1000 * params.GWei), Gas: 60000}, // top bucket
+		{GasFeeCap: big.NewInt(100 * params.GWei), GasTipCap: big.NewInt(params.GWei / 5 * 2), Gas: 70000}, // below half a gwei
+		{GasFeeCap: big.NewInt(100 * params.GWei), GasTipCap: big.NewInt(params.GWei / 2), Gas: 80000},     // bucket bound
+	}
+	buckets, underpriced := bucketDemand(pending, baseFee)
+	if underpriced != 21000 {
+		t.Errorf("underpriced gas mismatch, want %d, got %d", 21000, underpriced)
+	}
+	want := []uint64{30000, 70000, 80000, 40000, 50000, 0, 0, 60000}
+	if len(buckets) != len(want) {
+		t.Fatalf("bucket count mismatch, want %d, got %d", len(want), len(buckets))
+	}
+	for i, bucket := range buckets {
+		if bucket.MinTip.Cmp(tipBuckets[i]) != 0 {
+			t.Errorf("bucket %d: min tip mismatch, want %v, got %v", i, tipBuckets[i], bucket.MinTip)
+		}
+		if bucket.Gas != want[i] {
+			t.Errorf("bucket %d: gas mismatch, want %d, got %d", i, want[i], bucket.Gas)
+		}
+	}
+}
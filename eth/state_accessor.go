@@ -40,12 +40,20 @@ var noopReleaser = tracers.StateReleaseFunc(func() {})
 
 func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, readOnly bool, preferDisk bool) (statedb *state.StateDB, release tracers.StateReleaseFunc, err error) {
 	var (
-		current  *types.Block
-		database state.Database
-		tdb      *triedb.Database
-		report   = true
-		origin   = block.NumberU64()
+		current    *types.Block
+		database   state.Database
+		tdb        *triedb.Database
+		report     = true
+		origin     = block.NumberU64()
+		parentRoot common.Hash // Root of the last referenced intermediate state
 	)
+	// Drop the reference to the intermediate state if regeneration fails, as it
+	// may be held in a trie database cached beyond this request
+	defer func() {
+		if err != nil && parentRoot != (common.Hash{}) {
+			tdb.Dereference(parentRoot)
+		}
+	}()
 	// The state is only for reading purposes, check the state presence in
 	// live database.
 	if readOnly {
@@ -81,6 +89,10 @@ func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec u
 		// Otherwise, try to reexec blocks until we find a state or reach our limit
 		current = block
 
+		// Reuse the state if it was regenerated for an earlier request
+		if cached, _, release := eth.traceStates.get(block.Root()); cached != nil {
+			return cached, release, nil
+		}
 		// Create an ephemeral trie.Database for isolating the live one. Otherwise
 		// the internal junks created by tracing will be persisted into the disk.
 		// TODO(rjl493456442), clean cache is disabled to prevent memory leak,
@@ -111,6 +123,13 @@ func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec u
 			}
 			current = parent
 
+			// Continue from a state regenerated for an earlier request if there
+			// is one, holding its reference until the next block is processed
+			if cached, entry, _ := eth.traceStates.get(current.Root()); cached != nil {
+				statedb, database, tdb, err = cached, entry.database, entry.tdb, nil
+				parentRoot = current.Root()
+				break
+			}
 			statedb, err = state.New(current.Root(), database, nil)
 			if err == nil {
 				break
@@ -130,7 +149,6 @@ func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec u
 	var (
 		start  = time.Now()
 		logged time.Time
-		parent common.Hash
 	)
 	for current.NumberU64() < origin {
 		if err := ctx.Err(); err != nil {
@@ -163,15 +181,20 @@ func (eth *Ethereum) hashState(ctx context.Context, block *types.Block, reexec u
 		// Hold the state reference and also drop the parent state
 		// to prevent accumulating too many nodes in memory.
 		tdb.Reference(root, common.Hash{})
-		if parent != (common.Hash{}) {
-			tdb.Dereference(parent)
+		if parentRoot != (common.Hash{}) {
+			tdb.Dereference(parentRoot)
 		}
-		parent = root
+		parentRoot = root
 	}
 	if report {
 		_, nodes, imgs := tdb.Size() // all memory is contained within the nodes return in hashdb
 		log.Info("Historical state regenerated", "block", current.NumberU64(), "elapsed", time.Since(start), "nodes", nodes, "preimages", imgs)
 	}
+	// Retain states regenerated for individual requests for later ones, the
+	// states of chain traces are only needed once
+	if base == nil {
+		eth.traceStates.add(block.Root(), database, tdb)
+	}
 	return statedb, func() { tdb.Dereference(block.Root()) }, nil
 }
 
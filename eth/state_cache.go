@@ -0,0 +1,106 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+var (
+	traceStateHitMeter  = metrics.NewRegisteredMeter("trace/states/hit", nil)
+	traceStateMissMeter = metrics.NewRegisteredMeter("trace/states/miss", nil)
+)
+
+// cachedState is a historical state regenerated in an ephemeral trie database,
+// whose nodes are kept referenced while it is cached.
+type cachedState struct {
+	database state.Database
+	tdb      *triedb.Database
+}
+
+// stateCache retains the historical states regenerated for tracing, so later
+// requests for the same block or one of its descendants start from them, instead
+// of re-executing the chain from a persisted state again.
+type stateCache struct {
+	states lru.BasicLRU[common.Hash, *cachedState] // Regenerated states by state root
+	limit  int
+	lock   sync.Mutex
+}
+
+// newStateCache creates a cache retaining the given number of states.
+func newStateCache(limit int) *stateCache {
+	return &stateCache{
+		states: lru.NewBasicLRU[common.Hash, *cachedState](limit),
+		limit:  limit,
+	}
+}
+
+// get opens the cached state with the given root. The returned release function
+// must be called once the state is no longer used. It is safe to call get on a
+// nil cache.
+func (c *stateCache) get(root common.Hash) (*state.StateDB, *cachedState, tracers.StateReleaseFunc) {
+	if c == nil {
+		return nil, nil, nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached, ok := c.states.Get(root)
+	if !ok {
+		traceStateMissMeter.Mark(1)
+		return nil, nil, nil
+	}
+	statedb, err := state.New(root, cached.database, nil)
+	if err != nil {
+		c.states.Remove(root)
+		cached.tdb.Dereference(root)
+		return nil, nil, nil
+	}
+	traceStateHitMeter.Mark(1)
+
+	// Reference the state for the caller, so it outlives the eviction
+	cached.tdb.Reference(root, common.Hash{})
+	return statedb, cached, func() { cached.tdb.Dereference(root) }
+}
+
+// add retains a regenerated state, evicting the least recently used one if the
+// cache is full. It is safe to call add on a nil cache.
+func (c *stateCache) add(root common.Hash, database state.Database, tdb *triedb.Database) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.states.Contains(root) {
+		return
+	}
+	if c.states.Len() >= c.limit {
+		if oldest, evicted, ok := c.states.RemoveOldest(); ok {
+			evicted.tdb.Dereference(oldest)
+		}
+	}
+	tdb.Reference(root, common.Hash{})
+	c.states.Add(root, &cachedState{database: database, tdb: tdb})
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// newEphemeralState commits a state with a single funded account into a new
+// ephemeral trie database, returning its root referenced once.
+func newEphemeralState(t *testing.T, balance uint64) (common.Hash, state.Database, *triedb.Database) {
+	t.Helper()
+
+	db := rawdb.NewMemoryDatabase()
+	tdb := triedb.NewDatabase(db, triedb.HashDefaults)
+	database := state.NewDatabaseWithNodeDB(db, tdb)
+
+	statedb, _ := state.New(types.EmptyRootHash, database, nil)
+	statedb.AddBalance(common.Address{0x01}, uint256.NewInt(balance), tracing.BalanceChangeUnspecified)
+	root, err := statedb.Commit(0, true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	tdb.Reference(root, common.Hash{})
+	return root, database, tdb
+}
+
+func TestStateCache(t *testing.T) {
+	cache := newStateCache(1)
+
+	// The cache keeps the state alive after its regenerating request is done
+	root, database, tdb := newEphemeralState(t, 1)
+	cache.add(root, database, tdb)
+	tdb.Dereference(root)
+
+	statedb, _, release := cache.get(root)
+	if statedb == nil {
+		t.Fatalf("cached state missing")
+	}
+	if balance := statedb.GetBalance(common.Address{0x01}); balance.Uint64() != 1 {
+		t.Fatalf("cached state balance mismatch: %v", balance)
+	}
+	// Evicted states stay alive until released by their users
+	other, otherDatabase, otherTdb := newEphemeralState(t, 2)
+	cache.add(other, otherDatabase, otherTdb)
+	if cached, _, _ := cache.get(root); cached != nil {
+		t.Fatalf("evicted state still cached")
+	}
+	if _, err := state.New(root, database, nil); err != nil {
+		t.Fatalf("evicted state released while in use: %v", err)
+	}
+	release()
+	if _, err := state.New(root, database, nil); err == nil {
+		t.Fatalf("evicted state not released")
+	}
+	// Operations on a disabled cache are no-ops
+	var disabled *stateCache
+	disabled.add(other, otherDatabase, otherTdb)
+	if cached, _, _ := disabled.get(other); cached != nil {
+		t.Fatalf("disabled cache returned a state")
+	}
+}
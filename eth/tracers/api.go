@@ -27,6 +27,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -82,6 +83,9 @@ type Backend interface {
 	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
 	GetTransaction(ctx context.Context, txHash common.Hash) (bool, *types.Transaction, common.Hash, uint64, uint64, error)
 	RPCGasCap() uint64
+	ResponseCache() *ethapi.ResponseCache
+	TraceScheduler() *Scheduler
+	ErrorRegistry() *abi.ErrorRegistry
 	ChainConfig() *params.ChainConfig
 	Engine() consensus.Engine
 	ChainDb() ethdb.Database
@@ -224,10 +228,15 @@ func (api *API) TraceChain(ctx context.Context, start, end rpc.BlockNumber, conf
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	done, err := api.backend.TraceScheduler().acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
 	sub := notifier.CreateSubscription()
 
 	resCh := api.traceChain(from, to, config, sub.Err())
 	go func() {
+		defer done()
 		for result := range resCh {
 			notifier.Notify(sub.ID, result)
 		}
@@ -245,11 +254,17 @@ func (api *API) traceChain(start, end *types.Block, config *TraceConfig, closed
 	if config != nil && config.Reexec != nil {
 		reexec = *config.Reexec
 	}
+	// The caller holds an execution slot for the first tracing thread, the
+	// others can only use the slots left idle by other requests
 	blocks := int(end.NumberU64() - start.NumberU64())
 	threads := runtime.NumCPU()
 	if threads > blocks {
 		threads = blocks
 	}
+	scheduler := api.backend.TraceScheduler()
+	extra := scheduler.acquireIdle(threads - 1)
+	threads = 1 + extra
+
 	var (
 		pend    = new(sync.WaitGroup)
 		ctx     = context.Background()
@@ -314,6 +329,9 @@ func (api *API) traceChain(start, end *types.Block, config *TraceConfig, closed
 		defer func() {
 			close(taskCh)
 			pend.Wait()
+			for i := 0; i < extra; i++ {
+				scheduler.release()
+			}
 
 			// Clean out any pending release functions of trace states.
 			tracker.callReleases()
@@ -437,26 +455,120 @@ func (api *API) traceChain(start, end *types.Block, config *TraceConfig, closed
 // TraceBlockByNumber returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
 func (api *API) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*txTraceResult, error) {
+	return collectTraces(api.traceBlockByNumber(ctx, number, config))
+}
+
+// traceBlockByNumber is the streaming variant of TraceBlockByNumber.
+func (api *API) traceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
 	block, err := api.blockByNumber(ctx, number)
 	if err != nil {
 		return nil, err
 	}
-	return api.traceBlock(ctx, block, config)
+	return api.traceBlockCached(ctx, block, config)
 }
 
 // TraceBlockByHash returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
 func (api *API) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*txTraceResult, error) {
+	return collectTraces(api.traceBlockByHash(ctx, hash, config))
+}
+
+// traceBlockByHash is the streaming variant of TraceBlockByHash.
+func (api *API) traceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
 	block, err := api.blockByHash(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
-	return api.traceBlock(ctx, block, config)
+	return api.traceBlockCached(ctx, block, config)
+}
+
+// traceBlockCached is a wrapper around traceBlock which serves the traces of
+// finalized blocks from the response cache, and the ones of any block from the
+// trace cache of the scheduler, if configured.
+func (api *API) traceBlockCached(ctx context.Context, block *types.Block, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
+	var (
+		cache     = api.backend.ResponseCache()
+		scheduler = api.backend.TraceScheduler()
+	)
+	if cache == nil && !scheduler.caching() {
+		return api.traceBlock(ctx, block, config)
+	}
+	key := ethapi.ResponseCacheKey("debug_traceBlock", block.Hash(), normalizeTraceConfig(config))
+
+	var cached []*txTraceResult
+	if blob, ok := cache.Get(key); ok && json.Unmarshal(blob, &cached) == nil {
+		return rpc.SliceStream(cached), nil
+	}
+	if scheduler.cached(key, &cached) {
+		return rpc.SliceStream(cached), nil
+	}
+	stream, err := api.traceBlock(ctx, block, config)
+	if err != nil {
+		return nil, err
+	}
+	immutable := cache.Immutable(ctx, api.backend, block.NumberU64(), block.Hash())
+	if !immutable && !scheduler.caching() {
+		return stream, nil
+	}
+	limit := scheduler.maxCacheSize()
+	if immutable {
+		limit = max(limit, cache.MaxSize())
+	}
+	// Gather the encoded results while streaming them, to store once the trace
+	// is done. Traces too large for the caches aren't held on to.
+	return func(yield func(*txTraceResult) error) error {
+		var (
+			results []json.RawMessage
+			size    uint64
+			collect = true
+		)
+		err := stream(func(result *txTraceResult) error {
+			if collect {
+				blob, err := json.Marshal(result)
+				size += uint64(len(blob))
+
+				// Failures are usually timeouts, don't cache them
+				collect = err == nil && result.Error == "" && size <= limit
+				if collect {
+					results = append(results, blob)
+				} else {
+					results = nil
+				}
+			}
+			return yield(result)
+		})
+		if err == nil && collect {
+			// Traces are determined by the block hash, but only the ones of
+			// finalized blocks remain relevant for long
+			scheduler.cache(key, results)
+			if immutable {
+				cache.Put(key, results)
+			}
+		}
+		return err
+	}, nil
+}
+
+// normalizeTraceConfig returns a copy of config with all the fields removed which
+// do not influence the trace result, so it can be used as a cache key.
+func normalizeTraceConfig(config *TraceConfig) *TraceConfig {
+	if config == nil {
+		return nil
+	}
+	normalized := *config
+	normalized.Timeout = nil
+	normalized.Reexec = nil
+	return &normalized
 }
 
 // TraceBlock returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *API) TraceBlock(ctx context.Context, blob hexutil.Bytes, config *TraceConfig) ([]*txTraceResult, error) {
+	return collectTraces(api.traceBlockRLP(ctx, blob, config))
+}
+
+// traceBlockRLP is the streaming variant of TraceBlock.
+func (api *API) traceBlockRLP(ctx context.Context, blob hexutil.Bytes, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
 	block := new(types.Block)
 	if err := rlp.DecodeBytes(blob, block); err != nil {
 		return nil, fmt.Errorf("could not decode block: %v", err)
@@ -467,17 +579,27 @@ func (api *API) TraceBlock(ctx context.Context, blob hexutil.Bytes, config *Trac
 // TraceBlockFromFile returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
 func (api *API) TraceBlockFromFile(ctx context.Context, file string, config *TraceConfig) ([]*txTraceResult, error) {
+	return collectTraces(api.traceBlockFromFile(ctx, file, config))
+}
+
+// traceBlockFromFile is the streaming variant of TraceBlockFromFile.
+func (api *API) traceBlockFromFile(ctx context.Context, file string, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
 	blob, err := os.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("could not read file: %v", err)
 	}
-	return api.TraceBlock(ctx, blob, config)
+	return api.traceBlockRLP(ctx, blob, config)
 }
 
 // TraceBadBlock returns the structured logs created during the execution of
 // EVM against a block pulled from the pool of bad ones and returns them as a JSON
 // object.
 func (api *API) TraceBadBlock(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*txTraceResult, error) {
+	return collectTraces(api.traceBadBlock(ctx, hash, config))
+}
+
+// traceBadBlock is the streaming variant of TraceBadBlock.
+func (api *API) traceBadBlock(ctx context.Context, hash common.Hash, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
 	block := rawdb.ReadBadBlock(api.backend.ChainDb(), hash)
 	if block == nil {
 		return nil, fmt.Errorf("bad block %#x not found", hash)
@@ -485,6 +607,14 @@ func (api *API) TraceBadBlock(ctx context.Context, hash common.Hash, config *Tra
 	return api.traceBlock(ctx, block, config)
 }
 
+// collectTraces gathers the results of a streamed block trace.
+func collectTraces(stream rpc.Stream[*txTraceResult], err error) ([]*txTraceResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	return stream.Collect()
+}
+
 // StandardTraceBlockToFile dumps the structured logs created during the
 // execution of EVM to the local file system and returns a list of files
 // to the caller.
@@ -518,6 +648,11 @@ func (api *API) IntermediateRoots(ctx context.Context, hash common.Hash, config
 	if config != nil && config.Reexec != nil {
 		reexec = *config.Reexec
 	}
+	done, err := api.backend.TraceScheduler().acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	statedb, release, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
 	if err != nil {
 		return nil, err
@@ -561,6 +696,82 @@ func (api *API) IntermediateRoots(ctx context.Context, hash common.Hash, config
 	return roots, nil
 }
 
+// GetBlockReceipts returns the receipts of a block like eth_getBlockReceipts, with
+// the revert data and the decoded revert reason added to those of the reverted
+// transactions. Receipts don't carry the revert data, so the block is re-executed
+// up to the last reverted transaction.
+func (api *API) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	block, err := api.callBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		chainConfig = api.backend.ChainConfig()
+		signer      = types.MakeSigner(chainConfig, block.Number(), block.Time())
+		txs         = block.Transactions()
+		receipts    = rawdb.ReadReceipts(api.backend.ChainDb(), block.Hash(), block.NumberU64(), block.Time(), chainConfig)
+	)
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	result := make([]map[string]interface{}, len(receipts))
+	last := -1
+	for i, receipt := range receipts {
+		result[i] = ethapi.MarshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], i)
+		if receipt.Status == types.ReceiptStatusFailed {
+			last = i
+		}
+	}
+	if last < 0 {
+		return result, nil
+	}
+	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	done, err := api.backend.TraceScheduler().acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	statedb, release, err := api.backend.StateAtBlock(ctx, parent, defaultTraceReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		vmenv := vm.NewEVM(vmctx, vm.TxContext{}, statedb, chainConfig, vm.Config{})
+		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+	}
+	registry := api.backend.ErrorRegistry()
+	for i, tx := range txs[:last+1] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var (
+			msg, _    = core.TransactionToMessage(tx, signer, block.BaseFee())
+			txContext = core.NewEVMTxContext(msg)
+			vmenv     = vm.NewEVM(vmctx, txContext, statedb, chainConfig, vm.Config{})
+		)
+		statedb.SetTxContext(tx.Hash(), i)
+		res, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.GasLimit))
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d [%#x] failed: %v", i, tx.Hash(), err)
+		}
+		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+
+		if revert := res.Revert(); len(revert) > 0 {
+			result[i]["revertData"] = hexutil.Bytes(revert)
+			if reason, err := registry.Decode(revert); err == nil {
+				result[i]["revertReason"] = reason
+			}
+		}
+	}
+	return result, nil
+}
+
 // StandardTraceBadBlockToFile dumps the structured logs created during the
 // execution of EVM against a block pulled from the pool of bad ones to the
 // local file system and returns a list of files to the caller.
@@ -573,9 +784,10 @@ func (api *API) StandardTraceBadBlockToFile(ctx context.Context, hash common.Has
 }
 
 // traceBlock configures a new tracer according to the provided configuration, and
-// executes all the transactions contained within. The return value will be one item
-// per transaction, dependent on the requested tracer.
-func (api *API) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) ([]*txTraceResult, error) {
+// executes all the transactions contained within. The returned stream yields one item
+// per transaction, dependent on the requested tracer. The block state is only
+// acquired once the stream is consumed, and is released when it finishes.
+func (api *API) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
 	if block.NumberU64() == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
@@ -588,65 +800,96 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	if config != nil && config.Reexec != nil {
 		reexec = *config.Reexec
 	}
-	statedb, release, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
-	if err != nil {
-		return nil, err
-	}
-	defer release()
-	// JS tracers have high overhead. In this case run a parallel
-	// process that generates states in one thread and traces txes
-	// in separate worker threads.
-	if config != nil && config.Tracer != nil && *config.Tracer != "" {
-		if isJS := DefaultDirectory.IsJS(*config.Tracer); isJS {
-			return api.traceBlockParallel(ctx, block, statedb, config)
-		}
-	}
-	// Native tracers have low overhead
-	var (
-		txs       = block.Transactions()
-		blockHash = block.Hash()
-		blockCtx  = core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
-		signer    = types.MakeSigner(api.backend.ChainConfig(), block.Number(), block.Time())
-		results   = make([]*txTraceResult, len(txs))
-	)
-	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
-		vmenv := vm.NewEVM(blockCtx, vm.TxContext{}, statedb, api.backend.ChainConfig(), vm.Config{})
-		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
-	}
-	for i, tx := range txs {
-		// Generate the next state snapshot fast without tracing
-		msg, _ := core.TransactionToMessage(tx, signer, block.BaseFee())
-		txctx := &Context{
-			BlockHash:   blockHash,
-			BlockNumber: block.Number(),
-			TxIndex:     i,
-			TxHash:      tx.Hash(),
+	return func(yield func(*txTraceResult) error) error {
+		done, err := api.backend.TraceScheduler().acquire(ctx)
+		if err != nil {
+			return err
 		}
-		res, err := api.traceTx(ctx, tx, msg, txctx, blockCtx, statedb, config)
+		defer done()
+
+		statedb, release, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		results[i] = &txTraceResult{TxHash: tx.Hash(), Result: res}
-	}
-	return results, nil
+		defer release()
+
+		// JS tracers have high overhead. In this case run a parallel
+		// process that generates states in one thread and traces txes
+		// in separate worker threads.
+		if config != nil && config.Tracer != nil && *config.Tracer != "" {
+			if isJS := DefaultDirectory.IsJS(*config.Tracer); isJS {
+				return api.traceBlockParallel(ctx, block, statedb, config, yield)
+			}
+		}
+		// Native tracers have low overhead
+		var (
+			txs       = block.Transactions()
+			blockHash = block.Hash()
+			blockCtx  = core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+			signer    = types.MakeSigner(api.backend.ChainConfig(), block.Number(), block.Time())
+		)
+		if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+			vmenv := vm.NewEVM(blockCtx, vm.TxContext{}, statedb, api.backend.ChainConfig(), vm.Config{})
+			core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+		}
+		for i, tx := range txs {
+			// Generate the next state snapshot fast without tracing
+			msg, _ := core.TransactionToMessage(tx, signer, block.BaseFee())
+			txctx := &Context{
+				BlockHash:   blockHash,
+				BlockNumber: block.Number(),
+				TxIndex:     i,
+				TxHash:      tx.Hash(),
+			}
+			res, err := api.traceTx(ctx, tx, msg, txctx, blockCtx, statedb, config)
+			if err != nil {
+				return err
+			}
+			if err := yield(&txTraceResult{TxHash: tx.Hash(), Result: res}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
 }
 
 // traceBlockParallel is for tracers that have a high overhead (read JS tracers). One thread
 // runs along and executes txes without tracing enabled to generate their prestate.
-// Worker threads take the tasks and the prestate and trace them.
-func (api *API) traceBlockParallel(ctx context.Context, block *types.Block, statedb *state.StateDB, config *TraceConfig) ([]*txTraceResult, error) {
+// Worker threads take the tasks and the prestate and trace them. The results are
+// yielded in transaction order as soon as they become available.
+func (api *API) traceBlockParallel(ctx context.Context, block *types.Block, statedb *state.StateDB, config *TraceConfig, yield func(*txTraceResult) error) error {
+	// Stop feeding and tracing transactions if the consumer goes away
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	// Execute all the transaction contained within the block concurrently
 	var (
 		txs       = block.Transactions()
 		blockHash = block.Hash()
 		signer    = types.MakeSigner(api.backend.ChainConfig(), block.Number(), block.Time())
 		results   = make([]*txTraceResult, len(txs))
+		ready     = make([]chan struct{}, len(txs))
 		pend      sync.WaitGroup
 	)
+	for i := range ready {
+		ready[i] = make(chan struct{})
+	}
+	// The caller holds an execution slot for the first tracing thread, the
+	// others can only use the slots left idle by other requests
 	threads := runtime.NumCPU()
 	if threads > len(txs) {
 		threads = len(txs)
 	}
+	if threads > 1 {
+		scheduler := api.backend.TraceScheduler()
+		extra := scheduler.acquireIdle(threads - 1)
+		defer func() {
+			for i := 0; i < extra; i++ {
+				scheduler.release()
+			}
+		}()
+		threads = 1 + extra
+	}
 	jobs := make(chan *txTraceTask, threads)
 	for th := 0; th < threads; th++ {
 		pend.Add(1)
@@ -669,48 +912,66 @@ func (api *API) traceBlockParallel(ctx context.Context, block *types.Block, stat
 				res, err := api.traceTx(ctx, txs[task.index], msg, txctx, blockCtx, task.statedb, config)
 				if err != nil {
 					results[task.index] = &txTraceResult{TxHash: txs[task.index].Hash(), Error: err.Error()}
-					continue
+				} else {
+					results[task.index] = &txTraceResult{TxHash: txs[task.index].Hash(), Result: res}
 				}
-				results[task.index] = &txTraceResult{TxHash: txs[task.index].Hash(), Result: res}
+				close(ready[task.index])
 			}
 		}()
 	}
 
-	// Feed the transactions into the tracers and return
-	var failed error
-	blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
-txloop:
-	for i, tx := range txs {
-		// Send the trace task over for execution
-		task := &txTraceTask{statedb: statedb.Copy(), index: i}
-		select {
-		case <-ctx.Done():
-			failed = ctx.Err()
-			break txloop
-		case jobs <- task:
+	// Feed the transactions into the tracers in the background
+	fed := make(chan error, 1)
+	go func() {
+		var failed error
+		blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+	txloop:
+		for i, tx := range txs {
+			// Send the trace task over for execution
+			task := &txTraceTask{statedb: statedb.Copy(), index: i}
+			select {
+			case <-ctx.Done():
+				failed = ctx.Err()
+				break txloop
+			case jobs <- task:
+			}
+
+			// Generate the next state snapshot fast without tracing
+			msg, _ := core.TransactionToMessage(tx, signer, block.BaseFee())
+			statedb.SetTxContext(tx.Hash(), i)
+			vmenv := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vm.Config{})
+			if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.GasLimit)); err != nil {
+				failed = err
+				break txloop
+			}
+			// Finalize the state so any modifications are written to the trie
+			// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+			statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
 		}
+		close(jobs)
+		fed <- failed
+	}()
 
-		// Generate the next state snapshot fast without tracing
-		msg, _ := core.TransactionToMessage(tx, signer, block.BaseFee())
-		statedb.SetTxContext(tx.Hash(), i)
-		vmenv := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vm.Config{})
-		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.GasLimit)); err != nil {
-			failed = err
-			break txloop
+	// Deliver the results in order, aborting if execution fails in between
+	var (
+		failed  error
+		feeding = fed
+	)
+	for i := 0; i < len(txs) && failed == nil; {
+		select {
+		case <-ready[i]:
+			failed = yield(results[i])
+			i++
+		case failed = <-feeding:
+			feeding = nil
 		}
-		// Finalize the state so any modifications are written to the trie
-		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
-		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
 	}
-
-	close(jobs)
-	pend.Wait()
-
-	// If execution failed in between, abort
-	if failed != nil {
-		return nil, failed
+	cancel()
+	if feeding != nil {
+		<-feeding
 	}
-	return results, nil
+	pend.Wait()
+	return failed
 }
 
 // standardTraceBlockToFile configures a new tracer which uses standard JSON output,
@@ -734,6 +995,11 @@ func (api *API) standardTraceBlockToFile(ctx context.Context, block *types.Block
 	if config != nil && config.Reexec != nil {
 		reexec = *config.Reexec
 	}
+	done, err := api.backend.TraceScheduler().acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	statedb, release, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
 	if err != nil {
 		return nil, err
@@ -847,6 +1113,16 @@ func containsTx(block *types.Block, hash common.Hash) bool {
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (interface{}, error) {
+	var (
+		cache = api.backend.ResponseCache()
+		key   common.Hash
+	)
+	if cache != nil {
+		key = ethapi.ResponseCacheKey("debug_traceTransaction", hash, normalizeTraceConfig(config))
+		if cached, ok := cache.Get(key); ok {
+			return cached, nil
+		}
+	}
 	found, _, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
 	if err != nil {
 		return nil, ethapi.NewTxIndexingError()
@@ -867,6 +1143,21 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if err != nil {
 		return nil, err
 	}
+	// The transaction might be reorged into another block, so its trace is only
+	// determined by the block hash and position
+	var (
+		scheduler = api.backend.TraceScheduler()
+		traceKey  = ethapi.ResponseCacheKey("debug_traceTransaction", blockHash, index, normalizeTraceConfig(config))
+		cached    json.RawMessage
+	)
+	if scheduler.cached(traceKey, &cached) {
+		return cached, nil
+	}
+	done, err := scheduler.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 	tx, vmctx, statedb, release, err := api.backend.StateAtTransaction(ctx, block, int(index), reexec)
 	if err != nil {
 		return nil, err
@@ -883,7 +1174,15 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 		TxIndex:     int(index),
 		TxHash:      hash,
 	}
-	return api.traceTx(ctx, tx, msg, txctx, vmctx, statedb, config)
+	result, err := api.traceTx(ctx, tx, msg, txctx, vmctx, statedb, config)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.cache(traceKey, result)
+	if cache.Immutable(ctx, api.backend, blockNumber, blockHash) {
+		cache.Put(key, result)
+	}
+	return result, nil
 }
 
 // TraceCall lets you trace a given eth_call. It collects the structured logs
@@ -891,45 +1190,25 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 // top of the provided block and returns them as a JSON object.
 // If no transaction index is specified, the trace will be conducted on the state
 // after executing the specified block. However, if a transaction index is provided,
-// the trace will be conducted on the state after executing the specified transaction
-// within the specified block.
+// either in the block reference or in the config, the trace will be conducted on
+// the state right before executing the specified transaction within the specified
+// block.
 func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
 	// Try to retrieve the specified block
-	var (
-		err     error
-		block   *types.Block
-		statedb *state.StateDB
-		release StateReleaseFunc
-	)
-	if hash, ok := blockNrOrHash.Hash(); ok {
-		block, err = api.blockByHash(ctx, hash)
-	} else if number, ok := blockNrOrHash.Number(); ok {
-		if number == rpc.PendingBlockNumber {
-			// We don't have access to the miner here. For tracing 'future' transactions,
-			// it can be done with block- and state-overrides instead, which offers
-			// more flexibility and stability than trying to trace on 'pending', since
-			// the contents of 'pending' is unstable and probably not a true representation
-			// of what the next actual block is likely to contain.
-			return nil, errors.New("tracing on top of pending is not supported")
-		}
-		block, err = api.blockByNumber(ctx, number)
-	} else {
-		return nil, errors.New("invalid arguments; neither block nor hash specified")
-	}
+	block, err := api.callBlock(ctx, blockNrOrHash)
 	if err != nil {
 		return nil, err
 	}
 	// try to recompute the state
-	reexec := defaultTraceReexec
-	if config != nil && config.Reexec != nil {
-		reexec = *config.Reexec
+	var txIndex *hexutil.Uint
+	if config != nil {
+		txIndex = config.TxIndex
 	}
-
-	if config != nil && config.TxIndex != nil {
-		_, _, statedb, release, err = api.backend.StateAtTransaction(ctx, block, int(*config.TxIndex), reexec)
-	} else {
-		statedb, release, err = api.backend.StateAtBlock(ctx, block, reexec, nil, true, false)
+	txIndex, err = mergeTxIndex(blockNrOrHash.TransactionIndex, txIndex)
+	if err != nil {
+		return nil, err
 	}
+	statedb, release, err := api.callState(ctx, block, txIndex, config)
 	if err != nil {
 		return nil, err
 	}
@@ -958,6 +1237,133 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 	return api.traceTx(ctx, tx, msg, new(Context), vmctx, statedb, traceConfig)
 }
 
+// callTraceResult is the result of tracing a single call of a bundle.
+type callTraceResult struct {
+	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
+	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// TraceCallMany lets you trace a sequence of bundles of calls, like eth_callMany.
+// The calls are executed one after the other on top of the block selected by the
+// state context, or right before one of its transactions, each of them seeing
+// the state changes of the previous ones. Every bundle runs in the block context
+// of the selected block, modified by its own block overrides. The state overrides
+// of the config are applied once before the first bundle, its block overrides and
+// transaction index are ignored.
+func (api *API) TraceCallMany(ctx context.Context, bundles []ethapi.Bundle, stateContext ethapi.StateContext, config *TraceCallConfig) ([][]*callTraceResult, error) {
+	block, err := api.callBlock(ctx, stateContext.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	txIndex, err := mergeTxIndex(stateContext.BlockNumber.TransactionIndex, stateContext.TransactionIndex)
+	if err != nil {
+		return nil, err
+	}
+	statedb, release, err := api.callState(ctx, block, txIndex, config)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var traceConfig *TraceConfig
+	if config != nil {
+		if err := config.StateOverrides.Apply(statedb); err != nil {
+			return nil, err
+		}
+		traceConfig = &config.TraceConfig
+	}
+	results := make([][]*callTraceResult, len(bundles))
+	for i, bundle := range bundles {
+		vmctx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+		bundle.BlockOverride.Apply(&vmctx)
+
+		results[i] = make([]*callTraceResult, len(bundle.Transactions))
+		for j, args := range bundle.Transactions {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := args.CallDefaults(api.backend.RPCGasCap(), vmctx.BaseFee, api.backend.ChainConfig().ChainID); err != nil {
+				results[i][j] = &callTraceResult{Error: err.Error()}
+				continue
+			}
+			var (
+				msg = args.ToMessage(vmctx.BaseFee)
+				tx  = args.ToTransaction()
+			)
+			res, err := api.traceTx(ctx, tx, msg, new(Context), vmctx, statedb, traceConfig)
+			if err != nil {
+				results[i][j] = &callTraceResult{Error: err.Error()}
+				continue
+			}
+			results[i][j] = &callTraceResult{Result: res}
+		}
+	}
+	return results, nil
+}
+
+// callBlock retrieves the block on top of which calls are traced.
+func (api *API) callBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return api.blockByHash(ctx, hash)
+	}
+	if number, ok := blockNrOrHash.Number(); ok {
+		if number == rpc.PendingBlockNumber {
+			// We don't have access to the miner here. For tracing 'future' transactions,
+			// it can be done with block- and state-overrides instead, which offers
+			// more flexibility and stability than trying to trace on 'pending', since
+			// the contents of 'pending' is unstable and probably not a true representation
+			// of what the next actual block is likely to contain.
+			return nil, errors.New("tracing on top of pending is not supported")
+		}
+		return api.blockByNumber(ctx, number)
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+// mergeTxIndex combines the transaction index of a block reference with the one
+// given separately, rejecting requests which specify both.
+func mergeTxIndex(blockIndex, index *hexutil.Uint) (*hexutil.Uint, error) {
+	if blockIndex != nil && index != nil {
+		return nil, errors.New("transaction index specified twice")
+	}
+	if blockIndex != nil {
+		return blockIndex, nil
+	}
+	return index, nil
+}
+
+// callState recomputes the state calls are traced on: the one right before the
+// transaction with the given index, or the one after the block if it is nil.
+// The returned release function also gives back the execution slot taken for
+// the calls.
+func (api *API) callState(ctx context.Context, block *types.Block, txIndex *hexutil.Uint, config *TraceCallConfig) (*state.StateDB, StateReleaseFunc, error) {
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	done, err := api.backend.TraceScheduler().acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		statedb *state.StateDB
+		release StateReleaseFunc
+	)
+	if txIndex != nil {
+		_, _, statedb, release, err = api.backend.StateAtTransaction(ctx, block, int(*txIndex), reexec)
+	} else {
+		statedb, release, err = api.backend.StateAtBlock(ctx, block, reexec, nil, true, false)
+	}
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+	return statedb, func() {
+		release()
+		done()
+	}, nil
+}
+
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
@@ -980,6 +1386,8 @@ func (api *API) traceTx(ctx context.Context, tx *types.Transaction, message *cor
 			Stop:      logger.Stop,
 		}
 	} else {
+		// Let the tracer decode custom revert errors known to the node
+		txctx.ErrorRegistry = api.backend.ErrorRegistry()
 		tracer, err = DefaultDirectory.New(*config.Tracer, txctx, config.TracerConfig)
 		if err != nil {
 			return nil, err
@@ -1021,11 +1429,48 @@ func APIs(backend Backend) []rpc.API {
 	return []rpc.API{
 		{
 			Namespace: "debug",
-			Service:   NewAPI(backend),
+			Service:   &service{NewAPI(backend)},
+		},
+		{
+			Namespace: "debug",
+			Service:   NewStreamAPI(backend),
 		},
 	}
 }
 
+// service is the RPC service of API. It serves the same methods, but streams the
+// results of block traces to the client as they are produced, instead of
+// collecting them first.
+type service struct {
+	*API
+}
+
+// TraceBlockByNumber streams the traces of the transactions of a block.
+func (s *service) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
+	return s.traceBlockByNumber(ctx, number, config)
+}
+
+// TraceBlockByHash streams the traces of the transactions of a block.
+func (s *service) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
+	return s.traceBlockByHash(ctx, hash, config)
+}
+
+// TraceBlock streams the traces of the transactions of an RLP encoded block.
+func (s *service) TraceBlock(ctx context.Context, blob hexutil.Bytes, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
+	return s.traceBlockRLP(ctx, blob, config)
+}
+
+// TraceBlockFromFile streams the traces of the transactions of a block read
+// from a file.
+func (s *service) TraceBlockFromFile(ctx context.Context, file string, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
+	return s.traceBlockFromFile(ctx, file, config)
+}
+
+// TraceBadBlock streams the traces of the transactions of a bad block.
+func (s *service) TraceBadBlock(ctx context.Context, hash common.Hash, config *TraceConfig) (rpc.Stream[*txTraceResult], error) {
+	return s.traceBadBlock(ctx, hash, config)
+}
+
 // overrideConfig returns a copy of original with forks enabled by override enabled,
 // along with a boolean that indicates whether the copy is canonical (equivalent to the original).
 // Note: the Clique-part is _not_ deep copied
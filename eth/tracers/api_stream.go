@@ -0,0 +1,474 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+const (
+	// defaultStreamBatchSize is the number of trace entries delivered in a single
+	// notification, unless configured otherwise.
+	defaultStreamBatchSize = 256
+
+	// maximumStreamBatchSize is the maximum number of trace entries a subscriber
+	// may request to be delivered in a single notification.
+	maximumStreamBatchSize = 16384
+
+	// streamPendingBatches is the number of batches queued for delivery before
+	// tracing is paused to wait for the subscriber to catch up.
+	streamPendingBatches = 4
+
+	// streamSendTimeout is the time tracing stays paused waiting for the
+	// subscriber to catch up, before the stream is aborted.
+	streamSendTimeout = 30 * time.Second
+
+	// defaultStreamTimeout is the amount of time a stream may take from start
+	// to the end of execution, unless configured otherwise.
+	defaultStreamTimeout = 10 * time.Minute
+)
+
+const (
+	streamFormatJSON       = "json"       // EIP-3155 lines as produced by the JSON logger
+	streamFormatStructLogs = "structLogs" // Struct logs as returned by debug_traceTransaction
+)
+
+var (
+	errStreamClosed   = errors.New("trace stream closed")
+	errStreamTimeout  = errors.New("trace stream subscriber too slow")
+	errStreamDeadline = errors.New("trace stream deadline exceeded")
+)
+
+// StreamAPI is the collection of tracing APIs streaming standard traces to
+// subscribers as the transactions are executed, instead of collecting them in
+// memory or on disk first.
+type StreamAPI struct {
+	api *API
+}
+
+// NewStreamAPI creates a new API definition for the streaming tracing methods
+// of the Ethereum service.
+func NewStreamAPI(backend Backend) *StreamAPI {
+	return &StreamAPI{api: NewAPI(backend)}
+}
+
+// streamConfig holds the validated parameters of streamed standard traces.
+type streamConfig struct {
+	logger.Config
+	txHash    common.Hash // Only trace this transaction of the block
+	format    string      // Either "json" or "structLogs"
+	batchSize int         // Maximum number of entries per notification
+	timeout   time.Duration
+}
+
+// TraceTransaction streams the standard trace of a transaction to the subscriber.
+// Every notification holds a batch of trace entries, the last one of which is
+// marked as done and ends with the execution summary. A final notification marks
+// the end of the stream.
+func (api *StreamAPI) TraceTransaction(ctx context.Context, hash common.Hash, config *rpctypes.StreamTraceConfig) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	found, _, blockHash, blockNumber, index, err := api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, ethapi.NewTxIndexingError()
+	}
+	// Only mined txes are supported
+	if !found {
+		return nil, errTxNotFound
+	}
+	if blockNumber == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if config == nil {
+		config = new(rpctypes.StreamTraceConfig)
+	}
+	stream, err := newStreamConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	block, err := api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
+	if err != nil {
+		return nil, err
+	}
+	reexec := defaultTraceReexec
+	if config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	done, err := api.api.backend.TraceScheduler().acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, _, statedb, release, err := api.api.backend.StateAtTransaction(ctx, block, int(index), reexec)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	sub := notifier.CreateSubscription()
+	go api.stream(notifier, sub, block, statedb, func() { release(); done() }, int(index), hash, stream)
+	return sub, nil
+}
+
+// TraceBlock streams the standard traces of the transactions in a block to the
+// subscriber, or only the one of the configured transaction.
+func (api *StreamAPI) TraceBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *rpctypes.StreamTraceConfig) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	if config == nil {
+		config = new(rpctypes.StreamTraceConfig)
+	}
+	stream, err := newStreamConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	block, err := api.api.callBlock(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if stream.txHash != (common.Hash{}) && !containsTx(block, stream.txHash) {
+		return nil, fmt.Errorf("transaction %#x not found in block", stream.txHash)
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent, err := api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	reexec := defaultTraceReexec
+	if config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	done, err := api.api.backend.TraceScheduler().acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statedb, release, err := api.api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		vmctx := core.NewEVMBlockContext(block.Header(), api.api.chainContext(ctx), nil)
+		vmenv := vm.NewEVM(vmctx, vm.TxContext{}, statedb, api.api.backend.ChainConfig(), vm.Config{})
+		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+	}
+	sub := notifier.CreateSubscription()
+	go api.stream(notifier, sub, block, statedb, func() { release(); done() }, 0, stream.txHash, stream)
+	return sub, nil
+}
+
+// newStreamConfig checks the streaming parameters and fills in the defaults.
+func newStreamConfig(config *rpctypes.StreamTraceConfig) (*streamConfig, error) {
+	stream := &streamConfig{
+		Config: logger.Config{
+			EnableMemory:     config.EnableMemory,
+			DisableStack:     config.DisableStack,
+			DisableStorage:   config.DisableStorage,
+			EnableReturnData: config.EnableReturnData,
+			Limit:            config.Limit,
+			Overrides:        config.Overrides,
+		},
+		format:    config.Format,
+		batchSize: config.BatchSize,
+		timeout:   defaultStreamTimeout,
+	}
+	if config.TxHash != nil {
+		stream.txHash = *config.TxHash
+	}
+	switch stream.format {
+	case "":
+		stream.format = streamFormatJSON
+	case streamFormatJSON, streamFormatStructLogs:
+	default:
+		return nil, fmt.Errorf("unknown trace format %q", stream.format)
+	}
+	switch {
+	case stream.batchSize < 0 || stream.batchSize > maximumStreamBatchSize:
+		return nil, fmt.Errorf("batch size %d out of range [1, %d]", stream.batchSize, maximumStreamBatchSize)
+	case stream.batchSize == 0:
+		stream.batchSize = defaultStreamBatchSize
+	}
+	if config.Timeout != nil {
+		timeout, err := time.ParseDuration(*config.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		stream.timeout = timeout
+	}
+	return stream, nil
+}
+
+// stream executes the transactions of the block starting at the given index on
+// top of the provided state, and delivers the traces of the requested one, or of
+// all of them if no hash is given, to the subscriber.
+//
+// Execution is paused whenever the subscriber falls behind, and aborted if it
+// doesn't catch up in time, unsubscribes, the notifications can't be delivered
+// or the configured timeout of the whole stream expires.
+// The execution slot and the state are given back by release as soon as execution
+// ends, while the remaining batches are still being delivered. The stream ends
+// with a notification marked as the end.
+func (api *StreamAPI) stream(notifier *rpc.Notifier, sub *rpc.Subscription, block *types.Block, statedb *state.StateDB, release StateReleaseFunc, start int, txHash common.Hash, config *streamConfig) {
+	var (
+		queue  = make(chan *rpctypes.StreamedTrace, streamPendingBatches)
+		failed = make(chan struct{})
+		end    = &rpctypes.StreamedTrace{End: true} // Completed before the queue is closed
+	)
+	go func() {
+		for batch := range queue {
+			if err := notifier.Notify(sub.ID, batch); err != nil {
+				log.Debug("Trace stream delivery failed", "id", sub.ID, "err", err)
+				close(failed)
+				return
+			}
+		}
+		notifier.Notify(sub.ID, end)
+	}()
+	defer close(queue)
+
+	var (
+		begin       = time.Now()
+		deadline    = begin.Add(config.timeout)
+		stream      = &traceStream{closed: sub.Err(), failed: failed, queue: queue, size: config.batchSize, timeout: streamSendTimeout, deadline: deadline}
+		traced      int
+		ctx         = context.Background()
+		signer      = types.MakeSigner(api.api.backend.ChainConfig(), block.Number(), block.Time())
+		chainConfig = api.api.backend.ChainConfig()
+		vmctx       = core.NewEVMBlockContext(block.Header(), api.api.chainContext(ctx), nil)
+		txs         = block.Transactions()
+	)
+	// Chain overrides only apply to the traced block, not to the blocks the
+	// state was regenerated from.
+	if config.Overrides != nil {
+		chainConfig, _ = overrideConfig(chainConfig, config.Overrides)
+	}
+	for i := start; i < len(txs); i++ {
+		if time.Now().After(deadline) {
+			stream.err = errStreamDeadline
+			break
+		}
+		var (
+			tx     = txs[i]
+			msg, _ = core.TransactionToMessage(tx, signer, block.BaseFee())
+			trace  = tx.Hash() == txHash || txHash == (common.Hash{})
+			tracer *streamTracer
+			vmConf vm.Config
+		)
+		if trace {
+			stream.begin(i, tx.Hash())
+			tracer = newStreamTracer(stream, config)
+			vmConf.Tracer = tracer.hooks
+		}
+		vmenv := vm.NewEVM(vmctx, core.NewEVMTxContext(msg), statedb, chainConfig, vmConf)
+		if tracer != nil {
+			tracer.evm = vmenv
+		}
+		statedb.SetTxContext(tx.Hash(), i)
+		if vmConf.Tracer != nil && vmConf.Tracer.OnTxStart != nil {
+			vmConf.Tracer.OnTxStart(vmenv.GetVMContext(), tx, msg.From)
+		}
+		timer := time.AfterFunc(time.Until(deadline), vmenv.Cancel)
+		res, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.GasLimit))
+		timer.Stop()
+		if err != nil {
+			// Report failures of untraced transactions too, as the traces of the
+			// later ones would never arrive otherwise
+			if !trace {
+				stream.begin(i, tx.Hash())
+			}
+			stream.finish(err)
+			break
+		}
+		if trace {
+			if err := stream.finish(tracer.finish(res)); err != nil {
+				break
+			}
+			traced++
+		}
+		// Finalize the state so any modifications are written to the trie
+		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+
+		// If we've traced the transaction we were looking for, abort
+		if tx.Hash() == txHash {
+			break
+		}
+	}
+	release()
+
+	if stream.err != nil {
+		end.Error = stream.err.Error()
+		log.Debug("Trace stream aborted", "block", block.NumberU64(), "transactions", traced, "elapsed", time.Since(begin), "err", stream.err)
+	} else {
+		log.Debug("Trace stream finished", "block", block.NumberU64(), "transactions", traced, "elapsed", time.Since(begin))
+	}
+}
+
+// traceStream batches trace entries of the transaction being traced and queues
+// them for delivery, blocking while the delivery queue is full.
+type traceStream struct {
+	closed   <-chan error                   // Closed when the subscriber unsubscribes
+	failed   <-chan struct{}                // Closed when a notification can't be delivered
+	queue    chan<- *rpctypes.StreamedTrace // Batches pending delivery
+	size     int                            // Maximum number of entries in a batch
+	timeout  time.Duration                  // Time to wait for a full queue to drain
+	deadline time.Time                      // End of the stream, zero if unlimited
+
+	batch *rpctypes.StreamedTrace // Batch being filled
+	err   error                   // Error aborting the stream
+}
+
+// begin starts collecting the entries of a new transaction.
+func (s *traceStream) begin(index int, hash common.Hash) {
+	s.batch = &rpctypes.StreamedTrace{TxIndex: index, TxHash: hash, Entries: make([]json.RawMessage, 0, s.size)}
+}
+
+// add appends an entry to the current batch, delivering it if full.
+func (s *traceStream) add(entry json.RawMessage) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.batch.Entries = append(s.batch.Entries, entry)
+	if len(s.batch.Entries) < s.size {
+		return nil
+	}
+	return s.send(false)
+}
+
+// finish delivers the last batch of the current transaction, along with the
+// error aborting its execution, if any.
+func (s *traceStream) finish(err error) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err != nil {
+		s.batch.Error = err.Error()
+	}
+	return s.send(true)
+}
+
+// send queues the current batch for delivery and starts a new one. The stream is
+// aborted if the queue doesn't drain in time or the deadline of the stream passes.
+func (s *traceStream) send(done bool) error {
+	s.batch.Done = done
+
+	var (
+		wait    = s.timeout
+		timeout = errStreamTimeout
+	)
+	if !s.deadline.IsZero() && time.Until(s.deadline) < wait {
+		wait, timeout = time.Until(s.deadline), errStreamDeadline
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case s.queue <- s.batch:
+	case <-s.closed:
+		s.err = errStreamClosed
+	case <-s.failed:
+		s.err = errStreamClosed
+	case <-timer.C:
+		s.err = timeout
+	}
+	s.begin(s.batch.TxIndex, s.batch.TxHash)
+	return s.err
+}
+
+// streamTracer produces the trace entries of a transaction in the requested
+// format, cancelling the execution if they can't be streamed.
+type streamTracer struct {
+	stream *traceStream
+	evm    *vm.EVM
+	hooks  *tracing.Hooks
+	logger *logger.StructLogger // Struct logger, if the struct log format is used
+}
+
+func newStreamTracer(stream *traceStream, config *streamConfig) *streamTracer {
+	t := &streamTracer{stream: stream}
+	if config.format == streamFormatJSON {
+		t.hooks = logger.NewJSONLogger(&config.Config, t)
+		return t
+	}
+	t.logger = logger.NewStructLogger(&config.Config)
+	t.hooks = t.logger.Hooks()
+	t.hooks.OnOpcode = func(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+		t.logger.OnOpcode(pc, op, gas, cost, scope, rData, depth, err)
+		for _, entry := range logger.FormatLogs(t.logger.Drain()) {
+			blob, err := json.Marshal(entry)
+			if err == nil {
+				err = t.add(blob)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return t
+}
+
+// Write implements io.Writer, receiving the lines of the JSON logger.
+func (t *streamTracer) Write(line []byte) (int, error) {
+	if err := t.add(bytes.TrimSpace(common.CopyBytes(line))); err != nil {
+		return 0, err
+	}
+	return len(line), nil
+}
+
+// add streams a trace entry, cancelling execution on failure.
+func (t *streamTracer) add(entry json.RawMessage) error {
+	if err := t.stream.add(entry); err != nil {
+		t.evm.Cancel()
+		return err
+	}
+	return nil
+}
+
+// finish streams the execution summary of the struct logger. The JSON logger
+// emits its summary itself.
+func (t *streamTracer) finish(res *core.ExecutionResult) error {
+	if t.logger == nil {
+		return t.stream.err
+	}
+	t.hooks.OnTxEnd(&types.Receipt{GasUsed: res.UsedGas}, nil)
+	summary, err := t.logger.GetResult()
+	if err != nil {
+		return err
+	}
+	return t.add(summary)
+}
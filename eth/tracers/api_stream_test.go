@@ -0,0 +1,207 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// collectStream subscribes to a trace stream and collects the entries of every
+// transaction until the end of the stream, which must come after the given number
+// of transactions is complete.
+func collectStream(t *testing.T, client *rpc.Client, txs int, batchSize int, args ...interface{}) map[int][]json.RawMessage {
+	t.Helper()
+
+	ch := make(chan *rpctypes.StreamedTrace)
+	sub, err := client.Subscribe(context.Background(), "debug", ch, args...)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var (
+		entries = make(map[int][]json.RawMessage)
+		done    int
+	)
+	for {
+		select {
+		case batch := <-ch:
+			if batch.End {
+				if batch.Error != "" || done != txs {
+					t.Fatalf("stream ended after %d transactions: %v", done, batch.Error)
+				}
+				return entries
+			}
+			if len(batch.Entries) > batchSize {
+				t.Fatalf("batch size exceeded: %d > %d", len(batch.Entries), batchSize)
+			}
+			if batch.Error != "" {
+				t.Fatalf("transaction %d failed: %v", batch.TxIndex, batch.Error)
+			}
+			entries[batch.TxIndex] = append(entries[batch.TxIndex], batch.Entries...)
+			if batch.Done {
+				done++
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for trace entries")
+		}
+	}
+}
+
+func TestStreamTrace(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(2)
+		contract = common.HexToAddress("0xc0de")
+		code     = []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 2, byte(vm.ADD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+				contract:         {Balance: common.Big0, Code: code},
+			},
+		}
+		signer = types.HomesteadSigner{}
+		hashes []common.Hash
+	)
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		for nonce, to := range []common.Address{contract, accounts[1].addr} {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(nonce), to, big.NewInt(1000), 100000, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+			hashes = append(hashes, tx.Hash())
+		}
+	})
+	defer backend.teardown()
+
+	srv := rpc.NewServer()
+	defer srv.Stop()
+	if err := srv.RegisterName("debug", NewStreamAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(srv)
+	defer client.Close()
+
+	// The streamed EIP-3155 lines must match the ones written to file
+	block := backend.chain.GetBlockByNumber(1)
+	files, err := NewAPI(backend).StandardTraceBlockToFile(context.Background(), block.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace block to file: %v", err)
+	}
+	defer func() {
+		for _, file := range files {
+			os.Remove(file)
+		}
+	}()
+	streamed := collectStream(t, client, 2, 2, "traceBlock", rpc.BlockNumberOrHashWithNumber(1), &rpctypes.StreamTraceConfig{BatchSize: 2})
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read trace file: %v", err)
+		}
+		lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+		if len(lines) != len(streamed[i]) {
+			t.Fatalf("transaction %d: entry count mismatch: have %d, want %d", i, len(streamed[i]), len(lines))
+		}
+		for j, line := range lines {
+			if !bytes.Equal(line, streamed[i][j]) {
+				t.Errorf("transaction %d entry %d mismatch: have %s, want %s", i, j, streamed[i][j], line)
+			}
+		}
+	}
+	// Struct logs of a single transaction end with the execution summary
+	streamed = collectStream(t, client, 1, defaultStreamBatchSize, "traceTransaction", hashes[0], &rpctypes.StreamTraceConfig{Format: "structLogs"})
+	entries := streamed[0]
+	if len(entries) != len(code)-3+1 {
+		t.Fatalf("entry count mismatch: have %d, want %d", len(entries), len(code)-3+1)
+	}
+	var log logger.StructLogRes
+	if err := json.Unmarshal(entries[0], &log); err != nil || log.Op != "PUSH1" || log.Pc != 0 {
+		t.Errorf("first struct log mismatch: %s", entries[0])
+	}
+	var summary logger.ExecutionResult
+	if err := json.Unmarshal(entries[len(entries)-1], &summary); err != nil || summary.Failed || summary.Gas != backend.chain.GetReceiptsByHash(block.Hash())[0].GasUsed {
+		t.Errorf("execution summary mismatch: %s", entries[len(entries)-1])
+	}
+	// Invalid parameters are rejected at subscription time
+	ch := make(chan *rpctypes.StreamedTrace)
+	if _, err := client.Subscribe(context.Background(), "debug", ch, "traceTransaction", hashes[0], map[string]interface{}{"format": "yaml"}); err == nil {
+		t.Errorf("unknown format accepted")
+	}
+	if _, err := client.Subscribe(context.Background(), "debug", ch, "traceBlock", rpc.BlockNumberOrHashWithNumber(1), &rpctypes.StreamTraceConfig{TxHash: &common.Hash{1}}); err == nil {
+		t.Errorf("unknown transaction accepted")
+	}
+}
+
+// Tests that streams are aborted if the subscriber doesn't keep up.
+func TestStreamTimeout(t *testing.T) {
+	t.Parallel()
+
+	var (
+		queue  = make(chan *rpctypes.StreamedTrace, 1)
+		stream = &traceStream{queue: queue, size: 1, timeout: 50 * time.Millisecond}
+	)
+	stream.begin(0, common.Hash{})
+	if err := stream.add(json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("failed to queue first batch: %v", err)
+	}
+	if err := stream.add(json.RawMessage(`{}`)); err != errStreamTimeout {
+		t.Fatalf("wrong error for stalled subscriber: have %v, want %v", err, errStreamTimeout)
+	}
+	if err := stream.finish(nil); err != errStreamTimeout {
+		t.Fatalf("stream not aborted: %v", err)
+	}
+}
+
+// Tests that streams are aborted once their deadline passes, even if the
+// subscriber keeps up with every single batch.
+func TestStreamDeadline(t *testing.T) {
+	t.Parallel()
+
+	var (
+		queue  = make(chan *rpctypes.StreamedTrace, 1)
+		stream = &traceStream{queue: queue, size: 1, timeout: time.Minute, deadline: time.Now().Add(50 * time.Millisecond)}
+	)
+	stream.begin(0, common.Hash{})
+	if err := stream.add(json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("failed to queue first batch: %v", err)
+	}
+	start := time.Now()
+	if err := stream.add(json.RawMessage(`{}`)); err != errStreamDeadline {
+		t.Fatalf("wrong error for expired stream: have %v, want %v", err, errStreamDeadline)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("stream aborted after the send timeout instead of the deadline: %v", elapsed)
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
@@ -58,6 +59,9 @@ type testBackend struct {
 	chaindb     ethdb.Database
 	chain       *core.BlockChain
 
+	scheduler *Scheduler         // Optional scheduler limiting and caching traces
+	errors    *abi.ErrorRegistry // Optional registry decoding custom revert errors
+
 	refHook func() // Hook is invoked when the requested state is referenced
 	relHook func() // Hook is invoked when the requested state is released
 }
@@ -123,6 +127,18 @@ func (b *testBackend) RPCGasCap() uint64 {
 	return 25000000
 }
 
+func (b *testBackend) ResponseCache() *ethapi.ResponseCache {
+	return nil
+}
+
+func (b *testBackend) TraceScheduler() *Scheduler {
+	return b.scheduler
+}
+
+func (b *testBackend) ErrorRegistry() *abi.ErrorRegistry {
+	return b.errors
+}
+
 func (b *testBackend) ChainConfig() *params.ChainConfig {
 	return b.chainConfig
 }
@@ -250,6 +266,7 @@ func TestTraceCall(t *testing.T) {
 	api := NewAPI(backend)
 	var testSuite = []struct {
 		blockNumber rpc.BlockNumber
+		txIndex     *hexutil.Uint
 		call        ethapi.TransactionArgs
 		config      *TraceCallConfig
 		expectErr   error
@@ -324,6 +341,40 @@ func TestTraceCall(t *testing.T) {
 			expectErr: nil,
 			expect:    `{"gas":21000,"failed":false,"returnValue":"","structLogs":[]}`,
 		},
+		// Before the target transaction referenced by the block, should be failed
+		{
+			blockNumber: rpc.BlockNumber(genBlocks - 1),
+			txIndex:     uintPtr(1),
+			call: ethapi.TransactionArgs{
+				From:  &accounts[2].addr,
+				To:    &accounts[0].addr,
+				Value: (*hexutil.Big)(new(big.Int).Add(big.NewInt(params.Ether), big.NewInt(100))),
+			},
+			expectErr: fmt.Errorf("tracing failed: insufficient funds for gas * price + value: address %s have 1000000000000000000 want 1000000000000000100", accounts[2].addr),
+		},
+		// After the target transaction referenced by the block, should be succeeded
+		{
+			blockNumber: rpc.BlockNumber(genBlocks - 1),
+			txIndex:     uintPtr(2),
+			call: ethapi.TransactionArgs{
+				From:  &accounts[2].addr,
+				To:    &accounts[0].addr,
+				Value: (*hexutil.Big)(new(big.Int).Add(big.NewInt(params.Ether), big.NewInt(100))),
+			},
+			expect: `{"gas":21000,"failed":false,"returnValue":"","structLogs":[]}`,
+		},
+		// Transaction index given both in the block reference and the config
+		{
+			blockNumber: rpc.BlockNumber(genBlocks - 1),
+			txIndex:     uintPtr(2),
+			call: ethapi.TransactionArgs{
+				From:  &accounts[0].addr,
+				To:    &accounts[1].addr,
+				Value: (*hexutil.Big)(big.NewInt(1000)),
+			},
+			config:    &TraceCallConfig{TxIndex: uintPtr(2)},
+			expectErr: errors.New("transaction index specified twice"),
+		},
 		// Standard JSON trace upon the non-existent block, error expects
 		{
 			blockNumber: rpc.BlockNumber(genBlocks + 1),
@@ -375,7 +426,7 @@ func TestTraceCall(t *testing.T) {
 		},
 	}
 	for i, testspec := range testSuite {
-		result, err := api.TraceCall(context.Background(), testspec.call, rpc.BlockNumberOrHash{BlockNumber: &testspec.blockNumber}, testspec.config)
+		result, err := api.TraceCall(context.Background(), testspec.call, rpc.BlockNumberOrHash{BlockNumber: &testspec.blockNumber, TransactionIndex: testspec.txIndex}, testspec.config)
 		if testspec.expectErr != nil {
 			if err == nil {
 				t.Errorf("test %d: expect error %v, got nothing", i, testspec.expectErr)
@@ -404,6 +455,63 @@ func TestTraceCall(t *testing.T) {
 	}
 }
 
+func TestTraceCallMany(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		// Transfer from account[0] to account[1]
+		tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+			Nonce:    0,
+			To:       &accounts[1].addr,
+			Value:    big.NewInt(1000),
+			Gas:      params.TxGas,
+			GasPrice: b.BaseFee(),
+		}), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	defer backend.teardown()
+	api := NewAPI(backend)
+
+	// Moving almost the whole initial balance only works once, and only before
+	// the transfer of the block.
+	value := (*hexutil.Big)(new(big.Int).Sub(big.NewInt(params.Ether), big.NewInt(500)))
+	transfer := ethapi.TransactionArgs{From: &accounts[0].addr, To: &accounts[1].addr, Value: value}
+	bundles := []ethapi.Bundle{{Transactions: []ethapi.TransactionArgs{transfer, transfer}}}
+
+	index := hexutil.Uint(0)
+	results, err := api.TraceCallMany(context.Background(), bundles, ethapi.StateContext{
+		BlockNumber:      rpc.BlockNumberOrHashWithNumber(1),
+		TransactionIndex: &index,
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to trace call bundles: %v", err)
+	}
+	have, _ := json.Marshal(results)
+	want := `[[{"result":{"gas":21000,"failed":false,"returnValue":"","structLogs":[]}},{"error":"tracing failed: insufficient funds for gas * price + value: address ` + accounts[0].addr.Hex() + ` have 500 want 999999999999999500"}]]`
+	if string(have) != want {
+		t.Errorf("result mismatch before transaction\nhave: %s\nwant: %s", have, want)
+	}
+	results, err = api.TraceCallMany(context.Background(), bundles[:1], ethapi.StateContext{
+		BlockNumber: rpc.BlockNumberOrHashWithNumber(1),
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to trace call bundles: %v", err)
+	}
+	if results[0][0].Error == "" {
+		t.Errorf("transfer succeeded after the block: %+v", results[0][0])
+	}
+}
+
 func TestTraceTransaction(t *testing.T) {
 	t.Parallel()
 
@@ -459,6 +567,66 @@ func TestTraceTransaction(t *testing.T) {
 	}
 }
 
+func TestGetBlockReceipts(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts, and a contract reverting with a custom error
+	var (
+		accounts = newAccounts(2)
+		reverter = common.HexToAddress("0x00000000000000000000000000000000000000be")
+		selector = crypto.Keccak256([]byte("InsufficientBalance(uint256,uint256)"))[:4]
+	)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			reverter: {Code: []byte{
+				byte(vm.PUSH4), selector[0], selector[1], selector[2], selector[3], byte(vm.PUSH1), 0xe0, byte(vm.SHL), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+				byte(vm.PUSH1), 1, byte(vm.PUSH1), 4, byte(vm.MSTORE), byte(vm.PUSH1), 2, byte(vm.PUSH1), 36, byte(vm.MSTORE),
+				byte(vm.PUSH1), 68, byte(vm.PUSH1), 0, byte(vm.REVERT),
+			}},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		for nonce, to := range []common.Address{accounts[1].addr, reverter, accounts[1].addr} {
+			tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{
+				Nonce:    uint64(nonce),
+				To:       &to,
+				Value:    big.NewInt(0),
+				Gas:      100000,
+				GasPrice: b.BaseFee(),
+			}), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	})
+	defer backend.chain.Stop()
+	backend.errors = abi.NewErrorRegistry()
+	if err := backend.errors.AddSignature("InsufficientBalance(uint256,uint256)"); err != nil {
+		t.Fatal(err)
+	}
+	api := NewAPI(backend)
+	receipts, err := api.GetBlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(1))
+	if err != nil {
+		t.Fatalf("failed to get receipts: %v", err)
+	}
+	if len(receipts) != 3 {
+		t.Fatalf("wrong number of receipts: have %d, want 3", len(receipts))
+	}
+	for i, receipt := range receipts {
+		_, hasData := receipt["revertData"]
+		if reverted := i == 1; hasData != reverted {
+			t.Errorf("receipt %d: revert data mismatch: %v", i, receipt["revertData"])
+		}
+	}
+	if reason := receipts[1]["revertReason"]; reason != "InsufficientBalance(1, 2)" {
+		t.Errorf("wrong revert reason: %v", reason)
+	}
+	if status := receipts[1]["status"]; status != hexutil.Uint(types.ReceiptStatusFailed) {
+		t.Errorf("wrong status of reverted transaction: %v", status)
+	}
+}
+
 func TestTraceBlock(t *testing.T) {
 	t.Parallel()
 
@@ -25,6 +25,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
@@ -35,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpctypes"
 	"github.com/ethereum/go-ethereum/tests"
 )
 
@@ -159,6 +161,11 @@ func testCallTracer(tracerName string, dirPath string, t *testing.T) {
 			if string(want) != string(res) {
 				t.Fatalf("trace mismatch\n have: %v\n want: %v\n", string(res), string(want))
 			}
+			if !isLegacy {
+				if err := checkRoundTrip(res, new(rpctypes.CallFrame)); err != nil {
+					t.Fatal(err)
+				}
+			}
 			// Sanity check: compare top call's gas used against vm result
 			type simpleResult struct {
 				GasUsed hexutil.Uint64
@@ -272,12 +279,32 @@ func TestInternals(t *testing.T) {
 		}
 		return tr
 	}
+	registry := abi.NewErrorRegistry()
+	if err := registry.AddSignature("InsufficientBalance(uint256,uint256)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.AddSignature("CallFailed()"); err != nil {
+		t.Fatal(err)
+	}
+	selector := crypto.Keccak256([]byte("InsufficientBalance(uint256,uint256)"))[:4]
+	outerSelector := crypto.Keccak256([]byte("CallFailed()"))[:4]
+	inner := common.HexToAddress("0x00000000000000000000000000000000000000be")
+	decodingTracer, err := tracers.DefaultDirectory.New("callTracer", &tracers.Context{ErrorRegistry: registry}, nil)
+	if err != nil {
+		t.Fatalf("failed to create call tracer: %v", err)
+	}
+	chainTracer, err := tracers.DefaultDirectory.New("callTracer", &tracers.Context{ErrorRegistry: registry}, nil)
+	if err != nil {
+		t.Fatalf("failed to create call tracer: %v", err)
+	}
 
 	for _, tc := range []struct {
 		name   string
 		code   []byte
+		alloc  types.GenesisAlloc // Accounts besides the called contract and the sender
 		tracer *tracers.Tracer
 		want   string
+		result interface{} // Exported result type of the tracer
 	}{
 		{
 			// TestZeroValueToNotExitCall tests the calltracer(s) on the following:
@@ -291,12 +318,14 @@ func TestInternals(t *testing.T) {
 			},
 			tracer: mkTracer("callTracer", nil),
 			want:   fmt.Sprintf(`{"from":"%s","gas":"0x13880","gasUsed":"0x54d8","to":"0x00000000000000000000000000000000deadbeef","input":"0x","calls":[{"from":"0x00000000000000000000000000000000deadbeef","gas":"0xe01a","gasUsed":"0x0","to":"0x00000000000000000000000000000000000000ff","input":"0x","value":"0x0","type":"CALL"}],"value":"0x0","type":"CALL"}`, originHex),
+			result: new(rpctypes.CallFrame),
 		},
 		{
 			name:   "Stack depletion in LOG0",
 			code:   []byte{byte(vm.LOG3)},
 			tracer: mkTracer("callTracer", json.RawMessage(`{ "withLog": true }`)),
 			want:   fmt.Sprintf(`{"from":"%s","gas":"0x13880","gasUsed":"0x13880","to":"0x00000000000000000000000000000000deadbeef","input":"0x","error":"stack underflow (0 \u003c=\u003e 5)","value":"0x0","type":"CALL"}`, originHex),
+			result: new(rpctypes.CallFrame),
 		},
 		{
 			name: "Mem expansion in LOG0",
@@ -310,6 +339,39 @@ func TestInternals(t *testing.T) {
 			},
 			tracer: mkTracer("callTracer", json.RawMessage(`{ "withLog": true }`)),
 			want:   fmt.Sprintf(`{"from":"%s","gas":"0x13880","gasUsed":"0x5b9e","to":"0x00000000000000000000000000000000deadbeef","input":"0x","logs":[{"address":"0x00000000000000000000000000000000deadbeef","topics":[],"data":"0x000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000","position":"0x0"}],"value":"0x0","type":"CALL"}`, originHex),
+			result: new(rpctypes.CallFrame),
+		},
+		{
+			// Custom errors known to the registry are decoded as revert reason
+			name: "Custom error revert reason",
+			code: []byte{
+				byte(vm.PUSH4), selector[0], selector[1], selector[2], selector[3], byte(vm.PUSH1), 0xe0, byte(vm.SHL), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+				byte(vm.PUSH1), 1, byte(vm.PUSH1), 4, byte(vm.MSTORE), byte(vm.PUSH1), 2, byte(vm.PUSH1), 36, byte(vm.MSTORE),
+				byte(vm.PUSH1), 68, byte(vm.PUSH1), 0, byte(vm.REVERT),
+			},
+			tracer: decodingTracer,
+			want:   fmt.Sprintf(`{"from":"%s","gas":"0x13880","gasUsed":"0x5238","to":"0x00000000000000000000000000000000deadbeef","input":"0x","output":"0xcf47918100000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002","error":"execution reverted","revertReason":"InsufficientBalance(1, 2)","value":"0x0","type":"CALL"}`, originHex),
+			result: new(rpctypes.CallFrame),
+		},
+		{
+			// Reverts caused by failed subcalls are chained down to the root cause
+			name: "Nested revert chain",
+			code: []byte{
+				byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+				byte(vm.PUSH1), 0xbe, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+				byte(vm.PUSH4), outerSelector[0], outerSelector[1], outerSelector[2], outerSelector[3], byte(vm.PUSH1), 0xe0, byte(vm.SHL), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+				byte(vm.PUSH1), 4, byte(vm.PUSH1), 0, byte(vm.REVERT),
+			},
+			alloc: types.GenesisAlloc{
+				inner: types.Account{Code: []byte{
+					byte(vm.PUSH4), selector[0], selector[1], selector[2], selector[3], byte(vm.PUSH1), 0xe0, byte(vm.SHL), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+					byte(vm.PUSH1), 1, byte(vm.PUSH1), 4, byte(vm.MSTORE), byte(vm.PUSH1), 2, byte(vm.PUSH1), 36, byte(vm.MSTORE),
+					byte(vm.PUSH1), 68, byte(vm.PUSH1), 0, byte(vm.REVERT),
+				}},
+			},
+			tracer: chainTracer,
+			want:   fmt.Sprintf(`{"from":"%s","gas":"0x13880","gasUsed":"0x5522","to":"0x00000000000000000000000000000000deadbeef","input":"0x","output":"0x3204506f","error":"execution reverted","revertReason":"CallFailed()","revertChain":["CallFailed()","InsufficientBalance(1, 2)"],"calls":[{"from":"0x00000000000000000000000000000000deadbeef","gas":"0xe01a","gasUsed":"0x30","to":"0x00000000000000000000000000000000000000be","input":"0x","output":"0xcf47918100000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002","error":"execution reverted","revertReason":"InsufficientBalance(1, 2)","value":"0x0","type":"CALL"}],"value":"0x0","type":"CALL"}`, originHex),
+			result: new(rpctypes.CallFrame),
 		},
 		{
 			// Leads to OOM on the prestate tracer
@@ -329,6 +391,7 @@ func TestInternals(t *testing.T) {
 			},
 			tracer: mkTracer("prestateTracer", nil),
 			want:   fmt.Sprintf(`{"0x0000000000000000000000000000000000000000":{"balance":"0x0"},"0x00000000000000000000000000000000deadbeef":{"balance":"0x0","code":"0x6001600052600164ffffffffff60016000f560ff6000a0"},"%s":{"balance":"0x1c6bf52634000"}}`, originHex),
+			result: new(rpctypes.PrestateResult),
 		},
 		{
 			// CREATE2 which requires padding memory by prestate tracer
@@ -348,18 +411,22 @@ func TestInternals(t *testing.T) {
 			},
 			tracer: mkTracer("prestateTracer", nil),
 			want:   fmt.Sprintf(`{"0x0000000000000000000000000000000000000000":{"balance":"0x0"},"0x00000000000000000000000000000000deadbeef":{"balance":"0x0","code":"0x6001600052600160ff60016000f560ff6000a0"},"%s":{"balance":"0x1c6bf52634000"}}`, originHex),
+			result: new(rpctypes.PrestateResult),
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			state := tests.MakePreState(rawdb.NewMemoryDatabase(),
-				types.GenesisAlloc{
-					to: types.Account{
-						Code: tc.code,
-					},
-					origin: types.Account{
-						Balance: big.NewInt(500000000000000),
-					},
-				}, false, rawdb.HashScheme)
+			alloc := types.GenesisAlloc{
+				to: types.Account{
+					Code: tc.code,
+				},
+				origin: types.Account{
+					Balance: big.NewInt(500000000000000),
+				},
+			}
+			for addr, account := range tc.alloc {
+				alloc[addr] = account
+			}
+			state := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false, rawdb.HashScheme)
 			defer state.Close()
 			state.StateDB.SetLogger(tc.tracer.Hooks)
 			tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
@@ -394,6 +461,9 @@ func TestInternals(t *testing.T) {
 			if string(res) != tc.want {
 				t.Errorf("test %v: trace mismatch\n have: %v\n want: %v\n", tc.name, string(res), tc.want)
 			}
+			if err := checkRoundTrip(res, tc.result); err != nil {
+				t.Errorf("test %v: %v", tc.name, err)
+			}
 		})
 	}
 }
@@ -0,0 +1,175 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+func TestERC7562Tracer(t *testing.T) {
+	var (
+		config     = params.AllEthashProtocolChanges
+		entryPoint = common.HexToAddress("0xe0")
+		sender     = common.HexToAddress("0x5e")
+		paymaster  = common.HexToAddress("0x9a")
+		token      = common.HexToAddress("0x70")
+		key, _     = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		origin     = crypto.PubkeyToAddress(key.PublicKey)
+		signer     = types.LatestSigner(config)
+		context    = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: big.NewInt(1),
+			Time:        5,
+			Difficulty:  big.NewInt(0x30000),
+			GasLimit:    uint64(6000000),
+			BaseFee:     new(big.Int),
+		}
+		// callToken calls the token with the 32 bytes at memory 0 as input
+		callToken = []byte{
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), 0x70, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		}
+		// The entry point, which may use banned opcodes, validates the account
+		// and then the paymaster with little gas
+		entryPointCode = []byte{
+			byte(vm.NUMBER), byte(vm.POP),
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), 0x5e, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), 0x9a, byte(vm.PUSH2), 0x75, 0x30, byte(vm.CALL), byte(vm.POP),
+			byte(vm.STOP),
+		}
+		// The token reads the slot given as input
+		tokenCode = []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.SLOAD), byte(vm.POP), byte(vm.STOP)}
+	)
+	// The account uses TIMESTAMP and GAS without a call, reads its own storage,
+	// a token balance associated with it and an unassociated token slot
+	accountCode := []byte{
+		byte(vm.TIMESTAMP), byte(vm.POP),
+		byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.POP),
+		byte(vm.GAS), byte(vm.POP),
+		byte(vm.ADDRESS), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 32, byte(vm.MSTORE),
+		byte(vm.PUSH1), 64, byte(vm.PUSH1), 0, byte(vm.KECCAK256), byte(vm.PUSH1), 1, byte(vm.ADD), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+	}
+	accountCode = append(accountCode, callToken...)
+	accountCode = append(accountCode, byte(vm.PUSH1), 5, byte(vm.PUSH1), 0, byte(vm.MSTORE))
+	accountCode = append(accountCode, callToken...)
+	accountCode = append(accountCode, byte(vm.STOP))
+
+	// The unstaked paymaster writes its own storage, checks an address for code
+	// as allowed, accesses the code hash of it and runs out of gas
+	paymasterCode := []byte{
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH2), 0xde, 0xad, byte(vm.EXTCODESIZE), byte(vm.ISZERO), byte(vm.POP),
+		byte(vm.PUSH2), 0xde, 0xad, byte(vm.EXTCODEHASH), byte(vm.POP),
+		byte(vm.JUMPDEST), byte(vm.PUSH1), 16, byte(vm.JUMP),
+	}
+	state := tests.MakePreState(rawdb.NewMemoryDatabase(),
+		types.GenesisAlloc{
+			entryPoint: types.Account{Code: entryPointCode},
+			sender:     types.Account{Code: accountCode},
+			paymaster:  types.Account{Code: paymasterCode},
+			token:      types.Account{Code: tokenCode},
+			origin:     types.Account{Balance: big.NewInt(500000000000000)},
+		}, false, rawdb.HashScheme)
+	defer state.Close()
+
+	cfg, _ := json.Marshal(native.ERC7562TracerConfig{EntryPoint: entryPoint, Sender: sender, Paymaster: &paymaster})
+	tracer, err := tracers.DefaultDirectory.New("erc7562Tracer", new(tracers.Context), cfg)
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	state.StateDB.SetLogger(tracer.Hooks)
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		To:       &entryPoint,
+		Gas:      200000,
+		GasPrice: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	evm := vm.NewEVM(context, vm.TxContext{Origin: origin, GasPrice: tx.GasPrice()}, state.StateDB, config, vm.Config{Tracer: tracer.Hooks})
+	msg, err := core.TransactionToMessage(tx, signer, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	tracer.OnTxStart(evm.GetVMContext(), tx, msg.From)
+	if _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	var result native.ValidationResult
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	have := make([]string, 0, len(result.Violations))
+	for _, v := range result.Violations {
+		have = append(have, v.Entity+" "+v.Rule)
+	}
+	slices.Sort(have)
+	want := []string{
+		"account OP-011",
+		"account OP-012",
+		"account STO-033",
+		"paymaster OP-020",
+		"paymaster OP-041",
+		"paymaster STO-031",
+	}
+	if !slices.Equal(have, want) {
+		t.Fatalf("violations mismatch\nhave: %v\nwant: %v\nresult: %s", have, want, res)
+	}
+	for _, v := range result.Violations {
+		if v.Rule == "STO-033" && (v.Target == nil || *v.Target != token || v.Slot == nil || *v.Slot != common.BigToHash(big.NewInt(5))) {
+			t.Errorf("unassociated access mismatch: %+v", v)
+		}
+	}
+	if len(result.Phases) != 2 || result.Phases[0].Entity != native.RoleAccount || result.Phases[1].Entity != native.RolePaymaster {
+		t.Fatalf("phases mismatch: %s", res)
+	}
+	if result.Phases[0].OutOfGas || !result.Phases[1].OutOfGas {
+		t.Errorf("out of gas mismatch: %s", res)
+	}
+	// The associated token slot is accessed, and its preimage reported
+	preimage := append(common.LeftPadBytes(sender.Bytes(), 32), make([]byte, 32)...)
+	associated := new(big.Int).Add(crypto.Keccak256Hash(preimage).Big(), big.NewInt(1))
+	if reads := result.Phases[0].Storage[token]; reads == nil || !slices.Contains(reads.Reads, common.BigToHash(associated)) {
+		t.Errorf("associated slot access missing: %s", res)
+	}
+	if len(result.Keccak) != 1 || string(result.Keccak[0]) != string(preimage) {
+		t.Errorf("keccak preimages mismatch: %v", result.Keccak)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpctypes"
 	"github.com/ethereum/go-ethereum/tests"
 )
 
@@ -112,6 +113,9 @@ func flatCallTracerTestRunner(tracerName string, filename string, dirPath string
 	if err != nil {
 		return fmt.Errorf("failed to retrieve trace result: %v", err)
 	}
+	if err := checkRoundTrip(res, new([]rpctypes.FlatCallFrame)); err != nil {
+		return err
+	}
 	ret := make([]flatCallTrace, 0)
 	if err := json.Unmarshal(res, &ret); err != nil {
 		return fmt.Errorf("failed to unmarshal trace result: %v", err)
@@ -18,9 +18,11 @@ package tracetest
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -29,7 +31,10 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpctypes"
 	"github.com/ethereum/go-ethereum/tests"
 )
 
@@ -136,6 +141,133 @@ func testPrestateDiffTracer(tracerName string, dirPath string, t *testing.T) {
 			if string(want) != string(res) {
 				t.Fatalf("trace mismatch\n have: %v\n want: %v\n", string(res), string(want))
 			}
+			if !strings.HasSuffix(dirPath, "_legacy") {
+				var result interface{} = new(rpctypes.PrestateResult)
+				if strings.HasSuffix(dirPath, "_diff_mode") {
+					result = new(rpctypes.PrestateDiffResult)
+				}
+				if err := checkRoundTrip(res, result); err != nil {
+					t.Fatal(err)
+				}
+			}
 		})
 	}
 }
+
+func TestPrestateTracerStorageLayout(t *testing.T) {
+	var (
+		config   = params.AllEthashProtocolChanges
+		contract = common.HexToAddress("0xc0de")
+		holder   = common.HexToAddress("0xabc")
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		origin   = crypto.PubkeyToAddress(key.PublicKey)
+		signer   = types.LatestSigner(config)
+		context  = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: big.NewInt(1),
+			Difficulty:  big.NewInt(0x30000),
+			GasLimit:    uint64(6000000),
+			BaseFee:     new(big.Int),
+		}
+		// The contract sets b = 3 next to a, balances[0xabc] = 3 and list[0] = 9
+		code = []byte{
+			byte(vm.PUSH1), 3, byte(vm.PUSH1), 128, byte(vm.SHL), byte(vm.PUSH1), 1, byte(vm.OR), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+			byte(vm.PUSH2), 0x0a, 0xbc, byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 1, byte(vm.PUSH1), 32, byte(vm.MSTORE),
+			byte(vm.PUSH1), 64, byte(vm.PUSH1), 0, byte(vm.KECCAK256), byte(vm.PUSH1), 3, byte(vm.SWAP1), byte(vm.SSTORE),
+			byte(vm.PUSH1), 2, byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.KECCAK256),
+			byte(vm.PUSH1), 9, byte(vm.SWAP1), byte(vm.SSTORE), byte(vm.STOP),
+		}
+		balanceSlot = crypto.Keccak256Hash(common.LeftPadBytes(holder[:], 32), common.LeftPadBytes([]byte{1}, 32))
+		layout      = `{
+			"storage": [
+				{"label": "a", "offset": 0, "slot": "0", "type": "t_uint128"},
+				{"label": "b", "offset": 16, "slot": "0", "type": "t_uint128"},
+				{"label": "balances", "offset": 0, "slot": "1", "type": "t_mapping(t_address,t_uint256)"},
+				{"label": "list", "offset": 0, "slot": "2", "type": "t_array(t_uint256)dyn_storage"}
+			],
+			"types": {
+				"t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+				"t_uint128": {"encoding": "inplace", "label": "uint128", "numberOfBytes": "16"},
+				"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+				"t_array(t_uint256)dyn_storage": {"encoding": "dynamic_array", "label": "uint256[]", "numberOfBytes": "32", "base": "t_uint256"},
+				"t_mapping(t_address,t_uint256)": {"encoding": "mapping", "label": "mapping(address => uint256)", "numberOfBytes": "32", "key": "t_address", "value": "t_uint256"}
+			}
+		}`
+	)
+	cases := []struct {
+		diffMode bool
+		pre      map[string]string
+		post     map[string]string
+	}{
+		{
+			diffMode: false,
+			pre:      map[string]string{"a": "1", "b": "2", "balances[" + holder.Hex() + "]": "5", "list[0]": "0"},
+		},
+		{
+			diffMode: true,
+			pre:      map[string]string{"b": "2", "balances[" + holder.Hex() + "]": "5", "list[0]": "0"},
+			post:     map[string]string{"b": "3", "balances[" + holder.Hex() + "]": "3", "list[0]": "9"},
+		},
+	}
+	for _, tt := range cases {
+		state := tests.MakePreState(rawdb.NewMemoryDatabase(),
+			types.GenesisAlloc{
+				contract: types.Account{Code: code, Storage: map[common.Hash]common.Hash{
+					{}:                              common.HexToHash("0x0200000000000000000000000000000001"),
+					common.BigToHash(big.NewInt(2)): common.HexToHash("0x01"),
+					balanceSlot:                     common.HexToHash("0x05"),
+				}},
+				origin: types.Account{Balance: big.NewInt(500000000000000)},
+			}, false, rawdb.HashScheme)
+		defer state.Close()
+
+		cfg := fmt.Sprintf(`{"diffMode": %t, "storageLayouts": {"%s": %s}}`, tt.diffMode, contract.Hex(), layout)
+		tracer, err := tracers.DefaultDirectory.New("prestateTracer", new(tracers.Context), json.RawMessage(cfg))
+		if err != nil {
+			t.Fatalf("failed to create tracer: %v", err)
+		}
+		state.StateDB.SetLogger(tracer.Hooks)
+		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{To: &contract, Gas: 200000, GasPrice: big.NewInt(1)})
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		evm := vm.NewEVM(context, vm.TxContext{Origin: origin, GasPrice: tx.GasPrice()}, state.StateDB, config, vm.Config{Tracer: tracer.Hooks})
+		msg, err := core.TransactionToMessage(tx, signer, big.NewInt(0))
+		if err != nil {
+			t.Fatalf("failed to create message: %v", err)
+		}
+		tracer.OnTxStart(evm.GetVMContext(), tx, msg.From)
+		vmRet, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+		if err != nil {
+			t.Fatalf("failed to execute transaction: %v", err)
+		}
+		tracer.OnTxEnd(&types.Receipt{GasUsed: vmRet.UsedGas}, nil)
+
+		res, err := tracer.GetResult()
+		if err != nil {
+			t.Fatalf("failed to retrieve trace result: %v", err)
+		}
+		var result rpctypes.PrestateDiffResult
+		if tt.diffMode {
+			err = checkRoundTrip(res, &result)
+		} else {
+			err = checkRoundTrip(res, &result.Pre)
+		}
+		if err != nil {
+			t.Fatalf("diff mode %t: %v", tt.diffMode, err)
+		}
+		decoded := func(state rpctypes.PrestateResult) map[string]string {
+			if acc := state[contract]; acc != nil {
+				return acc.DecodedStorage
+			}
+			return nil
+		}
+		if have := decoded(result.Pre); !reflect.DeepEqual(have, tt.pre) {
+			t.Errorf("diff mode %t: decoded pre-state mismatch: have %v, want %v", tt.diffMode, have, tt.pre)
+		}
+		if have := decoded(result.Post); !reflect.DeepEqual(have, tt.post) {
+			t.Errorf("diff mode %t: decoded post-state mismatch: have %v, want %v", tt.diffMode, have, tt.post)
+		}
+	}
+}
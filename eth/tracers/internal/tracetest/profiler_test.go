@@ -0,0 +1,161 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+func TestGasProfiler(t *testing.T) {
+	var (
+		config  = params.AllEthashProtocolChanges
+		outer   = common.HexToAddress("0x0a")
+		inner   = common.HexToAddress("0x0b")
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		origin  = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.LatestSigner(config)
+		context = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: big.NewInt(1),
+			Time:        5,
+			Difficulty:  big.NewInt(0x30000),
+			GasLimit:    uint64(6000000),
+			BaseFee:     new(big.Int),
+		}
+		// The outer contract calls the inner one with the selector 0x12345678
+		outerCode = []byte{
+			byte(vm.PUSH4), 0x12, 0x34, 0x56, 0x78, byte(vm.PUSH1), 0xe0, byte(vm.SHL), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 4, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH1), 0x0b, byte(vm.GAS), byte(vm.CALL), byte(vm.POP), byte(vm.STOP),
+		}
+		// The inner contract stores 1 in slot 0, the SSTORE being on line 3
+		innerCode = []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+		sourceMap = &native.SourceMap{
+			SourceMap: "0:4:0;5:4;10:4;::-1",
+			Sources:   []string{"inner.sol"},
+			Contents:  []string{"aaaa\nbbbb\ncccc\n"},
+		}
+	)
+	state := tests.MakePreState(rawdb.NewMemoryDatabase(),
+		types.GenesisAlloc{
+			outer:  types.Account{Code: outerCode},
+			inner:  types.Account{Code: innerCode},
+			origin: types.Account{Balance: big.NewInt(500000000000000)},
+		}, false, rawdb.HashScheme)
+	defer state.Close()
+
+	cfg, _ := json.Marshal(native.GasProfilerConfig{SourceMaps: map[common.Address]*native.SourceMap{inner: sourceMap}})
+	tracer, err := tracers.DefaultDirectory.New("gasProfiler", new(tracers.Context), cfg)
+	if err != nil {
+		t.Fatalf("failed to create profiler: %v", err)
+	}
+	state.StateDB.SetLogger(tracer.Hooks)
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		To:       &outer,
+		Gas:      100000,
+		GasPrice: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	evm := vm.NewEVM(context, vm.TxContext{Origin: origin, GasPrice: tx.GasPrice()}, state.StateDB, config, vm.Config{Tracer: tracer.Hooks})
+	msg, err := core.TransactionToMessage(tx, signer, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	tracer.OnTxStart(evm.GetVMContext(), tx, msg.From)
+	vmRet, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	tracer.OnTxEnd(&types.Receipt{GasUsed: vmRet.UsedGas}, nil)
+
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	var profile native.GasProfile
+	if err := json.Unmarshal(res, &profile); err != nil {
+		t.Fatalf("failed to unmarshal profile: %v", err)
+	}
+	if profile.GasUsed != vmRet.UsedGas {
+		t.Errorf("gas used mismatch: have %d, want %d", profile.GasUsed, vmRet.UsedGas)
+	}
+	if profile.IntrinsicGas != params.TxGas {
+		t.Errorf("intrinsic gas mismatch: have %d, want %d", profile.IntrinsicGas, params.TxGas)
+	}
+	if profile.IntrinsicGas+profile.ExecutionGas != vmRet.UsedGas+vmRet.RefundedGas {
+		t.Errorf("execution gas mismatch: have %d, want %d", profile.ExecutionGas, vmRet.UsedGas+vmRet.RefundedGas-profile.IntrinsicGas)
+	}
+	// All of the execution gas is attributed exactly once
+	var self uint64
+	for _, entry := range profile.Contracts {
+		self += entry.SelfGas
+	}
+	if self != profile.ExecutionGas {
+		t.Errorf("self gas of contracts mismatch: have %d, want %d", self, profile.ExecutionGas)
+	}
+	var folded uint64
+	for _, line := range strings.Split(strings.TrimSuffix(profile.Folded, "\n"), "\n") {
+		idx := strings.LastIndexByte(line, ' ')
+		gas, err := strconv.ParseUint(line[idx+1:], 10, 64)
+		if err != nil {
+			t.Fatalf("invalid folded line %q: %v", line, err)
+		}
+		folded += gas
+	}
+	if folded != profile.ExecutionGas {
+		t.Errorf("folded gas mismatch: have %d, want %d", folded, profile.ExecutionGas)
+	}
+	// The inner call is attributed to its selector and the SSTORE to its line
+	var found bool
+	for _, entry := range profile.Functions {
+		if entry.Address == inner && entry.Selector == "0x12345678" && entry.Calls == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("inner function missing: %+v", profile.Functions)
+	}
+	stack := fmt.Sprintf("%s:fallback;%s:0x12345678;inner.sol:3 ", outer.Hex(), inner.Hex())
+	if !strings.Contains(profile.Folded, stack) {
+		t.Errorf("folded stack of the SSTORE missing:\n%s", profile.Folded)
+	}
+	if len(profile.Sources) == 0 || profile.Sources[0].Location != "inner.sol:3" || profile.Sources[0].Gas < params.SstoreSetGasEIP2200 {
+		t.Errorf("source profile mismatch: %+v", profile.Sources)
+	}
+	if len(profile.Opcodes) == 0 || profile.Opcodes[0].Op != "SSTORE" {
+		t.Errorf("opcode profile mismatch: %+v", profile.Opcodes)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// TestAPITypes checks that the exported result types of the tracers round-trip
+// the results of the native tracers without loss. The results of the flatCallTracer
+// are checked by its test runner, as its test suite contains legacy fields.
+func TestAPITypes(t *testing.T) {
+	dirs := map[string]func() interface{}{
+		"call_tracer":                    func() interface{} { return new(rpctypes.CallFrame) },
+		"call_tracer_withLog":            func() interface{} { return new(rpctypes.CallFrame) },
+		"prestate_tracer":                func() interface{} { return new(rpctypes.PrestateResult) },
+		"prestate_tracer_with_diff_mode": func() interface{} { return new(rpctypes.PrestateDiffResult) },
+	}
+	for dir, typ := range dirs {
+		files, err := os.ReadDir(filepath.Join("testdata", dir))
+		if err != nil {
+			t.Fatalf("failed to retrieve tracer test suite: %v", err)
+		}
+		for _, file := range files {
+			if !strings.HasSuffix(file.Name(), ".json") {
+				continue
+			}
+			blob, err := os.ReadFile(filepath.Join("testdata", dir, file.Name()))
+			if err != nil {
+				t.Fatalf("failed to read testcase: %v", err)
+			}
+			var test struct {
+				Result json.RawMessage `json:"result"`
+			}
+			if err := json.Unmarshal(blob, &test); err != nil {
+				t.Fatalf("failed to parse testcase: %v", err)
+			}
+			if err := checkRoundTrip(test.Result, typ()); err != nil {
+				t.Errorf("%s/%s: %v", dir, file.Name(), err)
+			}
+		}
+	}
+}
+
+// checkRoundTrip decodes a tracer result into the given exported result type,
+// rejecting fields missing from the type, and checks that it encodes back to the
+// same JSON.
+func checkRoundTrip(res json.RawMessage, result interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(res))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(result); err != nil {
+		return fmt.Errorf("failed to decode result: %v", err)
+	}
+	enc, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %v", err)
+	}
+	var want, have interface{}
+	json.Unmarshal(res, &want)
+	json.Unmarshal(enc, &have)
+	if !reflect.DeepEqual(want, have) {
+		return fmt.Errorf("result mismatch\nwant %s\nhave %s", res, enc)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// The output of the statediff tracer, decodable from both JSON and RLP.
+type (
+	stateDiffBalance struct {
+		Pre  *big.Int
+		Post *big.Int
+	}
+	stateDiffNonce struct {
+		Pre  uint64 `json:"pre"`
+		Post uint64 `json:"post"`
+	}
+	stateDiffCode struct {
+		PreHash  common.Hash   `json:"preHash"`
+		PostHash common.Hash   `json:"postHash"`
+		Post     hexutil.Bytes `json:"post"`
+	}
+	stateDiffSlot struct {
+		Slot common.Hash `json:"slot"`
+		Pre  common.Hash `json:"pre"`
+		Post common.Hash `json:"post"`
+	}
+	stateDiffAccount struct {
+		Address common.Address    `json:"address"`
+		Balance *stateDiffBalance `json:"balance" rlp:"nil"`
+		Nonce   *stateDiffNonce   `json:"nonce" rlp:"nil"`
+		Code    *stateDiffCode    `json:"code" rlp:"nil"`
+		Storage []stateDiffSlot   `json:"storage"`
+		Deleted bool              `json:"deleted"`
+	}
+	stateDiffTx struct {
+		Index    uint64              `json:"txIndex"`
+		Hash     common.Hash         `json:"txHash"`
+		Accounts []*stateDiffAccount `json:"accounts"`
+	}
+	stateDiffReorg struct {
+		OldHead   common.Hash `json:"oldHead"`
+		OldNumber uint64      `json:"oldNumber"`
+	}
+	stateDiffBlock struct {
+		Number       uint64              `json:"blockNumber"`
+		Hash         common.Hash         `json:"hash"`
+		ParentHash   common.Hash         `json:"parentHash"`
+		Reorg        *stateDiffReorg     `json:"reorg" rlp:"nil"`
+		BeforeTxs    []*stateDiffAccount `json:"beforeTransactions"`
+		Transactions []*stateDiffTx      `json:"transactions"`
+		AfterTxs     []*stateDiffAccount `json:"afterTransactions"`
+	}
+)
+
+func (b *stateDiffBalance) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		Pre  *hexutil.Big `json:"pre"`
+		Post *hexutil.Big `json:"post"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	b.Pre, b.Post = dec.Pre.ToInt(), dec.Post.ToInt()
+	return nil
+}
+
+func (b *stateDiffBlock) account(tx int, addr common.Address) *stateDiffAccount {
+	for _, acc := range b.Transactions[tx].Accounts {
+		if acc.Address == addr {
+			return acc
+		}
+	}
+	return nil
+}
+
+func TestStateDiffLive(t *testing.T) {
+	for _, encoding := range []string{"json", "rlp"} {
+		t.Run(encoding, func(t *testing.T) {
+			testStateDiffLive(t, encoding)
+		})
+	}
+}
+
+func testStateDiffLive(t *testing.T, encoding string) {
+	var (
+		config = *params.AllEthashProtocolChanges
+
+		outer = common.HexToAddress("0x0a")
+		inner = common.HexToAddress("0x0b")
+
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		eth1   = big.NewInt(params.Ether)
+	)
+	// The outer contract stores 7 in slot 1 and calls the inner one, which
+	// stores 9 in slot 0 but reverts
+	outerCode := []byte{
+		byte(vm.PUSH1), 7, byte(vm.PUSH1), 1, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), 0x0b, byte(vm.GAS), byte(vm.CALL), byte(vm.POP), byte(vm.STOP),
+	}
+	innerCode := []byte{byte(vm.PUSH1), 9, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}
+
+	gspec := &core.Genesis{
+		Config:  &config,
+		BaseFee: big.NewInt(params.InitialBaseFee),
+		Alloc: types.GenesisAlloc{
+			sender: {Balance: eth1},
+			outer:  {Balance: common.Big0, Code: outerCode, Storage: map[common.Hash]common.Hash{{}: common.HexToHash("0x05")}},
+			inner:  {Balance: common.Big0, Code: innerCode},
+		},
+	}
+	signer := types.LatestSigner(gspec.Config)
+	dir := filepath.ToSlash(t.TempDir())
+	tracer, err := tracers.LiveDirectory.New("statediff", json.RawMessage(fmt.Sprintf(`{"path":"%s","encoding":"%s"}`, dir, encoding)))
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), gspec, nil, engine, vm.Config{Tracer: tracer}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	// Import a chain of two blocks, and then a longer fork replacing it
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 2, func(i int, b *core.BlockGen) {
+		if i == 0 {
+			b.AddTx(types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				To:        &outer,
+				Gas:       100000,
+				GasFeeCap: big.NewInt(5 * params.GWei),
+				GasTipCap: big.NewInt(2),
+			}))
+			// A contract destructing itself during creation only leaves
+			// its deletion behind
+			b.AddTx(types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     1,
+				Gas:       100000,
+				GasFeeCap: big.NewInt(5 * params.GWei),
+				GasTipCap: big.NewInt(2),
+				Data:      []byte{byte(vm.PUSH1), 0, byte(vm.SELFDESTRUCT)},
+			}))
+		}
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	_, fork, _ := core.GenerateChainWithGenesis(gspec, engine, 3, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	if n, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("block %d: failed to insert fork into chain: %v", n, err)
+	}
+	chain.Stop()
+
+	var output []*stateDiffBlock
+	if encoding == "json" {
+		file, err := os.Open(filepath.Join(dir, "statediff.jsonl"))
+		if err != nil {
+			t.Fatalf("failed to open output file: %v", err)
+		}
+		defer file.Close()
+		for scanner := bufio.NewScanner(file); scanner.Scan(); {
+			b := new(stateDiffBlock)
+			if err := json.Unmarshal(scanner.Bytes(), b); err != nil {
+				t.Fatalf("failed to unmarshal result: %v", err)
+			}
+			output = append(output, b)
+		}
+	} else {
+		file, err := os.Open(filepath.Join(dir, "statediff.rlp"))
+		if err != nil {
+			t.Fatalf("failed to open output file: %v", err)
+		}
+		defer file.Close()
+		for stream := rlp.NewStream(file, 0); ; {
+			b := new(stateDiffBlock)
+			if err := stream.Decode(b); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				t.Fatalf("failed to decode result: %v", err)
+			}
+			output = append(output, b)
+		}
+	}
+	// Genesis, the two blocks and the three blocks of the fork
+	if len(output) != 6 {
+		t.Fatalf("block records mismatch: have %d, want 6", len(output))
+	}
+	genesis := output[0]
+	if genesis.Number != 0 || len(genesis.BeforeTxs) != 3 {
+		t.Fatalf("genesis record mismatch: %+v", genesis)
+	}
+	if acc := genesis.BeforeTxs[0]; acc.Address != outer || acc.Code == nil || len(acc.Storage) != 1 || acc.Storage[0].Post != common.HexToHash("0x05") {
+		t.Errorf("genesis account mismatch: %+v", acc)
+	}
+	block := output[1]
+	if block.Number != 1 || block.Hash != blocks[0].Hash() || block.Reorg != nil || len(block.Transactions) != 2 {
+		t.Fatalf("block record mismatch: %+v", block)
+	}
+	tx := block.Transactions[0]
+	if tx.Index != 0 || tx.Hash != blocks[0].Transactions()[0].Hash() {
+		t.Errorf("transaction mismatch: %+v", tx)
+	}
+	// The sender pays the fee and bumps its nonce
+	if acc := block.account(0, sender); acc == nil || acc.Nonce == nil || acc.Nonce.Pre != 0 || acc.Nonce.Post != 1 ||
+		acc.Balance == nil || acc.Balance.Pre.Cmp(eth1) != 0 || acc.Balance.Post.Cmp(eth1) >= 0 {
+		t.Errorf("sender diff mismatch: %+v", acc)
+	}
+	// The store of the outer contract is kept, the reverted one is not reported
+	want := []stateDiffSlot{{Slot: common.HexToHash("0x01"), Pre: common.Hash{}, Post: common.HexToHash("0x07")}}
+	if acc := block.account(0, outer); acc == nil || fmt.Sprint(acc.Storage) != fmt.Sprint(want) || acc.Balance != nil || acc.Nonce != nil {
+		t.Errorf("outer diff mismatch: %+v", acc)
+	}
+	if acc := block.account(0, inner); acc != nil {
+		t.Errorf("reverted diff reported: %+v", acc)
+	}
+	// A contract created and destroyed in the same transaction leaves no diff
+	if acc := block.account(1, crypto.CreateAddress(sender, 1)); acc != nil {
+		t.Errorf("transient account diff reported: %+v", acc)
+	}
+	// The fork is marked as a reorg of the first chain
+	if first := output[3]; first.Number != 1 || first.Hash != fork[0].Hash() || first.Reorg == nil || first.Reorg.OldHead != blocks[1].Hash() || first.Reorg.OldNumber != 2 {
+		t.Errorf("reorg record mismatch: %+v", first)
+	}
+	if next := output[4]; next.Reorg != nil {
+		t.Errorf("unexpected reorg marker: %+v", next.Reorg)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// emitTransfer returns the code emitting an ERC-20 Transfer event of 5 tokens
+// between the given single byte addresses.
+func emitTransfer(from, to byte) []byte {
+	code := []byte{byte(vm.PUSH1), 5, byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), to, byte(vm.PUSH1), from, byte(vm.PUSH32)}
+	code = append(code, crypto.Keccak256([]byte("Transfer(address,address,uint256)"))...)
+	return append(code, byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.LOG3))
+}
+
+func TestTokenTransfersLive(t *testing.T) {
+	var (
+		config = *params.AllEthashProtocolChanges
+
+		outer = common.HexToAddress("0x0a")
+		inner = common.HexToAddress("0x0b")
+
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender = crypto.PubkeyToAddress(key.PublicKey)
+		eth1   = big.NewInt(params.Ether)
+	)
+	// The outer contract emits a transfer and calls the inner one with 1 wei,
+	// which emits a transfer too but reverts
+	outerCode := append(emitTransfer(1, 2),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0x0b, byte(vm.GAS), byte(vm.CALL), byte(vm.POP), byte(vm.STOP))
+	innerCode := append(emitTransfer(3, 4), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT))
+
+	gspec := &core.Genesis{
+		Config:  &config,
+		BaseFee: big.NewInt(params.InitialBaseFee),
+		Alloc: types.GenesisAlloc{
+			sender: {Balance: eth1},
+			outer:  {Code: outerCode},
+			inner:  {Code: innerCode},
+		},
+	}
+	signer := types.LatestSigner(gspec.Config)
+	tx := types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+		ChainID:   gspec.Config.ChainID,
+		To:        &outer,
+		Value:     big.NewInt(100),
+		Gas:       100000,
+		GasFeeCap: big.NewInt(5 * params.GWei),
+		GasTipCap: big.NewInt(2),
+	})
+	dir := filepath.ToSlash(t.TempDir())
+	tracer, err := tracers.LiveDirectory.New("tokenTransfers", json.RawMessage(fmt.Sprintf(`{"path":"%s"}`, dir)))
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	engine := beacon.New(ethash.NewFaker())
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), core.DefaultCacheConfigWithScheme(rawdb.PathScheme), gspec, nil, engine, vm.Config{Tracer: tracer}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *core.BlockGen) {
+		b.AddTx(tx)
+	})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	chain.Stop()
+
+	file, err := os.Open(filepath.Join(dir, "token_transfers.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	type block struct {
+		Number       uint64                      `json:"blockNumber"`
+		Hash         common.Hash                 `json:"hash"`
+		ParentHash   common.Hash                 `json:"parentHash"`
+		Transactions []rpctypes.TxTokenTransfers `json:"transactions"`
+	}
+	var output []block
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var b block
+		if err := checkRoundTrip(scanner.Bytes(), &b); err != nil {
+			t.Fatal(err)
+		}
+		output = append(output, b)
+	}
+	if len(output) != 1 || output[0].Number != 1 || output[0].Hash != blocks[0].Hash() {
+		t.Fatalf("block records mismatch: %+v", output)
+	}
+	logIndex := hexutil.Uint(0)
+	want := []rpctypes.TxTokenTransfers{{
+		TxHash: tx.Hash(),
+		Transfers: []rpctypes.TokenTransfer{
+			{Standard: "eth", From: sender, To: outer, Value: (*hexutil.Big)(big.NewInt(100)), TraceAddress: []int{}},
+			{Standard: "erc20", Token: &outer, From: common.HexToAddress("0x01"), To: common.HexToAddress("0x02"), Value: (*hexutil.Big)(big.NewInt(5)), TraceAddress: []int{}, LogIndex: &logIndex},
+		},
+	}}
+	compareAsJSON(t, want, output[0].Transactions)
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+var (
+	// Transfer(address,address,uint256) of ERC-20 and ERC-721
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	// TransferSingle(address,address,address,uint256,uint256) of ERC-1155
+	transferSingleTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	// TransferBatch(address,address,address,uint256[],uint256[]) of ERC-1155
+	transferBatchTopic = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+
+	transferBatchArgs = func() abi.Arguments {
+		typ, _ := abi.NewType("uint256[]", "", nil)
+		return abi.Arguments{{Type: typ}, {Type: typ}}
+	}()
+)
+
+// transferFrame is a call frame tracked by TransferTracker.
+type transferFrame struct {
+	address   []int // Trace address of the frame
+	calls     int   // Number of subcalls made so far
+	transfers []rpctypes.TokenTransfer
+}
+
+// TransferTracker collects the ether and token transfers of a transaction from
+// the call frames and logs, dropping the transfers of reverted frames.
+type TransferTracker struct {
+	frames    []transferFrame
+	transfers []rpctypes.TokenTransfer
+}
+
+// Reset clears the tracker for tracking a new transaction.
+func (t *TransferTracker) Reset() {
+	t.frames = t.frames[:0]
+	t.transfers = nil
+}
+
+// Transfers returns the transfers of the transaction tracked last.
+func (t *TransferTracker) Transfers() []rpctypes.TokenTransfer {
+	if t.transfers == nil {
+		return []rpctypes.TokenTransfer{}
+	}
+	return t.transfers
+}
+
+// OnEnter opens a call frame, recording the ether moved by it.
+func (t *TransferTracker) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	var frame transferFrame
+	if len(t.frames) > 0 {
+		parent := &t.frames[len(t.frames)-1]
+		frame.address = append(append(make([]int, 0, len(parent.address)+1), parent.address...), parent.calls)
+		parent.calls++
+	} else {
+		frame.address = []int{}
+	}
+	// Delegated calls run with the value of the caller and CALLCODE pays to the
+	// caller itself, so only the other calls move ether
+	switch vm.OpCode(typ) {
+	case vm.CALL, vm.CREATE, vm.CREATE2, vm.SELFDESTRUCT:
+		if value != nil && value.Sign() > 0 && from != to {
+			frame.transfers = append(frame.transfers, rpctypes.TokenTransfer{
+				Standard:     "eth",
+				From:         from,
+				To:           to,
+				Value:        (*hexutil.Big)(new(big.Int).Set(value)),
+				TraceAddress: frame.address,
+			})
+		}
+	}
+	t.frames = append(t.frames, frame)
+}
+
+// OnExit closes a call frame, handing its transfers to the parent frame unless
+// it was reverted.
+func (t *TransferTracker) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	t.frames = t.frames[:len(t.frames)-1]
+
+	if reverted {
+		frame.transfers = nil
+	}
+	if len(t.frames) == 0 {
+		t.transfers = frame.transfers
+		return
+	}
+	parent := &t.frames[len(t.frames)-1]
+	parent.transfers = append(parent.transfers, frame.transfers...)
+}
+
+// OnLog records the token transfers announced by an event.
+func (t *TransferTracker) OnLog(log *types.Log) {
+	if len(t.frames) == 0 || len(log.Topics) == 0 {
+		return
+	}
+	frame := &t.frames[len(t.frames)-1]
+	for _, transfer := range decodeTransfers(log) {
+		index := hexutil.Uint(log.Index)
+		token := log.Address
+
+		transfer.Token = &token
+		transfer.TraceAddress = frame.address
+		transfer.LogIndex = &index
+		frame.transfers = append(frame.transfers, transfer)
+	}
+}
+
+// decodeTransfers decodes the token transfers of the standard transfer events,
+// returning nil for other events.
+func decodeTransfers(log *types.Log) []rpctypes.TokenTransfer {
+	topics := log.Topics
+	switch {
+	case topics[0] == transferTopic && len(topics) == 3 && len(log.Data) == 32:
+		from, ok1 := topicAddress(topics[1])
+		to, ok2 := topicAddress(topics[2])
+		if !ok1 || !ok2 {
+			return nil
+		}
+		return []rpctypes.TokenTransfer{{
+			Standard: "erc20",
+			From:     from,
+			To:       to,
+			Value:    (*hexutil.Big)(new(big.Int).SetBytes(log.Data)),
+		}}
+
+	case topics[0] == transferTopic && len(topics) == 4 && len(log.Data) == 0:
+		from, ok1 := topicAddress(topics[1])
+		to, ok2 := topicAddress(topics[2])
+		if !ok1 || !ok2 {
+			return nil
+		}
+		return []rpctypes.TokenTransfer{{
+			Standard: "erc721",
+			From:     from,
+			To:       to,
+			TokenID:  (*hexutil.Big)(topics[3].Big()),
+		}}
+
+	case topics[0] == transferSingleTopic && len(topics) == 4 && len(log.Data) == 64:
+		operator, ok1 := topicAddress(topics[1])
+		from, ok2 := topicAddress(topics[2])
+		to, ok3 := topicAddress(topics[3])
+		if !ok1 || !ok2 || !ok3 {
+			return nil
+		}
+		return []rpctypes.TokenTransfer{{
+			Standard: "erc1155",
+			Operator: &operator,
+			From:     from,
+			To:       to,
+			TokenID:  (*hexutil.Big)(new(big.Int).SetBytes(log.Data[:32])),
+			Value:    (*hexutil.Big)(new(big.Int).SetBytes(log.Data[32:])),
+		}}
+
+	case topics[0] == transferBatchTopic && len(topics) == 4:
+		operator, ok1 := topicAddress(topics[1])
+		from, ok2 := topicAddress(topics[2])
+		to, ok3 := topicAddress(topics[3])
+		if !ok1 || !ok2 || !ok3 {
+			return nil
+		}
+		values, err := transferBatchArgs.Unpack(log.Data)
+		if err != nil {
+			return nil
+		}
+		ids, amounts := values[0].([]*big.Int), values[1].([]*big.Int)
+		if len(ids) != len(amounts) {
+			return nil
+		}
+		transfers := make([]rpctypes.TokenTransfer, len(ids))
+		for i := range ids {
+			transfers[i] = rpctypes.TokenTransfer{
+				Standard: "erc1155",
+				Operator: &operator,
+				From:     from,
+				To:       to,
+				TokenID:  (*hexutil.Big)(ids[i]),
+				Value:    (*hexutil.Big)(amounts[i]),
+			}
+		}
+		return transfers
+	}
+	return nil
+}
+
+// topicAddress decodes an indexed address parameter of an event.
+func topicAddress(topic common.Hash) (common.Address, bool) {
+	for _, b := range topic[:common.HashLength-common.AddressLength] {
+		if b != 0 {
+			return common.Address{}, false
+		}
+	}
+	return common.BytesToAddress(topic[:]), true
+}
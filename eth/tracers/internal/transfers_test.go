@@ -0,0 +1,85 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestTransferTracker(t *testing.T) {
+	var (
+		token    = common.HexToAddress("0x7070")
+		operator = common.HexToHash("0x0f")
+		alice    = common.HexToHash("0xa1")
+		bob      = common.HexToHash("0xb0")
+		tracker  TransferTracker
+	)
+	batch, err := transferBatchArgs.Pack([]*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(10), big.NewInt(20)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Reset()
+	tracker.OnEnter(0, byte(vm.CALL), common.HexToAddress("0xa1"), token, nil, 0, big.NewInt(0))
+	{
+		// ERC-721 transfer by a delegated call, which doesn't move ether
+		tracker.OnEnter(1, byte(vm.DELEGATECALL), token, common.HexToAddress("0xde"), nil, 0, big.NewInt(7))
+		tracker.OnLog(&types.Log{Address: token, Topics: []common.Hash{transferTopic, alice, bob, common.BigToHash(big.NewInt(42))}, Index: 3})
+		tracker.OnExit(1, nil, 0, nil, false)
+
+		// ERC-1155 transfers along with ether to self, which isn't a movement
+		tracker.OnEnter(1, byte(vm.CALLCODE), token, token, nil, 0, big.NewInt(7))
+		tracker.OnLog(&types.Log{Address: token, Topics: []common.Hash{transferSingleTopic, operator, alice, bob}, Data: append(common.BigToHash(big.NewInt(5)).Bytes(), common.BigToHash(big.NewInt(50)).Bytes()...), Index: 4})
+		tracker.OnLog(&types.Log{Address: token, Topics: []common.Hash{transferBatchTopic, operator, alice, bob}, Data: batch, Index: 5})
+		{
+			// Ether sent by a nested call
+			tracker.OnEnter(2, byte(vm.CALL), token, common.HexToAddress("0xb0"), nil, 0, big.NewInt(9))
+			tracker.OnExit(2, nil, 0, nil, false)
+		}
+		tracker.OnExit(1, nil, 0, nil, false)
+
+		// Events with non-standard encodings are not transfers
+		tracker.OnLog(&types.Log{Address: token, Topics: []common.Hash{transferTopic, common.MaxHash, bob}, Data: common.Hash{}.Bytes(), Index: 6})
+		tracker.OnLog(&types.Log{Address: token, Topics: []common.Hash{transferTopic, alice, bob}, Index: 7})
+	}
+	tracker.OnExit(0, nil, 0, nil, false)
+
+	have, _ := json.Marshal(tracker.Transfers())
+	want := `[` +
+		`{"standard":"erc721","token":"0x0000000000000000000000000000000000007070","from":"0x00000000000000000000000000000000000000a1","to":"0x00000000000000000000000000000000000000b0","tokenId":"0x2a","traceAddress":[0],"logIndex":"0x3"},` +
+		`{"standard":"erc1155","token":"0x0000000000000000000000000000000000007070","operator":"0x000000000000000000000000000000000000000f","from":"0x00000000000000000000000000000000000000a1","to":"0x00000000000000000000000000000000000000b0","value":"0x32","tokenId":"0x5","traceAddress":[1],"logIndex":"0x4"},` +
+		`{"standard":"erc1155","token":"0x0000000000000000000000000000000000007070","operator":"0x000000000000000000000000000000000000000f","from":"0x00000000000000000000000000000000000000a1","to":"0x00000000000000000000000000000000000000b0","value":"0xa","tokenId":"0x1","traceAddress":[1],"logIndex":"0x5"},` +
+		`{"standard":"erc1155","token":"0x0000000000000000000000000000000000007070","operator":"0x000000000000000000000000000000000000000f","from":"0x00000000000000000000000000000000000000a1","to":"0x00000000000000000000000000000000000000b0","value":"0x14","tokenId":"0x2","traceAddress":[1],"logIndex":"0x5"},` +
+		`{"standard":"eth","from":"0x0000000000000000000000000000000000007070","to":"0x00000000000000000000000000000000000000b0","value":"0x9","traceAddress":[1,0]}` +
+		`]`
+	if string(have) != want {
+		t.Fatalf("transfers mismatch\nhave %s\nwant %s", have, want)
+	}
+	// A reverted transaction moves nothing
+	tracker.Reset()
+	tracker.OnEnter(0, byte(vm.CALL), common.HexToAddress("0xa1"), token, nil, 0, big.NewInt(1))
+	tracker.OnLog(&types.Log{Address: token, Topics: []common.Hash{transferTopic, alice, bob}, Data: common.Hash{}.Bytes()})
+	tracker.OnExit(0, nil, 0, vm.ErrExecutionReverted, true)
+	if transfers := tracker.Transfers(); len(transfers) != 0 {
+		t.Fatalf("transfers of reverted transaction: %v", transfers)
+	}
+}
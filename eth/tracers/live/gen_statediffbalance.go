@@ -0,0 +1,43 @@
+// Code generated by github.com/fjl/gencodec. DO NOT EDIT.
+
+package live
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var _ = (*stateDiffBalanceMarshaling)(nil)
+
+// MarshalJSON marshals as JSON.
+func (s stateDiffBalance) MarshalJSON() ([]byte, error) {
+	type stateDiffBalance struct {
+		Pre  *hexutil.Big `json:"pre"`
+		Post *hexutil.Big `json:"post"`
+	}
+	var enc stateDiffBalance
+	enc.Pre = (*hexutil.Big)(s.Pre)
+	enc.Post = (*hexutil.Big)(s.Post)
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (s *stateDiffBalance) UnmarshalJSON(input []byte) error {
+	type stateDiffBalance struct {
+		Pre  *hexutil.Big `json:"pre"`
+		Post *hexutil.Big `json:"post"`
+	}
+	var dec stateDiffBalance
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Pre != nil {
+		s.Pre = (*big.Int)(dec.Pre)
+	}
+	if dec.Post != nil {
+		s.Post = (*big.Int)(dec.Post)
+	}
+	return nil
+}
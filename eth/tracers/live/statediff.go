@@ -0,0 +1,544 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func init() {
+	tracers.LiveDirectory.Register("statediff", newStateDiff)
+}
+
+const (
+	stateDiffDefaultBuffer = 64              // Default number of blocks queued for writing
+	stateDiffQueueTimeout  = 5 * time.Second // Time to wait for room in a full queue before dropping a block
+	stateDiffRedialDelay   = time.Second     // Delay before reconnecting to the socket
+)
+
+var stateDiffDroppedMeter = metrics.NewRegisteredMeter("tracers/statediff/dropped", nil)
+
+type stateDiffBalance struct {
+	Pre  *big.Int `json:"pre"`
+	Post *big.Int `json:"post"`
+}
+
+//go:generate go run github.com/fjl/gencodec -type stateDiffBalance -field-override stateDiffBalanceMarshaling -out gen_statediffbalance.go
+type stateDiffBalanceMarshaling struct {
+	Pre  *hexutil.Big
+	Post *hexutil.Big
+}
+
+type stateDiffNonce struct {
+	Pre  uint64 `json:"pre"`
+	Post uint64 `json:"post"`
+}
+
+type stateDiffCode struct {
+	PreHash  common.Hash   `json:"preHash"`
+	PostHash common.Hash   `json:"postHash"`
+	Post     hexutil.Bytes `json:"post"`
+}
+
+type stateDiffSlot struct {
+	Slot common.Hash `json:"slot"`
+	Pre  common.Hash `json:"pre"`
+	Post common.Hash `json:"post"`
+}
+
+// stateDiffAccount is the change of an account. Only the changed fields are set.
+type stateDiffAccount struct {
+	Address common.Address    `json:"address"`
+	Balance *stateDiffBalance `json:"balance,omitempty" rlp:"nil"`
+	Nonce   *stateDiffNonce   `json:"nonce,omitempty" rlp:"nil"`
+	Code    *stateDiffCode    `json:"code,omitempty" rlp:"nil"`
+	Storage []stateDiffSlot   `json:"storage,omitempty"`
+	Deleted bool              `json:"deleted,omitempty"` // Whether the account was destructed or removed as empty
+}
+
+type stateDiffTx struct {
+	Index    uint64              `json:"txIndex"`
+	Hash     common.Hash         `json:"txHash"`
+	Accounts []*stateDiffAccount `json:"accounts"`
+}
+
+// stateDiffReorg marks a block that doesn't extend the previously emitted one,
+// either due to a reorg or because the blocks in between were dropped. Consumers
+// should drop the emitted diffs of the blocks not among its ancestors.
+type stateDiffReorg struct {
+	OldHead   common.Hash `json:"oldHead"`
+	OldNumber uint64      `json:"oldNumber"`
+}
+
+type stateDiffBlock struct {
+	Number       uint64              `json:"blockNumber"`
+	Hash         common.Hash         `json:"hash"`
+	ParentHash   common.Hash         `json:"parentHash"`
+	Reorg        *stateDiffReorg     `json:"reorg,omitempty" rlp:"nil"`
+	BeforeTxs    []*stateDiffAccount `json:"beforeTransactions,omitempty"` // Changes before the transactions, e.g. by system calls
+	Transactions []*stateDiffTx      `json:"transactions,omitempty"`
+	AfterTxs     []*stateDiffAccount `json:"afterTransactions,omitempty"` // Changes after the transactions, e.g. rewards and withdrawals
+}
+
+// accountChanges are the pre and post values of the changed fields of an account.
+type accountChanges struct {
+	balance    [2]*big.Int
+	nonce      *[2]uint64
+	codeHash   *[2]common.Hash
+	code       []byte
+	storage    map[common.Hash]*[2]common.Hash
+	deleted    bool
+	created    bool // didn't exist before the transaction
+	hasBalance bool
+}
+
+// stateChanges are the changes of the accounts in a part of a block.
+type stateChanges map[common.Address]*accountChanges
+
+func (c stateChanges) account(addr common.Address) *accountChanges {
+	acc := c[addr]
+	if acc == nil {
+		acc = new(accountChanges)
+		c[addr] = acc
+	}
+	return acc
+}
+
+// diff returns the changes that don't cancel out, ordered by address and slot.
+func (c stateChanges) diff() []*stateDiffAccount {
+	diffs := make([]*stateDiffAccount, 0, len(c))
+	for addr, acc := range c {
+		d := &stateDiffAccount{Address: addr, Deleted: acc.deleted}
+		if acc.hasBalance && acc.balance[0].Cmp(acc.balance[1]) != 0 {
+			d.Balance = &stateDiffBalance{Pre: acc.balance[0], Post: acc.balance[1]}
+		}
+		if acc.nonce != nil && acc.nonce[0] != acc.nonce[1] {
+			d.Nonce = &stateDiffNonce{Pre: acc.nonce[0], Post: acc.nonce[1]}
+		}
+		if acc.codeHash != nil && acc.codeHash[0] != acc.codeHash[1] {
+			d.Code = &stateDiffCode{PreHash: acc.codeHash[0], PostHash: acc.codeHash[1], Post: acc.code}
+		}
+		for slot, values := range acc.storage {
+			if values[0] != values[1] {
+				d.Storage = append(d.Storage, stateDiffSlot{Slot: slot, Pre: values[0], Post: values[1]})
+			}
+		}
+		slices.SortFunc(d.Storage, func(a, b stateDiffSlot) int { return a.Slot.Cmp(b.Slot) })
+		if d.Balance == nil && d.Nonce == nil && d.Code == nil && len(d.Storage) == 0 && !d.Deleted {
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	slices.SortFunc(diffs, func(a, b *stateDiffAccount) int { return a.Address.Cmp(b.Address) })
+	return diffs
+}
+
+type stateDiff struct {
+	encoding string
+	block    *stateDiffBlock
+	changes  stateChanges // Changes of the current part of the block
+	env      *tracing.VMContext
+	tx       *types.Transaction
+	txIndex  uint64
+	inTx     bool
+	seenTx   bool // Whether a transaction of the block started
+
+	lastHash   common.Hash // Last emitted block, to detect reorgs
+	lastNumber uint64
+
+	out      io.WriteCloser
+	queue    chan []byte   // Encoded blocks to write
+	blocking bool          // Whether to block processing instead of dropping blocks when the queue is full
+	quit     chan struct{} // Closed to stop retrying a failing sink on shutdown
+	done     chan struct{}
+}
+
+type stateDiffTracerConfig struct {
+	Path     string `json:"path"`     // Path to the directory where the tracer logs will be stored
+	Socket   string `json:"socket"`   // Path of a unix socket to write to instead, served by the consumer
+	Encoding string `json:"encoding"` // Encoding of the diffs, "json" (default) for JSON lines or "rlp" for a stream of RLP lists
+	MaxSize  int    `json:"maxSize"`  // MaxSize is the maximum size in megabytes of the tracer log file before it gets rotated. It defaults to 100 megabytes.
+	Buffer   int    `json:"buffer"`   // Number of blocks queued for writing. It defaults to 64.
+	Blocking bool   `json:"blocking"` // Whether to block processing when the queue is full, instead of dropping blocks after a timeout.
+}
+
+func newStateDiff(cfg json.RawMessage) (*tracing.Hooks, error) {
+	var config stateDiffTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+	}
+	if (config.Path == "") == (config.Socket == "") {
+		return nil, errors.New("statediff tracer requires either an output path or a socket")
+	}
+	ext := "jsonl"
+	switch config.Encoding {
+	case "", "json":
+		config.Encoding = "json"
+	case "rlp":
+		ext = "rlp"
+	default:
+		return nil, fmt.Errorf("unknown statediff encoding %q", config.Encoding)
+	}
+	if config.Buffer <= 0 {
+		config.Buffer = stateDiffDefaultBuffer
+	}
+	t := &stateDiff{
+		encoding: config.Encoding,
+		queue:    make(chan []byte, config.Buffer),
+		blocking: config.Blocking,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if config.Socket != "" {
+		t.out = &socketWriter{path: config.Socket, quit: t.quit}
+	} else {
+		// Store diffs in a rotating file
+		logger := &lumberjack.Logger{
+			Filename: filepath.Join(config.Path, "statediff."+ext),
+		}
+		if config.MaxSize > 0 {
+			logger.MaxSize = config.MaxSize
+		}
+		t.out = logger
+	}
+	go t.loop()
+
+	return &tracing.Hooks{
+		OnBlockStart:    t.OnBlockStart,
+		OnBlockEnd:      t.OnBlockEnd,
+		OnGenesisBlock:  t.OnGenesisBlock,
+		OnTxStart:       t.OnTxStart,
+		OnTxEnd:         t.OnTxEnd,
+		OnEnter:         t.OnEnter,
+		OnBalanceChange: t.OnBalanceChange,
+		OnNonceChange:   t.OnNonceChange,
+		OnCodeChange:    t.OnCodeChange,
+		OnStorageChange: t.OnStorageChange,
+		OnClose:         t.OnClose,
+	}, nil
+}
+
+func (s *stateDiff) OnBlockStart(ev tracing.BlockEvent) {
+	s.block = &stateDiffBlock{
+		Number:     ev.Block.NumberU64(),
+		Hash:       ev.Block.Hash(),
+		ParentHash: ev.Block.ParentHash(),
+	}
+	if s.lastHash != (common.Hash{}) && ev.Block.ParentHash() != s.lastHash {
+		s.block.Reorg = &stateDiffReorg{OldHead: s.lastHash, OldNumber: s.lastNumber}
+	}
+	s.changes = make(stateChanges)
+	s.txIndex, s.inTx, s.seenTx = 0, false, false
+}
+
+func (s *stateDiff) OnBlockEnd(err error) {
+	if s.block == nil {
+		return
+	}
+	// Diffs of invalid blocks are dropped
+	if err == nil {
+		s.endPart()
+		s.emit(s.block)
+	}
+	s.block, s.changes = nil, nil
+}
+
+func (s *stateDiff) OnGenesisBlock(b *types.Block, alloc types.GenesisAlloc) {
+	block := &stateDiffBlock{
+		Number:     b.NumberU64(),
+		Hash:       b.Hash(),
+		ParentHash: b.ParentHash(),
+	}
+	changes := make(stateChanges)
+	for addr, account := range alloc {
+		acc := changes.account(addr)
+		if account.Balance != nil {
+			acc.hasBalance, acc.balance = true, [2]*big.Int{new(big.Int), account.Balance}
+		}
+		acc.nonce = &[2]uint64{0, account.Nonce}
+		if len(account.Code) > 0 {
+			acc.codeHash = &[2]common.Hash{types.EmptyCodeHash, crypto.Keccak256Hash(account.Code)}
+			acc.code = account.Code
+		}
+		acc.storage = make(map[common.Hash]*[2]common.Hash, len(account.Storage))
+		for slot, value := range account.Storage {
+			acc.storage[slot] = &[2]common.Hash{{}, value}
+		}
+	}
+	block.BeforeTxs = changes.diff()
+	s.emit(block)
+}
+
+func (s *stateDiff) OnTxStart(vm *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	// Changes so far happened before the transactions
+	if s.block != nil && !s.seenTx {
+		s.block.BeforeTxs = s.changes.diff()
+	}
+	s.seenTx = true
+	s.changes = make(stateChanges)
+	s.env, s.tx, s.inTx = vm, tx, true
+}
+
+func (s *stateDiff) OnTxEnd(receipt *types.Receipt, err error) {
+	if s.block == nil || !s.inTx {
+		return
+	}
+	s.inTx = false
+	if err != nil {
+		s.changes = make(stateChanges)
+		return
+	}
+	// The hooks report changes as they happen, including the ones of reverted
+	// calls, so the post values are those of the final state
+	db := s.env.StateDB
+	for addr, acc := range s.changes {
+		if acc.hasBalance {
+			acc.balance[1] = db.GetBalance(addr).ToBig()
+		}
+		if acc.nonce != nil {
+			acc.nonce[1] = db.GetNonce(addr)
+		}
+		if acc.codeHash != nil {
+			acc.code = db.GetCode(addr)
+			acc.codeHash[1] = types.EmptyCodeHash
+			if len(acc.code) > 0 {
+				acc.codeHash[1] = crypto.Keccak256Hash(acc.code)
+			}
+		}
+		for slot, values := range acc.storage {
+			values[1] = db.GetState(addr, slot)
+		}
+		// Accounts created and destroyed within the transaction leave no trace
+		acc.deleted = !acc.created && !db.Exist(addr)
+	}
+	s.block.Transactions = append(s.block.Transactions, &stateDiffTx{
+		Index:    s.txIndex,
+		Hash:     s.tx.Hash(),
+		Accounts: s.changes.diff(),
+	})
+	s.changes = make(stateChanges)
+	s.txIndex++
+}
+
+func (s *stateDiff) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if s.block == nil || !s.inTx {
+		return
+	}
+	// The hook fires before the account is created, so it's still missing if
+	// it didn't exist before
+	if op := vm.OpCode(typ); (op == vm.CREATE || op == vm.CREATE2) && !s.env.StateDB.Exist(to) {
+		s.changes.account(to).created = true
+	}
+}
+
+// endPart files the changes outside of the transactions.
+func (s *stateDiff) endPart() {
+	if !s.seenTx {
+		s.block.BeforeTxs = s.changes.diff()
+	} else {
+		s.block.AfterTxs = s.changes.diff()
+	}
+}
+
+func (s *stateDiff) OnBalanceChange(a common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	if s.changes == nil {
+		return
+	}
+	acc := s.changes.account(a)
+	if !acc.hasBalance {
+		acc.hasBalance, acc.balance[0] = true, prev
+	}
+	acc.balance[1] = new
+}
+
+func (s *stateDiff) OnNonceChange(a common.Address, prev, new uint64) {
+	if s.changes == nil {
+		return
+	}
+	acc := s.changes.account(a)
+	if acc.nonce == nil {
+		acc.nonce = &[2]uint64{prev, prev}
+	}
+	acc.nonce[1] = new
+}
+
+func (s *stateDiff) OnCodeChange(a common.Address, prevCodeHash common.Hash, prev []byte, codeHash common.Hash, code []byte) {
+	if s.changes == nil {
+		return
+	}
+	acc := s.changes.account(a)
+	if acc.codeHash == nil {
+		if prevCodeHash == (common.Hash{}) {
+			prevCodeHash = types.EmptyCodeHash
+		}
+		acc.codeHash = &[2]common.Hash{prevCodeHash, prevCodeHash}
+	}
+	acc.codeHash[1], acc.code = codeHash, code
+}
+
+func (s *stateDiff) OnStorageChange(a common.Address, k, prev, new common.Hash) {
+	if s.changes == nil {
+		return
+	}
+	acc := s.changes.account(a)
+	if acc.storage == nil {
+		acc.storage = make(map[common.Hash]*[2]common.Hash)
+	}
+	values := acc.storage[k]
+	if values == nil {
+		values = &[2]common.Hash{prev, prev}
+		acc.storage[k] = values
+	}
+	values[1] = new
+}
+
+func (s *stateDiff) OnClose() {
+	close(s.queue)
+	close(s.quit)
+	<-s.done
+	if err := s.out.Close(); err != nil {
+		log.Warn("Failed to close statediff tracer output", "err", err)
+	}
+}
+
+// emit encodes a block and queues it for writing. If the sink falls behind, it
+// waits for room in the queue, dropping the block after a timeout unless the
+// tracer is configured to block.
+func (s *stateDiff) emit(block *stateDiffBlock) {
+	var (
+		out []byte
+		err error
+	)
+	if s.encoding == "rlp" {
+		out, err = rlp.EncodeToBytes(block)
+	} else {
+		out, err = json.Marshal(block)
+		out = append(out, '\n')
+	}
+	if err != nil {
+		log.Warn("Failed to encode state diff", "number", block.Number, "err", err)
+		return
+	}
+	if s.blocking {
+		s.queue <- out
+	} else {
+		timer := time.NewTimer(stateDiffQueueTimeout)
+		defer timer.Stop()
+
+		select {
+		case s.queue <- out:
+		case <-timer.C:
+			// The next block is marked as not extending the last emitted one
+			stateDiffDroppedMeter.Mark(1)
+			log.Warn("Dropped state diff, output is falling behind", "number", block.Number, "hash", block.Hash)
+			return
+		}
+	}
+	s.lastHash, s.lastNumber = block.Hash, block.Number
+}
+
+// loop writes the queued blocks to the sink.
+func (s *stateDiff) loop() {
+	defer close(s.done)
+	for out := range s.queue {
+		if _, err := s.out.Write(out); err != nil {
+			log.Warn("Failed to write to statediff tracer output", "err", err)
+		}
+	}
+}
+
+// socketWriter writes to a unix socket, reconnecting until the write succeeds or
+// the tracer is closed. Records interrupted by a failing connection are written
+// again in full to the next one, consumers should discard the incomplete record
+// at the end of a connection.
+type socketWriter struct {
+	path    string
+	conn    net.Conn
+	failing bool // Whether connecting failed before, to only warn once per outage
+	quit    chan struct{}
+	mu      sync.Mutex
+}
+
+func (w *socketWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		if w.conn == nil {
+			conn, err := net.Dial("unix", w.path)
+			switch {
+			case err == nil:
+				w.conn, w.failing = conn, false
+			case !w.failing:
+				log.Warn("Failed to connect to statediff socket, retrying", "path", w.path, "err", err)
+				w.failing = true
+			default:
+				log.Debug("Failed to connect to statediff socket", "path", w.path, "err", err)
+			}
+		}
+		if w.conn != nil {
+			n, err := w.conn.Write(p)
+			if err == nil {
+				return n, nil
+			}
+			// Partially written records can't be resumed, resend the whole record
+			log.Warn("Failed to write to statediff socket", "path", w.path, "written", n, "err", err)
+			w.conn.Close()
+			w.conn = nil
+		}
+		select {
+		case <-w.quit:
+			return 0, errors.New("statediff tracer closed")
+		case <-time.After(stateDiffRedialDelay):
+		}
+	}
+}
+
+func (w *socketWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
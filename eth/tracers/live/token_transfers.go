@@ -0,0 +1,165 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/internal"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpctypes"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func init() {
+	tracers.LiveDirectory.Register("tokenTransfers", newTokenTransfers)
+}
+
+// tokenTransfersBlock is the record of a block written by the tokenTransfers
+// tracer. The parent hash allows consumers to detect reorgs.
+type tokenTransfersBlock struct {
+	Number       uint64                      `json:"blockNumber"`
+	Hash         common.Hash                 `json:"hash"`
+	ParentHash   common.Hash                 `json:"parentHash"`
+	Transactions []rpctypes.TxTokenTransfers `json:"transactions"`
+}
+
+// tokenTransfers is a live tracer writing the ether and token transfers of the
+// imported blocks, see the tokenTransferTracer for details.
+type tokenTransfers struct {
+	block    tokenTransfersBlock
+	tracker  internal.TransferTracker
+	txHash   common.Hash
+	inSystem bool // Whether a system call is being executed
+	logger   *lumberjack.Logger
+}
+
+type tokenTransfersTracerConfig struct {
+	Path    string `json:"path"`    // Path to the directory where the tracer logs will be stored
+	MaxSize int    `json:"maxSize"` // MaxSize is the maximum size in megabytes of the tracer log file before it gets rotated. It defaults to 100 megabytes.
+}
+
+func newTokenTransfers(cfg json.RawMessage) (*tracing.Hooks, error) {
+	var config tokenTransfersTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+	}
+	if config.Path == "" {
+		return nil, errors.New("token transfers tracer output path is required")
+	}
+	// Store traces in a rotating file
+	logger := &lumberjack.Logger{
+		Filename: filepath.Join(config.Path, "token_transfers.jsonl"),
+	}
+	if config.MaxSize > 0 {
+		logger.MaxSize = config.MaxSize
+	}
+	t := &tokenTransfers{logger: logger}
+	return &tracing.Hooks{
+		OnBlockStart:      t.OnBlockStart,
+		OnBlockEnd:        t.OnBlockEnd,
+		OnSystemCallStart: t.OnSystemCallStart,
+		OnSystemCallEnd:   t.OnSystemCallEnd,
+		OnTxStart:         t.OnTxStart,
+		OnTxEnd:           t.OnTxEnd,
+		OnEnter:           t.OnEnter,
+		OnExit:            t.OnExit,
+		OnLog:             t.OnLog,
+		OnClose:           t.OnClose,
+	}, nil
+}
+
+func (t *tokenTransfers) OnBlockStart(ev tracing.BlockEvent) {
+	t.block = tokenTransfersBlock{
+		Number:       ev.Block.NumberU64(),
+		Hash:         ev.Block.Hash(),
+		ParentHash:   ev.Block.ParentHash(),
+		Transactions: []rpctypes.TxTokenTransfers{},
+	}
+}
+
+func (t *tokenTransfers) OnBlockEnd(err error) {
+	// Blocks failing to import are not part of the chain
+	if err != nil {
+		return
+	}
+	out, _ := json.Marshal(t.block)
+	if _, err := t.logger.Write(append(out, '\n')); err != nil {
+		log.Warn("failed to write to token transfers tracer log file", "error", err)
+	}
+}
+
+// The beacon root and other system calls don't move funds, and are not wrapped
+// into transactions, so their frames are skipped.
+func (t *tokenTransfers) OnSystemCallStart() {
+	t.inSystem = true
+}
+
+func (t *tokenTransfers) OnSystemCallEnd() {
+	t.inSystem = false
+}
+
+func (t *tokenTransfers) OnTxStart(vm *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.tracker.Reset()
+	t.txHash = tx.Hash()
+}
+
+func (t *tokenTransfers) OnTxEnd(receipt *types.Receipt, err error) {
+	if err != nil {
+		return
+	}
+	if transfers := t.tracker.Transfers(); len(transfers) > 0 {
+		t.block.Transactions = append(t.block.Transactions, rpctypes.TxTokenTransfers{
+			TxHash:    t.txHash,
+			Transfers: transfers,
+		})
+	}
+}
+
+func (t *tokenTransfers) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if !t.inSystem {
+		t.tracker.OnEnter(depth, typ, from, to, input, gas, value)
+	}
+}
+
+func (t *tokenTransfers) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if !t.inSystem {
+		t.tracker.OnExit(depth, output, gasUsed, err, reverted)
+	}
+}
+
+func (t *tokenTransfers) OnLog(log *types.Log) {
+	if !t.inSystem {
+		t.tracker.OnLog(log)
+	}
+}
+
+func (t *tokenTransfers) OnClose() {
+	if err := t.logger.Close(); err != nil {
+		log.Warn("failed to close token transfers tracer log file", "error", err)
+	}
+}
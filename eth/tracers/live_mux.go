@@ -0,0 +1,555 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// overheadSampleRate is the number of calls to the fine-grained hooks, those
+// invoked within transactions, per call timed to measure tracer overhead. Timing
+// each call would cost about as much as the hooks of most tracers themselves.
+const overheadSampleRate = 64
+
+var (
+	errLiveTracerExists  = errors.New("live tracer already attached")
+	errLiveTracerUnknown = errors.New("live tracer not attached")
+)
+
+// liveTracer is a live tracer attached to a LiveMux.
+type liveTracer struct {
+	id     string
+	name   string
+	config json.RawMessage
+	hooks  *tracing.Hooks
+
+	pending atomic.Int64  // Nanoseconds spent in the hooks since the last block
+	total   atomic.Int64  // Nanoseconds spent in the hooks since attached
+	calls   atomic.Uint64 // Number of calls to the fine-grained hooks
+	timer   metrics.Timer
+}
+
+// since accounts the time elapsed since start to the overhead of the tracer.
+func (t *liveTracer) since(start time.Time) {
+	t.pending.Add(int64(time.Since(start)))
+}
+
+// sample returns the start time of a call to a fine-grained hook if the call is
+// to be timed, or the zero time otherwise.
+func (t *liveTracer) sample() time.Time {
+	if t.calls.Add(1)%overheadSampleRate != 0 {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// sampled accounts the time elapsed since start, if the call was timed, to the
+// overhead of the tracer, scaled up to all calls of the sample.
+func (t *liveTracer) sampled(start time.Time) {
+	if !start.IsZero() {
+		t.pending.Add(int64(time.Since(start)) * overheadSampleRate)
+	}
+}
+
+// needsOpcodes reports whether the hooks of a tracer need the per-opcode hooks
+// of the mux.
+func needsOpcodes(hooks *tracing.Hooks) bool {
+	return hooks.OnOpcode != nil || hooks.OnFault != nil || hooks.OnGasChange != nil
+}
+
+// flush reports the overhead accumulated since the last block to the metrics.
+func (t *liveTracer) flush() {
+	if ns := t.pending.Swap(0); ns > 0 {
+		t.total.Add(ns)
+		t.timer.Update(time.Duration(ns))
+	}
+}
+
+// close releases the tracer. Its metrics are released by the mux, as they are
+// shared with tracers attached under the same id later on.
+func (t *liveTracer) close() {
+	if t.hooks.OnClose != nil {
+		t.hooks.OnClose()
+	}
+}
+
+func overheadMetric(id string) string {
+	return "vmtrace/" + id + "/overhead"
+}
+
+// LiveMux dispatches the events of the chain to a set of live tracers, which
+// can be attached and detached while the node is running. Changes to the set
+// made during the processing of a block only take effect after it.
+//
+// The per-opcode hooks are only provided while one of the tracers needs them, as
+// they slow down execution even with no tracer using them. Tracers needing them
+// can only be attached once the mux is in use if explicitly enabled.
+type LiveMux struct {
+	tracers atomic.Pointer[[]*liveTracer] // Tracers receiving the events
+
+	mu       sync.Mutex
+	next     []*liveTracer // Set of tracers to apply after the current block, if changed
+	closing  []*liveTracer // Tracers to close after the current block
+	inBlock  bool          // Whether a block is being processed
+	opcodes  bool          // Whether tracers needing the per-opcode hooks may be attached
+	hooks    *tracing.Hooks
+	chainCfg *params.ChainConfig
+}
+
+// NewLiveMux creates a live tracer mux. If opcodes is set, tracers needing the
+// per-opcode hooks may be attached while the mux is in use, not only initially.
+func NewLiveMux(opcodes bool) *LiveMux {
+	m := &LiveMux{opcodes: opcodes}
+	m.tracers.Store(new([]*liveTracer))
+	return m
+}
+
+// AttachConfigured attaches the tracers configured on the command line: a comma
+// separated list of tracers and their configuration. Each tracer is given as
+// "id=name", or as its name only, which is then also used as its id. With a
+// single tracer, the configuration is its own, with several it is an object
+// keyed by tracer id.
+func (m *LiveMux) AttachConfigured(tracers string, config string) error {
+	var ids, names []string
+	for _, tracer := range strings.Split(tracers, ",") {
+		id, name, ok := strings.Cut(tracer, "=")
+		if !ok {
+			name = id
+		}
+		ids, names = append(ids, id), append(names, name)
+	}
+	configs := make(map[string]json.RawMessage)
+	if len(ids) == 1 {
+		if config != "" {
+			configs[ids[0]] = json.RawMessage(config)
+		}
+	} else if config != "" {
+		if err := json.Unmarshal([]byte(config), &configs); err != nil {
+			return fmt.Errorf("invalid configuration of live tracers: %v", err)
+		}
+		for id := range configs {
+			if !slices.Contains(ids, id) {
+				return fmt.Errorf("configuration of unknown live tracer %q", id)
+			}
+		}
+	}
+	for i, id := range ids {
+		if err := m.Attach(id, names[i], configs[id]); err != nil {
+			return fmt.Errorf("failed to create tracer %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Attach instantiates the live tracer of the given name and attaches it under
+// the given id.
+func (m *LiveMux) Attach(id string, name string, config json.RawMessage) error {
+	if id == "" {
+		return errors.New("empty live tracer id")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.current()
+	if slices.ContainsFunc(current, func(t *liveTracer) bool { return t.id == id }) {
+		return fmt.Errorf("%w: %s", errLiveTracerExists, id)
+	}
+	hooks, err := LiveDirectory.New(name, config)
+	if err != nil {
+		return err
+	}
+	t := &liveTracer{
+		id:     id,
+		name:   name,
+		config: config,
+		hooks:  hooks,
+		timer:  metrics.GetOrRegisterTimer(overheadMetric(id), nil),
+	}
+	if m.hooks != nil && !m.opcodes && needsOpcodes(hooks) {
+		m.release(t)
+		return fmt.Errorf("live tracer %s needs opcode hooks, which are not enabled", name)
+	}
+	// The chain is already initialized if the mux is in use, let the tracer know
+	if m.chainCfg != nil && hooks.OnBlockchainInit != nil {
+		hooks.OnBlockchainInit(m.chainCfg)
+	}
+	m.update(append(slices.Clone(current), t))
+	return nil
+}
+
+// Detach detaches and closes the live tracer with the given id.
+func (m *LiveMux) Detach(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.current()
+	idx := slices.IndexFunc(current, func(t *liveTracer) bool { return t.id == id })
+	if idx < 0 {
+		return fmt.Errorf("%w: %s", errLiveTracerUnknown, id)
+	}
+	t := current[idx]
+	m.update(slices.Delete(slices.Clone(current), idx, idx+1))
+	if m.inBlock {
+		m.closing = append(m.closing, t)
+	} else {
+		m.release(t)
+	}
+	return nil
+}
+
+// release closes a detached tracer and unregisters its metrics, unless another
+// tracer has been attached under the same id since. The lock must be held.
+func (m *LiveMux) release(t *liveTracer) {
+	t.close()
+
+	used := func(c *liveTracer) bool { return c.id == t.id }
+	if !slices.ContainsFunc(m.current(), used) && !slices.ContainsFunc(m.closing, used) {
+		metrics.Unregister(overheadMetric(t.id))
+	}
+}
+
+// Tracers returns the attached live tracers.
+func (m *LiveMux) Tracers() []rpctypes.LiveTracerInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.current()
+	infos := make([]rpctypes.LiveTracerInfo, 0, len(current))
+	for _, t := range current {
+		infos = append(infos, rpctypes.LiveTracerInfo{
+			ID:       t.id,
+			Name:     t.name,
+			Config:   t.config,
+			Overhead: time.Duration(t.total.Load() + t.pending.Load()),
+		})
+	}
+	return infos
+}
+
+// current returns the set of tracers, including the changes yet to be applied.
+// The lock must be held.
+func (m *LiveMux) current() []*liveTracer {
+	if m.next != nil {
+		return m.next
+	}
+	return *m.tracers.Load()
+}
+
+// update changes the set of tracers, right away if no block is being processed
+// or after it otherwise. The lock must be held.
+func (m *LiveMux) update(tracers []*liveTracer) {
+	if m.inBlock {
+		m.next = tracers
+		return
+	}
+	m.tracers.Store(&tracers)
+	m.setOpcodeHooks(tracers)
+}
+
+// setOpcodeHooks provides the per-opcode hooks if one of the given tracers needs
+// them, and removes them otherwise. The EVM only reads the hooks while a block
+// is processed, so they may be changed in place between blocks. The lock must
+// be held.
+func (m *LiveMux) setOpcodeHooks(tracers []*liveTracer) {
+	if m.hooks == nil {
+		return
+	}
+	if slices.ContainsFunc(tracers, func(t *liveTracer) bool { return needsOpcodes(t.hooks) }) {
+		m.hooks.OnOpcode = m.onOpcode
+		m.hooks.OnFault = m.onFault
+		m.hooks.OnGasChange = m.onGasChange
+	} else {
+		m.hooks.OnOpcode = nil
+		m.hooks.OnFault = nil
+		m.hooks.OnGasChange = nil
+	}
+}
+
+// Hooks returns the hooks dispatching the events to the attached tracers. The
+// same hooks are returned on every call, and only the per-opcode ones change
+// along with the set of tracers. All initial tracers must be attached before
+// the first call.
+func (m *LiveMux) Hooks() *tracing.Hooks {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hooks != nil {
+		return m.hooks
+	}
+	m.hooks = &tracing.Hooks{
+		OnTxStart:         m.onTxStart,
+		OnTxEnd:           m.onTxEnd,
+		OnEnter:           m.onEnter,
+		OnExit:            m.onExit,
+		OnBlockchainInit:  m.onBlockchainInit,
+		OnClose:           m.onClose,
+		OnBlockStart:      m.onBlockStart,
+		OnBlockEnd:        m.onBlockEnd,
+		OnSkippedBlock:    m.onSkippedBlock,
+		OnGenesisBlock:    m.onGenesisBlock,
+		OnSystemCallStart: m.onSystemCallStart,
+		OnSystemCallEnd:   m.onSystemCallEnd,
+		OnBalanceChange:   m.onBalanceChange,
+		OnNonceChange:     m.onNonceChange,
+		OnCodeChange:      m.onCodeChange,
+		OnStorageChange:   m.onStorageChange,
+		OnLog:             m.onLog,
+	}
+	m.setOpcodeHooks(m.current())
+	return m.hooks
+}
+
+func (m *LiveMux) onBlockchainInit(chainConfig *params.ChainConfig) {
+	m.mu.Lock()
+	m.chainCfg = chainConfig
+	m.mu.Unlock()
+
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnBlockchainInit != nil {
+			start := time.Now()
+			t.hooks.OnBlockchainInit(chainConfig)
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onClose() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	released := append(m.closing, m.current()...)
+	m.closing, m.next = nil, nil
+	m.tracers.Store(new([]*liveTracer))
+
+	for _, t := range released {
+		m.release(t)
+	}
+}
+
+func (m *LiveMux) onBlockStart(ev tracing.BlockEvent) {
+	m.mu.Lock()
+	m.inBlock = true
+	m.mu.Unlock()
+
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnBlockStart != nil {
+			start := time.Now()
+			t.hooks.OnBlockStart(ev)
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onBlockEnd(err error) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnBlockEnd != nil {
+			start := time.Now()
+			t.hooks.OnBlockEnd(err)
+			t.since(start)
+		}
+		t.flush()
+	}
+	// Apply the changes made during the block
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inBlock = false
+	if m.next != nil {
+		m.update(m.next)
+		m.next = nil
+	}
+	closing := m.closing
+	m.closing = nil
+	for _, t := range closing {
+		m.release(t)
+	}
+}
+
+func (m *LiveMux) onSkippedBlock(ev tracing.BlockEvent) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnSkippedBlock != nil {
+			start := time.Now()
+			t.hooks.OnSkippedBlock(ev)
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onGenesisBlock(b *types.Block, alloc types.GenesisAlloc) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnGenesisBlock != nil {
+			start := time.Now()
+			t.hooks.OnGenesisBlock(b, alloc)
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onSystemCallStart() {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnSystemCallStart != nil {
+			start := time.Now()
+			t.hooks.OnSystemCallStart()
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onSystemCallEnd() {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnSystemCallEnd != nil {
+			start := time.Now()
+			t.hooks.OnSystemCallEnd()
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnTxStart != nil {
+			start := time.Now()
+			t.hooks.OnTxStart(env, tx, from)
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onTxEnd(receipt *types.Receipt, err error) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnTxEnd != nil {
+			start := time.Now()
+			t.hooks.OnTxEnd(receipt, err)
+			t.since(start)
+		}
+	}
+}
+
+func (m *LiveMux) onEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnEnter != nil {
+			start := t.sample()
+			t.hooks.OnEnter(depth, typ, from, to, input, gas, value)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnExit != nil {
+			start := t.sample()
+			t.hooks.OnExit(depth, output, gasUsed, err, reverted)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnOpcode != nil {
+			start := t.sample()
+			t.hooks.OnOpcode(pc, op, gas, cost, scope, rData, depth, err)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onFault(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, depth int, err error) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnFault != nil {
+			start := t.sample()
+			t.hooks.OnFault(pc, op, gas, cost, scope, depth, err)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onGasChange(old, new uint64, reason tracing.GasChangeReason) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnGasChange != nil {
+			start := t.sample()
+			t.hooks.OnGasChange(old, new, reason)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnBalanceChange != nil {
+			start := t.sample()
+			t.hooks.OnBalanceChange(addr, prev, new, reason)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onNonceChange(addr common.Address, prev, new uint64) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnNonceChange != nil {
+			start := t.sample()
+			t.hooks.OnNonceChange(addr, prev, new)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnCodeChange != nil {
+			start := t.sample()
+			t.hooks.OnCodeChange(addr, prevCodeHash, prevCode, codeHash, code)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnStorageChange != nil {
+			start := t.sample()
+			t.hooks.OnStorageChange(addr, slot, prev, new)
+			t.sampled(start)
+		}
+	}
+}
+
+func (m *LiveMux) onLog(log *types.Log) {
+	for _, t := range *m.tracers.Load() {
+		if t.hooks.OnLog != nil {
+			start := t.sample()
+			t.hooks.OnLog(log)
+			t.sampled(start)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// muxTestTracer counts the events it receives.
+type muxTestTracer struct {
+	Label  string `json:"label"`
+	inits  int
+	blocks int
+	txs    int
+	closed bool
+}
+
+var muxTestTracers = make(map[string]*muxTestTracer)
+
+func init() {
+	LiveDirectory.Register("muxTest", func(config json.RawMessage) (*tracing.Hooks, error) {
+		t := new(muxTestTracer)
+		if config != nil {
+			if err := json.Unmarshal(config, t); err != nil {
+				return nil, err
+			}
+		}
+		muxTestTracers[t.Label] = t
+		return &tracing.Hooks{
+			OnBlockchainInit: func(*params.ChainConfig) { t.inits++ },
+			OnBlockStart:     func(tracing.BlockEvent) { t.blocks++ },
+			OnTxEnd:          func(*types.Receipt, error) { t.txs++ },
+			OnClose:          func() { t.closed = true },
+		}, nil
+	})
+	LiveDirectory.Register("muxTestOpcodes", func(config json.RawMessage) (*tracing.Hooks, error) {
+		return &tracing.Hooks{
+			OnOpcode: func(uint64, byte, uint64, uint64, tracing.OpContext, []byte, int, error) {},
+		}, nil
+	})
+}
+
+func TestLiveMux(t *testing.T) {
+	mux := NewLiveMux(false)
+	if err := mux.AttachConfigured("muxTest", `{"label":"a"}`); err != nil {
+		t.Fatalf("failed to attach initial tracer: %v", err)
+	}
+	hooks := mux.Hooks()
+	if hooks.OnOpcode != nil {
+		t.Fatalf("opcode hooks provided without tracer using them")
+	}
+	hooks.OnBlockchainInit(params.TestChainConfig)
+
+	// Attach a tracer in the middle of a block, it only sees the next one
+	hooks.OnBlockStart(tracing.BlockEvent{})
+	if err := mux.Attach("b", "muxTest", json.RawMessage(`{"label":"b"}`)); err != nil {
+		t.Fatalf("failed to attach tracer: %v", err)
+	}
+	if err := mux.Attach("b", "muxTest", json.RawMessage(`{"label":"c"}`)); !errors.Is(err, errLiveTracerExists) {
+		t.Fatalf("duplicate id error mismatch: %v", err)
+	}
+	hooks.OnTxEnd(nil, nil)
+	hooks.OnBlockEnd(nil)
+
+	a, b := muxTestTracers["a"], muxTestTracers["b"]
+	if a.inits != 1 || a.blocks != 1 || a.txs != 1 {
+		t.Errorf("initial tracer events mismatch: %+v", a)
+	}
+	if b.inits != 1 || b.blocks != 0 || b.txs != 0 {
+		t.Errorf("attached tracer events mismatch: %+v", b)
+	}
+	hooks.OnBlockStart(tracing.BlockEvent{})
+	hooks.OnTxEnd(nil, nil)
+	if err := mux.Detach("muxTest"); err != nil {
+		t.Fatalf("failed to detach tracer: %v", err)
+	}
+	if a.closed {
+		t.Errorf("tracer closed during block")
+	}
+	hooks.OnBlockEnd(nil)
+	if !a.closed || a.blocks != 2 {
+		t.Errorf("detached tracer mismatch: %+v", a)
+	}
+	if b.blocks != 1 || b.txs != 1 {
+		t.Errorf("attached tracer events mismatch: %+v", b)
+	}
+	if err := mux.Detach("muxTest"); !errors.Is(err, errLiveTracerUnknown) {
+		t.Fatalf("unknown id error mismatch: %v", err)
+	}
+	infos := mux.Tracers()
+	if len(infos) != 1 || infos[0].ID != "b" || infos[0].Name != "muxTest" || string(infos[0].Config) != `{"label":"b"}` {
+		t.Errorf("tracer infos mismatch: %+v", infos)
+	}
+	// Tracers needing opcode hooks can only be attached at runtime if enabled
+	if err := mux.Attach("ops", "muxTestOpcodes", nil); err == nil {
+		t.Errorf("opcode tracer attached without opcode hooks")
+	}
+	hooks.OnClose()
+	if !b.closed {
+		t.Errorf("tracer not closed with the mux")
+	}
+}
+
+func TestLiveMuxReattach(t *testing.T) {
+	mux := NewLiveMux(false)
+	hooks := mux.Hooks()
+	if err := mux.Attach("reattach", "muxTest", json.RawMessage(`{"label":"r1"}`)); err != nil {
+		t.Fatalf("failed to attach tracer: %v", err)
+	}
+	// Re-attaching under the same id within a block must keep the metrics
+	hooks.OnBlockStart(tracing.BlockEvent{})
+	if err := mux.Detach("reattach"); err != nil {
+		t.Fatalf("failed to detach tracer: %v", err)
+	}
+	if err := mux.Attach("reattach", "muxTest", json.RawMessage(`{"label":"r2"}`)); err != nil {
+		t.Fatalf("failed to re-attach tracer: %v", err)
+	}
+	hooks.OnBlockEnd(nil)
+	if !muxTestTracers["r1"].closed {
+		t.Errorf("detached tracer not closed")
+	}
+	if metrics.DefaultRegistry.Get(overheadMetric("reattach")) == nil {
+		t.Errorf("metrics of re-attached tracer unregistered")
+	}
+	if err := mux.Detach("reattach"); err != nil {
+		t.Fatalf("failed to detach tracer: %v", err)
+	}
+	if metrics.DefaultRegistry.Get(overheadMetric("reattach")) != nil {
+		t.Errorf("metrics of detached tracer still registered")
+	}
+}
+
+func TestLiveMuxConfigured(t *testing.T) {
+	mux := NewLiveMux(false)
+	if err := mux.AttachConfigured("muxTest,muxTestOpcodes", `{"muxTest":{"label":"x"}}`); err != nil {
+		t.Fatalf("failed to attach tracers: %v", err)
+	}
+	if muxTestTracers["x"] == nil {
+		t.Fatalf("configuration not passed to the tracer")
+	}
+	if mux.Hooks().OnOpcode == nil {
+		t.Fatalf("opcode hooks missing")
+	}
+	// Instances of the same tracer are configured by id
+	mux = NewLiveMux(false)
+	if err := mux.AttachConfigured("first=muxTest,second=muxTest", `{"first":{"label":"y"},"second":{"label":"z"}}`); err != nil {
+		t.Fatalf("failed to attach tracer instances: %v", err)
+	}
+	if muxTestTracers["y"] == nil || muxTestTracers["z"] == nil {
+		t.Fatalf("configuration not passed to the tracer instances")
+	}
+	if infos := mux.Tracers(); len(infos) != 2 || infos[0].ID != "first" || infos[1].ID != "second" || infos[1].Name != "muxTest" {
+		t.Errorf("tracer infos mismatch: %+v", infos)
+	}
+	if err := NewLiveMux(false).AttachConfigured("muxTest,muxTestOpcodes", `{"other":{}}`); err == nil {
+		t.Fatalf("configuration of unknown tracer accepted")
+	}
+	if err := NewLiveMux(false).AttachConfigured("muxTest,muxTest", ""); !errors.Is(err, errLiveTracerExists) {
+		t.Fatalf("duplicate tracer error mismatch: %v", err)
+	}
+}
+
+func TestLiveMuxOpcodeHooks(t *testing.T) {
+	mux := NewLiveMux(true)
+	hooks := mux.Hooks()
+	if hooks.OnOpcode != nil {
+		t.Fatalf("opcode hooks provided without tracer using them")
+	}
+	// Opcode hooks are provided from the block after attaching a tracer needing them
+	hooks.OnBlockStart(tracing.BlockEvent{})
+	if err := mux.Attach("ops", "muxTestOpcodes", nil); err != nil {
+		t.Fatalf("failed to attach opcode tracer: %v", err)
+	}
+	if hooks.OnOpcode != nil {
+		t.Fatalf("opcode hooks provided during block")
+	}
+	hooks.OnBlockEnd(nil)
+	if hooks.OnOpcode == nil || hooks.OnGasChange == nil {
+		t.Fatalf("opcode hooks missing after attaching opcode tracer")
+	}
+	// And removed again once no tracer needs them
+	if err := mux.Detach("ops"); err != nil {
+		t.Fatalf("failed to detach opcode tracer: %v", err)
+	}
+	if hooks.OnOpcode != nil || hooks.OnFault != nil || hooks.OnGasChange != nil {
+		t.Fatalf("opcode hooks kept after detaching opcode tracer")
+	}
+}
@@ -113,6 +113,7 @@ type StructLogger struct {
 
 	storage map[common.Address]Storage
 	logs    []StructLog
+	drained int // Number of logs handed out by Drain
 	output  []byte
 	err     error
 	usedGas uint64
@@ -146,6 +147,7 @@ func (l *StructLogger) Reset() {
 	l.storage = make(map[common.Address]Storage)
 	l.output = make([]byte, 0)
 	l.logs = l.logs[:0]
+	l.drained = 0
 	l.err = nil
 }
 
@@ -158,7 +160,7 @@ func (l *StructLogger) OnOpcode(pc uint64, opcode byte, gas, cost uint64, scope
 		return
 	}
 	// check if already accumulated the specified number of logs
-	if l.cfg.Limit != 0 && l.cfg.Limit <= len(l.logs) {
+	if l.cfg.Limit != 0 && l.cfg.Limit <= l.drained+len(l.logs) {
 		return
 	}
 
@@ -246,7 +248,7 @@ func (l *StructLogger) GetResult() (json.RawMessage, error) {
 		Gas:         l.usedGas,
 		Failed:      failed,
 		ReturnValue: returnVal,
-		StructLogs:  formatLogs(l.StructLogs()),
+		StructLogs:  FormatLogs(l.StructLogs()),
 	})
 }
 
@@ -274,6 +276,16 @@ func (l *StructLogger) OnTxEnd(receipt *types.Receipt, err error) {
 // StructLogs returns the captured log entries.
 func (l *StructLogger) StructLogs() []StructLog { return l.logs }
 
+// Drain returns the logs captured since the previous call and releases them from
+// the logger, so long executions can be streamed out without accumulating all
+// logs in memory. The configured limit still applies to the total logs captured.
+func (l *StructLogger) Drain() []StructLog {
+	logs := l.logs
+	l.drained += len(logs)
+	l.logs = nil
+	return logs
+}
+
 // Error returns the VM error captured by the trace.
 func (l *StructLogger) Error() error { return l.err }
 
@@ -436,8 +448,8 @@ type StructLogRes struct {
 	RefundCounter uint64             `json:"refund,omitempty"`
 }
 
-// formatLogs formats EVM returned structured logs for json output
-func formatLogs(logs []StructLog) []StructLogRes {
+// FormatLogs formats EVM returned structured logs for json output
+func FormatLogs(logs []StructLog) []StructLogRes {
 	formatted := make([]StructLogRes, len(logs))
 	for index, trace := range logs {
 		formatted[index] = StructLogRes{
@@ -17,6 +17,7 @@
 package native
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"math/big"
@@ -56,6 +57,7 @@ type callFrame struct {
 	Output       []byte          `json:"output,omitempty" rlp:"optional"`
 	Error        string          `json:"error,omitempty" rlp:"optional"`
 	RevertReason string          `json:"revertReason,omitempty"`
+	RevertChain  []string        `json:"revertChain,omitempty"`
 	Calls        []callFrame     `json:"calls,omitempty" rlp:"optional"`
 	Logs         []callLog       `json:"logs,omitempty" rlp:"optional"`
 	// Placed at end on purpose. The RLP will be decoded to 0 instead of
@@ -72,7 +74,7 @@ func (f callFrame) failed() bool {
 	return len(f.Error) > 0 && f.revertedSnapshot
 }
 
-func (f *callFrame) processOutput(output []byte, err error, reverted bool) {
+func (f *callFrame) processOutput(output []byte, err error, reverted bool, registry *abi.ErrorRegistry) {
 	output = common.CopyBytes(output)
 	// Clear error if tx wasn't reverted. This happened
 	// for pre-homestead contract storage OOG.
@@ -88,18 +90,59 @@ func (f *callFrame) processOutput(output []byte, err error, reverted bool) {
 	if f.Type == vm.CREATE || f.Type == vm.CREATE2 {
 		f.To = nil
 	}
-	if !errors.Is(err, vm.ErrExecutionReverted) || len(output) == 0 {
+	if !errors.Is(err, vm.ErrExecutionReverted) {
 		return
 	}
-	f.Output = output
-	if len(output) < 4 {
+	if len(output) > 0 {
+		f.Output = output
+	}
+	if len(output) >= 4 {
+		if unpacked, err := registry.Decode(output); err == nil {
+			f.RevertReason = unpacked
+		}
+	}
+	f.chainReverts()
+}
+
+// chainReverts records the chain of reverts leading to the revert of the frame,
+// if it reverted with data after a subcall did so. The chain follows the last
+// failed subcall of every frame down to the root cause, with the reverts bubbling
+// up the same data listed once.
+func (f *callFrame) chainReverts() {
+	if len(f.Output) == 0 {
+		return
+	}
+	var cause *callFrame
+	for i := len(f.Calls) - 1; i >= 0; i-- {
+		if f.Calls[i].failed() {
+			cause = &f.Calls[i]
+			break
+		}
+	}
+	if cause == nil || len(cause.Output) == 0 {
 		return
 	}
-	if unpacked, err := abi.UnpackRevert(output); err == nil {
-		f.RevertReason = unpacked
+	chain := cause.RevertChain
+	if chain == nil {
+		chain = []string{cause.revertDescription()}
+	}
+	if !bytes.Equal(f.Output, cause.Output) {
+		chain = append([]string{f.revertDescription()}, chain...)
+	}
+	if len(chain) > 1 {
+		f.RevertChain = chain
 	}
 }
 
+// revertDescription describes the revert of the frame by its reason if known,
+// or its revert data otherwise.
+func (f *callFrame) revertDescription() string {
+	if f.RevertReason != "" {
+		return f.RevertReason
+	}
+	return hexutil.Encode(f.Output)
+}
+
 type callFrameMarshaling struct {
 	TypeString string `json:"type"`
 	Gas        hexutil.Uint64
@@ -114,8 +157,9 @@ type callTracer struct {
 	config    callTracerConfig
 	gasLimit  uint64
 	depth     int
-	interrupt atomic.Bool // Atomic flag to signal execution interruption
-	reason    error       // Textual reason for the interruption
+	interrupt atomic.Bool        // Atomic flag to signal execution interruption
+	reason    error              // Textual reason for the interruption
+	registry  *abi.ErrorRegistry // Decoder of custom revert errors
 }
 
 type callTracerConfig struct {
@@ -152,7 +196,11 @@ func newCallTracerObject(ctx *tracers.Context, cfg json.RawMessage) (*callTracer
 	}
 	// First callframe contains tx context info
 	// and is populated on start and end.
-	return &callTracer{callstack: make([]callFrame, 0, 1), config: config}, nil
+	t := &callTracer{callstack: make([]callFrame, 0, 1), config: config}
+	if ctx != nil {
+		t.registry = ctx.ErrorRegistry
+	}
+	return t, nil
 }
 
 // OnEnter is called when EVM enters a new scope (via call, create or selfdestruct).
@@ -204,7 +252,7 @@ func (t *callTracer) OnExit(depth int, output []byte, gasUsed uint64, err error,
 	size -= 1
 
 	call.GasUsed = gasUsed
-	call.processOutput(output, err, reverted)
+	call.processOutput(output, err, reverted, t.registry)
 	// Nest call into parent.
 	t.callstack[size-1].Calls = append(t.callstack[size-1].Calls, call)
 }
@@ -213,7 +261,7 @@ func (t *callTracer) captureEnd(output []byte, gasUsed uint64, err error, revert
 	if len(t.callstack) != 1 {
 		return
 	}
-	t.callstack[0].processOutput(output, err, reverted)
+	t.callstack[0].processOutput(output, err, reverted, t.registry)
 }
 
 func (t *callTracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
@@ -0,0 +1,600 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/internal"
+	"github.com/holiman/uint256"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("erc7562Tracer", newERC7562Tracer, false)
+}
+
+// Roles of the entities of a user operation.
+const (
+	RoleFactory    = "factory"
+	RoleAccount    = "account"
+	RolePaymaster  = "paymaster"
+	RoleAggregator = "aggregator"
+)
+
+// Rules not numbered by ERC-7562 itself.
+const ruleUnstakedEntityCall = "unstaked-entity-call"
+
+// maxAssociatedOffset is the largest offset of a slot from the keccak of an
+// address, for the slot to be associated with the address.
+const maxAssociatedOffset = 128
+
+var (
+	// bannedOpcodes are the opcodes entities may not use during validation (OP-011).
+	bannedOpcodes = map[vm.OpCode]bool{
+		vm.ORIGIN:       true,
+		vm.GASPRICE:     true,
+		vm.BLOCKHASH:    true,
+		vm.COINBASE:     true,
+		vm.TIMESTAMP:    true,
+		vm.NUMBER:       true,
+		vm.DIFFICULTY:   true,
+		vm.GASLIMIT:     true,
+		vm.BASEFEE:      true,
+		vm.BLOBHASH:     true,
+		vm.BLOBBASEFEE:  true,
+		vm.INVALID:      true,
+		vm.SELFDESTRUCT: true,
+	}
+	// depositToSelector is the selector of EntryPoint.depositTo(address).
+	depositToSelector = crypto.Keccak256([]byte("depositTo(address)"))[:4]
+)
+
+// ERC7562TracerConfig is the configuration of the erc7562Tracer, describing the
+// user operation whose validation is traced.
+type ERC7562TracerConfig struct {
+	EntryPoint common.Address   `json:"entryPoint"`
+	Sender     common.Address   `json:"sender"`
+	Factory    *common.Address  `json:"factory,omitempty"`    // Set if the account is deployed by the operation
+	Paymaster  *common.Address  `json:"paymaster,omitempty"`  // Set if the operation is sponsored
+	Aggregator *common.Address  `json:"aggregator,omitempty"` // Set if the signature is aggregated
+	Staked     []common.Address `json:"staked,omitempty"`     // Entities staked in the entry point
+}
+
+// ValidationViolation is a breach of a validation rule.
+type ValidationViolation struct {
+	Rule    string          `json:"rule"`             // Rule identifier, e.g. OP-011
+	Entity  string          `json:"entity"`           // Role of the entity being validated
+	Address common.Address  `json:"address"`          // Contract whose code breached the rule
+	PC      uint64          `json:"pc"`               // Program counter of the breach
+	Opcode  string          `json:"opcode,omitempty"` // Opcode of the breach
+	Target  *common.Address `json:"target,omitempty"` // Accessed or called account
+	Slot    *common.Hash    `json:"slot,omitempty"`   // Accessed storage slot
+	Message string          `json:"message"`
+}
+
+// ValidationStorage is the storage of an account accessed during validation.
+type ValidationStorage struct {
+	Reads           []common.Hash `json:"reads,omitempty"`
+	Writes          []common.Hash `json:"writes,omitempty"`
+	TransientReads  []common.Hash `json:"transientReads,omitempty"`
+	TransientWrites []common.Hash `json:"transientWrites,omitempty"`
+}
+
+// ValidationPhase is the validation of one entity.
+type ValidationPhase struct {
+	Entity   string                                `json:"entity"`
+	Address  common.Address                        `json:"address"`
+	GasUsed  hexutil.Uint64                        `json:"gasUsed"`
+	OutOfGas bool                                  `json:"outOfGas"` // Whether any call of the phase ran out of gas
+	Error    string                                `json:"error,omitempty"`
+	Storage  map[common.Address]*ValidationStorage `json:"storage"`
+}
+
+// ValidationResult is the result of the erc7562Tracer.
+type ValidationResult struct {
+	Violations []ValidationViolation `json:"violations"`
+	Phases     []*ValidationPhase    `json:"phases"`
+	Keccak     []hexutil.Bytes       `json:"keccak"` // Inputs of KECCAK256, from which slots are associated
+	Output     hexutil.Bytes         `json:"output,omitempty"`
+	Error      string                `json:"error,omitempty"`
+}
+
+// validationFrame is a call frame of the validation.
+type validationFrame struct {
+	addr    common.Address // Address of the executed code
+	phase   *validationPhase
+	lastPC  uint64
+	lastOp  vm.OpCode
+	pending *ValidationViolation // Breach unless followed by one of the allowed opcodes
+	allowed []vm.OpCode
+}
+
+// validationPhase is the state of a phase.
+type validationPhase struct {
+	*ValidationPhase
+	root    *validationFrame
+	staked  bool
+	created bool // Whether the sender was deployed by CREATE2 already
+	reads   map[common.Address]map[common.Hash]bool
+	writes  map[common.Address]map[common.Hash]bool
+	treads  map[common.Address]map[common.Hash]bool
+	twrites map[common.Address]map[common.Hash]bool
+}
+
+// erc7562Tracer checks the validation of an ERC-4337 user operation against the
+// validation rules of ERC-7562, i.e. the opcode, storage access and call rules
+// bundlers apply to protect themselves against invalidation of the operation
+// between its validation and its inclusion.
+//
+// The tracer is meant for the simulated validation call to the entry point. The
+// validation of each entity is the outermost call into it, including its nested
+// calls. Code of the entry point and its helpers outside of these is not checked.
+type erc7562Tracer struct {
+	env         *tracing.VMContext
+	config      ERC7562TracerConfig
+	roles       map[common.Address]string
+	staked      map[common.Address]bool
+	precompiles map[common.Address]bool
+
+	frames       []*validationFrame
+	phases       []*validationPhase
+	violations   []ValidationViolation
+	seen         map[string]bool                  // Reported violations, to report each once
+	keccak       []hexutil.Bytes                  // KECCAK256 inputs, in order
+	keccakSeen   map[string]bool                  // Recorded KECCAK256 inputs
+	associations map[common.Address][]common.Hash // Keccak of the preimages starting with an address
+
+	output []byte
+	err    error
+
+	interrupt atomic.Bool // Atomic flag to signal execution interruption
+	reason    error       // Textual reason for the interruption
+}
+
+// newERC7562Tracer returns a native go tracer which checks the validation of a
+// user operation against the rules of ERC-7562.
+func newERC7562Tracer(ctx *tracers.Context, cfg json.RawMessage) (*tracers.Tracer, error) {
+	var config ERC7562TracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	if config.EntryPoint == (common.Address{}) || config.Sender == (common.Address{}) {
+		return nil, errors.New("entryPoint and sender are required")
+	}
+	t := &erc7562Tracer{
+		config:       config,
+		roles:        map[common.Address]string{config.Sender: RoleAccount},
+		staked:       make(map[common.Address]bool),
+		seen:         make(map[string]bool),
+		keccakSeen:   make(map[string]bool),
+		associations: make(map[common.Address][]common.Hash),
+	}
+	if config.Factory != nil {
+		t.roles[*config.Factory] = RoleFactory
+	}
+	if config.Paymaster != nil {
+		t.roles[*config.Paymaster] = RolePaymaster
+	}
+	if config.Aggregator != nil {
+		t.roles[*config.Aggregator] = RoleAggregator
+	}
+	for _, addr := range config.Staked {
+		t.staked[addr] = true
+	}
+	return &tracers.Tracer{
+		Hooks: &tracing.Hooks{
+			OnTxStart: t.OnTxStart,
+			OnEnter:   t.OnEnter,
+			OnExit:    t.OnExit,
+			OnOpcode:  t.OnOpcode,
+		},
+		GetResult: t.GetResult,
+		Stop:      t.Stop,
+	}, nil
+}
+
+func (t *erc7562Tracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.env = env
+	rules := env.ChainConfig.Rules(env.BlockNumber, env.Random != nil, env.Time)
+	t.precompiles = make(map[common.Address]bool)
+	for _, addr := range vm.ActivePrecompiles(rules) {
+		t.precompiles[addr] = true
+	}
+}
+
+// OnEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *erc7562Tracer) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	frame := &validationFrame{addr: to}
+	if len(t.frames) > 0 {
+		parent := t.frames[len(t.frames)-1]
+		frame.phase = parent.phase
+		if frame.phase != nil && !t.interrupt.Load() {
+			t.checkCall(parent, vm.OpCode(typ), from, to, input, value)
+		}
+	}
+	// The outermost call into an entity starts its validation
+	if role, ok := t.roles[to]; ok && frame.phase == nil && to != t.config.EntryPoint {
+		frame.phase = &validationPhase{
+			ValidationPhase: &ValidationPhase{
+				Entity:  role,
+				Address: to,
+				Storage: make(map[common.Address]*ValidationStorage),
+			},
+			root:    frame,
+			staked:  t.staked[to],
+			reads:   make(map[common.Address]map[common.Hash]bool),
+			writes:  make(map[common.Address]map[common.Hash]bool),
+			treads:  make(map[common.Address]map[common.Hash]bool),
+			twrites: make(map[common.Address]map[common.Hash]bool),
+		}
+		t.phases = append(t.phases, frame.phase)
+	}
+	t.frames = append(t.frames, frame)
+}
+
+// checkCall checks a call made by the given frame during validation.
+func (t *erc7562Tracer) checkCall(caller *validationFrame, op vm.OpCode, from, to common.Address, input []byte, value *big.Int) {
+	phase := caller.phase
+	switch op {
+	case vm.SELFDESTRUCT:
+		// Already reported as banned opcode
+		return
+	case vm.CREATE2:
+		if phase.Entity != RoleFactory || phase.created || to != t.config.Sender {
+			t.violate(caller, phase, "OP-031", &to, nil, "CREATE2 is only allowed once, by the factory to deploy the sender")
+		}
+		phase.created = true
+		return
+	case vm.CREATE:
+		if from != t.config.Sender || t.config.Factory == nil {
+			t.violate(caller, phase, "OP-032", &to, nil, "CREATE is only allowed by a sender being deployed")
+		}
+		return
+	}
+	if to == t.config.EntryPoint {
+		switch {
+		case len(input) >= 4 && bytes.Equal(input[:4], depositToSelector) && (from == t.config.Sender || t.roles[from] == RoleFactory):
+			// depositTo is allowed from the sender and the factory (OP-052)
+		case len(input) == 0 && from == t.config.Sender:
+			// The fallback is allowed from the sender (OP-053)
+		default:
+			t.violate(caller, phase, "OP-054", &to, nil, "access to the entry point other than depositTo and the fallback")
+		}
+		return
+	}
+	if op == vm.CALL && value != nil && value.Sign() > 0 {
+		t.violate(caller, phase, "OP-061", &to, nil, "call with value other than to the entry point")
+	}
+	if !t.precompiles[to] && to != t.config.Sender && len(t.env.StateDB.GetCode(to)) == 0 {
+		t.violate(caller, phase, "OP-041", &to, nil, "call to an address without code")
+	}
+	if role, ok := t.roles[to]; ok && to != t.config.Sender && to != phase.Address && !t.staked[to] {
+		t.violate(caller, phase, ruleUnstakedEntityCall, &to, nil, fmt.Sprintf("call into unstaked %s", role))
+	}
+}
+
+// OnExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *erc7562Tracer) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	t.frames = t.frames[:len(t.frames)-1]
+	if frame.pending != nil {
+		t.report(*frame.pending)
+	}
+	if len(t.frames) == 0 {
+		t.output, t.err = common.CopyBytes(output), err
+	}
+	phase := frame.phase
+	if phase == nil {
+		return
+	}
+	if errors.Is(err, vm.ErrOutOfGas) || errors.Is(err, vm.ErrCodeStoreOutOfGas) {
+		phase.OutOfGas = true
+		t.report(ValidationViolation{
+			Rule:    "OP-020",
+			Entity:  phase.Entity,
+			Address: frame.addr,
+			PC:      frame.lastPC,
+			Opcode:  frame.lastOp.String(),
+			Message: "out of gas",
+		})
+	}
+	if phase.root == frame {
+		phase.GasUsed = hexutil.Uint64(gasUsed)
+		if err != nil {
+			phase.Error = err.Error()
+		}
+	}
+}
+
+// OnOpcode implements the EVMLogger interface to trace a single step of VM execution.
+func (t *erc7562Tracer) OnOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+	// Skip if tracing was interrupted
+	if t.interrupt.Load() || len(t.frames) == 0 {
+		return
+	}
+	var (
+		frame  = t.frames[len(t.frames)-1]
+		opcode = vm.OpCode(op)
+		stack  = scope.StackData()
+	)
+	// KECCAK256 is recorded everywhere, as the entry point computes slots too
+	if opcode == vm.KECCAK256 && len(stack) >= 2 {
+		t.recordKeccak(scope.MemoryData(), internal.StackBack(stack, 0), internal.StackBack(stack, 1))
+	}
+	phase := frame.phase
+	if phase == nil {
+		return
+	}
+	if frame.pending != nil {
+		if !slices.Contains(frame.allowed, opcode) {
+			t.report(*frame.pending)
+		}
+		frame.pending, frame.allowed = nil, nil
+	}
+	frame.lastPC, frame.lastOp = pc, opcode
+
+	switch {
+	case bannedOpcodes[opcode]:
+		t.violate(frame, phase, "OP-011", nil, nil, "banned opcode")
+	case strings.HasPrefix(opcode.String(), "opcode "):
+		t.violate(frame, phase, "OP-013", nil, nil, "unassigned opcode")
+	case opcode == vm.GAS:
+		// GAS is allowed only to pass it on to a call (OP-012)
+		frame.pending = t.violation(frame, phase, "OP-012", nil, nil, "GAS not followed by a call")
+		frame.allowed = []vm.OpCode{vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL}
+	case opcode == vm.BALANCE || opcode == vm.SELFBALANCE:
+		if !phase.staked {
+			t.violate(frame, phase, "OP-080", nil, nil, "balance access by unstaked entity")
+		}
+	case opcode == vm.EXTCODESIZE || opcode == vm.EXTCODEHASH || opcode == vm.EXTCODECOPY:
+		if len(stack) < 1 {
+			return
+		}
+		target := common.Address(internal.StackBack(stack, 0).Bytes20())
+		if target == t.config.EntryPoint {
+			t.violate(frame, phase, "OP-054", &target, nil, "access to the entry point other than depositTo and the fallback")
+			return
+		}
+		if t.precompiles[target] || target == t.config.Sender || len(t.env.StateDB.GetCode(target)) > 0 {
+			return
+		}
+		v := t.violation(frame, phase, "OP-041", &target, nil, "access to an address without code")
+		if opcode == vm.EXTCODESIZE {
+			// Checking whether a contract is deployed is allowed (OP-051)
+			frame.pending, frame.allowed = v, []vm.OpCode{vm.ISZERO}
+			return
+		}
+		t.report(*v)
+	case opcode == vm.SLOAD || opcode == vm.SSTORE || opcode == vm.TLOAD || opcode == vm.TSTORE:
+		if len(stack) < 1 {
+			return
+		}
+		slot := common.Hash(internal.StackBack(stack, 0).Bytes32())
+		t.checkStorage(frame, phase, scope.Address(), slot, opcode)
+	}
+}
+
+// checkStorage checks an access to the storage of the given account during
+// validation. Transient storage is subject to the same rules (OP-070).
+func (t *erc7562Tracer) checkStorage(frame *validationFrame, phase *validationPhase, addr common.Address, slot common.Hash, op vm.OpCode) {
+	var (
+		write  = op == vm.SSTORE || op == vm.TSTORE
+		access map[common.Address]map[common.Hash]bool
+	)
+	switch op {
+	case vm.SLOAD:
+		access = phase.reads
+	case vm.SSTORE:
+		access = phase.writes
+	case vm.TLOAD:
+		access = phase.treads
+	case vm.TSTORE:
+		access = phase.twrites
+	}
+	if access[addr] == nil {
+		access[addr] = make(map[common.Hash]bool)
+	}
+	access[addr][slot] = true
+
+	sender := t.config.Sender
+	switch {
+	case addr == sender:
+		// The storage of the account is always accessible (STO-010)
+	case t.associated(sender, slot):
+		// Storage associated with the account requires it to exist, or a staked factory (STO-021, STO-022)
+		if factory := t.config.Factory; factory != nil && !t.staked[*factory] {
+			t.violate(frame, phase, "STO-022", &addr, &slot, "access to storage associated with an undeployed account with an unstaked factory")
+		}
+	case addr == phase.Address:
+		if !phase.staked {
+			t.violate(frame, phase, "STO-031", &addr, &slot, "access to own storage by unstaked entity")
+		}
+	case t.associated(phase.Address, slot):
+		if !phase.staked {
+			t.violate(frame, phase, "STO-032", &addr, &slot, "access to storage associated with unstaked entity")
+		}
+	case write:
+		t.violate(frame, phase, "STO-033", &addr, &slot, "write to unassociated storage")
+	case !phase.staked:
+		t.violate(frame, phase, "STO-033", &addr, &slot, "read of unassociated storage by unstaked entity")
+	}
+}
+
+// associated returns whether the storage slot is associated with the address,
+// i.e. whether it is the address itself, or within maxAssociatedOffset of the
+// keccak of a preimage starting with the address.
+func (t *erc7562Tracer) associated(addr common.Address, slot common.Hash) bool {
+	if slot == common.BytesToHash(addr.Bytes()) {
+		return true
+	}
+	s := new(uint256.Int).SetBytes(slot[:])
+	for _, base := range t.associations[addr] {
+		diff := new(uint256.Int).Sub(s, new(uint256.Int).SetBytes(base[:]))
+		if diff.IsUint64() && diff.Uint64() <= maxAssociatedOffset {
+			return true
+		}
+	}
+	return false
+}
+
+// recordKeccak records the input of a KECCAK256, and the slot it derives if the
+// input starts with an address, e.g. as the key of a mapping.
+func (t *erc7562Tracer) recordKeccak(memory []byte, offset, size *uint256.Int) {
+	if !offset.IsUint64() || !size.IsUint64() {
+		return
+	}
+	preimage, err := internal.GetMemoryCopyPadded(memory, int64(offset.Uint64()), int64(size.Uint64()))
+	if err != nil {
+		return
+	}
+	// Repeated preimages hash to the same slots, record them only once
+	if t.keccakSeen[string(preimage)] {
+		return
+	}
+	t.keccakSeen[string(preimage)] = true
+	t.keccak = append(t.keccak, preimage)
+
+	if len(preimage) >= 32 && bytes.Equal(preimage[:12], make([]byte, 12)) {
+		addr := common.BytesToAddress(preimage[12:32])
+		t.associations[addr] = append(t.associations[addr], crypto.Keccak256Hash(preimage))
+	}
+}
+
+// violation creates a violation at the current step of the frame.
+func (t *erc7562Tracer) violation(frame *validationFrame, phase *validationPhase, rule string, target *common.Address, slot *common.Hash, msg string) *ValidationViolation {
+	return &ValidationViolation{
+		Rule:    rule,
+		Entity:  phase.Entity,
+		Address: frame.addr,
+		PC:      frame.lastPC,
+		Opcode:  frame.lastOp.String(),
+		Target:  target,
+		Slot:    slot,
+		Message: msg,
+	}
+}
+
+// violate reports a violation at the current step of the frame.
+func (t *erc7562Tracer) violate(frame *validationFrame, phase *validationPhase, rule string, target *common.Address, slot *common.Hash, msg string) {
+	t.report(*t.violation(frame, phase, rule, target, slot, msg))
+}
+
+// report records a violation, unless the same was reported already.
+func (t *erc7562Tracer) report(v ValidationViolation) {
+	key := fmt.Sprintf("%s/%s/%x/%d", v.Rule, v.Entity, v.Address, v.PC)
+	if v.Target != nil {
+		key += fmt.Sprintf("/%x", *v.Target)
+	}
+	if v.Slot != nil {
+		key += fmt.Sprintf("/%x", *v.Slot)
+	}
+	if t.seen[key] {
+		return
+	}
+	t.seen[key] = true
+	t.violations = append(t.violations, v)
+}
+
+// GetResult returns the json-encoded validation result, and any error arising
+// from the encoding or forceful termination (via `Stop`).
+func (t *erc7562Tracer) GetResult() (json.RawMessage, error) {
+	result := ValidationResult{
+		Violations: t.violations,
+		Phases:     make([]*ValidationPhase, 0, len(t.phases)),
+		Keccak:     t.keccak,
+		Output:     t.output,
+	}
+	if result.Violations == nil {
+		result.Violations = []ValidationViolation{}
+	}
+	if result.Keccak == nil {
+		result.Keccak = []hexutil.Bytes{}
+	}
+	if t.err != nil {
+		result.Error = t.err.Error()
+		if errors.Is(t.err, vm.ErrExecutionReverted) && len(t.output) >= 4 {
+			if reason, err := abi.UnpackRevert(t.output); err == nil {
+				result.Error += ": " + reason
+			}
+		}
+	}
+	for _, phase := range t.phases {
+		for addr, slots := range phase.reads {
+			phase.storage(addr).Reads = sortedSlots(slots)
+		}
+		for addr, slots := range phase.writes {
+			phase.storage(addr).Writes = sortedSlots(slots)
+		}
+		for addr, slots := range phase.treads {
+			phase.storage(addr).TransientReads = sortedSlots(slots)
+		}
+		for addr, slots := range phase.twrites {
+			phase.storage(addr).TransientWrites = sortedSlots(slots)
+		}
+		result.Phases = append(result.Phases, phase.ValidationPhase)
+	}
+	res, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *erc7562Tracer) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
+
+func (p *validationPhase) storage(addr common.Address) *ValidationStorage {
+	s := p.Storage[addr]
+	if s == nil {
+		s = new(ValidationStorage)
+		p.Storage[addr] = s
+	}
+	return s
+}
+
+func sortedSlots(slots map[common.Hash]bool) []common.Hash {
+	sorted := make([]common.Hash, 0, len(slots))
+	for slot := range slots {
+		sorted = append(sorted, slot)
+	}
+	slices.SortFunc(sorted, func(a, b common.Hash) int { return a.Cmp(b) })
+	return sorted
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+func TestERC7562KeccakAssociations(t *testing.T) {
+	tracer := &erc7562Tracer{
+		keccakSeen:   make(map[string]bool),
+		associations: make(map[common.Address][]common.Hash),
+	}
+	// Mapping slots of an address, as hashed by keccak(pad(addr) . slot)
+	addr := common.Address{0x01}
+	memory := append(common.BytesToHash(addr[:]).Bytes(), make([]byte, 32)...)
+	offset, size := uint256.NewInt(0), uint256.NewInt(64)
+
+	tracer.recordKeccak(memory, offset, size)
+	tracer.recordKeccak(memory, offset, size)
+	if n := len(tracer.associations[addr]); n != 1 {
+		t.Fatalf("repeated preimage associated %d times", n)
+	}
+	memory[63] = 1
+	tracer.recordKeccak(memory, offset, size)
+	if n := len(tracer.associations[addr]); n != 2 {
+		t.Fatalf("distinct preimages associated %d times, want 2", n)
+	}
+	if n := len(tracer.keccak); n != 2 {
+		t.Fatalf("recorded %d preimages, want 2", n)
+	}
+}
@@ -25,6 +25,7 @@ func (c callFrame) MarshalJSON() ([]byte, error) {
 		Output       hexutil.Bytes   `json:"output,omitempty" rlp:"optional"`
 		Error        string          `json:"error,omitempty" rlp:"optional"`
 		RevertReason string          `json:"revertReason,omitempty"`
+		RevertChain  []string        `json:"revertChain,omitempty"`
 		Calls        []callFrame     `json:"calls,omitempty" rlp:"optional"`
 		Logs         []callLog       `json:"logs,omitempty" rlp:"optional"`
 		Value        *hexutil.Big    `json:"value,omitempty" rlp:"optional"`
@@ -40,6 +41,7 @@ func (c callFrame) MarshalJSON() ([]byte, error) {
 	enc.Output = c.Output
 	enc.Error = c.Error
 	enc.RevertReason = c.RevertReason
+	enc.RevertChain = c.RevertChain
 	enc.Calls = c.Calls
 	enc.Logs = c.Logs
 	enc.Value = (*hexutil.Big)(c.Value)
@@ -59,6 +61,7 @@ func (c *callFrame) UnmarshalJSON(input []byte) error {
 		Output       *hexutil.Bytes  `json:"output,omitempty" rlp:"optional"`
 		Error        *string         `json:"error,omitempty" rlp:"optional"`
 		RevertReason *string         `json:"revertReason,omitempty"`
+		RevertChain  []string        `json:"revertChain,omitempty"`
 		Calls        []callFrame     `json:"calls,omitempty" rlp:"optional"`
 		Logs         []callLog       `json:"logs,omitempty" rlp:"optional"`
 		Value        *hexutil.Big    `json:"value,omitempty" rlp:"optional"`
@@ -94,6 +97,9 @@ func (c *callFrame) UnmarshalJSON(input []byte) error {
 	if dec.RevertReason != nil {
 		c.RevertReason = *dec.RevertReason
 	}
+	if dec.RevertChain != nil {
+		c.RevertChain = dec.RevertChain
+	}
 	if dec.Calls != nil {
 		c.Calls = dec.Calls
 	}
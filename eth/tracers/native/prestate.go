@@ -19,6 +19,7 @@ package native
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"sync/atomic"
 
@@ -31,10 +32,15 @@ import (
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/eth/tracers/internal"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/holiman/uint256"
 )
 
 //go:generate go run github.com/fjl/gencodec -type account -field-override accountMarshaling -out gen_account_json.go
 
+// maxPreimageSize is the maximum size of the keccak preimages recorded for
+// decoding storage slots. Larger inputs are not mapping keys in practice.
+const maxPreimageSize = 1024
+
 func init() {
 	tracers.DefaultDirectory.Register("prestateTracer", newPrestateTracer, false)
 }
@@ -46,7 +52,9 @@ type account struct {
 	Code    []byte                      `json:"code,omitempty"`
 	Nonce   uint64                      `json:"nonce,omitempty"`
 	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
-	empty   bool
+
+	DecodedStorage map[string]string `json:"decodedStorage,omitempty"` // Storage by variable, if the layout is known
+	empty          bool
 }
 
 func (a *account) exists() bool {
@@ -68,10 +76,18 @@ type prestateTracer struct {
 	reason    error       // Textual reason for the interruption
 	created   map[common.Address]bool
 	deleted   map[common.Address]bool
+
+	decoders  map[common.Address]*storageDecoder // Storage decoders by contract, nil if no layout is given
+	preimages map[common.Hash][]byte             // Keccak preimages, for resolving mapping and array slots
 }
 
 type prestateTracerConfig struct {
 	DiffMode bool `json:"diffMode"` // If true, this tracer will return state modifications
+
+	// StorageLayouts are the solc storage layouts of contracts, keyed by the
+	// address holding the storage (i.e. the proxy, not the implementation).
+	// Their slots are additionally reported by variable.
+	StorageLayouts map[common.Address]*StorageLayout `json:"storageLayouts,omitempty"`
 }
 
 func newPrestateTracer(ctx *tracers.Context, cfg json.RawMessage) (*tracers.Tracer, error) {
@@ -88,6 +104,17 @@ func newPrestateTracer(ctx *tracers.Context, cfg json.RawMessage) (*tracers.Trac
 		created: make(map[common.Address]bool),
 		deleted: make(map[common.Address]bool),
 	}
+	if len(config.StorageLayouts) > 0 {
+		t.decoders = make(map[common.Address]*storageDecoder)
+		t.preimages = make(map[common.Hash][]byte)
+		for addr, layout := range config.StorageLayouts {
+			decoder, err := newStorageDecoder(layout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid storage layout of %s: %v", addr, err)
+			}
+			t.decoders[addr] = decoder
+		}
+	}
 	return &tracers.Tracer{
 		Hooks: &tracing.Hooks{
 			OnTxStart: t.OnTxStart,
@@ -116,6 +143,8 @@ func (t *prestateTracer) OnOpcode(pc uint64, opcode byte, gas, cost uint64, scop
 	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
 		slot := common.Hash(stackData[stackLen-1].Bytes32())
 		t.lookupStorage(caller, slot)
+	case stackLen >= 2 && op == vm.KECCAK256 && t.decoders != nil:
+		t.recordPreimage(scope.MemoryData(), &stackData[stackLen-1], &stackData[stackLen-2])
 	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT):
 		addr := common.Address(stackData[stackLen-1].Bytes20())
 		t.lookupAccount(addr)
@@ -174,6 +203,9 @@ func (t *prestateTracer) OnTxEnd(receipt *types.Receipt, err error) {
 			delete(t.pre, a)
 		}
 	}
+	if t.decoders != nil {
+		t.decodeStorage()
+	}
 }
 
 // GetResult returns the json-encoded nested list of call traces, and any
@@ -281,3 +313,72 @@ func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
 	}
 	t.pre[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
 }
+
+// recordPreimage records the input of a KECCAK256, from which storage slots may
+// be derived.
+func (t *prestateTracer) recordPreimage(memory []byte, offset, size *uint256.Int) {
+	if !offset.IsUint64() || !size.IsUint64() || size.Uint64() < 32 || size.Uint64() > maxPreimageSize {
+		return
+	}
+	preimage, err := internal.GetMemoryCopyPadded(memory, int64(offset.Uint64()), int64(size.Uint64()))
+	if err != nil {
+		return
+	}
+	t.preimages[crypto.Keccak256Hash(preimage)] = preimage
+}
+
+// decodeStorage reports the storage of the contracts with a known layout by
+// variable. In diff mode, only the variables whose value changed are reported.
+func (t *prestateTracer) decodeStorage() {
+	for addr, decoder := range t.decoders {
+		pre, post := t.pre[addr], t.post[addr]
+		if pre == nil && post == nil {
+			continue
+		}
+		slots := make(map[common.Hash]struct{})
+		if pre != nil {
+			for slot := range pre.Storage {
+				slots[slot] = struct{}{}
+			}
+		}
+		if post != nil {
+			for slot := range post.Storage {
+				slots[slot] = struct{}{}
+			}
+		}
+		for slot := range slots {
+			for _, field := range decoder.locate(slot, t.preimages) {
+				var value common.Hash
+				if pre != nil {
+					value = pre.Storage[slot]
+				}
+				before := field.format(value)
+				if before == "" {
+					continue // mappings hold no value in their slot
+				}
+				if !t.config.DiffMode || post == nil {
+					if pre != nil {
+						setDecoded(pre, field.path, before)
+					}
+					continue
+				}
+				after := field.format(post.Storage[slot])
+				if before == after {
+					continue // unchanged variable packed with a changed one
+				}
+				if pre != nil {
+					setDecoded(pre, field.path, before)
+				}
+				setDecoded(post, field.path, after)
+			}
+		}
+	}
+}
+
+// setDecoded sets the value of a variable in the decoded storage of an account.
+func setDecoded(acc *account, path string, value string) {
+	if acc.DecodedStorage == nil {
+		acc.DecodedStorage = make(map[string]string)
+	}
+	acc.DecodedStorage[path] = value
+}
@@ -0,0 +1,472 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("gasProfiler", newGasProfiler, false)
+}
+
+// GasProfilerConfig is the configuration of the gasProfiler.
+type GasProfilerConfig struct {
+	// SourceMaps are the source maps of the runtime code of contracts, by address
+	SourceMaps map[common.Address]*SourceMap `json:"sourceMaps,omitempty"`
+}
+
+// ProfileEntry is the gas and time spent by a contract or a function. The totals
+// include the subcalls, and are counted again for recursive calls.
+type ProfileEntry struct {
+	Address    common.Address `json:"address"`
+	Selector   string         `json:"selector,omitempty"` // Function selector, constructor or fallback
+	Calls      uint64         `json:"calls"`
+	Gas        uint64         `json:"gas"`
+	SelfGas    uint64         `json:"selfGas"`
+	TimeNs     uint64         `json:"timeNs"`
+	SelfTimeNs uint64         `json:"selfTimeNs"`
+}
+
+// OpcodeProfile is the gas and time spent by the executions of an opcode, or by
+// the instructions of a source location.
+type OpcodeProfile struct {
+	Op       string `json:"op,omitempty"`
+	Location string `json:"location,omitempty"`
+	Count    uint64 `json:"count"`
+	Gas      uint64 `json:"gas"`
+	TimeNs   uint64 `json:"timeNs"`
+}
+
+// PCProfile is the gas and time spent by the executions of an instruction.
+type PCProfile struct {
+	Address  common.Address `json:"address"`
+	PC       uint64         `json:"pc"`
+	Op       string         `json:"op"`
+	Location string         `json:"location,omitempty"` // Source location, if a source map is configured
+	Count    uint64         `json:"count"`
+	Gas      uint64         `json:"gas"`
+	TimeNs   uint64         `json:"timeNs"`
+}
+
+// GasProfile is the result of the gasProfiler.
+type GasProfile struct {
+	GasUsed      uint64          `json:"gasUsed"`      // Gas used by the transaction, after refunds
+	IntrinsicGas uint64          `json:"intrinsicGas"` // Gas charged before execution
+	ExecutionGas uint64          `json:"executionGas"` // Gas used by the execution, before refunds
+	Contracts    []ProfileEntry  `json:"contracts"`
+	Functions    []ProfileEntry  `json:"functions"`
+	Opcodes      []OpcodeProfile `json:"opcodes"`
+	PCs          []PCProfile     `json:"pcs"`
+	Sources      []OpcodeProfile `json:"sources,omitempty"`
+	Folded       string          `json:"folded"` // Gas by stack, in the folded format of flamegraph tools
+}
+
+// profilerFrame is a call frame being profiled.
+type profilerFrame struct {
+	entry    *ProfileEntry // Contract profile of the frame
+	function *ProfileEntry // Function profile of the frame
+	stack    string        // Folded stack of the frame
+	locator  *sourceLocator
+	gas      uint64    // Gas available to the frame
+	start    time.Time // Time of entering the frame
+
+	childGas  uint64        // Gas used by the subcalls since the last step
+	childTime time.Duration // Time spent in the subcalls since the last step
+
+	// The last step of the frame, accounted once the next one is reached
+	hasLast  bool
+	lastOp   vm.OpCode
+	lastPC   uint64
+	lastGas  uint64
+	lastTime time.Time
+}
+
+type pcKey struct {
+	addr common.Address
+	pc   uint64
+}
+
+type functionKey struct {
+	addr     common.Address
+	selector string
+}
+
+// gasProfiler aggregates the gas and time spent by a transaction per contract,
+// per function and per opcode and instruction, and by stack of calls in the
+// folded format of flamegraph tools. Given the solc source maps of contracts,
+// the instructions are attributed to source locations as well.
+//
+// Gas is attributed to the step of the frame that spent it, measured from one
+// step to the next in the same frame, excluding the gas used by the subcalls.
+type gasProfiler struct {
+	env      *tracing.VMContext
+	config   GasProfilerConfig
+	frames   []*profilerFrame
+	locators map[common.Address]*sourceLocator
+
+	txGas        uint64 // Gas limit of the transaction
+	topGas       uint64 // Gas available to the top frame
+	gasUsed      uint64
+	executionGas uint64
+	contracts    map[common.Address]*ProfileEntry
+	functions    map[functionKey]*ProfileEntry
+	opcodes      map[vm.OpCode]*OpcodeProfile
+	pcs          map[pcKey]*PCProfile
+	sources      map[string]*OpcodeProfile
+	folded       map[string]uint64
+
+	interrupt atomic.Bool // Atomic flag to signal execution interruption
+	reason    error       // Textual reason for the interruption
+}
+
+// newGasProfiler returns a native go tracer which profiles the gas usage of a tx.
+func newGasProfiler(ctx *tracers.Context, cfg json.RawMessage) (*tracers.Tracer, error) {
+	var config GasProfilerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	t := &gasProfiler{
+		config:    config,
+		locators:  make(map[common.Address]*sourceLocator),
+		contracts: make(map[common.Address]*ProfileEntry),
+		functions: make(map[functionKey]*ProfileEntry),
+		opcodes:   make(map[vm.OpCode]*OpcodeProfile),
+		pcs:       make(map[pcKey]*PCProfile),
+		sources:   make(map[string]*OpcodeProfile),
+		folded:    make(map[string]uint64),
+	}
+	return &tracers.Tracer{
+		Hooks: &tracing.Hooks{
+			OnTxStart: t.OnTxStart,
+			OnTxEnd:   t.OnTxEnd,
+			OnEnter:   t.OnEnter,
+			OnExit:    t.OnExit,
+			OnOpcode:  t.OnOpcode,
+		},
+		GetResult: t.GetResult,
+		Stop:      t.Stop,
+	}, nil
+}
+
+func (t *gasProfiler) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.env = env
+	t.txGas = tx.Gas()
+}
+
+func (t *gasProfiler) OnTxEnd(receipt *types.Receipt, err error) {
+	if err == nil && receipt != nil {
+		t.gasUsed = receipt.GasUsed
+	}
+}
+
+// OnEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *gasProfiler) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	var selector string
+	switch op := vm.OpCode(typ); {
+	case op == vm.CREATE || op == vm.CREATE2:
+		selector = "constructor"
+	case len(input) < 4:
+		selector = "fallback"
+	default:
+		selector = hexutil.Encode(input[:4])
+	}
+	frame := &profilerFrame{
+		entry:    t.contract(to),
+		function: t.function(to, selector),
+		gas:      gas,
+		start:    time.Now(),
+	}
+	frame.entry.Calls++
+	frame.function.Calls++
+
+	label := to.Hex() + ":" + selector
+	if len(t.frames) > 0 {
+		frame.stack = t.frames[len(t.frames)-1].stack + ";" + label
+	} else {
+		frame.stack = label
+		t.topGas = gas
+	}
+	// Source maps are of the runtime code, so don't apply to constructors
+	if selector != "constructor" {
+		frame.locator = t.locator(to)
+	}
+	t.frames = append(t.frames, frame)
+}
+
+// OnExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *gasProfiler) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	if len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	t.frames = t.frames[:len(t.frames)-1]
+
+	now := time.Now()
+	elapsed := now.Sub(frame.start)
+	if frame.hasLast {
+		left := frame.gas - min(gasUsed, frame.gas)
+		t.account(frame, frame.lastGas-min(left, frame.lastGas), now)
+	} else {
+		// Precompiles and accounts without code use the gas without steps
+		t.folded[frame.stack] += gasUsed
+		frame.entry.SelfGas += gasUsed
+		frame.function.SelfGas += gasUsed
+		frame.entry.SelfTimeNs += uint64(elapsed)
+		frame.function.SelfTimeNs += uint64(elapsed)
+	}
+	frame.entry.Gas += gasUsed
+	frame.function.Gas += gasUsed
+	frame.entry.TimeNs += uint64(elapsed)
+	frame.function.TimeNs += uint64(elapsed)
+
+	if len(t.frames) == 0 {
+		t.executionGas = gasUsed
+		return
+	}
+	parent := t.frames[len(t.frames)-1]
+	parent.childGas += gasUsed
+	parent.childTime += elapsed
+}
+
+// OnOpcode implements the EVMLogger interface to trace a single step of VM execution.
+func (t *gasProfiler) OnOpcode(pc uint64, op byte, gas, cost uint64, scope tracing.OpContext, rData []byte, depth int, err error) {
+	// Skip if tracing was interrupted
+	if t.interrupt.Load() || len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	now := time.Now()
+	if frame.hasLast {
+		t.account(frame, frame.lastGas-min(gas, frame.lastGas), now)
+	}
+	frame.hasLast = true
+	frame.lastOp, frame.lastPC, frame.lastGas, frame.lastTime = vm.OpCode(op), pc, gas, now
+}
+
+// account attributes the gas spent since the last step of the frame to it,
+// excluding the gas used by the subcalls.
+func (t *gasProfiler) account(frame *profilerFrame, spent uint64, now time.Time) {
+	var (
+		gas     = spent - min(frame.childGas, spent)
+		elapsed = now.Sub(frame.lastTime) - frame.childTime
+		addr    = frame.entry.Address
+		leaf    = frame.lastOp.String()
+	)
+	frame.childGas, frame.childTime = 0, 0
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	frame.entry.SelfGas += gas
+	frame.entry.SelfTimeNs += uint64(elapsed)
+	frame.function.SelfGas += gas
+	frame.function.SelfTimeNs += uint64(elapsed)
+
+	op := t.opcodes[frame.lastOp]
+	if op == nil {
+		op = &OpcodeProfile{Op: frame.lastOp.String()}
+		t.opcodes[frame.lastOp] = op
+	}
+	op.Count++
+	op.Gas += gas
+	op.TimeNs += uint64(elapsed)
+
+	key := pcKey{addr, frame.lastPC}
+	step := t.pcs[key]
+	if step == nil {
+		step = &PCProfile{Address: addr, PC: frame.lastPC, Op: frame.lastOp.String()}
+		if frame.locator != nil {
+			step.Location = frame.locator.locate(frame.lastPC)
+		}
+		t.pcs[key] = step
+	}
+	step.Count++
+	step.Gas += gas
+	step.TimeNs += uint64(elapsed)
+
+	if step.Location != "" {
+		source := t.sources[step.Location]
+		if source == nil {
+			source = &OpcodeProfile{Location: step.Location}
+			t.sources[step.Location] = source
+		}
+		source.Count++
+		source.Gas += gas
+		source.TimeNs += uint64(elapsed)
+		leaf = step.Location
+	}
+	t.folded[frame.stack+";"+leaf] += gas
+}
+
+func (t *gasProfiler) contract(addr common.Address) *ProfileEntry {
+	entry := t.contracts[addr]
+	if entry == nil {
+		entry = &ProfileEntry{Address: addr}
+		t.contracts[addr] = entry
+	}
+	return entry
+}
+
+func (t *gasProfiler) function(addr common.Address, selector string) *ProfileEntry {
+	key := functionKey{addr, selector}
+	entry := t.functions[key]
+	if entry == nil {
+		entry = &ProfileEntry{Address: addr, Selector: selector}
+		t.functions[key] = entry
+	}
+	return entry
+}
+
+// locator returns the source locator of the code at the given address, if a
+// source map is configured for it.
+func (t *gasProfiler) locator(addr common.Address) *sourceLocator {
+	m := t.config.SourceMaps[addr]
+	if m == nil || t.env == nil {
+		return nil
+	}
+	if l, ok := t.locators[addr]; ok {
+		return l
+	}
+	l, err := newSourceLocator(t.env.StateDB.GetCode(addr), m)
+	if err != nil {
+		t.Stop(fmt.Errorf("source map of %x: %w", addr, err))
+	}
+	t.locators[addr] = l
+	return l
+}
+
+// Profile returns the profile of the traced transaction.
+func (t *gasProfiler) Profile() *GasProfile {
+	profile := &GasProfile{
+		GasUsed:      t.gasUsed,
+		ExecutionGas: t.executionGas,
+		Contracts:    make([]ProfileEntry, 0, len(t.contracts)),
+		Functions:    make([]ProfileEntry, 0, len(t.functions)),
+		Opcodes:      make([]OpcodeProfile, 0, len(t.opcodes)),
+		PCs:          make([]PCProfile, 0, len(t.pcs)),
+	}
+	// The gas available to the top frame is what is left after the intrinsic gas
+	if t.txGas > t.topGas {
+		profile.IntrinsicGas = t.txGas - t.topGas
+	}
+	if profile.GasUsed == 0 {
+		profile.GasUsed = t.executionGas
+	}
+	for _, entry := range t.contracts {
+		profile.Contracts = append(profile.Contracts, *entry)
+	}
+	for _, entry := range t.functions {
+		profile.Functions = append(profile.Functions, *entry)
+	}
+	for _, entry := range t.opcodes {
+		profile.Opcodes = append(profile.Opcodes, *entry)
+	}
+	for _, entry := range t.pcs {
+		profile.PCs = append(profile.PCs, *entry)
+	}
+	for _, entry := range t.sources {
+		profile.Sources = append(profile.Sources, *entry)
+	}
+	// Order by the gas spent, with deterministic tie breaks
+	slices.SortFunc(profile.Contracts, func(a, b ProfileEntry) int {
+		if a.SelfGas != b.SelfGas {
+			return compareDesc(a.SelfGas, b.SelfGas)
+		}
+		return a.Address.Cmp(b.Address)
+	})
+	slices.SortFunc(profile.Functions, func(a, b ProfileEntry) int {
+		if a.SelfGas != b.SelfGas {
+			return compareDesc(a.SelfGas, b.SelfGas)
+		}
+		if c := a.Address.Cmp(b.Address); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Selector, b.Selector)
+	})
+	slices.SortFunc(profile.Opcodes, func(a, b OpcodeProfile) int {
+		if a.Gas != b.Gas {
+			return compareDesc(a.Gas, b.Gas)
+		}
+		return strings.Compare(a.Op, b.Op)
+	})
+	slices.SortFunc(profile.PCs, func(a, b PCProfile) int {
+		if a.Gas != b.Gas {
+			return compareDesc(a.Gas, b.Gas)
+		}
+		if c := a.Address.Cmp(b.Address); c != 0 {
+			return c
+		}
+		return compareDesc(b.PC, a.PC)
+	})
+	slices.SortFunc(profile.Sources, func(a, b OpcodeProfile) int {
+		if a.Gas != b.Gas {
+			return compareDesc(a.Gas, b.Gas)
+		}
+		return strings.Compare(a.Location, b.Location)
+	})
+	stacks := make([]string, 0, len(t.folded))
+	for stack, gas := range t.folded {
+		if gas > 0 {
+			stacks = append(stacks, fmt.Sprintf("%s %d\n", stack, gas))
+		}
+	}
+	slices.Sort(stacks)
+	profile.Folded = strings.Join(stacks, "")
+	return profile
+}
+
+// GetResult returns the json-encoded profile, and any error arising from the
+// encoding or forceful termination (via `Stop`).
+func (t *gasProfiler) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.Profile())
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *gasProfiler) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
+
+func compareDesc(a, b uint64) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// SourceMap is the solc source mapping of the runtime code of a contract.
+type SourceMap struct {
+	SourceMap string   `json:"sourceMap"`          // Compressed source map, as output by solc
+	Sources   []string `json:"sources"`            // Names of the source files, by file index
+	Contents  []string `json:"contents,omitempty"` // Contents of the source files, for resolving lines
+}
+
+// sourceRange is the source range of an instruction.
+type sourceRange struct {
+	start, length, file int
+}
+
+// sourceLocator resolves the program counters of a code to source locations.
+type sourceLocator struct {
+	instructions []int         // Instruction index by program counter
+	ranges       []sourceRange // Source range by instruction index
+	sources      []string      // Names of the source files
+	lines        [][]int       // Offsets of the line starts of the source files, if known
+}
+
+// newSourceLocator decodes the source map of the given code.
+func newSourceLocator(code []byte, m *SourceMap) (*sourceLocator, error) {
+	ranges, err := parseSourceMap(m.SourceMap)
+	if err != nil {
+		return nil, err
+	}
+	l := &sourceLocator{
+		instructions: make([]int, len(code)),
+		ranges:       ranges,
+		sources:      m.Sources,
+		lines:        make([][]int, len(m.Contents)),
+	}
+	for pc, index := 0, 0; pc < len(code); index++ {
+		op := vm.OpCode(code[pc])
+		l.instructions[pc] = index
+		pc++
+		if op.IsPush() {
+			size := int(op - vm.PUSH0)
+			for i := 0; i < size && pc < len(code); i++ {
+				l.instructions[pc] = index
+				pc++
+			}
+		}
+	}
+	for i, content := range m.Contents {
+		starts := []int{0}
+		for offset, c := range content {
+			if c == '\n' {
+				starts = append(starts, offset+1)
+			}
+		}
+		l.lines[i] = starts
+	}
+	return l, nil
+}
+
+// locate returns the source location of the instruction at the given program
+// counter, as file:line if the contents of the file is known, or file:start:length
+// otherwise. It returns the empty string for compiler generated code.
+func (l *sourceLocator) locate(pc uint64) string {
+	if pc >= uint64(len(l.instructions)) {
+		return ""
+	}
+	index := l.instructions[pc]
+	if index >= len(l.ranges) {
+		return ""
+	}
+	r := l.ranges[index]
+	if r.file < 0 || r.file >= len(l.sources) {
+		return ""
+	}
+	if r.file < len(l.lines) {
+		line := sort.Search(len(l.lines[r.file]), func(i int) bool { return l.lines[r.file][i] > r.start })
+		return fmt.Sprintf("%s:%d", l.sources[r.file], line)
+	}
+	return fmt.Sprintf("%s:%d:%d", l.sources[r.file], r.start, r.length)
+}
+
+// parseSourceMap decodes a compressed solc source map into the source ranges of
+// the instructions. Each entry is of the form s:l:f:j:m, where omitted fields are
+// inherited from the previous entry.
+func parseSourceMap(m string) ([]sourceRange, error) {
+	if m == "" {
+		return nil, nil
+	}
+	var (
+		entries = strings.Split(m, ";")
+		ranges  = make([]sourceRange, len(entries))
+		last    = sourceRange{file: -1}
+	)
+	for i, entry := range entries {
+		fields := strings.Split(entry, ":")
+		for j, field := range fields {
+			if j > 2 || field == "" {
+				continue
+			}
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid source map entry %d: %q", i, entry)
+			}
+			switch j {
+			case 0:
+				last.start = n
+			case 1:
+				last.length = n
+			case 2:
+				last.file = n
+			}
+		}
+		ranges[i] = last
+	}
+	return ranges, nil
+}
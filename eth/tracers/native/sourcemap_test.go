@@ -0,0 +1,64 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestParseSourceMap(t *testing.T) {
+	have, err := parseSourceMap("1:2:0:-;:5;;7::1:i;::-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sourceRange{{1, 2, 0}, {1, 5, 0}, {1, 5, 0}, {7, 5, 1}, {7, 5, -1}}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("source ranges mismatch: have %v, want %v", have, want)
+	}
+	if _, err := parseSourceMap("1:x"); err == nil {
+		t.Error("expected error for invalid entry")
+	}
+}
+
+func TestSourceLocator(t *testing.T) {
+	// The PUSH2 immediates must not count as instructions
+	code := []byte{byte(vm.PUSH2), 0xff, 0xff, byte(vm.POP), byte(vm.STOP)}
+	m := &SourceMap{SourceMap: "0:3:0;4:3;::-1", Sources: []string{"a.sol"}}
+	l, err := newSourceLocator(code, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pc, want := range []string{"a.sol:0:3", "a.sol:0:3", "a.sol:0:3", "a.sol:4:3", ""} {
+		if have := l.locate(uint64(pc)); have != want {
+			t.Errorf("pc %d: have %q, want %q", pc, have, want)
+		}
+	}
+	// Lines are reported if the contents are known
+	m.Contents = []string{"abc\ndef\n"}
+	if l, err = newSourceLocator(code, m); err != nil {
+		t.Fatal(err)
+	}
+	if have := l.locate(3); have != "a.sol:2" {
+		t.Errorf("have %q, want %q", have, "a.sol:2")
+	}
+	if have := l.locate(100); have != "" {
+		t.Errorf("out of range pc: have %q, want empty", have)
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/holiman/uint256"
+)
+
+// maxDerivedOffset is the maximum distance of a slot from the hash it is derived
+// from, e.g. the index of a dynamic array element or the member of a struct held
+// in a mapping.
+const maxDerivedOffset = 1 << 32
+
+// StorageLayout is the solc storage layout of a contract, as output with the
+// storageLayout output selection.
+type StorageLayout struct {
+	Storage []StorageVariable       `json:"storage"` // State variables, in declaration order
+	Types   map[string]*StorageType `json:"types"`   // Types of the variables, by type identifier
+}
+
+// StorageVariable is a state variable or a struct member in a storage layout.
+type StorageVariable struct {
+	Label  string `json:"label"`  // Name of the variable
+	Offset int    `json:"offset"` // Offset in bytes within the slot, from the right
+	Slot   string `json:"slot"`   // Slot of the variable, as a decimal string
+	Type   string `json:"type"`   // Identifier of the type of the variable
+}
+
+// StorageType is a type in a storage layout.
+type StorageType struct {
+	Encoding      string            `json:"encoding"`          // One of inplace, mapping, dynamic_array or bytes
+	Label         string            `json:"label"`             // Canonical name of the type
+	NumberOfBytes string            `json:"numberOfBytes"`     // Size of the type, as a decimal string
+	Key           string            `json:"key,omitempty"`     // Key type of a mapping
+	Value         string            `json:"value,omitempty"`   // Value type of a mapping
+	Base          string            `json:"base,omitempty"`    // Element type of an array
+	Members       []StorageVariable `json:"members,omitempty"` // Members of a struct
+}
+
+// storageField is a variable, or a part of one, held in a storage slot.
+type storageField struct {
+	path   string       // Access path of the variable, e.g. balances[0xabc].amount
+	typ    *StorageType // Type of the variable, nil for raw slot contents
+	offset int          // Offset in bytes within the slot, from the right
+}
+
+// storageDecoder maps the storage slots of a contract back to its variables.
+type storageDecoder struct {
+	layout *StorageLayout
+	slots  []*uint256.Int                 // Parsed slots of the state variables
+	fields map[common.Hash][]storageField // Located slots, nil if not decodable
+}
+
+// newStorageDecoder validates a storage layout and creates a decoder for it.
+func newStorageDecoder(layout *StorageLayout) (*storageDecoder, error) {
+	for id, typ := range layout.Types {
+		if _, err := strconv.ParseUint(typ.NumberOfBytes, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid size of type %s: %v", id, err)
+		}
+		for _, ref := range []string{typ.Key, typ.Value, typ.Base} {
+			if _, ok := layout.Types[ref]; ref != "" && !ok {
+				return nil, fmt.Errorf("unknown type %s referenced by %s", ref, id)
+			}
+		}
+		for _, member := range typ.Members {
+			if _, err := strconv.ParseUint(member.Slot, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid slot of %s in %s: %v", member.Label, id, err)
+			}
+			if _, ok := layout.Types[member.Type]; !ok {
+				return nil, fmt.Errorf("unknown type %s of %s in %s", member.Type, member.Label, id)
+			}
+		}
+	}
+	d := &storageDecoder{
+		layout: layout,
+		slots:  make([]*uint256.Int, len(layout.Storage)),
+		fields: make(map[common.Hash][]storageField),
+	}
+	for i, v := range layout.Storage {
+		slot, err := uint256.FromDecimal(v.Slot)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slot of %s: %v", v.Label, err)
+		}
+		if _, ok := layout.Types[v.Type]; !ok {
+			return nil, fmt.Errorf("unknown type %s of %s", v.Type, v.Label)
+		}
+		d.slots[i] = slot
+	}
+	return d, nil
+}
+
+// size returns the number of bytes taken by a type.
+func size(typ *StorageType) uint64 {
+	n, _ := strconv.ParseUint(typ.NumberOfBytes, 10, 64)
+	return n
+}
+
+// span returns the number of slots taken by a type.
+func span(typ *StorageType) uint64 {
+	if n := (size(typ) + 31) / 32; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// locate returns the variables held in a slot. Slots derived from hashes, i.e.
+// mapping values and dynamic array elements, are resolved through the given
+// keccak preimages recorded during execution.
+func (d *storageDecoder) locate(slot common.Hash, preimages map[common.Hash][]byte) []storageField {
+	if fields, ok := d.fields[slot]; ok {
+		return fields
+	}
+	var (
+		fields []storageField
+		pos    = new(uint256.Int).SetBytes32(slot[:])
+	)
+	// Check the state variables, then the slots derived from hashes
+	for i, v := range d.layout.Storage {
+		if pos.Lt(d.slots[i]) {
+			continue
+		}
+		typ := d.layout.Types[v.Type]
+		if rel := new(uint256.Int).Sub(pos, d.slots[i]); rel.IsUint64() && rel.Uint64() < span(typ) {
+			fields = append(fields, d.expand(v.Label, typ, rel.Uint64(), v.Offset)...)
+		}
+	}
+	if len(fields) == 0 {
+		for hash, preimage := range preimages {
+			base := new(uint256.Int).SetBytes32(hash[:])
+			if pos.Lt(base) || len(preimage) < 32 {
+				continue
+			}
+			rel := new(uint256.Int).Sub(pos, base)
+			if !rel.IsUint64() || rel.Uint64() >= maxDerivedOffset {
+				continue
+			}
+			key, parent := preimage[:len(preimage)-32], common.BytesToHash(preimage[len(preimage)-32:])
+			for _, f := range d.locate(parent, preimages) {
+				fields = append(fields, d.derive(f, key, rel.Uint64())...)
+			}
+			if len(fields) > 0 {
+				break
+			}
+		}
+	}
+	d.fields[slot] = fields
+	return fields
+}
+
+// derive returns the variables held in the slot at the given distance from the
+// hash of a key and the slot of a mapping, dynamic array or byte array.
+func (d *storageDecoder) derive(parent storageField, key []byte, rel uint64) []storageField {
+	if parent.typ == nil {
+		return nil
+	}
+	switch parent.typ.Encoding {
+	case "mapping":
+		path := fmt.Sprintf("%s[%s]", parent.path, formatKey(d.layout.Types[parent.typ.Key], key))
+		value := d.layout.Types[parent.typ.Value]
+		if rel >= span(value) {
+			return nil
+		}
+		return d.expand(path, value, rel, 0)
+	case "dynamic_array":
+		if len(key) != 0 {
+			return nil
+		}
+		return d.elements(parent.path, d.layout.Types[parent.typ.Base], rel, 0)
+	case "bytes":
+		if len(key) != 0 {
+			return nil
+		}
+		return []storageField{{path: fmt.Sprintf("%s.data[%d]", parent.path, rel)}}
+	}
+	return nil
+}
+
+// expand returns the variables held in the slot at the given distance from the
+// start of a variable.
+func (d *storageDecoder) expand(path string, typ *StorageType, rel uint64, offset int) []storageField {
+	switch {
+	case len(typ.Members) > 0:
+		var fields []storageField
+		for _, member := range typ.Members {
+			start, _ := strconv.ParseUint(member.Slot, 10, 64)
+			mtyp := d.layout.Types[member.Type]
+			if rel >= start && rel < start+span(mtyp) {
+				fields = append(fields, d.expand(path+"."+member.Label, mtyp, rel-start, member.Offset)...)
+			}
+		}
+		return fields
+	case typ.Encoding == "inplace" && typ.Base != "":
+		return d.elements(path, d.layout.Types[typ.Base], rel, arrayLength(typ.Label))
+	case rel == 0:
+		return []storageField{{path: path, typ: typ, offset: offset}}
+	}
+	return nil
+}
+
+// elements returns the array elements held in the slot at the given distance
+// from the start of the array data. Elements smaller than half a slot are packed,
+// larger ones start a new slot. A length of zero means the length is unknown.
+func (d *storageDecoder) elements(path string, base *StorageType, rel uint64, length uint64) []storageField {
+	if n := size(base); n > 0 && n <= 16 {
+		var (
+			fields []storageField
+			count  = 32 / n
+		)
+		for i := uint64(0); i < count; i++ {
+			index := rel*count + i
+			if length > 0 && index >= length {
+				break
+			}
+			fields = append(fields, storageField{path: fmt.Sprintf("%s[%d]", path, index), typ: base, offset: int(i * n)})
+		}
+		return fields
+	}
+	index := rel / span(base)
+	if length > 0 && index >= length {
+		return nil
+	}
+	return d.expand(fmt.Sprintf("%s[%d]", path, index), base, rel%span(base), 0)
+}
+
+// arrayLength returns the length of a static array type from its label, e.g. 3
+// for uint256[3], or zero if unknown.
+func arrayLength(label string) uint64 {
+	start := strings.LastIndexByte(label, '[')
+	if start < 0 || !strings.HasSuffix(label, "]") {
+		return 0
+	}
+	n, _ := strconv.ParseUint(label[start+1:len(label)-1], 10, 64)
+	return n
+}
+
+// formatKey formats the key of a mapping according to its type.
+func formatKey(typ *StorageType, key []byte) string {
+	switch {
+	case typ.Encoding == "bytes" && typ.Label == "string":
+		return strconv.Quote(string(key))
+	case typ.Encoding == "bytes":
+		return hexutil.Encode(key)
+	case len(key) != 32:
+		return hexutil.Encode(key)
+	case strings.HasPrefix(typ.Label, "bytes"):
+		// Fixed size byte arrays are left aligned in the hashed key
+		return hexutil.Encode(key[:min(size(typ), 32)])
+	}
+	return formatValue(typ, key[32-int(min(size(typ), 32)):])
+}
+
+// format formats the value of a field held in the given slot contents.
+func (f storageField) format(value common.Hash) string {
+	if f.typ == nil {
+		return value.Hex()
+	}
+	switch f.typ.Encoding {
+	case "mapping":
+		return ""
+	case "dynamic_array":
+		return new(uint256.Int).SetBytes32(value[:]).Dec()
+	case "bytes":
+		// Short values are stored with their length in the lowest byte, long
+		// ones only store their length, the data residing in derived slots.
+		if value[31]&1 == 1 {
+			length := new(uint256.Int).SetBytes32(value[:])
+			return fmt.Sprintf("<%s bytes>", length.Rsh(length, 1).Dec())
+		}
+		data := value[:min(int(value[31]/2), 31)]
+		if f.typ.Label == "string" {
+			return strconv.Quote(string(data))
+		}
+		return hexutil.Encode(data)
+	}
+	n := int(min(size(f.typ), 32))
+	if f.offset+n > 32 {
+		return value.Hex()
+	}
+	return formatValue(f.typ, value[32-f.offset-n:32-f.offset])
+}
+
+// formatValue formats a big endian value type according to its type.
+func formatValue(typ *StorageType, data []byte) string {
+	label := typ.Label
+	switch {
+	case label == "bool":
+		return strconv.FormatBool(len(data) > 0 && data[len(data)-1] != 0)
+	case label == "address" || label == "address payable" || strings.HasPrefix(label, "contract "):
+		return common.BytesToAddress(data).Hex()
+	case strings.HasPrefix(label, "uint") || strings.HasPrefix(label, "enum "):
+		return new(big.Int).SetBytes(data).String()
+	case strings.HasPrefix(label, "int"):
+		v := new(big.Int).SetBytes(data)
+		if len(data) > 0 && data[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+		}
+		return v.String()
+	}
+	return hexutil.Encode(data)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testStorageLayout is the solc storage layout of:
+//
+//	struct S { uint256 x; address owner; bool flag; }
+//	S s;
+//	uint8[4] small;
+//	mapping(address => mapping(uint256 => S)) nested;
+//	string name;
+const testStorageLayout = `{
+	"storage": [
+		{"label": "s", "offset": 0, "slot": "0", "type": "t_struct(S)storage"},
+		{"label": "small", "offset": 0, "slot": "2", "type": "t_array(t_uint8)4_storage"},
+		{"label": "nested", "offset": 0, "slot": "3", "type": "t_mapping(t_address,t_mapping(t_uint256,t_struct(S)storage))"},
+		{"label": "name", "offset": 0, "slot": "4", "type": "t_string_storage"}
+	],
+	"types": {
+		"t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+		"t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"},
+		"t_uint8": {"encoding": "inplace", "label": "uint8", "numberOfBytes": "1"},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+		"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+		"t_array(t_uint8)4_storage": {"encoding": "inplace", "label": "uint8[4]", "numberOfBytes": "32", "base": "t_uint8"},
+		"t_mapping(t_uint256,t_struct(S)storage)": {"encoding": "mapping", "label": "mapping(uint256 => struct S)", "numberOfBytes": "32", "key": "t_uint256", "value": "t_struct(S)storage"},
+		"t_mapping(t_address,t_mapping(t_uint256,t_struct(S)storage))": {"encoding": "mapping", "label": "mapping(address => mapping(uint256 => struct S))", "numberOfBytes": "32", "key": "t_address", "value": "t_mapping(t_uint256,t_struct(S)storage)"},
+		"t_struct(S)storage": {"encoding": "inplace", "label": "struct S", "numberOfBytes": "64", "members": [
+			{"label": "x", "offset": 0, "slot": "0", "type": "t_uint256"},
+			{"label": "owner", "offset": 0, "slot": "1", "type": "t_address"},
+			{"label": "flag", "offset": 20, "slot": "1", "type": "t_bool"}
+		]}
+	}
+}`
+
+func TestStorageDecoder(t *testing.T) {
+	var layout StorageLayout
+	if err := json.Unmarshal([]byte(testStorageLayout), &layout); err != nil {
+		t.Fatal(err)
+	}
+	d, err := newStorageDecoder(&layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Derive the slot of nested[owner][7].owner through the recorded preimages
+	var (
+		owner     = common.HexToAddress("0xabc")
+		outerKey  = append(common.LeftPadBytes(owner[:], 32), common.BigToHash(common.Big3).Bytes()...)
+		outerSlot = crypto.Keccak256Hash(outerKey)
+		innerKey  = append(common.LeftPadBytes([]byte{7}, 32), outerSlot[:]...)
+		innerSlot = crypto.Keccak256Hash(innerKey)
+		preimages = map[common.Hash][]byte{outerSlot: outerKey, innerSlot: innerKey}
+		member    = common.BigToHash(new(big.Int).Add(innerSlot.Big(), common.Big1))
+	)
+
+	tests := []struct {
+		slot  common.Hash
+		value common.Hash
+		want  map[string]string
+	}{
+		{
+			slot:  common.BigToHash(common.Big1),
+			value: common.BytesToHash(append([]byte{1}, owner[:]...)),
+			want:  map[string]string{"s.owner": owner.Hex(), "s.flag": "true"},
+		},
+		{
+			slot:  common.BigToHash(common.Big2),
+			value: common.HexToHash("0x04030201"),
+			want:  map[string]string{"small[0]": "1", "small[1]": "2", "small[2]": "3", "small[3]": "4"},
+		},
+		{
+			slot:  member,
+			value: common.HexToHash("0x0abc"),
+			want:  map[string]string{"nested[" + owner.Hex() + "][7].owner": owner.Hex(), "nested[" + owner.Hex() + "][7].flag": "false"},
+		},
+		{
+			slot:  common.HexToHash("0x04"),
+			value: common.HexToHash("0x6162630000000000000000000000000000000000000000000000000000000006"),
+			want:  map[string]string{"name": `"abc"`},
+		},
+		{
+			slot: common.HexToHash("0xdead"),
+			want: map[string]string{},
+		},
+	}
+	for i, tt := range tests {
+		have := make(map[string]string)
+		for _, field := range d.locate(tt.slot, preimages) {
+			have[field.path] = field.format(tt.value)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: decoded slot mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Layouts referencing unknown types are rejected
+	layout.Storage = append(layout.Storage, StorageVariable{Label: "missing", Slot: "5", Type: "t_missing"})
+	if _, err := newStorageDecoder(&layout); err == nil {
+		t.Error("expected error for unknown type")
+	}
+}
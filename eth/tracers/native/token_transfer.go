@@ -0,0 +1,112 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/internal"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("tokenTransferTracer", newTokenTransferTracer, false)
+}
+
+// tokenTransferTracer collects the ether transfers of a transaction, and the
+// token transfers announced by the standard ERC-20, ERC-721 and ERC-1155 events.
+// Each transfer is attributed to the call frame making it, and the transfers of
+// reverted frames are dropped.
+//
+// Example:
+//
+//	> debug.traceTransaction("0x...", {tracer: "tokenTransferTracer"})
+//	[{
+//	  standard: "erc20",
+//	  token: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+//	  from: "0x...",
+//	  to: "0x...",
+//	  value: "0x5f5e100",
+//	  traceAddress: [0],
+//	  logIndex: "0x3"
+//	}]
+type tokenTransferTracer struct {
+	tracker   internal.TransferTracker
+	interrupt atomic.Bool // Atomic flag to signal execution interruption
+	reason    error       // Textual reason for the interruption
+}
+
+// newTokenTransferTracer returns a native go tracer which collects the ether and
+// token transfers of a tx.
+func newTokenTransferTracer(ctx *tracers.Context, _ json.RawMessage) (*tracers.Tracer, error) {
+	t := new(tokenTransferTracer)
+	return &tracers.Tracer{
+		Hooks: &tracing.Hooks{
+			OnTxStart: t.OnTxStart,
+			OnEnter:   t.OnEnter,
+			OnExit:    t.OnExit,
+			OnLog:     t.OnLog,
+		},
+		GetResult: t.GetResult,
+		Stop:      t.Stop,
+	}, nil
+}
+
+func (t *tokenTransferTracer) OnTxStart(env *tracing.VMContext, tx *types.Transaction, from common.Address) {
+	t.tracker.Reset()
+}
+
+// OnEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *tokenTransferTracer) OnEnter(depth int, typ byte, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	// The frames are tracked even if interrupted, to keep them balanced
+	t.tracker.OnEnter(depth, typ, from, to, input, gas, value)
+}
+
+// OnExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *tokenTransferTracer) OnExit(depth int, output []byte, gasUsed uint64, err error, reverted bool) {
+	t.tracker.OnExit(depth, output, gasUsed, err, reverted)
+}
+
+func (t *tokenTransferTracer) OnLog(log *types.Log) {
+	// Skip if tracing was interrupted
+	if t.interrupt.Load() {
+		return
+	}
+	t.tracker.OnLog(log)
+}
+
+// GetResult returns the json-encoded list of transfers, and any error arising
+// from the encoding or forceful termination (via `Stop`).
+func (t *tokenTransferTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.tracker.Transfers())
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *tokenTransferTracer) Stop(err error) {
+	t.reason = err
+	t.interrupt.Store(true)
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	schedulerRunningGauge  = metrics.NewRegisteredGauge("trace/scheduler/running", nil)
+	schedulerQueuedGauge   = metrics.NewRegisteredGauge("trace/scheduler/queued", nil)
+	schedulerRejectedMeter = metrics.NewRegisteredMeter("trace/scheduler/rejected", nil)
+	schedulerWaitTimer     = metrics.NewRegisteredTimer("trace/scheduler/wait", nil)
+
+	traceCacheHitMeter   = metrics.NewRegisteredMeter("trace/cache/hit", nil)
+	traceCacheMissMeter  = metrics.NewRegisteredMeter("trace/cache/miss", nil)
+	traceCacheStoreMeter = metrics.NewRegisteredMeter("trace/cache/store", nil)
+)
+
+// errTraceQueueFull is returned if a trace request arrives while the maximum
+// number of requests is already waiting for execution.
+var errTraceQueueFull = errors.New("too many pending trace requests")
+
+// SchedulerConfig are the limits of the tracing scheduler.
+type SchedulerConfig struct {
+	Concurrency int    // Maximum number of concurrent re-executions, 0 = number of CPUs
+	Queue       int    // Maximum number of requests waiting for execution
+	CacheSize   uint64 // Memory allowance for cached trace results in bytes, 0 = disabled
+}
+
+// Scheduler limits the re-execution done for tracing across all requests of the
+// node. Every request holds an execution slot while re-executing transactions,
+// requests arriving while all slots are taken are queued, and requests arriving
+// while the queue is full are rejected. Tracers parallelizing their work only
+// use the slots left idle by other requests.
+//
+// The scheduler also caches the results of traces of blocks and transactions,
+// which are immutable for a given block hash and trace configuration.
+type Scheduler struct {
+	slots   chan struct{} // Execution slots, holding a token while taken
+	queue   int           // Maximum number of waiting requests
+	waiting atomic.Int32  // Number of waiting requests
+
+	results   *lru.SizeConstrainedCache[common.Hash, []byte] // Encoded trace results, nil if disabled
+	cacheSize uint64                                         // Memory allowance of the results cache
+}
+
+// NewScheduler creates a tracing scheduler with the given limits.
+func NewScheduler(config SchedulerConfig) *Scheduler {
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	s := &Scheduler{
+		slots: make(chan struct{}, concurrency),
+		queue: config.Queue,
+	}
+	if config.CacheSize > 0 {
+		s.results = lru.NewSizeConstrainedCache[common.Hash, []byte](config.CacheSize)
+		s.cacheSize = config.CacheSize
+	}
+	return s
+}
+
+// acquire takes an execution slot, waiting in the queue if all are taken. The
+// returned function gives the slot back. Without scheduler, any number of
+// requests are executed concurrently.
+func (s *Scheduler) acquire(ctx context.Context) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		schedulerRunningGauge.Inc(1)
+		return s.release, nil
+	default:
+	}
+	if int(s.waiting.Add(1)) > s.queue {
+		s.waiting.Add(-1)
+		schedulerRejectedMeter.Mark(1)
+		return nil, errTraceQueueFull
+	}
+	schedulerQueuedGauge.Inc(1)
+	defer func() {
+		s.waiting.Add(-1)
+		schedulerQueuedGauge.Dec(1)
+	}()
+
+	start := time.Now()
+	select {
+	case s.slots <- struct{}{}:
+		schedulerWaitTimer.UpdateSince(start)
+		schedulerRunningGauge.Inc(1)
+		return s.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// acquireIdle takes up to n execution slots without waiting, returning the number
+// of slots taken. Without scheduler, all n slots are granted.
+func (s *Scheduler) acquireIdle(n int) int {
+	if s == nil {
+		return n
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case s.slots <- struct{}{}:
+			schedulerRunningGauge.Inc(1)
+		default:
+			return i
+		}
+	}
+	return n
+}
+
+// release gives an execution slot back.
+func (s *Scheduler) release() {
+	if s == nil {
+		return
+	}
+	<-s.slots
+	schedulerRunningGauge.Dec(1)
+}
+
+// caching reports whether trace results are cached.
+func (s *Scheduler) caching() bool {
+	return s != nil && s.results != nil
+}
+
+// maxCacheSize returns the size of the largest trace result which can be cached.
+func (s *Scheduler) maxCacheSize() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.cacheSize
+}
+
+// cached retrieves the cached trace result for key, decoding it into result.
+func (s *Scheduler) cached(key common.Hash, result interface{}) bool {
+	if s == nil || s.results == nil {
+		return false
+	}
+	blob, ok := s.results.Get(key)
+	if !ok {
+		traceCacheMissMeter.Mark(1)
+		return false
+	}
+	if err := json.Unmarshal(blob, result); err != nil {
+		log.Warn("Failed to decode cached trace", "key", key, "err", err)
+		return false
+	}
+	traceCacheHitMeter.Mark(1)
+	return true
+}
+
+// cache stores the trace result for key.
+func (s *Scheduler) cache(key common.Hash, result interface{}) {
+	if s == nil || s.results == nil {
+		return
+	}
+	blob, err := json.Marshal(result)
+	if err != nil {
+		log.Warn("Failed to encode trace for caching", "key", key, "err", err)
+		return
+	}
+	if uint64(len(blob)) > s.cacheSize {
+		return // would evict everything else
+	}
+	s.results.Add(key, blob)
+	traceCacheStoreMeter.Mark(1)
+}
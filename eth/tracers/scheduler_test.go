@@ -0,0 +1,172 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{Concurrency: 2, Queue: 1})
+
+	// Take all slots, the next request is queued and the one after rejected
+	var dones []func()
+	for i := 0; i < 2; i++ {
+		done, err := s.acquire(context.Background())
+		if err != nil {
+			t.Fatalf("failed to acquire slot %d: %v", i, err)
+		}
+		dones = append(dones, done)
+	}
+	acquired := make(chan func())
+	go func() {
+		done, err := s.acquire(context.Background())
+		if err != nil {
+			t.Errorf("queued request failed: %v", err)
+		}
+		acquired <- done
+	}()
+	for s.waiting.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := s.acquire(context.Background()); !errors.Is(err, errTraceQueueFull) {
+		t.Fatalf("request beyond queue not rejected: %v", err)
+	}
+	if n := s.acquireIdle(4); n != 0 {
+		t.Fatalf("idle slots granted while busy: %d", n)
+	}
+	// Releasing a slot hands it to the queued request
+	dones[0]()
+	select {
+	case done := <-acquired:
+		done()
+	case <-time.After(time.Second):
+		t.Fatalf("queued request not scheduled")
+	}
+	// Waiting requests give up with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done, err := s.acquire(ctx)
+	if err != nil {
+		t.Fatalf("failed to acquire free slot: %v", err)
+	}
+	if _, err := s.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting request not cancelled: %v", err)
+	}
+	done()
+	dones[1]()
+
+	if n := s.acquireIdle(4); n != 2 {
+		t.Fatalf("idle slot count mismatch: have %d, want 2", n)
+	}
+}
+
+func TestSchedulerCache(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	defer backend.teardown()
+
+	var states atomic.Int32
+	backend.refHook = func() { states.Add(1) }
+	backend.scheduler = NewScheduler(SchedulerConfig{Concurrency: 1, CacheSize: 1024 * 1024})
+	api := NewAPI(backend)
+
+	trace := func() []*txTraceResult {
+		t.Helper()
+		results, err := api.TraceBlockByNumber(context.Background(), 1, nil)
+		if err != nil {
+			t.Fatalf("failed to trace block: %v", err)
+		}
+		return results
+	}
+	first := trace()
+	second := trace()
+	if states.Load() != 1 {
+		t.Errorf("block re-executed for cached trace: %d executions", states.Load())
+	}
+	if len(first) != 1 || len(second) != 1 || first[0].TxHash != second[0].TxHash {
+		t.Errorf("cached trace mismatch: %v != %v", second, first)
+	}
+	// Transaction traces are cached by block and position too
+	hash := backend.chain.GetBlockByNumber(2).Transactions()[0].Hash()
+	for i := 0; i < 2; i++ {
+		if _, err := api.TraceTransaction(context.Background(), hash, nil); err != nil {
+			t.Fatalf("failed to trace transaction: %v", err)
+		}
+	}
+	if states.Load() != 2 {
+		t.Errorf("transaction re-executed for cached trace: %d executions", states.Load())
+	}
+	// The execution slot is given back once traces are done
+	if n := backend.scheduler.acquireIdle(1); n != 1 {
+		t.Errorf("execution slot not released")
+	}
+}
+
+// Tests that block traces exceeding the cache allowance are not kept around.
+func TestSchedulerCacheLimit(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: types.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	defer backend.teardown()
+
+	var states atomic.Int32
+	backend.refHook = func() { states.Add(1) }
+	backend.scheduler = NewScheduler(SchedulerConfig{Concurrency: 1, CacheSize: 16})
+	api := NewAPI(backend)
+
+	for i := 0; i < 2; i++ {
+		if _, err := api.TraceBlockByNumber(context.Background(), 1, nil); err != nil {
+			t.Fatalf("failed to trace block: %v", err)
+		}
+	}
+	if states.Load() != 2 {
+		t.Errorf("oversized trace served from cache: %d executions", states.Load())
+	}
+}
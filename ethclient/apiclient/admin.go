@@ -0,0 +1,105 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// NodeInfo returns information about the running node.
+func (ec *Client) NodeInfo(ctx context.Context) (*p2p.NodeInfo, error) {
+	var result p2p.NodeInfo
+	if err := ec.c.CallContext(ctx, &result, "admin_nodeInfo"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Peers returns information about the connected peers.
+func (ec *Client) Peers(ctx context.Context) ([]*p2p.PeerInfo, error) {
+	var result []*p2p.PeerInfo
+	err := ec.c.CallContext(ctx, &result, "admin_peers")
+	return result, err
+}
+
+// AddPeer requests connecting to the node with the given enode URL.
+func (ec *Client) AddPeer(ctx context.Context, url string) (bool, error) {
+	return ec.callBool(ctx, "admin_addPeer", url)
+}
+
+// RemovePeer disconnects from the node with the given enode URL.
+func (ec *Client) RemovePeer(ctx context.Context, url string) (bool, error) {
+	return ec.callBool(ctx, "admin_removePeer", url)
+}
+
+// AddTrustedPeer allows the node with the given enode URL to always connect,
+// even if the peer slots are full.
+func (ec *Client) AddTrustedPeer(ctx context.Context, url string) (bool, error) {
+	return ec.callBool(ctx, "admin_addTrustedPeer", url)
+}
+
+// RemoveTrustedPeer removes the node with the given enode URL from the trusted
+// peers, without disconnecting from it.
+func (ec *Client) RemoveTrustedPeer(ctx context.Context, url string) (bool, error) {
+	return ec.callBool(ctx, "admin_removeTrustedPeer", url)
+}
+
+// Datadir returns the data directory of the node.
+func (ec *Client) Datadir(ctx context.Context) (string, error) {
+	var result string
+	err := ec.c.CallContext(ctx, &result, "admin_datadir")
+	return result, err
+}
+
+// ExportChain exports the blocks in the given range, or the whole chain if the
+// bounds are nil, into a file on the server.
+func (ec *Client) ExportChain(ctx context.Context, file string, first, last *uint64) (bool, error) {
+	return ec.callBool(ctx, "admin_exportChain", file, first, last)
+}
+
+// ImportChain imports the blocks of an export file on the server.
+func (ec *Client) ImportChain(ctx context.Context, file string) (bool, error) {
+	return ec.callBool(ctx, "admin_importChain", file)
+}
+
+// AttachLiveTracer attaches the live tracer of the given name and configuration
+// under the given id.
+func (ec *Client) AttachLiveTracer(ctx context.Context, id string, name string, config json.RawMessage) error {
+	return ec.c.CallContext(ctx, nil, "admin_attachLiveTracer", id, name, config)
+}
+
+// DetachLiveTracer detaches the live tracer with the given id.
+func (ec *Client) DetachLiveTracer(ctx context.Context, id string) error {
+	return ec.c.CallContext(ctx, nil, "admin_detachLiveTracer", id)
+}
+
+// LiveTracers returns the live tracers attached to the node.
+func (ec *Client) LiveTracers(ctx context.Context) ([]rpctypes.LiveTracerInfo, error) {
+	var result []rpctypes.LiveTracerInfo
+	err := ec.c.CallContext(ctx, &result, "admin_liveTracers")
+	return result, err
+}
+
+func (ec *Client) callBool(ctx context.Context, method string, args ...interface{}) (bool, error) {
+	var result bool
+	err := ec.c.CallContext(ctx, &result, method, args...)
+	return result, err
+}
@@ -0,0 +1,206 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package apiclient provides typed access to the JSON-RPC API of geth beyond
+// the standardized methods of ethclient, including the txpool, admin, clique and
+// engine namespaces.
+//
+// The results are the types the server uses itself, shared by way of package
+// rpctypes, and of packages p2p, consensus/clique and beacon/engine. Use
+// CheckVersion to make sure a node serves results compatible with them.
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// Client is a typed client of the JSON-RPC API of geth.
+type Client struct {
+	c *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL with context.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return New(c), nil
+}
+
+// New creates a client that uses the given RPC client.
+func New(c *rpc.Client) *Client {
+	return &Client{c}
+}
+
+// Close closes the underlying RPC connection.
+func (ec *Client) Close() {
+	ec.c.Close()
+}
+
+// Client gets the underlying RPC client.
+func (ec *Client) Client() *rpc.Client {
+	return ec.c
+}
+
+// APIVersion returns the version of the result types served by the node.
+func (ec *Client) APIVersion(ctx context.Context) (string, error) {
+	var version string
+	err := ec.c.CallContext(ctx, &version, "web3_apiVersion")
+	return version, err
+}
+
+// CheckVersion checks that the results served by the node can be decoded into
+// the result types of the client.
+func (ec *Client) CheckVersion(ctx context.Context) error {
+	version, err := ec.APIVersion(ctx)
+	if err != nil {
+		return err
+	}
+	return rpctypes.CheckVersion(version)
+}
+
+// FeeHistory retrieves the fee market history, including the blob fee market
+// after Cancun.
+func (ec *Client) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*rpctypes.FeeHistoryResult, error) {
+	var result rpctypes.FeeHistoryResult
+	if err := ec.c.CallContext(ctx, &result, "eth_feeHistory", hexutil.Uint64(blockCount), lastBlock, rewardPercentiles); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BlockReceipts returns the receipts of the given block.
+func (ec *Client) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	if err := ec.c.CallContext(ctx, &receipts, "eth_getBlockReceipts", blockNrOrHash); err != nil {
+		return nil, err
+	}
+	if receipts == nil {
+		return nil, ethereum.NotFound
+	}
+	return receipts, nil
+}
+
+// CreateAccessList creates an access list for the given transaction on top of
+// the state of the given block. A failure of the transaction itself is reported
+// in the result.
+func (ec *Client) CreateAccessList(ctx context.Context, msg ethereum.CallMsg, blockNrOrHash rpc.BlockNumberOrHash) (*rpctypes.AccessListResult, error) {
+	var result rpctypes.AccessListResult
+	if err := ec.c.CallContext(ctx, &result, "eth_createAccessList", toCallArg(msg), blockNrOrHash); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetProof returns the account and the storage values of the given storage
+// keys of an account, along with their Merkle proofs. The proofs are not
+// verified, see VerifiedProof.
+func (ec *Client) GetProof(ctx context.Context, account common.Address, keys []common.Hash, blockNrOrHash rpc.BlockNumberOrHash) (*rpctypes.AccountResult, error) {
+	var result rpctypes.AccountResult
+	if err := ec.c.CallContext(ctx, &result, "eth_getProof", account, keys, blockNrOrHash); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// VerifiedProof returns the proven account and storage values of an account like
+// GetProof, after verifying the proofs against the state root of the block.
+//
+// Note, the state root is retrieved from the same server as the proof. To trust
+// the result, the header of the block must be verified independently, e.g. by
+// checking its hash against a trusted one.
+func (ec *Client) VerifiedProof(ctx context.Context, account common.Address, keys []common.Hash, blockNrOrHash rpc.BlockNumberOrHash) (*rpctypes.AccountResult, *types.Header, error) {
+	// Resolve the block first, so the proof is for the same one even if the
+	// chain progresses meanwhile
+	var header *types.Header
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		if err := ec.c.CallContext(ctx, &header, "eth_getHeaderByHash", hash); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		number, _ := blockNrOrHash.Number()
+		if err := ec.c.CallContext(ctx, &header, "eth_getHeaderByNumber", number); err != nil {
+			return nil, nil, err
+		}
+	}
+	if header == nil {
+		return nil, nil, ethereum.NotFound
+	}
+	result, err := ec.GetProof(ctx, account, keys, rpc.BlockNumberOrHashWithHash(header.Hash(), false))
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Address != account {
+		return nil, nil, fmt.Errorf("proof of wrong account %x", result.Address)
+	}
+	if len(result.StorageProof) != len(keys) {
+		return nil, nil, errors.New("storage proofs missing")
+	}
+	if err := result.Verify(header.Root); err != nil {
+		return nil, nil, err
+	}
+	return result, header, nil
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["input"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.GasFeeCap != nil {
+		arg["maxFeePerGas"] = (*hexutil.Big)(msg.GasFeeCap)
+	}
+	if msg.GasTipCap != nil {
+		arg["maxPriorityFeePerGas"] = (*hexutil.Big)(msg.GasTipCap)
+	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
+	if msg.BlobGasFeeCap != nil {
+		arg["maxFeePerBlobGas"] = (*hexutil.Big)(msg.BlobGasFeeCap)
+	}
+	if msg.BlobHashes != nil {
+		arg["blobVersionedHashes"] = msg.BlobHashes
+	}
+	return arg
+}
@@ -0,0 +1,366 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+var (
+	testKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr     = crypto.PubkeyToAddress(testKey.PublicKey)
+	testContract = common.HexToAddress("0xbeef")
+	testSlot     = common.HexToHash("0xdeadbeef")
+	testValue    = crypto.Keccak256Hash(testSlot[:])
+	testBalance  = big.NewInt(2e15)
+)
+
+func newTestBackend(t *testing.T) (*node.Node, *eth.Ethereum) {
+	genesis := &core.Genesis{
+		Config: params.AllEthashProtocolChanges,
+		Alloc: types.GenesisAlloc{
+			testAddr:     {Balance: testBalance},
+			testContract: {Nonce: 1, Code: []byte{0x60, 0x00, 0x54, 0x00}, Storage: map[common.Hash]common.Hash{testSlot: testValue}},
+		},
+		Timestamp: 9000,
+	}
+	signer := types.LatestSigner(genesis.Config)
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), 2, func(i int, g *core.BlockGen) {
+		g.OffsetTime(5)
+		if i == 0 {
+			g.AddTx(types.MustSignNewTx(testKey, signer, &types.DynamicFeeTx{
+				ChainID:   genesis.Config.ChainID,
+				To:        &testContract,
+				Gas:       100000,
+				GasFeeCap: big.NewInt(10 * params.GWei),
+				GasTipCap: big.NewInt(params.GWei),
+				Value:     big.NewInt(1),
+				Data:      common.FromHex("0x12345678"),
+			}))
+		}
+	})
+	n, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	config := ethconfig.Defaults
+	config.Genesis = genesis
+	ethservice, err := eth.New(n, &config)
+	if err != nil {
+		t.Fatalf("can't create new ethereum service: %v", err)
+	}
+	n.RegisterAPIs(tracers.APIs(ethservice.APIBackend))
+
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	if _, err := ethservice.BlockChain().InsertChain(blocks); err != nil {
+		t.Fatalf("can't import test blocks: %v", err)
+	}
+	return n, ethservice
+}
+
+func TestAPIClient(t *testing.T) {
+	backend, ethservice := newTestBackend(t)
+	client := New(backend.Attach())
+	defer backend.Close()
+	defer client.Close()
+
+	t.Run("Version", func(t *testing.T) { testVersion(t, client) })
+	t.Run("VerifiedProof", func(t *testing.T) { testVerifiedProof(t, client) })
+	t.Run("FeeHistory", func(t *testing.T) { testFeeHistory(t, client) })
+	t.Run("CreateAccessList", func(t *testing.T) { testCreateAccessList(t, client) })
+	t.Run("BlockReceipts", func(t *testing.T) { testBlockReceipts(t, client) })
+	t.Run("NodeInfo", func(t *testing.T) { testNodeInfo(t, client) })
+	t.Run("Trace", func(t *testing.T) { testTrace(t, client) })
+	t.Run("TxPool", func(t *testing.T) { testTxPool(t, client, ethservice) })
+}
+
+func testVersion(t *testing.T, client *Client) {
+	version, err := client.APIVersion(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve API version: %v", err)
+	}
+	if version != rpctypes.Version {
+		t.Fatalf("API version mismatch: have %s, want %s", version, rpctypes.Version)
+	}
+	if err := client.CheckVersion(context.Background()); err != nil {
+		t.Fatalf("compatible API version rejected: %v", err)
+	}
+}
+
+func testVerifiedProof(t *testing.T, client *Client) {
+	ctx := context.Background()
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	for _, addr := range []common.Address{testAddr, testContract, common.HexToAddress("0xdead")} {
+		result, _, err := client.VerifiedProof(ctx, addr, []common.Hash{testSlot, {}}, latest)
+		if err != nil {
+			t.Fatalf("%x: failed to verify proof: %v", addr, err)
+		}
+		if addr == testContract && result.StorageProof[0].Value.ToInt().Cmp(testValue.Big()) != 0 {
+			t.Fatalf("%x: storage value mismatch, want %x, got %x", addr, testValue, result.StorageProof[0].Value)
+		}
+	}
+	// Tampered results must not verify
+	var head *types.Header
+	if err := client.Client().CallContext(ctx, &head, "eth_getHeaderByNumber", rpc.LatestBlockNumber); err != nil {
+		t.Fatalf("failed to retrieve head: %v", err)
+	}
+	result, err := client.GetProof(ctx, testContract, []common.Hash{testSlot}, rpc.BlockNumberOrHashWithHash(head.Hash(), false))
+	if err != nil {
+		t.Fatalf("failed to retrieve proof: %v", err)
+	}
+	if err := result.Verify(head.Root); err != nil {
+		t.Fatalf("failed to verify proof: %v", err)
+	}
+	balance := result.Balance
+	result.Balance = (*hexutil.Big)(new(big.Int).Add(balance.ToInt(), big.NewInt(1)))
+	if err := result.Verify(head.Root); err == nil {
+		t.Fatal("tampered balance verified")
+	}
+	result.Balance = balance
+	result.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(1))
+	if err := result.Verify(head.Root); err == nil {
+		t.Fatal("tampered storage value verified")
+	}
+}
+
+func testFeeHistory(t *testing.T, client *Client) {
+	result, err := client.FeeHistory(context.Background(), 2, rpc.LatestBlockNumber, []float64{50})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if result.OldestBlock.ToInt().Uint64() != 1 {
+		t.Errorf("oldest block mismatch, want %d, got %d", 1, result.OldestBlock.ToInt())
+	}
+	if len(result.BaseFee) != 3 || len(result.GasUsedRatio) != 2 || len(result.Reward) != 2 {
+		t.Errorf("result length mismatch: %d base fees, %d ratios, %d rewards", len(result.BaseFee), len(result.GasUsedRatio), len(result.Reward))
+	}
+	if len(result.BlobBaseFee) != 3 || len(result.BlobGasUsedRatio) != 2 {
+		t.Errorf("blob result length mismatch: %d blob base fees, %d ratios", len(result.BlobBaseFee), len(result.BlobGasUsedRatio))
+	}
+}
+
+func testCreateAccessList(t *testing.T, client *Client) {
+	msg := ethereum.CallMsg{From: testAddr, To: &testContract, Gas: 100000, GasPrice: big.NewInt(params.GWei)}
+	result, err := client.CreateAccessList(context.Background(), msg, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	if err != nil {
+		t.Fatalf("failed to create access list: %v", err)
+	}
+	if result.Error != "" {
+		t.Fatalf("call failed: %v", result.Error)
+	}
+	list := *result.Accesslist
+	if len(list) != 1 || list[0].Address != testContract || len(list[0].StorageKeys) != 1 || list[0].StorageKeys[0] != (common.Hash{}) {
+		t.Fatalf("access list mismatch: %v", list)
+	}
+	if result.GasUsed == 0 {
+		t.Fatal("missing gas used")
+	}
+}
+
+func testBlockReceipts(t *testing.T, client *Client) {
+	var head *types.Header
+	if err := client.Client().CallContext(context.Background(), &head, "eth_getHeaderByNumber", rpc.LatestBlockNumber); err != nil {
+		t.Fatalf("failed to retrieve head: %v", err)
+	}
+	receipts, err := client.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(head.Hash(), false))
+	if err != nil {
+		t.Fatalf("failed to retrieve receipts: %v", err)
+	}
+	if len(receipts) != 0 {
+		t.Fatalf("receipt count mismatch, want 0, got %d", len(receipts))
+	}
+}
+
+func testNodeInfo(t *testing.T, client *Client) {
+	info, err := client.NodeInfo(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve node info: %v", err)
+	}
+	if info.ID == "" || info.Protocols["eth"] == nil {
+		t.Fatalf("incomplete node info: %+v", info)
+	}
+	peers, err := client.Peers(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve peers: %v", err)
+	}
+	if len(peers) != 0 {
+		t.Fatalf("peer count mismatch, want 0, got %d", len(peers))
+	}
+}
+
+func testTxPool(t *testing.T, client *Client, ethservice *eth.Ethereum) {
+	signer := types.LatestSigner(params.AllEthashProtocolChanges)
+	tx := types.MustSignNewTx(testKey, signer, &types.DynamicFeeTx{
+		ChainID:   params.AllEthashProtocolChanges.ChainID,
+		Nonce:     1,
+		To:        &testContract,
+		Gas:       params.TxGas,
+		GasFeeCap: big.NewInt(10 * params.GWei),
+		GasTipCap: big.NewInt(params.GWei),
+	})
+	if errs := ethservice.TxPool().Add([]*types.Transaction{tx}, true, true); errs[0] != nil {
+		t.Fatalf("failed to add transaction: %v", errs[0])
+	}
+	ctx := context.Background()
+
+	status, err := client.TxPoolStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve status: %v", err)
+	}
+	if status.Pending != 1 || status.Queued != 0 {
+		t.Fatalf("status mismatch: %d pending, %d queued", status.Pending, status.Queued)
+	}
+	content, err := client.TxPoolContent(ctx)
+	if err != nil {
+		t.Fatalf("failed to retrieve content: %v", err)
+	}
+	if have := content.Pending[testAddr][1]; have == nil || have.Hash != tx.Hash() {
+		t.Fatalf("pending transaction mismatch: %+v", content.Pending)
+	}
+	from, err := client.TxPoolContentFrom(ctx, testAddr)
+	if err != nil {
+		t.Fatalf("failed to retrieve content of sender: %v", err)
+	}
+	if have := from.Pending[1]; have == nil || have.Hash != tx.Hash() {
+		t.Fatalf("pending transaction of sender mismatch: %+v", from.Pending)
+	}
+	inspect, err := client.TxPoolInspect(ctx)
+	if err != nil {
+		t.Fatalf("failed to inspect pool: %v", err)
+	}
+	if inspect.Pending[testAddr][1] == "" {
+		t.Fatalf("missing pending summary: %+v", inspect.Pending)
+	}
+}
+
+func testTrace(t *testing.T, client *Client) {
+	ctx := context.Background()
+
+	var block struct {
+		Transactions []common.Hash `json:"transactions"`
+	}
+	if err := client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", rpc.BlockNumber(1), false); err != nil {
+		t.Fatalf("failed to retrieve block: %v", err)
+	}
+	hash := block.Transactions[0]
+
+	frame, err := client.CallTrace(ctx, hash, nil)
+	if err != nil {
+		t.Fatalf("failed to trace calls: %v", err)
+	}
+	if frame.Type != "CALL" || frame.From != testAddr || *frame.To != testContract || frame.Failed() {
+		t.Errorf("call frame mismatch: %+v", frame)
+	}
+	call, err := client.CallTraceCall(ctx, ethereum.CallMsg{From: testAddr, To: &testContract, Gas: 100000}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), &rpctypes.CallTracerConfig{OnlyTopCall: true})
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	if call.GasUsed == 0 || call.Failed() {
+		t.Errorf("call frame mismatch: %+v", call)
+	}
+	pre, err := client.PrestateTrace(ctx, hash)
+	if err != nil {
+		t.Fatalf("failed to trace prestate: %v", err)
+	}
+	if _, ok := pre[testContract].Storage[common.Hash{}]; !ok || pre[testContract].Nonce != 1 {
+		t.Errorf("prestate mismatch: %+v", pre[testContract])
+	}
+	diff, err := client.PrestateDiffTrace(ctx, hash)
+	if err != nil {
+		t.Fatalf("failed to trace state diff: %v", err)
+	}
+	if diff.Pre[testAddr] == nil || diff.Post[testAddr] == nil || diff.Post[testAddr].Nonce != 1 {
+		t.Errorf("state diff mismatch: %+v", diff)
+	}
+	fourbyte, err := client.FourByteTrace(ctx, hash)
+	if err != nil {
+		t.Fatalf("failed to trace selectors: %v", err)
+	}
+	calls, err := fourbyte.Calls()
+	if err != nil {
+		t.Fatalf("invalid selectors: %v", err)
+	}
+	if calls[rpctypes.FourByteCall{Selector: [4]byte{0x12, 0x34, 0x56, 0x78}}] != 1 {
+		t.Errorf("selector count mismatch: %v", calls)
+	}
+	flat, err := client.FlatCallTrace(ctx, hash)
+	if err != nil {
+		t.Fatalf("failed to trace flat calls: %v", err)
+	}
+	if len(flat) != 1 || flat[0].Type != "call" || *flat[0].TransactionHash != hash {
+		t.Errorf("flat call mismatch: %+v", flat)
+	}
+	transfers, err := client.TokenTransferTrace(ctx, hash)
+	if err != nil {
+		t.Fatalf("failed to trace transfers: %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].Standard != "eth" || transfers[0].To != testContract || transfers[0].Value.ToInt().Int64() != 1 {
+		t.Errorf("transfers mismatch: %+v", transfers)
+	}
+	blockTransfers, err := client.BlockTokenTransfers(ctx, 1)
+	if err != nil {
+		t.Fatalf("failed to trace block transfers: %v", err)
+	}
+	if len(blockTransfers) != 1 || blockTransfers[0].TxHash != hash || len(blockTransfers[0].Transfers) != 1 {
+		t.Errorf("block transfers mismatch: %+v", blockTransfers)
+	}
+	ch := make(chan *rpctypes.StreamedTrace)
+	sub, err := client.SubscribeTraceTransaction(ctx, hash, &rpctypes.StreamTraceConfig{BatchSize: 1}, ch)
+	if err != nil {
+		t.Fatalf("failed to subscribe to trace stream: %v", err)
+	}
+	defer sub.Unsubscribe()
+	var entries []json.RawMessage
+	for done := false; !done; {
+		select {
+		case batch := <-ch:
+			if len(batch.Entries) > 1 || batch.TxHash != hash || batch.Error != "" {
+				t.Fatalf("streamed batch mismatch: %+v", batch)
+			}
+			entries, done = append(entries, batch.Entries...), batch.Done
+		case err := <-sub.Err():
+			t.Fatalf("trace stream failed: %v", err)
+		}
+	}
+	// PUSH1, SLOAD and STOP, followed by the summary
+	if len(entries) != 4 || !strings.Contains(string(entries[3]), `"gasUsed"`) {
+		t.Errorf("streamed entries mismatch: %s", entries)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// CliqueSnapshot returns the clique snapshot at the given block.
+func (ec *Client) CliqueSnapshot(ctx context.Context, number rpc.BlockNumber) (*clique.Snapshot, error) {
+	var result *clique.Snapshot
+	err := ec.c.CallContext(ctx, &result, "clique_getSnapshot", number)
+	return result, err
+}
+
+// CliqueSnapshotAtHash returns the clique snapshot at the given block hash.
+func (ec *Client) CliqueSnapshotAtHash(ctx context.Context, hash common.Hash) (*clique.Snapshot, error) {
+	var result *clique.Snapshot
+	err := ec.c.CallContext(ctx, &result, "clique_getSnapshotAtHash", hash)
+	return result, err
+}
+
+// CliqueSigners returns the authorized signers at the given block.
+func (ec *Client) CliqueSigners(ctx context.Context, number rpc.BlockNumber) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "clique_getSigners", number)
+	return result, err
+}
+
+// CliqueSignersAtHash returns the authorized signers at the given block hash.
+func (ec *Client) CliqueSignersAtHash(ctx context.Context, hash common.Hash) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "clique_getSignersAtHash", hash)
+	return result, err
+}
+
+// CliqueProposals returns the pending proposals of the node, mapping the
+// proposed signers to whether they are to be authorized or dropped.
+func (ec *Client) CliqueProposals(ctx context.Context) (map[common.Address]bool, error) {
+	var result map[common.Address]bool
+	err := ec.c.CallContext(ctx, &result, "clique_proposals")
+	return result, err
+}
+
+// CliquePropose proposes authorizing or dropping a signer.
+func (ec *Client) CliquePropose(ctx context.Context, address common.Address, auth bool) error {
+	return ec.c.CallContext(ctx, nil, "clique_propose", address, auth)
+}
+
+// CliqueDiscard drops a pending proposal.
+func (ec *Client) CliqueDiscard(ctx context.Context, address common.Address) error {
+	return ec.c.CallContext(ctx, nil, "clique_discard", address)
+}
+
+// CliqueStatus returns the signing activity of the recent blocks.
+func (ec *Client) CliqueStatus(ctx context.Context) (*clique.Status, error) {
+	var result *clique.Status
+	err := ec.c.CallContext(ctx, &result, "clique_status")
+	return result, err
+}
+
+// CliqueSigner returns the signer of the given block.
+func (ec *Client) CliqueSigner(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (common.Address, error) {
+	var result common.Address
+	err := ec.c.CallContext(ctx, &result, "clique_getSigner", blockNrOrHash)
+	return result, err
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/beacon/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The engine API is served on the authenticated endpoint only, so the client of
+// the engine methods must be created with the JWT secret, e.g. via
+// rpc.WithHTTPAuth(node.NewJWTAuth(secret)).
+
+// ExchangeCapabilities returns the engine methods supported by the node.
+func (ec *Client) ExchangeCapabilities(ctx context.Context, capabilities []string) ([]string, error) {
+	var result []string
+	err := ec.c.CallContext(ctx, &result, "engine_exchangeCapabilities", capabilities)
+	return result, err
+}
+
+// GetClientVersionV1 exchanges the client versions of the consensus and the
+// execution client.
+func (ec *Client) GetClientVersionV1(ctx context.Context, info engine.ClientVersionV1) ([]engine.ClientVersionV1, error) {
+	var result []engine.ClientVersionV1
+	err := ec.c.CallContext(ctx, &result, "engine_getClientVersionV1", info)
+	return result, err
+}
+
+// ForkchoiceUpdatedV1 updates the fork choice of the node, optionally starting
+// to build a payload with the given attributes.
+func (ec *Client) ForkchoiceUpdatedV1(ctx context.Context, update engine.ForkchoiceStateV1, attributes *engine.PayloadAttributes) (engine.ForkChoiceResponse, error) {
+	return ec.forkchoiceUpdated(ctx, "engine_forkchoiceUpdatedV1", update, attributes)
+}
+
+// ForkchoiceUpdatedV2 is ForkchoiceUpdatedV1 with withdrawals, for Shanghai.
+func (ec *Client) ForkchoiceUpdatedV2(ctx context.Context, update engine.ForkchoiceStateV1, attributes *engine.PayloadAttributes) (engine.ForkChoiceResponse, error) {
+	return ec.forkchoiceUpdated(ctx, "engine_forkchoiceUpdatedV2", update, attributes)
+}
+
+// ForkchoiceUpdatedV3 is ForkchoiceUpdatedV2 with the beacon root, for Cancun.
+func (ec *Client) ForkchoiceUpdatedV3(ctx context.Context, update engine.ForkchoiceStateV1, attributes *engine.PayloadAttributes) (engine.ForkChoiceResponse, error) {
+	return ec.forkchoiceUpdated(ctx, "engine_forkchoiceUpdatedV3", update, attributes)
+}
+
+func (ec *Client) forkchoiceUpdated(ctx context.Context, method string, update engine.ForkchoiceStateV1, attributes *engine.PayloadAttributes) (engine.ForkChoiceResponse, error) {
+	var result engine.ForkChoiceResponse
+	err := ec.c.CallContext(ctx, &result, method, update, attributes)
+	return result, err
+}
+
+// NewPayloadV1 submits an execution payload to the node.
+func (ec *Client) NewPayloadV1(ctx context.Context, payload engine.ExecutableData) (engine.PayloadStatusV1, error) {
+	var result engine.PayloadStatusV1
+	err := ec.c.CallContext(ctx, &result, "engine_newPayloadV1", payload)
+	return result, err
+}
+
+// NewPayloadV2 submits an execution payload with withdrawals to the node.
+func (ec *Client) NewPayloadV2(ctx context.Context, payload engine.ExecutableData) (engine.PayloadStatusV1, error) {
+	var result engine.PayloadStatusV1
+	err := ec.c.CallContext(ctx, &result, "engine_newPayloadV2", payload)
+	return result, err
+}
+
+// NewPayloadV3 submits a Cancun execution payload to the node, along with the
+// versioned hashes of its blobs and the parent beacon block root.
+func (ec *Client) NewPayloadV3(ctx context.Context, payload engine.ExecutableData, versionedHashes []common.Hash, beaconRoot *common.Hash) (engine.PayloadStatusV1, error) {
+	var result engine.PayloadStatusV1
+	err := ec.c.CallContext(ctx, &result, "engine_newPayloadV3", payload, versionedHashes, beaconRoot)
+	return result, err
+}
+
+// GetPayloadV1 returns the payload built for the given id.
+func (ec *Client) GetPayloadV1(ctx context.Context, id engine.PayloadID) (*engine.ExecutableData, error) {
+	var result *engine.ExecutableData
+	err := ec.c.CallContext(ctx, &result, "engine_getPayloadV1", id)
+	return result, err
+}
+
+// GetPayloadV2 returns the payload built for the given id along with its value.
+func (ec *Client) GetPayloadV2(ctx context.Context, id engine.PayloadID) (*engine.ExecutionPayloadEnvelope, error) {
+	var result *engine.ExecutionPayloadEnvelope
+	err := ec.c.CallContext(ctx, &result, "engine_getPayloadV2", id)
+	return result, err
+}
+
+// GetPayloadV3 returns the payload built for the given id along with its value
+// and blobs bundle.
+func (ec *Client) GetPayloadV3(ctx context.Context, id engine.PayloadID) (*engine.ExecutionPayloadEnvelope, error) {
+	var result *engine.ExecutionPayloadEnvelope
+	err := ec.c.CallContext(ctx, &result, "engine_getPayloadV3", id)
+	return result, err
+}
+
+// GetPayloadBodiesByHashV1 returns the bodies of the given blocks, with nil for
+// the unknown ones.
+func (ec *Client) GetPayloadBodiesByHashV1(ctx context.Context, hashes []common.Hash) ([]*engine.ExecutionPayloadBodyV1, error) {
+	var result []*engine.ExecutionPayloadBodyV1
+	err := ec.c.CallContext(ctx, &result, "engine_getPayloadBodiesByHashV1", hashes)
+	return result, err
+}
+
+// GetPayloadBodiesByRangeV1 returns the bodies of the given range of blocks.
+func (ec *Client) GetPayloadBodiesByRangeV1(ctx context.Context, start, count uint64) ([]*engine.ExecutionPayloadBodyV1, error) {
+	var result []*engine.ExecutionPayloadBodyV1
+	err := ec.c.CallContext(ctx, &result, "engine_getPayloadBodiesByRangeV1", hexutil.Uint64(start), hexutil.Uint64(count))
+	return result, err
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// TraceTransaction replays a transaction with the given tracer and tracer
+// configuration, decoding the result of the tracer into result.
+func (ec *Client) TraceTransaction(ctx context.Context, hash common.Hash, tracer string, config interface{}, result interface{}) error {
+	return ec.c.CallContext(ctx, result, "debug_traceTransaction", hash, traceConfig(tracer, config))
+}
+
+// CallTrace replays a transaction with the callTracer.
+func (ec *Client) CallTrace(ctx context.Context, hash common.Hash, config *rpctypes.CallTracerConfig) (*rpctypes.CallFrame, error) {
+	var result rpctypes.CallFrame
+	if err := ec.TraceTransaction(ctx, hash, "callTracer", config, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CallTraceCall executes a call on top of the state of the given block with the
+// callTracer.
+func (ec *Client) CallTraceCall(ctx context.Context, msg ethereum.CallMsg, blockNrOrHash rpc.BlockNumberOrHash, config *rpctypes.CallTracerConfig) (*rpctypes.CallFrame, error) {
+	var result rpctypes.CallFrame
+	if err := ec.c.CallContext(ctx, &result, "debug_traceCall", toCallArg(msg), blockNrOrHash, traceConfig("callTracer", config)); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PrestateTrace replays a transaction with the prestateTracer, returning the
+// state accessed by it.
+func (ec *Client) PrestateTrace(ctx context.Context, hash common.Hash) (rpctypes.PrestateResult, error) {
+	var result rpctypes.PrestateResult
+	if err := ec.TraceTransaction(ctx, hash, "prestateTracer", nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PrestateDiffTrace replays a transaction with the prestateTracer in diff mode,
+// returning the state modified by it.
+func (ec *Client) PrestateDiffTrace(ctx context.Context, hash common.Hash) (*rpctypes.PrestateDiffResult, error) {
+	var result rpctypes.PrestateDiffResult
+	if err := ec.TraceTransaction(ctx, hash, "prestateTracer", &rpctypes.PrestateTracerConfig{DiffMode: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FourByteTrace replays a transaction with the 4byteTracer, counting the calls
+// made by it by method selector.
+func (ec *Client) FourByteTrace(ctx context.Context, hash common.Hash) (rpctypes.FourByteResult, error) {
+	var result rpctypes.FourByteResult
+	if err := ec.TraceTransaction(ctx, hash, "4byteTracer", nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FlatCallTrace replays a transaction with the flatCallTracer.
+func (ec *Client) FlatCallTrace(ctx context.Context, hash common.Hash) ([]rpctypes.FlatCallFrame, error) {
+	var result []rpctypes.FlatCallFrame
+	if err := ec.TraceTransaction(ctx, hash, "flatCallTracer", nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TokenTransferTrace replays a transaction with the tokenTransferTracer,
+// returning the ether and token transfers made by it.
+func (ec *Client) TokenTransferTrace(ctx context.Context, hash common.Hash) ([]rpctypes.TokenTransfer, error) {
+	var result []rpctypes.TokenTransfer
+	if err := ec.TraceTransaction(ctx, hash, "tokenTransferTracer", nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// BlockTokenTransfers replays the transactions of a block with the
+// tokenTransferTracer, returning the ether and token transfers made by each.
+func (ec *Client) BlockTokenTransfers(ctx context.Context, number rpc.BlockNumber) ([]rpctypes.TxTokenTransfers, error) {
+	var results []struct {
+		TxHash common.Hash              `json:"txHash"`
+		Result []rpctypes.TokenTransfer `json:"result"`
+		Error  string                   `json:"error"`
+	}
+	if err := ec.c.CallContext(ctx, &results, "debug_traceBlockByNumber", number, traceConfig("tokenTransferTracer", nil)); err != nil {
+		return nil, err
+	}
+	transfers := make([]rpctypes.TxTokenTransfers, len(results))
+	for i, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("failed to trace transaction %x: %s", result.TxHash, result.Error)
+		}
+		transfers[i] = rpctypes.TxTokenTransfers{TxHash: result.TxHash, Transfers: result.Result}
+	}
+	return transfers, nil
+}
+
+// SubscribeTraceTransaction streams the standard trace of a transaction in
+// batches of entries, followed by a batch marked as the end of the stream.
+// Tracing on the node is paused while the subscriber lags behind, and aborted
+// when it unsubscribes or doesn't catch up in time.
+func (ec *Client) SubscribeTraceTransaction(ctx context.Context, hash common.Hash, config *rpctypes.StreamTraceConfig, ch chan<- *rpctypes.StreamedTrace) (*rpc.ClientSubscription, error) {
+	return ec.c.Subscribe(ctx, "debug", ch, "traceTransaction", hash, config)
+}
+
+// SubscribeTraceBlock streams the standard traces of the transactions of a
+// block, or only of the configured one, in batches of entries.
+func (ec *Client) SubscribeTraceBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *rpctypes.StreamTraceConfig, ch chan<- *rpctypes.StreamedTrace) (*rpc.ClientSubscription, error) {
+	return ec.c.Subscribe(ctx, "debug", ch, "traceBlock", blockNrOrHash, config)
+}
+
+func traceConfig(tracer string, config interface{}) interface{} {
+	arg := map[string]interface{}{
+		"tracer": tracer,
+	}
+	if config != nil {
+		blob, _ := json.Marshal(config)
+		arg["tracerConfig"] = json.RawMessage(blob)
+	}
+	return arg
+}
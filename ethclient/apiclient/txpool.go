@@ -0,0 +1,61 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpctypes"
+)
+
+// TxPoolContent returns the transactions in the pool, grouped by sender and nonce.
+func (ec *Client) TxPoolContent(ctx context.Context) (*rpctypes.TxPoolContent, error) {
+	var result rpctypes.TxPoolContent
+	if err := ec.c.CallContext(ctx, &result, "txpool_content"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TxPoolContentFrom returns the transactions of the given sender in the pool,
+// grouped by nonce.
+func (ec *Client) TxPoolContentFrom(ctx context.Context, account common.Address) (*rpctypes.TxPoolContentFrom, error) {
+	var result rpctypes.TxPoolContentFrom
+	if err := ec.c.CallContext(ctx, &result, "txpool_contentFrom", account); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TxPoolStatus returns the number of pending and queued transactions in the pool.
+func (ec *Client) TxPoolStatus(ctx context.Context) (*rpctypes.TxPoolStatus, error) {
+	var result rpctypes.TxPoolStatus
+	if err := ec.c.CallContext(ctx, &result, "txpool_status"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TxPoolInspect returns textual summaries of the transactions in the pool.
+func (ec *Client) TxPoolInspect(ctx context.Context) (*rpctypes.TxPoolInspect, error) {
+	var result rpctypes.TxPoolInspect
+	if err := ec.c.CallContext(ctx, &result, "txpool_inspect"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
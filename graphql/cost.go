@@ -0,0 +1,378 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/scanner"
+	_ "unsafe" // for go:linkname
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gqlErrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/types"
+)
+
+// The cost of a query estimates the work needed to execute it. Every object in
+// the result costs one unit, on top of which each field has a weight for work
+// done once per resolution, like executing calls or filtering logs. The objects
+// of list fields are counted as many times as the list is estimated to be long:
+//
+//	cost(field) = weight(field) + size(field) * (1 + cost(selections))
+//
+// Scalar fields have a size of zero, leaving just their weight.
+
+// defaultFieldCosts are the weights of fields doing more work than resolving a
+// single object. They can be overridden by configuration.
+var defaultFieldCosts = map[string]uint64{
+	"Query.logs":                 100,
+	"Query.logsConnection":       100,
+	"Block.logs":                 10,
+	"Block.call":                 100,
+	"Block.estimateGas":          100,
+	"Pending.call":               100,
+	"Pending.estimateGas":        100,
+	"Transaction.calls":          100,
+	"Transaction.stateDiff":      100,
+	"Block.traces":               1000,
+	"TransactionTrace.stateDiff": 5,
+}
+
+// listSizes are the estimated lengths of list fields which aren't limited by
+// their arguments.
+var listSizes = map[string]uint64{
+	"Block.ommers":         2,
+	"Block.transactions":   200,
+	"Block.withdrawals":    16,
+	"Block.logs":           500,
+	"Transaction.logs":     10,
+	"Pending.transactions": 1000,
+	"Query.logs":           1000,
+	"Block.traces":         200,
+}
+
+// defaultListSize is the estimated length of list fields not in listSizes.
+const defaultListSize = 10
+
+// maxCostWork is the number of selections the analysis may visit before giving
+// up on a query. Expanding memoized fragments counts as one selection.
+const maxCostWork = 100_000
+
+var errQueryComplex = errors.New("query too complex for cost analysis")
+
+// graphql-go keeps its query parser and validator internal, they're linked in so
+// that the cost is computed on the same document that is validated. Their
+// signatures must match the version of graphql-go in go.mod.
+
+//go:linkname parseQuery github.com/graph-gophers/graphql-go/internal/query.Parse
+func parseQuery(queryString string) (*types.ExecutableDefinition, *gqlErrors.QueryError)
+
+//go:linkname validateQuery github.com/graph-gophers/graphql-go/internal/validation.Validate
+func validateQuery(s *types.Schema, doc *types.ExecutableDefinition, variables map[string]interface{}, maxDepth int) []*gqlErrors.QueryError
+
+// costAnalyzer computes the cost of queries against a schema.
+type costAnalyzer struct {
+	schema  *types.Schema
+	weights map[string]uint64
+	head    func() uint64 // current head block number, for open block ranges
+}
+
+// newCostAnalyzer creates a cost analyzer, overriding the default field weights
+// by the given ones.
+func newCostAnalyzer(schema *types.Schema, weights map[string]uint64, head func() uint64) (*costAnalyzer, error) {
+	a := &costAnalyzer{schema: schema, weights: make(map[string]uint64), head: head}
+	for field, weight := range defaultFieldCosts {
+		a.weights[field] = weight
+	}
+	for field, weight := range weights {
+		typ, name, ok := strings.Cut(field, ".")
+		if !ok || a.field(schema.Types[typ], name) == nil {
+			return nil, fmt.Errorf("unknown field %q in GraphQL field costs", field)
+		}
+		a.weights[field] = weight
+	}
+	return a, nil
+}
+
+// cost returns the cost of executing an operation of a validated query, along
+// with the kind of the operation (query, mutation or subscription).
+func (a *costAnalyzer) cost(doc *types.ExecutableDefinition, operationName string, variables map[string]interface{}) (uint64, string, error) {
+	var op *types.OperationDefinition
+	switch {
+	case operationName != "":
+		op = doc.Operations.Get(operationName)
+	case len(doc.Operations) == 1:
+		op = doc.Operations[0]
+	}
+	if op == nil {
+		return 0, "", fmt.Errorf("operation %q not found", operationName)
+	}
+	kind := strings.ToLower(string(op.Type))
+	root := a.schema.EntryPoints[kind]
+	if root == nil {
+		return 0, "", fmt.Errorf("operation type %q not supported", kind)
+	}
+	c := &costContext{
+		analyzer:  a,
+		fragments: doc.Fragments,
+		variables: make(map[string]interface{}),
+		visiting:  make(map[string]bool),
+		memo:      make(map[fragmentCostKey]uint64),
+	}
+	for _, v := range op.Vars {
+		if v.Default != nil {
+			c.variables[v.Name.Name] = c.literal(v.Default)
+		}
+	}
+	for name, value := range variables {
+		c.variables[name] = value
+	}
+	cost := c.selections(op.Selections, root, 0)
+	if c.work > maxCostWork {
+		return 0, "", errQueryComplex
+	}
+	return cost, kind, nil
+}
+
+// field returns the definition of a field of an object or interface type.
+func (a *costAnalyzer) field(typ types.NamedType, name string) *types.FieldDefinition {
+	switch t := typ.(type) {
+	case *types.ObjectTypeDefinition:
+		return t.Fields.Get(name)
+	case *types.InterfaceTypeDefinition:
+		return t.Fields.Get(name)
+	}
+	return nil
+}
+
+// costContext is the state of computing the cost of an operation.
+type costContext struct {
+	analyzer  *costAnalyzer
+	fragments types.FragmentList
+	variables map[string]interface{}
+	visiting  map[string]bool // fragments being expanded, to break cycles
+	memo      map[fragmentCostKey]uint64
+	work      int // selections visited so far
+}
+
+// fragmentCostKey identifies the cost of a fragment spread, which only depends
+// on the type it is spread on and the page size of the enclosing connection.
+type fragmentCostKey struct {
+	name     string
+	typ      string
+	pageSize uint64
+}
+
+// selections returns the cost of a selection set on an object of the given type.
+// The page size is the number of edges of the connection the object is.
+func (c *costContext) selections(sels types.SelectionSet, typ types.NamedType, pageSize uint64) uint64 {
+	var cost uint64
+	for _, sel := range sels {
+		if c.work++; c.work > maxCostWork {
+			return cost
+		}
+		switch sel := sel.(type) {
+		case *types.FragmentSpread:
+			cost = saturatingAdd(cost, c.spread(sel.Name.Name, typ, pageSize))
+
+		case *types.InlineFragment:
+			cost = saturatingAdd(cost, c.selections(sel.Selections, c.typeOf(sel.On.Name, typ), pageSize))
+
+		case *types.Field:
+			// Introspection is served from memory
+			if !strings.HasPrefix(sel.Name.Name, "__") {
+				cost = saturatingAdd(cost, c.field(sel, typ, pageSize))
+			}
+		}
+	}
+	return cost
+}
+
+// spread returns the cost of a fragment spread on an object of the given type.
+// Fragments are only expanded once per type and page size, so that fragments
+// spreading other fragments several times can't blow up the analysis.
+func (c *costContext) spread(name string, typ types.NamedType, pageSize uint64) uint64 {
+	frag := c.fragments.Get(name)
+	if frag == nil || c.visiting[name] {
+		return 0
+	}
+	key := fragmentCostKey{name: name, typ: typ.TypeName(), pageSize: pageSize}
+	if cost, ok := c.memo[key]; ok {
+		return cost
+	}
+	c.visiting[name] = true
+	cost := c.selections(frag.Selections, c.typeOf(frag.On.Name, typ), pageSize)
+	delete(c.visiting, name)
+
+	c.memo[key] = cost
+	return cost
+}
+
+// field returns the cost of a field of an object of the given type.
+func (c *costContext) field(sel *types.Field, parent types.NamedType, pageSize uint64) uint64 {
+	def := c.analyzer.field(parent, sel.Name.Name)
+	if def == nil {
+		return 0 // rejected by validation
+	}
+	var (
+		key    = parent.TypeName() + "." + sel.Name.Name
+		weight = c.analyzer.weights[key]
+		list   bool
+		typ    = def.Type
+	)
+	for {
+		if t, ok := typ.(*types.NonNull); ok {
+			typ = t.OfType
+		} else if t, ok := typ.(*types.List); ok {
+			list, typ = true, t.OfType
+		} else {
+			break
+		}
+	}
+	named, ok := typ.(types.NamedType)
+	if !ok || sel.SelectionSet == nil {
+		return weight // scalar
+	}
+	// Connections estimate the number of their edges from their arguments
+	var (
+		size     = uint64(1)
+		children = pageSize
+	)
+	if def.Arguments.Get("first") != nil {
+		children = c.pageSize(sel)
+	}
+	switch {
+	case key == "Query.blocks":
+		size = c.blockRange(sel)
+	case key == "Query.blocksConnection":
+		children = min(children, c.blockRange(sel))
+	case list && strings.HasSuffix(named.TypeName(), "Edge"):
+		size = pageSize
+	case list:
+		size = defaultListSize
+		if n, ok := listSizes[key]; ok {
+			size = n
+		}
+	}
+	cost := saturatingAdd(1, c.selections(sel.SelectionSet, named, children))
+	return saturatingAdd(weight, saturatingMul(size, cost))
+}
+
+// typeOf resolves the type condition of a fragment.
+func (c *costContext) typeOf(cond string, typ types.NamedType) types.NamedType {
+	if cond == "" {
+		return typ
+	}
+	if t := c.analyzer.schema.Types[cond]; t != nil {
+		return t
+	}
+	return typ
+}
+
+// pageSize returns the number of edges requested from a connection field.
+func (c *costContext) pageSize(sel *types.Field) uint64 {
+	for _, arg := range []string{"first", "last"} {
+		if n, ok := c.number(c.argument(sel, arg)); ok {
+			return uint64(max(0, n))
+		}
+	}
+	return defaultPageSize
+}
+
+// blockRange returns the number of blocks in the range of a field taking
+// from and to block numbers.
+func (c *costContext) blockRange(sel *types.Field) uint64 {
+	from, ok := c.number(c.argument(sel, "from"))
+	if !ok {
+		from = 0
+	}
+	to, ok := c.number(c.argument(sel, "to"))
+	if !ok {
+		to = int64(c.analyzer.head())
+	}
+	if to < from {
+		return 0
+	}
+	return uint64(to-from) + 1
+}
+
+// argument returns the value of an argument of a field, resolving variables.
+func (c *costContext) argument(sel *types.Field, name string) interface{} {
+	value, ok := sel.Arguments.Get(name)
+	if !ok {
+		return nil
+	}
+	if v, ok := value.(*types.Variable); ok {
+		return c.variables[v.Name]
+	}
+	return c.literal(value)
+}
+
+// literal converts the scalar literals needed for computing costs. Unlike
+// their Deserialize method, integers aren't limited to 32 bits.
+func (c *costContext) literal(value types.Value) interface{} {
+	v, ok := value.(*types.PrimitiveValue)
+	if !ok {
+		return nil
+	}
+	switch v.Type {
+	case scanner.Int:
+		if n, err := strconv.ParseInt(v.Text, 10, 64); err == nil {
+			return n
+		}
+	case scanner.String:
+		if s, err := strconv.Unquote(v.Text); err == nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// number resolves an argument value to an integer.
+func (c *costContext) number(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, true
+		}
+		if n, err := hexutil.DecodeUint64(v); err == nil && n <= math.MaxInt64 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+func saturatingAdd(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+func saturatingMul(a, b uint64) uint64 {
+	if a != 0 && b > math.MaxUint64/a {
+		return math.MaxUint64
+	}
+	return a * b
+}
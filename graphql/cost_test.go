@@ -0,0 +1,237 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace"
+)
+
+func TestQueryCost(t *testing.T) {
+	s, err := graphql.ParseSchema(schema, &Resolver{})
+	if err != nil {
+		t.Fatalf("could not parse schema: %v", err)
+	}
+	head := func() uint64 { return 100 }
+
+	for i, tt := range []struct {
+		query     string
+		variables map[string]interface{}
+		weights   map[string]uint64
+		want      uint64
+	}{
+		{query: `{ block { number } }`, want: 1},
+		{query: `{ block { number hash parent { number } } }`, want: 2},
+		{query: `{ blocks(from: 1, to: 10) { number } }`, want: 10},
+		{query: `{ blocks(from: "0xa", to: "0x13") { number } }`, want: 10},
+		{query: `{ blocks(from: 91) { number } }`, want: 10},
+		{query: `{ blocks(from: 4294967296, to: 4294967305) { number } }`, want: 10},
+		{query: `query($to: Long = 5) { blocks(from: 1, to: $to) { hash } }`, want: 5},
+		{query: `query($to: Long = 5) { blocks(from: 1, to: $to) { hash } }`, variables: map[string]interface{}{"to": float64(10)}, want: 10},
+		{query: `{ block { transactions { hash } } }`, want: 201},
+		{query: `{ block { transactions { hash } } }`, weights: map[string]uint64{"Block.transactions": 5}, want: 206},
+		{query: `{ block { ...txs } } fragment txs on Block { transactions { hash } }`, want: 201},
+		{query: `{ block { ... on Block { transactions { hash } } } }`, want: 201},
+		{query: `{ logs(filter: {}) { data } }`, want: 1100},
+		{query: `{ blocksConnection(first: 5) { edges { cursor node { number } } pageInfo { hasNextPage } } }`, want: 12},
+		{query: `{ blocksConnection(from: 99, last: 5) { edges { node { number } } } }`, want: 5},
+		{query: `{ blocksConnection { edges { node { transactionsConnection(first: 2) { edges { node { hash } } } } } } }`, want: 1 + 100*(1+1+1+2*2)},
+		{query: `query A { block { number } } query B { blocks(from: 1, to: 10) { number } }`, want: 10},
+		{query: `{ __schema { types { name } } }`, want: 0},
+		{query: "# comment\n{ block(number: 1) { a: number b: hash } }", want: 1},
+	} {
+		a, err := newCostAnalyzer(s.ASTSchema(), tt.weights, head)
+		if err != nil {
+			t.Fatalf("test %d: could not create analyzer: %v", i, err)
+		}
+		op := ""
+		if strings.Contains(tt.query, "query B") {
+			op = "B"
+		}
+		have, err := queryCost(a, tt.query, op, tt.variables)
+		if err != nil {
+			t.Errorf("test %d: failed to compute cost: %v", i, err)
+			continue
+		}
+		if have != tt.want {
+			t.Errorf("test %d: wrong cost for %s: have %d, want %d", i, tt.query, have, tt.want)
+		}
+	}
+	if _, err := newCostAnalyzer(s.ASTSchema(), map[string]uint64{"Block.bleh": 1}, head); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
+
+func TestQueryLimits(t *testing.T) {
+	stack := createNode(t)
+	defer stack.Close()
+
+	h, err := newHandler(stack, nil, nil, &Config{MaxDepth: 2, MaxCost: 50})
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+	for i, tt := range []struct {
+		body string
+		want string
+	}{
+		{
+			body: `{"query": "{ blocks(from: 1, to: 100) { number } }"}`,
+			want: `{"errors":[{"message":"query cost 100 exceeds the maximum of 50"}]}`,
+		},
+		{
+			body: `{"query": "{ block { transactions { hash } } }"}`,
+			want: `{"errors":[{"message":"Field \"hash\" has depth 3 that exceeds max depth 2","locations":[{"line":1,"column":26}]}]}`,
+		},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tt.body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("test %d: wrong status code: have %d, want %d", i, w.Code, http.StatusBadRequest)
+		}
+		body, _ := io.ReadAll(w.Body)
+		if string(body) != tt.want {
+			t.Errorf("test %d: wrong response\nhave: %s\nwant: %s", i, body, tt.want)
+		}
+	}
+}
+
+// Tests that fragments spread many times are analysed without expanding them
+// at every use, and that queries beyond the work budget are rejected.
+func TestQueryCostFragments(t *testing.T) {
+	s, err := graphql.ParseSchema(schema, &Resolver{})
+	if err != nil {
+		t.Fatalf("could not parse schema: %v", err)
+	}
+	a, err := newCostAnalyzer(s.ASTSchema(), nil, func() uint64 { return 100 })
+	if err != nil {
+		t.Fatalf("could not create analyzer: %v", err)
+	}
+	const depth = 40
+	query := "{ block { ...F0 } }"
+	for i := 0; i < depth; i++ {
+		query += fmt.Sprintf(" fragment F%d on Block { ...F%d ...F%d }", i, i+1, i+1)
+	}
+	query += fmt.Sprintf(" fragment F%d on Block { transactions { hash } }", depth)
+
+	start := time.Now()
+	have, err := queryCost(a, query, "", nil)
+	if err != nil {
+		t.Fatalf("failed to compute cost: %v", err)
+	}
+	if want := uint64(1 + 200<<depth); have != want {
+		t.Errorf("wrong cost: have %d, want %d", have, want)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cost analysis took %v", elapsed)
+	}
+
+	wide := "{ block {" + strings.Repeat(" number", maxCostWork) + " } }"
+	if _, err := queryCost(a, wide, "", nil); err != errQueryComplex {
+		t.Errorf("wrong error for query beyond the work budget: %v", err)
+	}
+}
+
+// queryCost parses a query and computes the cost of one of its operations.
+func queryCost(a *costAnalyzer, query string, operationName string, variables map[string]interface{}) (uint64, error) {
+	doc, qErr := parseQuery(query)
+	if qErr != nil {
+		return 0, qErr
+	}
+	cost, _, err := a.cost(doc, operationName, variables)
+	return cost, err
+}
+
+// resolverCounter is a graphql-go tracer counting the resolved fields. It also
+// silences the panics of resolvers running without backend.
+type resolverCounter struct {
+	fields atomic.Int64
+}
+
+func (c *resolverCounter) LogPanic(ctx context.Context, value interface{}) {}
+
+func (c *resolverCounter) TraceQuery(ctx context.Context, queryString string, operationName string, variables map[string]interface{}, varTypes map[string]*introspection.Type) (context.Context, trace.TraceQueryFinishFunc) {
+	return ctx, func([]*errors.QueryError) {}
+}
+
+func (c *resolverCounter) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, trace.TraceFieldFinishFunc) {
+	c.fields.Add(1)
+	return ctx, func(*errors.QueryError) {}
+}
+
+// Tests that queries exceeding the default limits are rejected before any of
+// their fields is resolved.
+func TestQueryRejectedBeforeExecution(t *testing.T) {
+	counter := new(resolverCounter)
+	s, err := graphql.ParseSchema(schema, &Resolver{}, graphql.MaxDepth(node.DefaultConfig.GraphQLMaxDepth), graphql.Tracer(counter), graphql.Logger(counter))
+	if err != nil {
+		t.Fatalf("could not parse schema: %v", err)
+	}
+	cost, err := newCostAnalyzer(s.ASTSchema(), nil, func() uint64 { return 100 })
+	if err != nil {
+		t.Fatalf("could not create analyzer: %v", err)
+	}
+	h := &handler{Schema: s, cost: cost, maxDepth: node.DefaultConfig.GraphQLMaxDepth, maxCost: node.DefaultConfig.GraphQLMaxCost}
+
+	deep := "{ block { number } }"
+	for i := 0; i < node.DefaultConfig.GraphQLMaxDepth; i++ {
+		deep = strings.Replace(deep, "number", "parent { number }", 1)
+	}
+	wide := "{"
+	for i := 0; i < 100; i++ {
+		wide += fmt.Sprintf(" l%d: logs(filter: {}) { data }", i)
+	}
+	wide += " }"
+
+	for i, query := range []string{
+		deep,
+		wide,
+		`{ blocks(from: 0, to: 1000000) { number } }`,
+	} {
+		if _, errs := h.check(query, "", nil); len(errs) == 0 {
+			t.Errorf("test %d: query accepted: %s", i, query)
+		}
+		body := `{"query": ` + strconv.Quote(query) + `}`
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("test %d: wrong status code: have %d, want %d", i, w.Code, http.StatusBadRequest)
+		}
+	}
+	if n := counter.fields.Load(); n != 0 {
+		t.Fatalf("rejected queries resolved %d fields", n)
+	}
+	// Make sure accepted queries are counted
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ chainID }"}`)))
+	if counter.fields.Load() == 0 {
+		t.Fatalf("accepted query resolved no fields")
+	}
+}
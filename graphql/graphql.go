@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -166,6 +167,10 @@ func (l *Log) Topics(ctx context.Context) []common.Hash {
 	return l.log.Topics
 }
 
+func (l *Log) Removed(ctx context.Context) bool {
+	return l.log.Removed
+}
+
 func (l *Log) Data(ctx context.Context) hexutil.Bytes {
 	return l.log.Data
 }
@@ -655,6 +660,9 @@ type Block struct {
 	header   *types.Header
 	block    *types.Block
 	receipts []*types.Receipt
+
+	traceMu sync.Mutex
+	traces  map[string]*blockTrace // Traces of all transactions by tracer
 }
 
 // resolve returns the internal Block object representing this block, fetching
@@ -1213,7 +1221,7 @@ func (b *Block) Call(ctx context.Context, args struct {
 func (b *Block) EstimateGas(ctx context.Context, args struct {
 	Data ethapi.TransactionArgs
 }) (hexutil.Uint64, error) {
-	return ethapi.DoEstimateGas(ctx, b.r.backend, args.Data, *b.numberOrHash, nil, b.r.backend.RPCGasCap())
+	return ethapi.DoEstimateGas(ctx, b.r.backend, args.Data, *b.numberOrHash, nil, nil, b.r.backend.RPCGasCap())
 }
 
 type Pending struct {
@@ -1277,13 +1285,15 @@ func (p *Pending) EstimateGas(ctx context.Context, args struct {
 	Data ethapi.TransactionArgs
 }) (hexutil.Uint64, error) {
 	latestBlockNr := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
-	return ethapi.DoEstimateGas(ctx, p.r.backend, args.Data, latestBlockNr, nil, p.r.backend.RPCGasCap())
+	return ethapi.DoEstimateGas(ctx, p.r.backend, args.Data, latestBlockNr, nil, nil, p.r.backend.RPCGasCap())
 }
 
 // Resolver is the top-level object in the GraphQL hierarchy.
 type Resolver struct {
 	backend      ethapi.Backend
 	filterSystem *filters.FilterSystem
+	events       *filters.EventSystem // Event system serving subscriptions, nil if unavailable
+	tracers      *tracers.API         // Tracing API, nil if unsupported by the backend
 }
 
 func (r *Resolver) Block(ctx context.Context, args struct {
@@ -1411,29 +1421,37 @@ type FilterCriteria struct {
 }
 
 func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+	filter, err := r.newRangeFilter(args.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return runFilter(ctx, r, filter)
+}
+
+// newRangeFilter creates the filter searching the logs matching the criteria.
+func (r *Resolver) newRangeFilter(crit FilterCriteria) (*filters.Filter, error) {
 	// Convert the RPC block numbers into internal representations
 	begin := rpc.LatestBlockNumber.Int64()
-	if args.Filter.FromBlock != nil {
-		begin = int64(*args.Filter.FromBlock)
+	if crit.FromBlock != nil {
+		begin = int64(*crit.FromBlock)
 	}
 	end := rpc.LatestBlockNumber.Int64()
-	if args.Filter.ToBlock != nil {
-		end = int64(*args.Filter.ToBlock)
+	if crit.ToBlock != nil {
+		end = int64(*crit.ToBlock)
 	}
 	if begin > 0 && end > 0 && begin > end {
 		return nil, errInvalidBlockRange
 	}
 	var addresses []common.Address
-	if args.Filter.Addresses != nil {
-		addresses = *args.Filter.Addresses
+	if crit.Addresses != nil {
+		addresses = *crit.Addresses
 	}
 	var topics [][]common.Hash
-	if args.Filter.Topics != nil {
-		topics = *args.Filter.Topics
+	if crit.Topics != nil {
+		topics = *crit.Topics
 	}
 	// Construct the range filter
-	filter := r.filterSystem.NewRangeFilter(begin, end, addresses, topics)
-	return runFilter(ctx, r, filter)
+	return r.filterSystem.NewRangeFilter(begin, end, addresses, topics), nil
 }
 
 func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
@@ -42,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,7 +58,7 @@ func TestBuildSchema(t *testing.T) {
 	}
 	defer stack.Close()
 	// Make sure the schema can be parsed and matched up to the object model.
-	if _, err := newHandler(stack, nil, nil, []string{}, []string{}); err != nil {
+	if _, err := newHandler(stack, nil, nil, &Config{}); err != nil {
 		t.Errorf("Could not construct GraphQL handler: %v", err)
 	}
 }
@@ -446,6 +448,13 @@ func createNode(t *testing.T) *node.Node {
 }
 
 func newGQLService(t *testing.T, stack *node.Node, shanghai bool, gspec *core.Genesis, genBlocks int, genfunc func(i int, gen *core.BlockGen)) (*handler, []*types.Block) {
+	handler, _, chain := newGQLBackend(t, stack, shanghai, gspec, genBlocks, genfunc)
+	return handler, chain
+}
+
+// newGQLBackend is like newGQLService, but additionally returns the backing
+// Ethereum service.
+func newGQLBackend(t *testing.T, stack *node.Node, shanghai bool, gspec *core.Genesis, genBlocks int, genfunc func(i int, gen *core.BlockGen)) (*handler, *eth.Ethereum, []*types.Block) {
 	ethConf := &ethconfig.Config{
 		Genesis:        gspec,
 		NetworkId:      1337,
@@ -458,20 +467,21 @@ func newGQLService(t *testing.T, stack *node.Node, shanghai bool, gspec *core.Ge
 	var engine consensus.Engine = ethash.NewFaker()
 	if shanghai {
 		engine = beacon.NewFaker()
-		chainCfg := gspec.Config
+		chainCfg := *gspec.Config
 		chainCfg.TerminalTotalDifficultyPassed = true
 		chainCfg.TerminalTotalDifficulty = common.Big0
 		// GenerateChain will increment timestamps by 10.
 		// Shanghai upgrade at block 1.
 		shanghaiTime := uint64(5)
 		chainCfg.ShanghaiTime = &shanghaiTime
+		gspec.Config = &chainCfg
 	}
 	ethBackend, err := eth.New(stack, ethConf)
 	if err != nil {
 		t.Fatalf("could not create eth backend: %v", err)
 	}
 	// Create some blocks and import them
-	chain, _ := core.GenerateChain(params.AllEthashProtocolChanges, ethBackend.BlockChain().Genesis(),
+	chain, _ := core.GenerateChain(gspec.Config, ethBackend.BlockChain().Genesis(),
 		engine, ethBackend.ChainDb(), genBlocks, genfunc)
 	_, err = ethBackend.BlockChain().InsertChain(chain)
 	if err != nil {
@@ -479,9 +489,412 @@ func newGQLService(t *testing.T, stack *node.Node, shanghai bool, gspec *core.Ge
 	}
 	// Set up handler
 	filterSystem := filters.NewFilterSystem(ethBackend.APIBackend, filters.Config{})
-	handler, err := newHandler(stack, ethBackend.APIBackend, filterSystem, []string{}, []string{})
+	handler, err := newHandler(stack, ethBackend.APIBackend, filterSystem, &Config{})
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
-	return handler, chain
+	return handler, ethBackend, chain
+}
+
+func TestGraphQLConnections(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		dad     = common.HexToAddress("0x0000000000000000000000000000000000000dad")
+		genesis = &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+			Alloc: types.GenesisAlloc{
+				addr: {Balance: big.NewInt(params.Ether)},
+				dad: {
+					// LOG0(0, 0), LOG0(0, 0), RETURN(0, 0)
+					Code:    common.Hex2Bytes("60006000a060006000a060006000f3"),
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		stack  = createNode(t)
+	)
+	defer stack.Close()
+
+	// Three blocks with two transactions emitting two logs each
+	handler, _ := newGQLService(t, stack, false, genesis, 3, func(i int, gen *core.BlockGen) {
+		for j := 0; j < 2; j++ {
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{To: &dad, Nonce: gen.TxNonce(addr), Gas: 100000, GasPrice: big.NewInt(params.InitialBaseFee)})
+			gen.AddTx(tx)
+		}
+	})
+	exec := func(query string) map[string]interface{} {
+		t.Helper()
+		res := handler.Schema.Exec(context.Background(), query, "", nil)
+		if res.Errors != nil {
+			t.Fatalf("failed to execute query %s: %v", query, res.Errors)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(res.Data, &data); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return data
+	}
+	page := func(data map[string]interface{}, path ...string) ([]interface{}, map[string]interface{}) {
+		for _, field := range path {
+			data = data[field].(map[string]interface{})
+		}
+		var nodes []interface{}
+		for _, edge := range data["edges"].([]interface{}) {
+			nodes = append(nodes, edge.(map[string]interface{})["node"])
+		}
+		return nodes, data["pageInfo"].(map[string]interface{})
+	}
+	const info = "pageInfo { hasNextPage hasPreviousPage startCursor endCursor }"
+
+	// Page forward and backward through the blocks
+	nodes, pi := page(exec(`{ blocksConnection(first: 2) { edges { node { number } } `+info+` } }`), "blocksConnection")
+	assert.Equal(t, `[{"number":"0x0"},{"number":"0x1"}]`, mustJSON(t, nodes))
+	assert.Equal(t, true, pi["hasNextPage"])
+	assert.Equal(t, false, pi["hasPreviousPage"])
+
+	nodes, pi = page(exec(fmt.Sprintf(`{ blocksConnection(first: 2, after: "%s") { edges { node { number } } `+info+` } }`, pi["endCursor"])), "blocksConnection")
+	assert.Equal(t, `[{"number":"0x2"},{"number":"0x3"}]`, mustJSON(t, nodes))
+	assert.Equal(t, false, pi["hasNextPage"])
+	assert.Equal(t, true, pi["hasPreviousPage"])
+
+	nodes, pi = page(exec(fmt.Sprintf(`{ blocksConnection(last: 1, before: "%s") { edges { node { number } } `+info+` } }`, pi["startCursor"])), "blocksConnection")
+	assert.Equal(t, `[{"number":"0x1"}]`, mustJSON(t, nodes))
+	assert.Equal(t, true, pi["hasNextPage"])
+	assert.Equal(t, true, pi["hasPreviousPage"])
+
+	nodes, _ = page(exec(`{ blocksConnection(from: 1, to: 2) { edges { node { number } } `+info+` } }`), "blocksConnection")
+	assert.Equal(t, `[{"number":"0x1"},{"number":"0x2"}]`, mustJSON(t, nodes))
+
+	// Page through the transactions of a block
+	nodes, pi = page(exec(`{ block(number: 1) { transactionsConnection(last: 1) { edges { node { index } } `+info+` } } }`), "block", "transactionsConnection")
+	assert.Equal(t, `[{"index":"0x1"}]`, mustJSON(t, nodes))
+	assert.Equal(t, false, pi["hasNextPage"])
+	assert.Equal(t, true, pi["hasPreviousPage"])
+
+	// Page through all logs, checking that none are skipped or repeated
+	var (
+		logs    []interface{}
+		after   string
+		pages   int
+		hasNext = true
+	)
+	for hasNext {
+		args := "filter: { fromBlock: 0 }, first: 5"
+		if after != "" {
+			args += fmt.Sprintf(`, after: "%s"`, after)
+		}
+		nodes, pi = page(exec(`{ logsConnection(`+args+`) { edges { node { index transaction { block { number } } } } `+info+` } }`), "logsConnection")
+		logs = append(logs, nodes...)
+		hasNext = pi["hasNextPage"].(bool)
+		if hasNext {
+			after = pi["endCursor"].(string)
+		}
+		pages++
+	}
+	if pages != 3 {
+		t.Errorf("wrong number of log pages: have %d, want 3", pages)
+	}
+	var want []interface{}
+	for number := 1; number <= 3; number++ {
+		for index := 0; index < 4; index++ {
+			want = append(want, map[string]interface{}{
+				"index":       hexutil.Uint64(index).String(),
+				"transaction": map[string]interface{}{"block": map[string]interface{}{"number": hexutil.Uint64(number).String()}},
+			})
+		}
+	}
+	assert.Equal(t, mustJSON(t, want), mustJSON(t, logs))
+
+	// Invalid arguments must be rejected
+	for _, query := range []string{
+		`{ blocksConnection(first: 1, last: 1) { edges { cursor } } }`,
+		`{ blocksConnection(first: 1001) { edges { cursor } } }`,
+		`{ blocksConnection(after: "invalid") { edges { cursor } } }`,
+		`{ logsConnection(filter: {}, after: "invalid") { edges { cursor } } }`,
+	} {
+		if res := handler.Schema.Exec(context.Background(), query, "", nil); res.Errors == nil {
+			t.Errorf("expected error for query %s", query)
+		}
+	}
+}
+
+func TestGraphQLWebsocketSubscription(t *testing.T) {
+	var (
+		genesis = &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+		}
+		stack = createNode(t)
+	)
+	defer stack.Close()
+
+	_, backend, chain := newGQLBackend(t, stack, false, genesis, 1, func(i int, gen *core.BlockGen) {})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	url := strings.Replace(stack.HTTPEndpoint(), "http://", "ws://", 1) + "/graphql"
+
+	// Connections without the subprotocol are rejected
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseProtocolError) {
+		t.Errorf("wrong error for missing subprotocol: %v", err)
+	}
+	conn.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{wsSubprotocol}}
+	conn, _, err = dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	send := func(msg string) {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("could not send message: %v", err)
+		}
+	}
+	expect := func(want string) {
+		t.Helper()
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		if string(msg) != want {
+			t.Fatalf("wrong message\nhave: %s\nwant: %s", msg, want)
+		}
+	}
+	send(`{"type":"connection_init"}`)
+	expect(`{"type":"connection_ack"}`)
+	send(`{"type":"ping"}`)
+	expect(`{"type":"pong"}`)
+
+	// Queries are answered with a single result
+	send(`{"id":"1","type":"subscribe","payload":{"query":"{ block { number } }"}}`)
+	expect(`{"id":"1","type":"next","payload":{"data":{"block":{"number":"0x1"}}}}`)
+	expect(`{"id":"1","type":"complete"}`)
+
+	// Invalid operations result in an error
+	send(`{"id":"2","type":"subscribe","payload":{"query":"{ bleh }"}}`)
+	expect(`{"id":"2","type":"error","payload":[{"message":"Cannot query field \"bleh\" on type \"Query\".","locations":[{"line":1,"column":3}]}]}`)
+
+	// Subscriptions deliver events until completed by the client
+	send(`{"id":"3","type":"subscribe","payload":{"query":"subscription { newBlock { number } }"}}`)
+	time.Sleep(100 * time.Millisecond) // wait for the subscription to be installed
+
+	blocks, _ := core.GenerateChain(params.AllEthashProtocolChanges, chain[len(chain)-1], ethash.NewFaker(), backend.ChainDb(), 2, func(i int, gen *core.BlockGen) {})
+	for _, block := range blocks {
+		if _, err := backend.BlockChain().InsertChain([]*types.Block{block}); err != nil {
+			t.Fatalf("could not insert block: %v", err)
+		}
+	}
+	expect(`{"id":"3","type":"next","payload":{"data":{"newBlock":{"number":"0x2"}}}}`)
+	expect(`{"id":"3","type":"next","payload":{"data":{"newBlock":{"number":"0x3"}}}}`)
+	send(`{"id":"3","type":"complete"}`)
+
+	// Reusing the id of a running operation terminates the connection
+	send(`{"id":"4","type":"subscribe","payload":{"query":"subscription { newBlock { number } }"}}`)
+	send(`{"id":"4","type":"subscribe","payload":{"query":"subscription { newBlock { number } }"}}`)
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, wsCloseSubscriberDup) {
+		t.Errorf("wrong error for duplicate subscriber: %v", err)
+	}
+}
+
+func TestGraphQLWebsocketVirtualHosts(t *testing.T) {
+	var (
+		genesis = &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+		}
+		stack = createNode(t)
+	)
+	defer stack.Close()
+
+	newGQLService(t, stack, false, genesis, 0, func(i int, gen *core.BlockGen) {})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	url := strings.Replace(stack.HTTPEndpoint(), "http://", "ws://", 1) + "/graphql"
+	dialer := websocket.Dialer{Subprotocols: []string{wsSubprotocol}}
+
+	// Hosts not in the allowlist are rejected before the upgrade
+	_, resp, err := dialer.Dial(url, http.Header{"Host": {"evil.example"}})
+	if err == nil {
+		t.Fatal("connection with disallowed host accepted")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("wrong response for disallowed host: %v", err)
+	}
+	// IP addresses are always allowed
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	conn.Close()
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	blob, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+	return string(blob)
+}
+
+func TestGraphQLTraces(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		dad     = common.HexToAddress("0x0000000000000000000000000000000000000dad")
+		beef    = common.HexToAddress("0x000000000000000000000000000000000000beef")
+		factory = common.HexToAddress("0x000000000000000000000000000000000000cafe")
+		// SSTORE(0, 42), RETURN(0, 1)
+		initcode = common.Hex2Bytes("602a60005560016000f3")
+		genesis  = &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+			Alloc: types.GenesisAlloc{
+				addr: {Balance: big.NewInt(params.Ether)},
+				dad: {
+					// SSTORE(0, 1), CALL(0xffff, 0xbeef, 1, 0, 0, 0, 0), STOP
+					Code:    common.Hex2Bytes("60016000556000600060006000600161beef61fffff100"),
+					Balance: big.NewInt(10),
+				},
+				factory: {
+					// MSTORE(0, initcode), CREATE2(0, 22, 10, 0), STOP
+					Code: common.Hex2Bytes("69602a60005560016000f36000526000600a60166000f500"),
+				},
+			},
+		}
+		signer = types.LatestSigner(genesis.Config)
+		stack  = createNode(t)
+	)
+	defer stack.Close()
+
+	var tx *types.Transaction
+	handler, _ := newGQLService(t, stack, false, genesis, 1, func(i int, gen *core.BlockGen) {
+		tx, _ = types.SignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &dad, Gas: 100000, GasPrice: big.NewInt(params.InitialBaseFee)})
+		gen.AddTx(tx)
+		create, _ := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: 1, Gas: 100000, GasPrice: big.NewInt(params.InitialBaseFee), Data: initcode})
+		gen.AddTx(create)
+		create2, _ := types.SignNewTx(key, signer, &types.LegacyTx{Nonce: 2, To: &factory, Gas: 100000, GasPrice: big.NewInt(params.InitialBaseFee)})
+		gen.AddTx(create2)
+	})
+	const calls = `calls(flatten: true) { type from to value depth }`
+	res := handler.Schema.Exec(context.Background(), `{
+		block(number: 1) {
+			transactions { `+calls+` stateDiff { address deleted balanceBefore balanceAfter nonceBefore nonceAfter codeBefore codeAfter storage { slot before after } } }
+			traces { transaction { hash } calls { type calls { type to value } } stateDiff { address } error }
+		}
+	}`, "", nil)
+	if res.Errors != nil {
+		t.Fatalf("failed to execute query: %v", res.Errors)
+	}
+	type callFrame struct {
+		Type  string
+		From  *common.Address
+		To    *common.Address
+		Value *hexutil.Big
+		Depth hexutil.Uint64
+		Calls []callFrame
+	}
+	type accountDiff struct {
+		Address       common.Address
+		Deleted       bool
+		BalanceBefore *hexutil.Big
+		BalanceAfter  *hexutil.Big
+		NonceBefore   hexutil.Uint64
+		NonceAfter    hexutil.Uint64
+		CodeBefore    hexutil.Bytes
+		CodeAfter     hexutil.Bytes
+		Storage       []struct{ Slot, Before, After common.Hash }
+	}
+	var data struct {
+		Block struct {
+			Transactions []struct {
+				Calls     []callFrame
+				StateDiff []accountDiff
+			}
+			Traces []struct {
+				Transaction struct{ Hash common.Hash }
+				Calls       []callFrame
+				StateDiff   []accountDiff
+				Error       *string
+			}
+		}
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// Check the flattened calls of the transaction
+	txs := data.Block.Transactions
+	if len(txs) != 3 || len(txs[0].Calls) != 2 {
+		t.Fatalf("wrong calls: %s", res.Data)
+	}
+	root, inner := txs[0].Calls[0], txs[0].Calls[1]
+	if root.Type != "CALL" || *root.From != addr || *root.To != dad || root.Depth != 0 {
+		t.Errorf("wrong top-level call: %+v", root)
+	}
+	if inner.Type != "CALL" || *inner.From != dad || *inner.To != beef || inner.Value.ToInt().Uint64() != 1 || inner.Depth != 1 {
+		t.Errorf("wrong inner call: %+v", inner)
+	}
+	// Check the balance changes and storage writes
+	diffs := make(map[common.Address]accountDiff)
+	for _, diff := range txs[0].StateDiff {
+		diffs[diff.Address] = diff
+	}
+	if diff := diffs[dad]; diff.BalanceBefore.ToInt().Uint64() != 10 || diff.BalanceAfter.ToInt().Uint64() != 9 {
+		t.Errorf("wrong balance change of caller: %+v", diff)
+	} else if len(diff.Storage) != 1 || diff.Storage[0].Slot != (common.Hash{}) || diff.Storage[0].Before != (common.Hash{}) || diff.Storage[0].After != common.BigToHash(common.Big1) {
+		t.Errorf("wrong storage change of caller: %+v", diff.Storage)
+	}
+	if diff := diffs[beef]; diff.BalanceBefore.ToInt().Uint64() != 0 || diff.BalanceAfter.ToInt().Uint64() != 1 {
+		t.Errorf("wrong balance change of callee: %+v", diff)
+	}
+	// Check that contracts deployed by CREATE and CREATE2 are reported with an
+	// empty prestate
+	for i, created := range []common.Address{
+		crypto.CreateAddress(addr, 1),
+		crypto.CreateAddress2(factory, common.Hash{}, crypto.Keccak256(initcode)),
+	} {
+		var diff *accountDiff
+		for j := range txs[i+1].StateDiff {
+			if txs[i+1].StateDiff[j].Address == created {
+				diff = &txs[i+1].StateDiff[j]
+			}
+		}
+		switch {
+		case diff == nil:
+			t.Errorf("tx %d: created contract %x missing from state diff", i+1, created)
+		case diff.Deleted || diff.BalanceBefore.ToInt().Sign() != 0 || diff.NonceBefore != 0 || len(diff.CodeBefore) != 0:
+			t.Errorf("tx %d: wrong prestate of created contract: %+v", i+1, diff)
+		case diff.NonceAfter != 1 || len(diff.CodeAfter) != 1:
+			t.Errorf("tx %d: wrong poststate of created contract: %+v", i+1, diff)
+		case len(diff.Storage) != 1 || diff.Storage[0].Before != (common.Hash{}) || diff.Storage[0].After != common.BigToHash(big.NewInt(42)):
+			t.Errorf("tx %d: wrong storage of created contract: %+v", i+1, diff.Storage)
+		}
+	}
+	// Check that block traces match the transaction traces
+	traces := data.Block.Traces
+	if len(traces) != 3 || traces[0].Transaction.Hash != tx.Hash() || traces[0].Error != nil {
+		t.Fatalf("wrong block traces: %s", res.Data)
+	}
+	if len(traces[0].Calls) != 1 || len(traces[0].Calls[0].Calls) != 1 || *traces[0].Calls[0].Calls[0].To != beef {
+		t.Errorf("wrong block trace calls: %+v", traces[0].Calls)
+	}
+	if len(traces[0].StateDiff) != len(txs[0].StateDiff) {
+		t.Errorf("wrong block trace state diff: have %d accounts, want %d", len(traces[0].StateDiff), len(txs[0].StateDiff))
+	}
 }
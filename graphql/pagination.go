@@ -0,0 +1,360 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	defaultPageSize = 100  // Number of edges returned if neither first nor last is given
+	maxPageSize     = 1000 // Maximum number of edges returned at once
+)
+
+var (
+	errInvalidCursor   = errors.New("invalid cursor")
+	errInvalidPageSize = fmt.Errorf("page size must be between 0 and %d", maxPageSize)
+	errFirstAndLast    = errors.New("only one of first or last must be specified")
+	errCursorReorged   = errors.New("cursor invalidated by chain reorganisation")
+
+	// errPageFull is used internally to stop searching once a page is complete.
+	errPageFull = errors.New("page full")
+)
+
+// ConnectionArgs are the arguments of Relay-style connection fields.
+type ConnectionArgs struct {
+	First  *int32
+	After  *string
+	Last   *int32
+	Before *string
+}
+
+// size returns the requested number of edges.
+func (args ConnectionArgs) size() (int64, error) {
+	if args.First != nil && args.Last != nil {
+		return 0, errFirstAndLast
+	}
+	size := int64(defaultPageSize)
+	if args.First != nil {
+		size = int64(*args.First)
+	} else if args.Last != nil {
+		size = int64(*args.Last)
+	}
+	if size < 0 || size > maxPageSize {
+		return 0, errInvalidPageSize
+	}
+	return size, nil
+}
+
+// window returns the positions of the edges to return from the inclusive range
+// [lo, hi] of positions identified by cursors of the given kind, along with the
+// page info of the window without its cursors. The window is empty if its start
+// exceeds its end.
+func (args ConnectionArgs) window(kind string, lo, hi int64) (int64, int64, *PageInfo, error) {
+	size, err := args.size()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	start, end := lo, hi
+	if args.After != nil {
+		after, err := decodePositionCursor(*args.After, kind)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		start = max(start, after+1)
+	}
+	if args.Before != nil {
+		before, err := decodePositionCursor(*args.Before, kind)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		end = min(end, before-1)
+	}
+	if args.Last != nil {
+		start = max(start, end-size+1)
+	} else {
+		end = min(end, start+size-1)
+	}
+	return start, end, &PageInfo{hasPrevious: start > lo, hasNext: end < hi}, nil
+}
+
+// encodeCursor creates an opaque cursor of the given kind from its fields.
+func encodeCursor(kind string, fields ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(append([]string{kind}, fields...), ":")))
+}
+
+// decodeCursor returns the n fields of a cursor of the given kind.
+func decodeCursor(cursor string, kind string, n int) ([]string, error) {
+	blob, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	fields := strings.Split(string(blob), ":")
+	if len(fields) != n+1 || fields[0] != kind {
+		return nil, errInvalidCursor
+	}
+	return fields[1:], nil
+}
+
+// positionCursor creates the cursor of an edge identified by its position.
+func positionCursor(kind string, pos int64) string {
+	return encodeCursor(kind, strconv.FormatInt(pos, 10))
+}
+
+// decodePositionCursor returns the position of the edge of a cursor.
+func decodePositionCursor(cursor string, kind string) (int64, error) {
+	fields, err := decodeCursor(cursor, kind, 1)
+	if err != nil {
+		return 0, err
+	}
+	pos, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || pos < 0 {
+		return 0, errInvalidCursor
+	}
+	return pos, nil
+}
+
+// logCursor creates the cursor of a log, binding it to the hash of its block.
+func logCursor(log *types.Log) string {
+	return encodeCursor("log", strconv.FormatUint(log.BlockNumber, 10), strconv.FormatUint(uint64(log.Index), 10), log.BlockHash.Hex())
+}
+
+// decodeLogCursor returns the block number, block hash and index of the log of
+// a cursor.
+func decodeLogCursor(cursor string) (uint64, common.Hash, uint, error) {
+	fields, err := decodeCursor(cursor, "log", 3)
+	if err != nil {
+		return 0, common.Hash{}, 0, err
+	}
+	number, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, common.Hash{}, 0, errInvalidCursor
+	}
+	index, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0, common.Hash{}, 0, errInvalidCursor
+	}
+	hash, err := hexutil.Decode(fields[2])
+	if err != nil || len(hash) != common.HashLength {
+		return 0, common.Hash{}, 0, errInvalidCursor
+	}
+	return number, common.BytesToHash(hash), uint(index), nil
+}
+
+// PageInfo describes a page of a connection.
+type PageInfo struct {
+	hasNext     bool
+	hasPrevious bool
+	start       *string
+	end         *string
+}
+
+func (p *PageInfo) HasNextPage() bool     { return p.hasNext }
+func (p *PageInfo) HasPreviousPage() bool { return p.hasPrevious }
+func (p *PageInfo) StartCursor() *string  { return p.start }
+func (p *PageInfo) EndCursor() *string    { return p.end }
+
+// setCursors sets the start and end cursors of a page with the given number of
+// edges from the cursor of the edge at the given position.
+func (p *PageInfo) setCursors(n int, cursor func(i int) string) {
+	if n > 0 {
+		start, end := cursor(0), cursor(n-1)
+		p.start, p.end = &start, &end
+	}
+}
+
+// BlockEdge is a block within a block connection.
+type BlockEdge struct {
+	cursor string
+	node   *Block
+}
+
+func (e *BlockEdge) Cursor() string { return e.cursor }
+func (e *BlockEdge) Node() *Block   { return e.node }
+
+// BlockConnection is a page of blocks.
+type BlockConnection struct {
+	edges    []*BlockEdge
+	pageInfo *PageInfo
+}
+
+func (c *BlockConnection) Edges() []*BlockEdge { return c.edges }
+func (c *BlockConnection) PageInfo() *PageInfo { return c.pageInfo }
+
+// TransactionEdge is a transaction within a transaction connection.
+type TransactionEdge struct {
+	cursor string
+	node   *Transaction
+}
+
+func (e *TransactionEdge) Cursor() string     { return e.cursor }
+func (e *TransactionEdge) Node() *Transaction { return e.node }
+
+// TransactionConnection is a page of transactions.
+type TransactionConnection struct {
+	edges    []*TransactionEdge
+	pageInfo *PageInfo
+}
+
+func (c *TransactionConnection) Edges() []*TransactionEdge { return c.edges }
+func (c *TransactionConnection) PageInfo() *PageInfo       { return c.pageInfo }
+
+// LogEdge is a log within a log connection.
+type LogEdge struct {
+	cursor string
+	node   *Log
+}
+
+func (e *LogEdge) Cursor() string { return e.cursor }
+func (e *LogEdge) Node() *Log     { return e.node }
+
+// LogConnection is a page of logs.
+type LogConnection struct {
+	edges    []*LogEdge
+	pageInfo *PageInfo
+}
+
+func (c *LogConnection) Edges() []*LogEdge   { return c.edges }
+func (c *LogConnection) PageInfo() *PageInfo { return c.pageInfo }
+
+func (r *Resolver) BlocksConnection(ctx context.Context, args struct {
+	From *Long
+	To   *Long
+	ConnectionArgs
+}) (*BlockConnection, error) {
+	var from int64
+	if args.From != nil {
+		from = int64(*args.From)
+	}
+	to := r.backend.CurrentBlock().Number.Int64()
+	if args.To != nil {
+		if int64(*args.To) < from {
+			return nil, errInvalidBlockRange
+		}
+		to = min(to, int64(*args.To))
+	}
+	start, end, info, err := args.window("block", max(from, 0), to)
+	if err != nil {
+		return nil, err
+	}
+	conn := &BlockConnection{edges: []*BlockEdge{}, pageInfo: info}
+	for i := start; i <= end; i++ {
+		numberOrHash := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(i))
+		block := &Block{r: r, numberOrHash: &numberOrHash}
+		if h, err := block.resolveHeader(ctx); err != nil {
+			return nil, err
+		} else if h == nil {
+			break
+		}
+		conn.edges = append(conn.edges, &BlockEdge{cursor: positionCursor("block", i), node: block})
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	info.setCursors(len(conn.edges), func(i int) string { return conn.edges[i].cursor })
+	return conn, nil
+}
+
+func (b *Block) TransactionsConnection(ctx context.Context, args ConnectionArgs) (*TransactionConnection, error) {
+	txs, err := b.Transactions(ctx)
+	if err != nil || txs == nil {
+		return nil, err
+	}
+	start, end, info, err := args.window("tx", 0, int64(len(*txs))-1)
+	if err != nil {
+		return nil, err
+	}
+	conn := &TransactionConnection{edges: []*TransactionEdge{}, pageInfo: info}
+	for i := start; i <= end; i++ {
+		conn.edges = append(conn.edges, &TransactionEdge{cursor: positionCursor("tx", i), node: (*txs)[i]})
+	}
+	info.setCursors(len(conn.edges), func(i int) string { return conn.edges[i].cursor })
+	return conn, nil
+}
+
+func (r *Resolver) LogsConnection(ctx context.Context, args struct {
+	Filter FilterCriteria
+	First  *int32
+	After  *string
+}) (*LogConnection, error) {
+	size, err := ConnectionArgs{First: args.First}.size()
+	if err != nil {
+		return nil, err
+	}
+	crit := args.Filter
+	var (
+		afterNumber uint64
+		afterIndex  uint
+		after       = args.After != nil
+	)
+	if after {
+		var hash common.Hash
+		if afterNumber, hash, afterIndex, err = decodeLogCursor(*args.After); err != nil {
+			return nil, err
+		}
+		// Resume from the block of the last returned log, if it's still canonical
+		header, err := r.backend.HeaderByNumber(ctx, rpc.BlockNumber(afterNumber))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil || header.Hash() != hash {
+			return nil, errCursorReorged
+		}
+		from := Long(afterNumber)
+		crit.FromBlock = &from
+	}
+	filter, err := r.newRangeFilter(crit)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := filter.Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn := &LogConnection{edges: []*LogEdge{}, pageInfo: &PageInfo{hasPrevious: after}}
+	err = stream(func(log *types.Log) error {
+		if after && log.BlockNumber == afterNumber && log.Index <= afterIndex {
+			return nil
+		}
+		if int64(len(conn.edges)) == size {
+			return errPageFull
+		}
+		conn.edges = append(conn.edges, &LogEdge{
+			cursor: logCursor(log),
+			node:   &Log{r: r, transaction: &Transaction{r: r, hash: log.TxHash}, log: log},
+		})
+		return nil
+	})
+	switch {
+	case errors.Is(err, errPageFull):
+		conn.pageInfo.hasNext = true
+	case err != nil:
+		return nil, err
+	}
+	conn.pageInfo.setCursors(len(conn.edges), func(i int) string { return conn.edges[i].cursor })
+	return conn, nil
+}
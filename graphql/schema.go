@@ -36,6 +36,20 @@ const schema string = `
     schema {
         query: Query
         mutation: Mutation
+        subscription: Subscription
+    }
+
+    # PageInfo describes a page of a connection, following the Relay cursor
+    # connections specification.
+    type PageInfo {
+        # HasNextPage is whether more edges exist after the page.
+        hasNextPage: Boolean!
+        # HasPreviousPage is whether more edges exist before the page.
+        hasPreviousPage: Boolean!
+        # StartCursor is the cursor of the first edge of the page, if any.
+        startCursor: String
+        # EndCursor is the cursor of the last edge of the page, if any.
+        endCursor: String
     }
 
     # Account is an Ethereum account at a particular block.
@@ -69,6 +83,21 @@ const schema string = `
         data: Bytes!
         # Transaction is the transaction that generated this log entry.
         transaction: Transaction!
+        # Removed is true if the log was reverted due to a chain reorganisation.
+        # It is only ever set on logs delivered by subscriptions.
+        removed: Boolean!
+    }
+
+    # LogEdge is a log within a LogConnection.
+    type LogEdge {
+        cursor: String!
+        node: Log!
+    }
+
+    # LogConnection is a page of logs.
+    type LogConnection {
+        edges: [LogEdge!]!
+        pageInfo: PageInfo!
     }
 
     # EIP-2718
@@ -170,6 +199,94 @@ const schema string = `
         rawReceipt: Bytes!
         # BlobVersionedHashes is a set of hash outputs from the blobs in the transaction.
         blobVersionedHashes: [Bytes32!]
+        # Calls is the tree of message calls made by this transaction, traced by
+        # re-executing it. Only the top-level call is returned, unless flatten is
+        # true, in which case all calls are returned in depth-first order. If the
+        # transaction has not yet been mined, this field will be null.
+        calls(flatten: Boolean = false): [CallFrame!]
+        # StateDiff is the list of accounts modified by this transaction, traced
+        # by re-executing it. If the transaction has not yet been mined, this
+        # field will be null.
+        stateDiff: [AccountDiff!]
+    }
+
+    # CallFrame is a message call or contract creation made during the
+    # execution of a transaction.
+    type CallFrame {
+        # Type is the kind of the call, e.g. CALL, DELEGATECALL or CREATE.
+        type: String!
+        # From is the address of the caller.
+        from: Address!
+        # To is the address of the callee or the created contract. It is null
+        # if the contract creation failed.
+        to: Address
+        # Value is the amount of wei transferred, null for calls which cannot
+        # transfer value.
+        value: BigInt
+        # Gas is the amount of gas provided to the call.
+        gas: Long!
+        # GasUsed is the amount of gas consumed by the call.
+        gasUsed: Long!
+        # Input is the call data or the init code of the call.
+        input: Bytes!
+        # Output is the data returned by the call.
+        output: Bytes
+        # Error is the reason the call failed, null if it succeeded.
+        error: String
+        # RevertReason is the decoded reason of reverted calls.
+        revertReason: String
+        # Depth is the nesting level of the call, zero for the top-level call.
+        depth: Long!
+        # Calls are the calls made by this call.
+        calls: [CallFrame!]!
+    }
+
+    # AccountDiff describes the changes made to an account by a transaction.
+    type AccountDiff {
+        address: Address!
+        # Deleted is true if the account was destroyed by the transaction.
+        deleted: Boolean!
+        balanceBefore: BigInt!
+        balanceAfter: BigInt!
+        nonceBefore: Long!
+        nonceAfter: Long!
+        codeBefore: Bytes!
+        codeAfter: Bytes!
+        # Storage lists the modified storage slots.
+        storage: [StorageDiff!]!
+    }
+
+    # StorageDiff describes the change of a storage slot made by a transaction.
+    type StorageDiff {
+        slot: Bytes32!
+        before: Bytes32!
+        after: Bytes32!
+    }
+
+    # TransactionTrace is the trace of a transaction, produced while tracing
+    # its entire block.
+    type TransactionTrace {
+        transaction: Transaction!
+        # Calls is the tree of message calls made by the transaction, see
+        # Transaction.calls. It is null if tracing the transaction failed.
+        calls(flatten: Boolean = false): [CallFrame!]
+        # StateDiff is the list of accounts modified by the transaction. It is
+        # null if tracing the transaction failed.
+        stateDiff: [AccountDiff!]
+        # Error is the reason tracing the transaction failed, e.g. a timeout.
+        error: String
+    }
+
+    # TransactionEdge is a transaction within a TransactionConnection.
+    type TransactionEdge {
+        cursor: String!
+        node: Transaction!
+    }
+
+    # TransactionConnection is a page of transactions.
+    type TransactionConnection {
+        edges: [TransactionEdge!]!
+        pageInfo: PageInfo!
     }
 
     # BlockFilterCriteria encapsulates log filter criteria for a filter applied
@@ -252,6 +369,9 @@ const schema string = `
         # Transactions is a list of transactions associated with this block. If
         # transactions are unavailable for this block, this field will be null.
         transactions: [Transaction!]
+        # TransactionsConnection returns a page of the transactions of this block.
+        # If transactions are unavailable for this block, this field will be null.
+        transactionsConnection(first: Int, after: String, last: Int, before: String): TransactionConnection
         # TransactionAt returns the transaction at the specified index. If
         # transactions are unavailable for this block, or if the index is out of
         # bounds, this field will be null.
@@ -279,6 +399,22 @@ const schema string = `
         blobGasUsed: Long
         # ExcessBlobGas is a running total of blob gas consumed in excess of the target, prior to the block.
         excessBlobGas: Long
+        # Traces are the execution traces of the transactions of this block,
+        # produced by re-executing it. If transactions are unavailable for this
+        # block, this field will be null.
+        traces: [TransactionTrace!]
+    }
+
+    # BlockEdge is a block within a BlockConnection.
+    type BlockEdge {
+        cursor: String!
+        node: Block!
+    }
+
+    # BlockConnection is a page of blocks.
+    type BlockConnection {
+        edges: [BlockEdge!]!
+        pageInfo: PageInfo!
     }
 
     # CallData represents the data associated with a local contract call.
@@ -369,12 +505,19 @@ const schema string = `
         # Blocks returns all the blocks between two numbers, inclusive. If
         # to is not supplied, it defaults to the most recent known block.
         blocks(from: Long, to: Long): [Block!]!
+        # BlocksConnection returns a page of the blocks between two numbers,
+        # inclusive. If from is not supplied, it defaults to the genesis block,
+        # if to is not supplied, it defaults to the most recent known block.
+        blocksConnection(from: Long, to: Long, first: Int, after: String, last: Int, before: String): BlockConnection!
         # Pending returns the current pending state.
         pending: Pending!
         # Transaction returns a transaction specified by its hash.
         transaction(hash: Bytes32!): Transaction
         # Logs returns log entries matching the provided filter.
         logs(filter: FilterCriteria!): [Log!]!
+        # LogsConnection returns a page of the log entries matching the provided
+        # filter. Cursors are invalidated if the block of their log is reorged.
+        logsConnection(filter: FilterCriteria!, first: Int, after: String): LogConnection!
         # GasPrice returns the node's estimate of a gas price sufficient to
         # ensure a transaction is mined in a timely fashion.
         gasPrice: BigInt!
@@ -391,4 +534,16 @@ const schema string = `
         # SendRawTransaction sends an RLP-encoded transaction to the network.
         sendRawTransaction(data: Bytes!): Bytes32!
     }
+
+    # Subscriptions are served over WebSocket connections to the GraphQL endpoint,
+    # using the graphql-transport-ws protocol.
+    type Subscription {
+        # NewBlock delivers the blocks becoming the head of the chain.
+        newBlock: Block!
+        # NewLogs delivers the log entries of new chain head blocks matching the
+        # provided filter, along with the removed logs of reorged blocks.
+        newLogs(filter: BlockFilterCriteria): Log!
+        # PendingTransaction delivers the transactions entering the transaction pool.
+        pendingTransaction: Transaction!
+    }
 `
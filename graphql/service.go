@@ -19,24 +19,73 @@ package graphql
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/graph-gophers/graphql-go"
 	gqlErrors "github.com/graph-gophers/graphql-go/errors"
 )
 
+var (
+	queryCostHist      = metrics.NewRegisteredHistogram("graphql/cost", nil, metrics.NewExpDecaySample(1028, 0.015))
+	queryTimer         = metrics.NewRegisteredTimer("graphql/duration", nil)
+	rejectedQueryMeter = metrics.NewRegisteredMeter("graphql/rejected", nil)
+	timedOutQueryMeter = metrics.NewRegisteredMeter("graphql/timeout", nil)
+)
+
+// Config contains the settings of the GraphQL service.
+type Config struct {
+	Cors         []string // Allowed CORS domains
+	VirtualHosts []string // Allowed virtual hostnames
+
+	MaxDepth   int               // Maximum depth of queries, zero for no limit
+	MaxCost    uint64            // Maximum estimated cost of queries, zero for no limit
+	Timeout    time.Duration     // Maximum execution time of queries, zero for no limit
+	FieldCosts map[string]uint64 // Weights of fields ("Type.field"), overriding the defaults
+}
+
 type handler struct {
 	Schema *graphql.Schema
+
+	cost     *costAnalyzer
+	maxDepth int
+	maxCost  uint64
+	timeout  time.Duration
 }
 
-func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// check rejects queries which are invalid or exceed the cost limit before they
+// are executed. For accepted queries, the kind of the operation is returned.
+func (h *handler) check(query string, operationName string, variables map[string]interface{}) (string, []*gqlErrors.QueryError) {
+	doc, qErr := parseQuery(query)
+	if qErr != nil {
+		return "", []*gqlErrors.QueryError{qErr}
+	}
+	if errs := validateQuery(h.Schema.ASTSchema(), doc, variables, h.maxDepth); len(errs) > 0 {
+		return "", errs
+	}
+	cost, kind, err := h.cost.cost(doc, operationName, variables)
+	if err != nil {
+		return "", []*gqlErrors.QueryError{{Message: err.Error()}}
+	}
+	queryCostHist.Update(int64(min(cost, math.MaxInt64)))
+	if h.maxCost != 0 && cost > h.maxCost {
+		return "", []*gqlErrors.QueryError{{Message: fmt.Sprintf("query cost %d exceeds the maximum of %d", cost, h.maxCost)}}
+	}
+	return kind, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var params struct {
 		Query         string                 `json:"query"`
 		OperationName string                 `json:"operationName"`
@@ -46,6 +95,18 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, errs := h.check(params.Query, params.OperationName, params.Variables); errs != nil {
+		rejectedQueryMeter.Mark(1)
+		responseJSON, err := json.Marshal(&graphql.Response{Errors: errs})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(responseJSON)
+		return
+	}
 
 	var (
 		ctx       = r.Context()
@@ -56,9 +117,15 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel = context.WithCancel(ctx)
 	defer cancel()
 
-	if timeout, ok := rpc.ContextRequestTimeout(ctx); ok {
+	timeout, ok := rpc.ContextRequestTimeout(ctx)
+	if h.timeout != 0 && (!ok || h.timeout < timeout) {
+		timeout, ok = h.timeout, true
+	}
+	if ok {
 		timer = time.AfterFunc(timeout, func() {
 			responded.Do(func() {
+				timedOutQueryMeter.Mark(1)
+
 				// Cancel request handling.
 				cancel()
 
@@ -87,10 +154,12 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	start := time.Now()
 	response := h.Schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
 	if timer != nil {
 		timer.Stop()
 	}
+	queryTimer.UpdateSince(start)
 	responded.Do(func() {
 		responseJSON, err := json.Marshal(response)
 		if err != nil {
@@ -105,28 +174,71 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// New constructs a new GraphQL service instance.
+// newGraphQLHandler routes websocket upgrade requests to the websocket handler,
+// bypassing the HTTP middleware which doesn't support hijacking connections.
+// WebSocket requests are still validated against the allowed virtual hosts,
+// the origin is checked by the websocket handler itself.
+func newGraphQLHandler(httpHandler http.Handler, wsHandler http.Handler, vhosts []string) http.Handler {
+	wsHandler = node.NewVHostHandler(wsHandler, vhosts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocket(r) {
+			wsHandler.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
+
+// New constructs a new GraphQL service instance, limiting queries to the default
+// depth and cost of the node configuration.
 func New(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string) error {
-	_, err := newHandler(stack, backend, filterSystem, cors, vhosts)
+	return NewWithConfig(stack, backend, filterSystem, &Config{
+		Cors:         cors,
+		VirtualHosts: vhosts,
+		MaxDepth:     node.DefaultConfig.GraphQLMaxDepth,
+		MaxCost:      node.DefaultConfig.GraphQLMaxCost,
+	})
+}
+
+// NewWithConfig constructs a new GraphQL service instance with the given settings.
+func NewWithConfig(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, config *Config) error {
+	_, err := newHandler(stack, backend, filterSystem, config)
 	return err
 }
 
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
 // It additionally exports an interactive query browser on the / endpoint.
-func newHandler(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, cors, vhosts []string) (*handler, error) {
-	q := Resolver{backend, filterSystem}
+func newHandler(stack *node.Node, backend ethapi.Backend, filterSystem *filters.FilterSystem, config *Config) (*handler, error) {
+	q := Resolver{backend: backend, filterSystem: filterSystem}
+	if filterSystem != nil {
+		q.events = filters.NewEventSystem(filterSystem)
+	}
+	if tb, ok := backend.(tracers.Backend); ok {
+		q.tracers = tracers.NewAPI(tb)
+	}
 
-	s, err := graphql.ParseSchema(schema, &q)
+	opts := []graphql.SchemaOpt{graphql.MaxDepth(config.MaxDepth)}
+	if config.Timeout != 0 {
+		// Limits the execution of every subscription event
+		opts = append(opts, graphql.SubscribeResolverTimeout(config.Timeout))
+	}
+	s, err := graphql.ParseSchema(schema, &q, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cost, err := newCostAnalyzer(s.ASTSchema(), config.FieldCosts, func() uint64 {
+		return backend.CurrentBlock().Number.Uint64()
+	})
 	if err != nil {
 		return nil, err
 	}
-	h := handler{Schema: s}
-	handler := node.NewHTTPHandlerStack(h, cors, vhosts, nil)
+	h := &handler{Schema: s, cost: cost, maxDepth: config.MaxDepth, maxCost: config.MaxCost, timeout: config.Timeout}
+	handler := newGraphQLHandler(node.NewHTTPHandlerStack(h, config.Cors, config.VirtualHosts, nil), newWSHandler(h, config.Cors), config.VirtualHosts)
 
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui/", GraphiQL{})
 	stack.RegisterHandler("GraphQL", "/graphql", handler)
 	stack.RegisterHandler("GraphQL", "/graphql/", handler)
 
-	return &h, nil
+	return h, nil
 }
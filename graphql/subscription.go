@@ -0,0 +1,119 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// subscriptionBuffer is the number of events buffered for a subscriber. If a
+// subscriber falls further behind, its subscription is ended.
+const subscriptionBuffer = 256
+
+var errSubscriptionsUnavailable = errors.New("subscriptions are not available")
+
+// forward delivers the items of the events received from a filter subscription
+// until the context is cancelled.
+func forward[E, T any](ctx context.Context, sub *filters.Subscription, events <-chan E, items func(E) []T) <-chan T {
+	ch := make(chan T, subscriptionBuffer)
+	go func() {
+		defer close(ch)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				for _, item := range items(ev) {
+					select {
+					case ch <- item:
+					default:
+						log.Debug("Ending GraphQL subscription of slow subscriber")
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (r *Resolver) NewBlock(ctx context.Context) (<-chan *Block, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsUnavailable
+	}
+	headers := make(chan *types.Header)
+	sub := r.events.SubscribeNewHeads(headers)
+
+	return forward(ctx, sub, headers, func(header *types.Header) []*Block {
+		numberOrHash := rpc.BlockNumberOrHashWithHash(header.Hash(), false)
+		return []*Block{{r: r, numberOrHash: &numberOrHash, hash: header.Hash(), header: header}}
+	}), nil
+}
+
+func (r *Resolver) NewLogs(ctx context.Context, args struct{ Filter *BlockFilterCriteria }) (<-chan *Log, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsUnavailable
+	}
+	var crit ethereum.FilterQuery
+	if args.Filter != nil {
+		if args.Filter.Addresses != nil {
+			crit.Addresses = *args.Filter.Addresses
+		}
+		if args.Filter.Topics != nil {
+			crit.Topics = *args.Filter.Topics
+		}
+	}
+	logs := make(chan []*types.Log)
+	sub, err := r.events.SubscribeLogs(crit, logs)
+	if err != nil {
+		return nil, err
+	}
+	return forward(ctx, sub, logs, func(logs []*types.Log) []*Log {
+		ret := make([]*Log, len(logs))
+		for i, log := range logs {
+			ret[i] = &Log{r: r, transaction: &Transaction{r: r, hash: log.TxHash}, log: log}
+		}
+		return ret
+	}), nil
+}
+
+func (r *Resolver) PendingTransaction(ctx context.Context) (<-chan *Transaction, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsUnavailable
+	}
+	txs := make(chan []*types.Transaction)
+	sub := r.events.SubscribePendingTxs(txs)
+
+	return forward(ctx, sub, txs, func(txs []*types.Transaction) []*Transaction {
+		ret := make([]*Transaction, len(txs))
+		for i, tx := range txs {
+			ret[i] = &Transaction{r: r, hash: tx.Hash(), tx: tx}
+		}
+		return ret
+	}), nil
+}
@@ -0,0 +1,399 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+var (
+	errTracingUnavailable = errors.New("tracing is not available")
+
+	callTracerName     = "callTracer"
+	prestateTracerName = "prestateTracer"
+	stateDiffConfig    = json.RawMessage(`{"diffMode":true}`)
+)
+
+// traceConfig returns the configuration for tracing with a native tracer. The
+// timeouts are left at the defaults of the debug_trace* methods.
+func traceConfig(tracer *string, config json.RawMessage) *tracers.TraceConfig {
+	return &tracers.TraceConfig{Tracer: tracer, TracerConfig: config}
+}
+
+// decodeTrace converts the result of a tracer into the given value.
+func decodeTrace(result interface{}, v interface{}) error {
+	blob, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, v)
+}
+
+// callFrameJSON is the output format of the call tracer.
+type callFrameJSON struct {
+	Type         string           `json:"type"`
+	From         common.Address   `json:"from"`
+	To           *common.Address  `json:"to"`
+	Value        *hexutil.Big     `json:"value"`
+	Gas          hexutil.Uint64   `json:"gas"`
+	GasUsed      hexutil.Uint64   `json:"gasUsed"`
+	Input        hexutil.Bytes    `json:"input"`
+	Output       hexutil.Bytes    `json:"output"`
+	Error        string           `json:"error"`
+	RevertReason string           `json:"revertReason"`
+	Calls        []*callFrameJSON `json:"calls"`
+}
+
+// CallFrame is a message call or contract creation within a transaction.
+type CallFrame struct {
+	frame *callFrameJSON
+	depth int
+}
+
+// newCallFrames returns the root frame of a call trace, or all frames in depth
+// first order if the trace is flattened.
+func newCallFrames(root *callFrameJSON, flatten bool) *[]*CallFrame {
+	frames := []*CallFrame{{frame: root}}
+	if flatten {
+		frames = frames[:0]
+		var walk func(frame *callFrameJSON, depth int)
+		walk = func(frame *callFrameJSON, depth int) {
+			frames = append(frames, &CallFrame{frame: frame, depth: depth})
+			for _, call := range frame.Calls {
+				walk(call, depth+1)
+			}
+		}
+		walk(root, 0)
+	}
+	return &frames
+}
+
+func (c *CallFrame) Type() string            { return c.frame.Type }
+func (c *CallFrame) From() common.Address    { return c.frame.From }
+func (c *CallFrame) To() *common.Address     { return c.frame.To }
+func (c *CallFrame) Value() *hexutil.Big     { return c.frame.Value }
+func (c *CallFrame) Gas() hexutil.Uint64     { return c.frame.Gas }
+func (c *CallFrame) GasUsed() hexutil.Uint64 { return c.frame.GasUsed }
+func (c *CallFrame) Input() hexutil.Bytes    { return c.frame.Input }
+func (c *CallFrame) Depth() hexutil.Uint64   { return hexutil.Uint64(c.depth) }
+
+func (c *CallFrame) Output() *hexutil.Bytes {
+	if c.frame.Output == nil {
+		return nil
+	}
+	return &c.frame.Output
+}
+
+func (c *CallFrame) Error() *string {
+	if c.frame.Error == "" {
+		return nil
+	}
+	return &c.frame.Error
+}
+
+func (c *CallFrame) RevertReason() *string {
+	if c.frame.RevertReason == "" {
+		return nil
+	}
+	return &c.frame.RevertReason
+}
+
+func (c *CallFrame) Calls() []*CallFrame {
+	calls := make([]*CallFrame, len(c.frame.Calls))
+	for i, call := range c.frame.Calls {
+		calls[i] = &CallFrame{frame: call, depth: c.depth + 1}
+	}
+	return calls
+}
+
+// accountStateJSON is the output format of the prestate tracer for an account.
+type accountStateJSON struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// stateDiffJSON is the output format of the prestate tracer in diff mode.
+type stateDiffJSON struct {
+	Pre  map[common.Address]*accountStateJSON `json:"pre"`
+	Post map[common.Address]*accountStateJSON `json:"post"`
+}
+
+// newAccountDiffs converts a state diff into account diffs, ordered by address.
+// The prestate tracer only reports modified fields in the post state, other
+// fields are unchanged unless the account was deleted. Contracts created by
+// the transaction are only reported in the post state, with an empty prestate.
+func newAccountDiffs(diff *stateDiffJSON) *[]*AccountDiff {
+	addrs := make(map[common.Address]struct{}, len(diff.Pre)+len(diff.Post))
+	for addr := range diff.Pre {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range diff.Post {
+		addrs[addr] = struct{}{}
+	}
+	diffs := make([]*AccountDiff, 0, len(addrs))
+	for addr := range addrs {
+		pre, existed := diff.Pre[addr]
+		if !existed {
+			pre = new(accountStateJSON)
+		}
+		post, ok := diff.Post[addr]
+		d := &AccountDiff{
+			address: addr,
+			deleted: !ok,
+			pre:     *pre,
+			post:    accountStateJSON{Balance: (*hexutil.Big)(new(big.Int))},
+			storage: []*StorageDiff{},
+		}
+		if d.pre.Balance == nil {
+			d.pre.Balance = (*hexutil.Big)(new(big.Int))
+		}
+		if ok {
+			d.post = d.pre
+			if post.Balance != nil {
+				d.post.Balance = post.Balance
+			}
+			if post.Nonce != 0 {
+				d.post.Nonce = post.Nonce
+			}
+			if post.Code != nil {
+				d.post.Code = post.Code
+			}
+			d.post.Storage = post.Storage
+		}
+		// Slots are reported before if they weren't empty, after if they aren't
+		slots := make(map[common.Hash]struct{})
+		for slot := range pre.Storage {
+			slots[slot] = struct{}{}
+		}
+		for slot := range d.post.Storage {
+			slots[slot] = struct{}{}
+		}
+		for slot := range slots {
+			d.storage = append(d.storage, &StorageDiff{
+				slot:   slot,
+				before: pre.Storage[slot],
+				after:  d.post.Storage[slot],
+			})
+		}
+		sort.Slice(d.storage, func(i, j int) bool {
+			return bytes.Compare(d.storage[i].slot[:], d.storage[j].slot[:]) < 0
+		})
+		diffs = append(diffs, d)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].address[:], diffs[j].address[:]) < 0
+	})
+	return &diffs
+}
+
+// AccountDiff describes the changes of an account made by a transaction.
+type AccountDiff struct {
+	address common.Address
+	deleted bool
+	pre     accountStateJSON
+	post    accountStateJSON
+	storage []*StorageDiff
+}
+
+func (d *AccountDiff) Address() common.Address     { return d.address }
+func (d *AccountDiff) Deleted() bool               { return d.deleted }
+func (d *AccountDiff) BalanceBefore() hexutil.Big  { return *d.pre.Balance }
+func (d *AccountDiff) BalanceAfter() hexutil.Big   { return *d.post.Balance }
+func (d *AccountDiff) NonceBefore() hexutil.Uint64 { return hexutil.Uint64(d.pre.Nonce) }
+func (d *AccountDiff) NonceAfter() hexutil.Uint64  { return hexutil.Uint64(d.post.Nonce) }
+func (d *AccountDiff) CodeBefore() hexutil.Bytes   { return d.pre.Code }
+func (d *AccountDiff) CodeAfter() hexutil.Bytes    { return d.post.Code }
+func (d *AccountDiff) Storage() []*StorageDiff     { return d.storage }
+
+// StorageDiff describes the change of a storage slot made by a transaction.
+type StorageDiff struct {
+	slot   common.Hash
+	before common.Hash
+	after  common.Hash
+}
+
+func (d *StorageDiff) Slot() common.Hash   { return d.slot }
+func (d *StorageDiff) Before() common.Hash { return d.before }
+func (d *StorageDiff) After() common.Hash  { return d.after }
+
+// trace traces a mined transaction with a native tracer, decoding
+// the result into v. False is returned if the transaction is not mined.
+func (t *Transaction) trace(ctx context.Context, tracer *string, config json.RawMessage, v interface{}) (bool, error) {
+	if t.r.tracers == nil {
+		return false, errTracingUnavailable
+	}
+	if _, block := t.resolve(ctx); block == nil {
+		return false, nil
+	}
+	result, err := t.r.tracers.TraceTransaction(ctx, t.hash, traceConfig(tracer, config))
+	if err != nil {
+		return false, err
+	}
+	return true, decodeTrace(result, v)
+}
+
+func (t *Transaction) Calls(ctx context.Context, args struct{ Flatten bool }) (*[]*CallFrame, error) {
+	var root callFrameJSON
+	if mined, err := t.trace(ctx, &callTracerName, nil, &root); !mined || err != nil {
+		return nil, err
+	}
+	return newCallFrames(&root, args.Flatten), nil
+}
+
+func (t *Transaction) StateDiff(ctx context.Context) (*[]*AccountDiff, error) {
+	var diff stateDiffJSON
+	if mined, err := t.trace(ctx, &prestateTracerName, stateDiffConfig, &diff); !mined || err != nil {
+		return nil, err
+	}
+	return newAccountDiffs(&diff), nil
+}
+
+// blockTrace is the cached result of tracing all transactions of a block.
+type blockTrace struct {
+	done    chan struct{}
+	results []json.RawMessage // Trace results, nil for failed transactions
+	errors  []string          // Trace failures
+	err     error
+}
+
+// trace traces all transactions of a block with a native tracer. Results are
+// cached, so that the fields of all transactions are resolved by a single trace.
+func (b *Block) trace(ctx context.Context, tracer string, config json.RawMessage) (*blockTrace, error) {
+	if b.r.tracers == nil {
+		return nil, errTracingUnavailable
+	}
+	b.traceMu.Lock()
+	if b.traces == nil {
+		b.traces = make(map[string]*blockTrace)
+	}
+	trace, ok := b.traces[tracer]
+	if !ok {
+		trace = &blockTrace{done: make(chan struct{})}
+		b.traces[tracer] = trace
+	}
+	b.traceMu.Unlock()
+
+	if !ok {
+		trace.err = b.runTrace(ctx, trace, tracer, config)
+		close(trace.done)
+	}
+	select {
+	case <-trace.done:
+		return trace, trace.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runTrace executes the trace of all transactions of the block.
+func (b *Block) runTrace(ctx context.Context, trace *blockTrace, tracer string, config json.RawMessage) error {
+	hash, err := b.Hash(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := b.r.tracers.TraceBlockByHash(ctx, hash, traceConfig(&tracer, config))
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		var result txTraceResultJSON
+		if err := decodeTrace(res, &result); err != nil {
+			return err
+		}
+		trace.results = append(trace.results, result.Result)
+		trace.errors = append(trace.errors, result.Error)
+	}
+	return nil
+}
+
+// txTraceResultJSON is the output format of block traces for a transaction.
+type txTraceResultJSON struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+func (b *Block) Traces(ctx context.Context) (*[]*TransactionTrace, error) {
+	txs, err := b.Transactions(ctx)
+	if err != nil || txs == nil {
+		return nil, err
+	}
+	traces := make([]*TransactionTrace, len(*txs))
+	for i, tx := range *txs {
+		traces[i] = &TransactionTrace{block: b, tx: tx, index: i}
+	}
+	return &traces, nil
+}
+
+// TransactionTrace is the trace of a transaction produced by tracing its block.
+type TransactionTrace struct {
+	block *Block
+	tx    *Transaction
+	index int
+}
+
+func (t *TransactionTrace) Transaction() *Transaction { return t.tx }
+
+// result decodes the result of tracing the transaction with a native tracer.
+// False is returned if tracing the transaction failed.
+func (t *TransactionTrace) result(ctx context.Context, tracer string, config json.RawMessage, v interface{}) (bool, error) {
+	trace, err := t.block.trace(ctx, tracer, config)
+	if err != nil {
+		return false, err
+	}
+	if t.index >= len(trace.results) || trace.results[t.index] == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(trace.results[t.index], v)
+}
+
+func (t *TransactionTrace) Calls(ctx context.Context, args struct{ Flatten bool }) (*[]*CallFrame, error) {
+	var root callFrameJSON
+	if ok, err := t.result(ctx, callTracerName, nil, &root); !ok || err != nil {
+		return nil, err
+	}
+	return newCallFrames(&root, args.Flatten), nil
+}
+
+func (t *TransactionTrace) StateDiff(ctx context.Context) (*[]*AccountDiff, error) {
+	var diff stateDiffJSON
+	if ok, err := t.result(ctx, prestateTracerName, stateDiffConfig, &diff); !ok || err != nil {
+		return nil, err
+	}
+	return newAccountDiffs(&diff), nil
+}
+
+func (t *TransactionTrace) Error(ctx context.Context) (*string, error) {
+	trace, err := t.block.trace(ctx, callTracerName, nil)
+	if err != nil {
+		return nil, err
+	}
+	if t.index >= len(trace.errors) || trace.errors[t.index] == "" {
+		return nil, nil
+	}
+	return &trace.errors[t.index], nil
+}
@@ -0,0 +1,344 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+	gqlErrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+const (
+	// wsSubprotocol is the WebSocket subprotocol of GraphQL over WebSocket.
+	// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+	wsSubprotocol = "graphql-transport-ws"
+
+	wsInitTimeout   = 10 * time.Second // Time for clients to initialise connections
+	wsWriteTimeout  = 10 * time.Second // Time for writing a message to a client
+	wsReadLimit     = 1024 * 1024      // Maximum size of client messages
+	wsSubscriptions = 100              // Maximum number of operations per connection
+)
+
+// Close codes of the graphql-transport-ws protocol.
+const (
+	wsCloseBadRequest    = 4400
+	wsCloseUnauthorized  = 4401
+	wsCloseInitTimeout   = 4408
+	wsCloseSubscriberDup = 4409
+	wsCloseTooManyInits  = 4429
+)
+
+// wsMessage is a message of the graphql-transport-ws protocol.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsSubscribePayload is the payload of subscribe messages.
+type wsSubscribePayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// wsHandler serves GraphQL operations, including subscriptions, over WebSocket
+// connections.
+type wsHandler struct {
+	handler  *handler
+	upgrader websocket.Upgrader
+}
+
+// newWSHandler creates a WebSocket handler accepting connections from browsers
+// of the given origins.
+func newWSHandler(handler *handler, origins []string) *wsHandler {
+	return &wsHandler{
+		handler: handler,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{wsSubprotocol},
+			CheckOrigin:  wsOriginChecker(origins),
+		},
+	}
+}
+
+// wsOriginChecker returns a function checking the origin of WebSocket requests
+// against the allowed origins. Only localhost is allowed if none are configured.
+func wsOriginChecker(origins []string) func(r *http.Request) bool {
+	if len(origins) == 0 {
+		origins = []string{"http://localhost"}
+	}
+	return func(r *http.Request) bool {
+		// Requests without origin are not from browsers
+		origin := strings.ToLower(r.Header.Get("Origin"))
+		if origin == "" || slices.Contains(origins, "*") || slices.ContainsFunc(origins, func(allowed string) bool {
+			return strings.ToLower(allowed) == origin
+		}) {
+			return true
+		}
+		log.Warn("Rejected GraphQL WebSocket connection", "origin", origin)
+		return false
+	}
+}
+
+// isWebsocket checks whether a request asks for upgrading to a WebSocket.
+func isWebsocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL WebSocket upgrade failed", "err", err)
+		return
+	}
+	c := &wsConn{
+		conn:    conn,
+		handler: h.handler,
+		ops:     make(map[string]context.CancelFunc),
+	}
+	c.serve()
+}
+
+// wsConn is a GraphQL WebSocket connection.
+type wsConn struct {
+	conn    *websocket.Conn
+	handler *handler
+
+	writeLock sync.Mutex
+
+	lock  sync.Mutex
+	acked bool
+	ops   map[string]context.CancelFunc // Running operations by id
+	wg    sync.WaitGroup
+}
+
+// serve handles the messages of the connection until it is closed.
+func (c *wsConn) serve() {
+	defer c.conn.Close()
+
+	if c.conn.Subprotocol() != wsSubprotocol {
+		c.close(websocket.CloseProtocolError, "Subprotocol not acceptable")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		c.wg.Wait()
+	}()
+	c.conn.SetReadLimit(wsReadLimit)
+
+	// Close the connection if it isn't initialised in time
+	initTimer := time.AfterFunc(wsInitTimeout, func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if !c.acked {
+			c.close(wsCloseInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				c.close(wsCloseBadRequest, "Invalid message received")
+			}
+			return
+		}
+		switch msg.Type {
+		case "connection_init":
+			c.lock.Lock()
+			acked := c.acked
+			c.acked = true
+			c.lock.Unlock()
+			if acked {
+				c.close(wsCloseTooManyInits, "Too many initialisation requests")
+				return
+			}
+			c.send(&wsMessage{Type: "connection_ack"})
+
+		case "ping":
+			c.send(&wsMessage{Type: "pong"})
+
+		case "pong":
+
+		case "subscribe":
+			if err := c.subscribe(ctx, &msg); err != nil {
+				return
+			}
+
+		case "complete":
+			c.lock.Lock()
+			if cancel, ok := c.ops[msg.ID]; ok {
+				cancel()
+				delete(c.ops, msg.ID)
+			}
+			c.lock.Unlock()
+
+		default:
+			c.close(wsCloseBadRequest, fmt.Sprintf("Invalid message type %q", msg.Type))
+			return
+		}
+	}
+}
+
+// subscribe starts executing an operation. An error is returned if the
+// connection was closed due to the request.
+func (c *wsConn) subscribe(ctx context.Context, msg *wsMessage) error {
+	var payload wsSubscribePayload
+	if msg.ID == "" || json.Unmarshal(msg.Payload, &payload) != nil {
+		return c.close(wsCloseBadRequest, "Invalid subscribe message")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch {
+	case !c.acked:
+		return c.close(wsCloseUnauthorized, "Unauthorized")
+	case c.ops[msg.ID] != nil:
+		return c.close(wsCloseSubscriberDup, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+	case len(c.ops) >= wsSubscriptions:
+		return c.close(wsCloseBadRequest, "Too many operations")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.ops[msg.ID] = cancel
+	c.wg.Add(1)
+	go c.execute(ctx, msg.ID, &payload)
+	return nil
+}
+
+// execute runs an operation and delivers its responses.
+func (c *wsConn) execute(ctx context.Context, id string, payload *wsSubscribePayload) {
+	defer c.wg.Done()
+
+	kind, errs := c.handler.check(payload.Query, payload.OperationName, payload.Variables)
+	if errs != nil {
+		rejectedQueryMeter.Mark(1)
+		if c.release(id) {
+			payload, _ := json.Marshal(errs)
+			c.send(&wsMessage{ID: id, Type: "error", Payload: payload})
+		}
+		return
+	}
+	// Queries are limited as a whole, subscriptions by the schema for each event
+	if kind != "subscription" && c.handler.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.handler.timeout)
+		defer cancel()
+	}
+	responses, err := c.handler.Schema.Subscribe(ctx, payload.Query, payload.OperationName, payload.Variables)
+	if err != nil {
+		if c.release(id) {
+			c.sendErrors(id, err)
+		}
+		return
+	}
+	c.run(ctx, id, responses)
+}
+
+// run delivers the responses of an operation.
+func (c *wsConn) run(ctx context.Context, id string, responses <-chan interface{}) {
+	first := true
+	for res := range responses {
+		if ctx.Err() != nil {
+			continue // drain the responses of cancelled operations
+		}
+		res := res.(*graphql.Response)
+		if first && res.Data == nil && len(res.Errors) > 0 {
+			// The operation failed before execution
+			c.abort(id, res.Errors, responses)
+			return
+		}
+		first = false
+		payload, _ := json.Marshal(res)
+		c.send(&wsMessage{ID: id, Type: "next", Payload: payload})
+	}
+	// Completions are only sent for operations not completed by the client
+	if c.release(id) {
+		c.send(&wsMessage{ID: id, Type: "complete"})
+	}
+}
+
+// abort ends a running operation with an error, draining its remaining responses.
+func (c *wsConn) abort(id string, errs []*gqlErrors.QueryError, responses <-chan interface{}) {
+	if c.release(id) {
+		payload, _ := json.Marshal(errs)
+		c.send(&wsMessage{ID: id, Type: "error", Payload: payload})
+	}
+	for range responses {
+	}
+}
+
+// release cancels and removes a running operation, reporting whether it was
+// still running, i.e. not completed by the client or the connection.
+func (c *wsConn) release(id string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cancel, ok := c.ops[id]
+	if ok {
+		cancel()
+		delete(c.ops, id)
+	}
+	return ok
+}
+
+// sendErrors sends an error message for an operation.
+func (c *wsConn) sendErrors(id string, err error) {
+	payload, _ := json.Marshal([]map[string]string{{"message": err.Error()}})
+	c.send(&wsMessage{ID: id, Type: "error", Payload: payload})
+}
+
+// send writes a message to the connection.
+func (c *wsConn) send(msg *wsMessage) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	blob, err := json.Marshal(msg)
+	if err != nil {
+		log.Error("Failed to encode GraphQL WebSocket message", "err", err)
+		return
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteMessage(websocket.TextMessage, blob); err != nil {
+		log.Debug("Failed to write GraphQL WebSocket message", "err", err)
+		c.conn.Close()
+	}
+}
+
+// close terminates the connection with the given close code and reason. It
+// always returns an error, for convenience of callers aborting the connection.
+func (c *wsConn) close(code int, reason string) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	deadline := time.Now().Add(wsWriteTimeout)
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.conn.Close()
+	return fmt.Errorf("connection closed: %s", reason)
+}
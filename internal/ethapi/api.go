@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"strings"
 	"time"
 
@@ -47,6 +48,7 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
 	"github.com/ethereum/go-ethereum/trie"
 	"github.com/holiman/uint256"
 	"github.com/tyler-smith/go-bip39"
@@ -89,22 +91,13 @@ func (api *EthereumAPI) MaxPriorityFeePerGas(ctx context.Context) (*hexutil.Big,
 	return (*hexutil.Big)(tipcap), err
 }
 
-type feeHistoryResult struct {
-	OldestBlock      *hexutil.Big     `json:"oldestBlock"`
-	Reward           [][]*hexutil.Big `json:"reward,omitempty"`
-	BaseFee          []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
-	GasUsedRatio     []float64        `json:"gasUsedRatio"`
-	BlobBaseFee      []*hexutil.Big   `json:"baseFeePerBlobGas,omitempty"`
-	BlobGasUsedRatio []float64        `json:"blobGasUsedRatio,omitempty"`
-}
-
 // FeeHistory returns the fee market history.
-func (api *EthereumAPI) FeeHistory(ctx context.Context, blockCount math.HexOrDecimal64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
-	oldest, reward, baseFee, gasUsed, blobBaseFee, blobGasUsed, err := api.b.FeeHistory(ctx, uint64(blockCount), lastBlock, rewardPercentiles)
+func (api *EthereumAPI) FeeHistory(ctx context.Context, blockCount math.HexOrDecimal64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*rpctypes.FeeHistoryResult, error) {
+	oldest, reward, baseFee, gasUsed, blobBaseFee, blobGasUsed, blobReward, err := api.b.FeeHistory(ctx, uint64(blockCount), lastBlock, rewardPercentiles)
 	if err != nil {
 		return nil, err
 	}
-	results := &feeHistoryResult{
+	results := &rpctypes.FeeHistoryResult{
 		OldestBlock:  (*hexutil.Big)(oldest),
 		GasUsedRatio: gasUsed,
 	}
@@ -132,6 +125,19 @@ func (api *EthereumAPI) FeeHistory(ctx context.Context, blockCount math.HexOrDec
 	if blobGasUsed != nil {
 		results.BlobGasUsedRatio = blobGasUsed
 	}
+	// Blob rewards are omitted if the range is before Cancun altogether
+	if slices.ContainsFunc(blobReward, func(w []*big.Int) bool { return w != nil }) {
+		results.BlobReward = make([][]*hexutil.Big, len(blobReward))
+		for i, w := range blobReward {
+			if w == nil {
+				continue
+			}
+			results.BlobReward[i] = make([]*hexutil.Big, len(w))
+			for j, v := range w {
+				results.BlobReward[i][j] = (*hexutil.Big)(v)
+			}
+		}
+	}
 	return results, nil
 }
 
@@ -140,6 +146,28 @@ func (api *EthereumAPI) BlobBaseFee(ctx context.Context) *hexutil.Big {
 	return (*hexutil.Big)(api.b.BlobBaseFee(ctx))
 }
 
+// FeePrediction predicts the base fee and the blob base fee of the given number
+// of blocks following the head from the pending transactions and the fullness of
+// the recent blocks. Each fee is predicted along with a confidence band.
+func (api *EthereumAPI) FeePrediction(ctx context.Context, blockCount math.HexOrDecimal64) (*rpctypes.FeePredictionResult, error) {
+	return api.b.FeePrediction(ctx, uint64(blockCount))
+}
+
+// SuggestBlobFee returns a maxFeePerBlobGas for blob transactions to remain
+// includable over the given number of blocks, predicted from the trend of the
+// blob base fee. If no horizon is given, a default of a few blocks is used.
+func (api *EthereumAPI) SuggestBlobFee(ctx context.Context, horizon *hexutil.Uint64) (*hexutil.Big, error) {
+	var blocks uint64
+	if horizon != nil {
+		blocks = uint64(*horizon)
+	}
+	fee, err := api.b.SuggestBlobFeeCap(ctx, blocks)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(fee), nil
+}
+
 // Syncing returns false in case the node is currently not syncing with the network. It can be up-to-date or has not
 // yet received the latest block headers from its peers. In case it is synchronizing:
 // - startingBlock: block number this node started to synchronize from
@@ -237,11 +265,11 @@ func (api *TxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RP
 }
 
 // Status returns the number of pending and queued transaction in the pool.
-func (api *TxPoolAPI) Status() map[string]hexutil.Uint {
+func (api *TxPoolAPI) Status() *rpctypes.TxPoolStatus {
 	pending, queue := api.b.Stats()
-	return map[string]hexutil.Uint{
-		"pending": hexutil.Uint(pending),
-		"queued":  hexutil.Uint(queue),
+	return &rpctypes.TxPoolStatus{
+		Pending: hexutil.Uint(pending),
+		Queued:  hexutil.Uint(queue),
 	}
 }
 
@@ -660,34 +688,39 @@ func (api *BlockChainAPI) BlockNumber() hexutil.Uint64 {
 	return hexutil.Uint64(header.Number.Uint64())
 }
 
+// stateAndHeaderByNumberOrHash retrieves the state and header referenced by the
+// given block number or hash. If the reference is scoped to a transaction index,
+// the state right before that transaction is regenerated. The returned release
+// function must be called once the state is no longer needed.
+func stateAndHeaderByNumberOrHash(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, func(), error) {
+	if txIndex, ok := blockNrOrHash.TxIndex(); ok {
+		return b.StateAndHeaderAtTransaction(ctx, blockNrOrHash, txIndex)
+	}
+	statedb, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, nil, nil, err
+	}
+	return statedb, header, func() {}, nil
+}
+
 // GetBalance returns the amount of wei for the given address in the state of the
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
 func (api *BlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
-	state, _, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	state, _, release, err := stateAndHeaderByNumberOrHash(ctx, api.b, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	defer release()
+
 	b := state.GetBalance(address).ToBig()
 	return (*hexutil.Big)(b), state.Error()
 }
 
 // AccountResult structs for GetProof
-type AccountResult struct {
-	Address      common.Address  `json:"address"`
-	AccountProof []string        `json:"accountProof"`
-	Balance      *hexutil.Big    `json:"balance"`
-	CodeHash     common.Hash     `json:"codeHash"`
-	Nonce        hexutil.Uint64  `json:"nonce"`
-	StorageHash  common.Hash     `json:"storageHash"`
-	StorageProof []StorageResult `json:"storageProof"`
-}
+type AccountResult = rpctypes.AccountResult
 
-type StorageResult struct {
-	Key   string       `json:"key"`
-	Value *hexutil.Big `json:"value"`
-	Proof []string     `json:"proof"`
-}
+type StorageResult = rpctypes.StorageResult
 
 // proofList implements ethdb.KeyValueWriter and collects the proofs as
 // hex-strings for delivery to rpc-caller.
@@ -717,6 +750,10 @@ func (api *BlockChainAPI) GetProof(ctx context.Context, address common.Address,
 			return nil, err
 		}
 	}
+	// Proofs are only available against the committed state of a block.
+	if _, ok := blockNrOrHash.TxIndex(); ok {
+		return nil, errors.New("proofs are not available for transaction-scoped state")
+	}
 	statedb, header, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if statedb == nil || err != nil {
 		return nil, err
@@ -747,7 +784,7 @@ func (api *BlockChainAPI) GetProof(ctx context.Context, address common.Address,
 				outputKey = hexutil.Encode(key[:])
 			}
 			if storageTrie == nil {
-				storageProof[i] = StorageResult{outputKey, &hexutil.Big{}, []string{}}
+				storageProof[i] = StorageResult{Key: outputKey, Value: &hexutil.Big{}, Proof: []string{}}
 				continue
 			}
 			var proof proofList
@@ -755,7 +792,7 @@ func (api *BlockChainAPI) GetProof(ctx context.Context, address common.Address,
 				return nil, err
 			}
 			value := (*hexutil.Big)(statedb.GetState(address, key).Big())
-			storageProof[i] = StorageResult{outputKey, value, proof}
+			storageProof[i] = StorageResult{Key: outputKey, Value: value, Proof: proof}
 		}
 	}
 	// Create the accountProof.
@@ -834,7 +871,19 @@ func (api *BlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.Hash)
 //   - When blockNr is -4 the chain safe block is returned.
 //   - When fullTx is true all transactions in the block are returned, otherwise
 //     only the transaction hash is returned.
-func (api *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+func (api *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (interface{}, error) {
+	// Serve finalized blocks from the response cache if possible. Only explicit
+	// block numbers are cacheable, tags move along with the chain.
+	var (
+		cache = api.b.ResponseCache()
+		key   common.Hash
+	)
+	if cache != nil && number >= 0 {
+		key = ResponseCacheKey("eth_getBlockByNumber", uint64(number), fullTx)
+		if cached, ok := cache.Get(key); ok {
+			return cached, nil
+		}
+	}
 	block, err := api.b.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
 		response, err := api.rpcMarshalBlock(ctx, block, true, fullTx)
@@ -844,6 +893,9 @@ func (api *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.Block
 				response[field] = nil
 			}
 		}
+		if err == nil && key != (common.Hash{}) && cache.Immutable(ctx, api.b, block.NumberU64(), common.Hash{}) {
+			cache.Put(key, response)
+		}
 		return response, err
 	}
 	return nil, err
@@ -851,10 +903,24 @@ func (api *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.Block
 
 // GetBlockByHash returns the requested block. When fullTx is true all transactions in the block are returned in full
 // detail, otherwise only the transaction hash is returned.
-func (api *BlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+func (api *BlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (interface{}, error) {
+	var (
+		cache = api.b.ResponseCache()
+		key   common.Hash
+	)
+	if cache != nil {
+		key = ResponseCacheKey("eth_getBlockByHash", hash, fullTx)
+		if cached, ok := cache.Get(key); ok {
+			return cached, nil
+		}
+	}
 	block, err := api.b.BlockByHash(ctx, hash)
 	if block != nil {
-		return api.rpcMarshalBlock(ctx, block, true, fullTx)
+		response, err := api.rpcMarshalBlock(ctx, block, true, fullTx)
+		if err == nil && cache.Immutable(ctx, api.b, block.NumberU64(), hash) {
+			cache.Put(key, response)
+		}
+		return response, err
 	}
 	return nil, err
 }
@@ -909,10 +975,12 @@ func (api *BlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, blockHas
 
 // GetCode returns the code stored at the given address in the state for the given block number.
 func (api *BlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
-	state, _, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	state, _, release, err := stateAndHeaderByNumberOrHash(ctx, api.b, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	defer release()
+
 	code := state.GetCode(address)
 	return code, state.Error()
 }
@@ -921,10 +989,12 @@ func (api *BlockChainAPI) GetCode(ctx context.Context, address common.Address, b
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
 func (api *BlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, hexKey string, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
-	state, _, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	state, _, release, err := stateAndHeaderByNumberOrHash(ctx, api.b, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	defer release()
+
 	key, _, err := decodeHash(hexKey)
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode storage key: %s", err)
@@ -934,7 +1004,21 @@ func (api *BlockChainAPI) GetStorageAt(ctx context.Context, address common.Addre
 }
 
 // GetBlockReceipts returns the block receipts for the given block hash or number or tag.
-func (api *BlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+func (api *BlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (interface{}, error) {
+	var (
+		cache = api.b.ResponseCache()
+		key   common.Hash
+	)
+	if cache != nil {
+		if hash, ok := blockNrOrHash.Hash(); ok {
+			key = ResponseCacheKey("eth_getBlockReceipts", hash)
+		} else if number, ok := blockNrOrHash.Number(); ok && number >= 0 {
+			key = ResponseCacheKey("eth_getBlockReceipts", uint64(number))
+		}
+		if cached, ok := cache.Get(key); ok {
+			return cached, nil
+		}
+	}
 	block, err := api.b.BlockByNumberOrHash(ctx, blockNrOrHash)
 	if block == nil || err != nil {
 		// When the block doesn't exist, the RPC method should return JSON null
@@ -955,9 +1039,11 @@ func (api *BlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rp
 
 	result := make([]map[string]interface{}, len(receipts))
 	for i, receipt := range receipts {
-		result[i] = marshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], i)
+		result[i] = MarshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], i)
+	}
+	if key != (common.Hash{}) && cache.Immutable(ctx, api.b, block.NumberU64(), block.Hash()) {
+		cache.Put(key, result)
 	}
-
 	return result, nil
 }
 
@@ -1114,11 +1200,17 @@ func doCall(ctx context.Context, b Backend, args TransactionArgs, state *state.S
 	if blockOverrides != nil {
 		blockOverrides.Apply(&blockCtx)
 	}
+	return applyMessage(ctx, b, args, state, header, &blockCtx, timeout, globalGasCap)
+}
+
+// applyMessage executes the call described by args in the given block context on
+// top of state. The EVM is aborted once ctx is cancelled.
+func applyMessage(ctx context.Context, b Backend, args TransactionArgs, state *state.StateDB, header *types.Header, blockCtx *vm.BlockContext, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
 	if err := args.CallDefaults(globalGasCap, blockCtx.BaseFee, b.ChainConfig().ChainID); err != nil {
 		return nil, err
 	}
 	msg := args.ToMessage(blockCtx.BaseFee)
-	evm := b.GetEVM(ctx, msg, state, header, &vm.Config{NoBaseFee: true}, &blockCtx)
+	evm := b.GetEVM(ctx, msg, state, header, &vm.Config{NoBaseFee: true}, blockCtx)
 
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
@@ -1147,10 +1239,11 @@ func doCall(ctx context.Context, b Backend, args TransactionArgs, state *state.S
 func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	state, header, release, err := stateAndHeaderByNumberOrHash(ctx, b, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	defer release()
 
 	return doCall(ctx, b, args, state, header, overrides, blockOverrides, timeout, globalGasCap)
 }
@@ -1172,47 +1265,62 @@ func (api *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockN
 	}
 	// If the result contains a revert reason, try to unpack and return it.
 	if len(result.Revert()) > 0 {
-		return nil, newRevertError(result.Revert())
+		return nil, newRevertError(result.Revert(), api.b.ErrorRegistry())
 	}
 	return result.Return(), result.Err
 }
 
-// DoEstimateGas returns the lowest possible gas limit that allows the transaction to run
-// successfully at block `blockNrOrHash`. It returns error if the transaction would revert, or if
-// there are unexpected failures. The gas limit is capped by both `args.Gas` (if non-nil &
-// non-zero) and `gasCap` (if non-zero).
-func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, gasCap uint64) (hexutil.Uint64, error) {
-	// Retrieve the base state and mutate it with any overrides
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
-	if state == nil || err != nil {
-		return 0, err
-	}
-	if err = overrides.Apply(state); err != nil {
-		return 0, err
+// estimateGasCall constructs the gas estimator options and the call to estimate
+// from the user input, on top of the given state with any overrides applied.
+func estimateGasCall(ctx context.Context, b Backend, args TransactionArgs, state *state.StateDB, header *types.Header, overrides *StateOverride, blockOverrides *BlockOverrides, gasCap uint64) (*gasestimator.Options, *core.Message, error) {
+	if err := overrides.Apply(state); err != nil {
+		return nil, nil, err
 	}
 	// Construct the gas estimator option from the user input
 	opts := &gasestimator.Options{
-		Config:     b.ChainConfig(),
-		Chain:      NewChainContext(ctx, b),
-		Header:     header,
-		State:      state,
-		ErrorRatio: estimateGasErrorRatio,
+		Config:         b.ChainConfig(),
+		Chain:          NewChainContext(ctx, b),
+		Header:         header,
+		State:          state,
+		BlockOverrides: blockOverrides.Apply,
+		ErrorRatio:     estimateGasErrorRatio,
+	}
+	baseFee := header.BaseFee
+	if blockOverrides != nil && blockOverrides.BaseFee != nil {
+		baseFee = blockOverrides.BaseFee.ToInt()
 	}
 	// Set any required transaction default, but make sure the gas cap itself is not messed with
 	// if it was not specified in the original argument list.
 	if args.Gas == nil {
 		args.Gas = new(hexutil.Uint64)
 	}
-	if err := args.CallDefaults(gasCap, header.BaseFee, b.ChainConfig().ChainID); err != nil {
+	if err := args.CallDefaults(gasCap, baseFee, b.ChainConfig().ChainID); err != nil {
+		return nil, nil, err
+	}
+	return opts, args.ToMessage(baseFee), nil
+}
+
+// DoEstimateGas returns the lowest possible gas limit that allows the transaction to run
+// successfully at block `blockNrOrHash`. It returns error if the transaction would revert, or if
+// there are unexpected failures. The gas limit is capped by both `args.Gas` (if non-nil &
+// non-zero) and `gasCap` (if non-zero).
+func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, gasCap uint64) (hexutil.Uint64, error) {
+	// Retrieve the base state and mutate it with any overrides
+	state, header, release, err := stateAndHeaderByNumberOrHash(ctx, b, blockNrOrHash)
+	if state == nil || err != nil {
 		return 0, err
 	}
-	call := args.ToMessage(header.BaseFee)
+	defer release()
 
+	opts, call, err := estimateGasCall(ctx, b, args, state, header, overrides, blockOverrides, gasCap)
+	if err != nil {
+		return 0, err
+	}
 	// Run the gas estimation and wrap any revertals into a custom return
 	estimate, revert, err := gasestimator.Estimate(ctx, call, opts, gasCap)
 	if err != nil {
 		if len(revert) > 0 {
-			return 0, newRevertError(revert)
+			return 0, newRevertError(revert, b.ErrorRegistry())
 		}
 		return 0, err
 	}
@@ -1225,12 +1333,148 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 // value is capped by both `args.Gas` (if non-nil & non-zero) and the backend's RPCGasCap
 // configuration (if non-zero).
 // Note: Required blob gas is not computed in this method.
-func (api *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Uint64, error) {
+func (api *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) (hexutil.Uint64, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	return DoEstimateGas(ctx, api.b, args, bNrOrHash, overrides, blockOverrides, api.b.RPCGasCap())
+}
+
+// gasBreakdownConfig are the options of eth_estimateGasBreakdown.
+type gasBreakdownConfig struct {
+	// OptimizeAccessList requests an access list minimizing the gas of the
+	// transaction, which the breakdown is then computed with.
+	OptimizeAccessList bool `json:"optimizeAccessList"`
+}
+
+// gasBreakdownResult is the result of eth_estimateGasBreakdown.
+type gasBreakdownResult struct {
+	Gas           hexutil.Uint64    `json:"gas"`
+	GasUsed       hexutil.Uint64    `json:"gasUsed"`
+	IntrinsicGas  hexutil.Uint64    `json:"intrinsicGas"`
+	CalldataGas   hexutil.Uint64    `json:"calldataGas"`
+	AccessListGas hexutil.Uint64    `json:"accessListGas"`
+	ExecutionGas  hexutil.Uint64    `json:"executionGas"`
+	RefundedGas   hexutil.Uint64    `json:"refundedGas"`
+	BlobGas       hexutil.Uint64    `json:"blobGas"`
+	LimitingFrame *gasFrameResult   `json:"limitingFrame,omitempty"`
+	AccessList    *types.AccessList `json:"accessList,omitempty"`
+}
+
+// gasFrameResult is the call frame determining the gas limit of a transaction.
+type gasFrameResult struct {
+	Depth   int            `json:"depth"`
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// EstimateGasBreakdown estimates the lowest possible gas limit of a transaction
+// like EstimateGas, and breaks the gas down into the intrinsic gas, its calldata
+// and access list components, the execution gas and the refund. It also reports
+// the call frame which would run out of gas first with a lower gas limit.
+//
+// If requested, the access list minimizing the gas of the transaction is created
+// and returned, and the breakdown is computed with it.
+func (api *BlockChainAPI) EstimateGasBreakdown(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, config *gasBreakdownConfig) (*gasBreakdownResult, error) {
 	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
 	if blockNrOrHash != nil {
 		bNrOrHash = *blockNrOrHash
 	}
-	return DoEstimateGas(ctx, api.b, args, bNrOrHash, overrides, api.b.RPCGasCap())
+	state, header, release, err := stateAndHeaderByNumberOrHash(ctx, api.b, bNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// The transaction is executed many times, especially when optimizing the
+	// access list, so limit all executions together by the EVM timeout
+	timeout := api.b.RPCEVMTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	gasCap := api.b.RPCGasCap()
+	opts, call, err := estimateGasCall(ctx, api.b, args, state, header, overrides, blockOverrides, gasCap)
+	if err != nil {
+		return nil, err
+	}
+	var accessList *types.AccessList
+	if config != nil && config.OptimizeAccessList {
+		// Create the access list touched by the transaction, and prune the
+		// entries which don't pay off
+		blockCtx := core.NewEVMBlockContext(header, NewChainContext(ctx, api.b), nil)
+		blockOverrides.Apply(&blockCtx)
+
+		to := crypto.CreateAddress(call.From, state.GetNonce(call.From))
+		if call.To != nil {
+			to = *call.To
+		}
+		// The call has no gas limit unless requested, execute it with the highest
+		// one the estimation allows
+		msg := *call
+		if msg.GasLimit == 0 {
+			msg.GasLimit = blockCtx.GasLimit
+		}
+		if gasCap != 0 && msg.GasLimit > gasCap {
+			msg.GasLimit = gasCap
+		}
+		candidate, _, err := traceAccessList(ctx, api.b, &msg, to, state, header, &blockCtx)
+		if err != nil {
+			return nil, err
+		}
+		acl, _, revert, err := gasestimator.OptimizeAccessList(ctx, call, opts, gasCap, candidate)
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		if err != nil {
+			if len(revert) > 0 {
+				return nil, newRevertError(revert, api.b.ErrorRegistry())
+			}
+			return nil, err
+		}
+		call.AccessList, accessList = acl, &acl
+	}
+	breakdown, revert, err := gasestimator.EstimateBreakdown(ctx, call, opts, gasCap)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+	}
+	if err != nil {
+		if len(revert) > 0 {
+			return nil, newRevertError(revert, api.b.ErrorRegistry())
+		}
+		return nil, err
+	}
+	result := &gasBreakdownResult{
+		Gas:           hexutil.Uint64(breakdown.Gas),
+		GasUsed:       hexutil.Uint64(breakdown.UsedGas),
+		IntrinsicGas:  hexutil.Uint64(breakdown.IntrinsicGas),
+		CalldataGas:   hexutil.Uint64(breakdown.CalldataGas),
+		AccessListGas: hexutil.Uint64(breakdown.AccessListGas),
+		ExecutionGas:  hexutil.Uint64(breakdown.ExecutionGas),
+		RefundedGas:   hexutil.Uint64(breakdown.RefundedGas),
+		BlobGas:       hexutil.Uint64(breakdown.BlobGas),
+		AccessList:    accessList,
+	}
+	if frame := breakdown.LimitingFrame; frame != nil {
+		result.LimitingFrame = &gasFrameResult{
+			Depth:   frame.Depth,
+			Type:    frame.Type.String(),
+			From:    frame.From,
+			To:      frame.To,
+			Gas:     hexutil.Uint64(frame.Gas),
+			GasUsed: hexutil.Uint64(frame.GasUsed),
+		}
+		if frame.Err != nil {
+			result.LimitingFrame.Error = frame.Err.Error()
+		}
+	}
+	return result, nil
 }
 
 // RPCMarshalHeader converts the given header to the RPC output .
@@ -1325,30 +1569,7 @@ func (api *BlockChainAPI) rpcMarshalBlock(ctx context.Context, b *types.Block, i
 }
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
-type RPCTransaction struct {
-	BlockHash           *common.Hash      `json:"blockHash"`
-	BlockNumber         *hexutil.Big      `json:"blockNumber"`
-	From                common.Address    `json:"from"`
-	Gas                 hexutil.Uint64    `json:"gas"`
-	GasPrice            *hexutil.Big      `json:"gasPrice"`
-	GasFeeCap           *hexutil.Big      `json:"maxFeePerGas,omitempty"`
-	GasTipCap           *hexutil.Big      `json:"maxPriorityFeePerGas,omitempty"`
-	MaxFeePerBlobGas    *hexutil.Big      `json:"maxFeePerBlobGas,omitempty"`
-	Hash                common.Hash       `json:"hash"`
-	Input               hexutil.Bytes     `json:"input"`
-	Nonce               hexutil.Uint64    `json:"nonce"`
-	To                  *common.Address   `json:"to"`
-	TransactionIndex    *hexutil.Uint64   `json:"transactionIndex"`
-	Value               *hexutil.Big      `json:"value"`
-	Type                hexutil.Uint64    `json:"type"`
-	Accesses            *types.AccessList `json:"accessList,omitempty"`
-	ChainID             *hexutil.Big      `json:"chainId,omitempty"`
-	BlobVersionedHashes []common.Hash     `json:"blobVersionedHashes,omitempty"`
-	V                   *hexutil.Big      `json:"v"`
-	R                   *hexutil.Big      `json:"r"`
-	S                   *hexutil.Big      `json:"s"`
-	YParity             *hexutil.Uint64   `json:"yParity,omitempty"`
-}
+type RPCTransaction = rpctypes.RPCTransaction
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
 // representation, with the given location metadata set (if available).
@@ -1472,18 +1693,9 @@ func newRPCRawTransactionFromBlockIndex(b *types.Block, index uint64) hexutil.By
 	return blob
 }
 
-// accessListResult returns an optional accesslist
-// It's the result of the `debug_createAccessList` RPC call.
-// It contains an error if the transaction itself failed.
-type accessListResult struct {
-	Accesslist *types.AccessList `json:"accessList"`
-	Error      string            `json:"error,omitempty"`
-	GasUsed    hexutil.Uint64    `json:"gasUsed"`
-}
-
 // CreateAccessList creates an EIP-2930 type AccessList for the given transaction.
 // Reexec and BlockNrOrHash can be specified to create the accessList on top of a certain state.
-func (api *BlockChainAPI) CreateAccessList(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*accessListResult, error) {
+func (api *BlockChainAPI) CreateAccessList(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*rpctypes.AccessListResult, error) {
 	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
 	if blockNrOrHash != nil {
 		bNrOrHash = *blockNrOrHash
@@ -1492,7 +1704,7 @@ func (api *BlockChainAPI) CreateAccessList(ctx context.Context, args Transaction
 	if err != nil {
 		return nil, err
 	}
-	result := &accessListResult{Accesslist: &acl, GasUsed: hexutil.Uint64(gasUsed)}
+	result := &rpctypes.AccessListResult{Accesslist: &acl, GasUsed: hexutil.Uint64(gasUsed)}
 	if vmerr != nil {
 		result.Error = vmerr.Error()
 	}
@@ -1504,10 +1716,11 @@ func (api *BlockChainAPI) CreateAccessList(ctx context.Context, args Transaction
 // If the transaction itself fails, an vmErr is returned.
 func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash, args TransactionArgs) (acl types.AccessList, gasUsed uint64, vmErr error, err error) {
 	// Retrieve the execution context
-	db, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	db, header, release, err := stateAndHeaderByNumberOrHash(ctx, b, blockNrOrHash)
 	if db == nil || err != nil {
 		return nil, 0, nil, err
 	}
+	defer release()
 
 	// Ensure any missing fields are filled, extract the recipient and input data
 	if err := args.setDefaults(ctx, b, true); err != nil {
@@ -1519,18 +1732,32 @@ func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrH
 	} else {
 		to = crypto.CreateAddress(args.from(), uint64(*args.Nonce))
 	}
-	isPostMerge := header.Difficulty.Sign() == 0
+	blockCtx := core.NewEVMBlockContext(header, NewChainContext(ctx, b), nil)
+	acl, res, err := traceAccessList(ctx, b, args.ToMessage(header.BaseFee), to, db, header, &blockCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, nil, err
+		}
+		return nil, 0, nil, fmt.Errorf("failed to apply transaction: %v err: %v", args.ToTransaction().Hash(), err)
+	}
+	return acl, res.UsedGas, res.Err, nil
+}
+
+// traceAccessList creates an access list for the message by executing it on top
+// of db repeatedly, starting from the access list of the message, until the
+// accessed accounts and slots converge. The result of the last execution is
+// returned along with the access list.
+func traceAccessList(ctx context.Context, b Backend, msg *core.Message, to common.Address, db *state.StateDB, header *types.Header, blockCtx *vm.BlockContext) (types.AccessList, *core.ExecutionResult, error) {
 	// Retrieve the precompiles since they don't need to be added to the access list
-	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(header.Number, isPostMerge, header.Time))
+	isPostMerge := blockCtx.Random != nil
+	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(blockCtx.BlockNumber, isPostMerge, blockCtx.Time))
 
-	// Create an initial tracer
-	prevTracer := logger.NewAccessListTracer(nil, args.from(), to, precompiles)
-	if args.AccessList != nil {
-		prevTracer = logger.NewAccessListTracer(*args.AccessList, args.from(), to, precompiles)
-	}
+	// Create an initial tracer, restoring the access list of the message after
+	defer func(accessList types.AccessList) { msg.AccessList = accessList }(msg.AccessList)
+	prevTracer := logger.NewAccessListTracer(msg.AccessList, msg.From, to, precompiles)
 	for {
 		if err := ctx.Err(); err != nil {
-			return nil, 0, nil, err
+			return nil, nil, err
 		}
 		// Retrieve the current access list to expand
 		accessList := prevTracer.AccessList()
@@ -1539,19 +1766,18 @@ func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrH
 		// Copy the original db so we don't modify it
 		statedb := db.Copy()
 		// Set the accesslist to the last al
-		args.AccessList = &accessList
-		msg := args.ToMessage(header.BaseFee)
+		msg.AccessList = accessList
 
 		// Apply the transaction with the access list tracer
-		tracer := logger.NewAccessListTracer(accessList, args.from(), to, precompiles)
+		tracer := logger.NewAccessListTracer(accessList, msg.From, to, precompiles)
 		config := vm.Config{Tracer: tracer.Hooks(), NoBaseFee: true}
-		vmenv := b.GetEVM(ctx, msg, statedb, header, &config, nil)
+		vmenv := b.GetEVM(ctx, msg, statedb, header, &config, blockCtx)
 		res, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.GasLimit))
 		if err != nil {
-			return nil, 0, nil, fmt.Errorf("failed to apply transaction: %v err: %v", args.ToTransaction().Hash(), err)
+			return nil, nil, err
 		}
 		if tracer.Equal(prevTracer) {
-			return accessList, res.UsedGas, res.Err, nil
+			return accessList, res, nil
 		}
 		prevTracer = tracer
 	}
@@ -1625,7 +1851,7 @@ func (api *TransactionAPI) GetRawTransactionByBlockHashAndIndex(ctx context.Cont
 // GetTransactionCount returns the number of transactions the given address has sent for the given block number
 func (api *TransactionAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
 	// Ask transaction pool for the nonce which includes pending transactions
-	if blockNr, ok := blockNrOrHash.Number(); ok && blockNr == rpc.PendingBlockNumber {
+	if blockNr, ok := blockNrOrHash.Number(); ok && blockNr == rpc.PendingBlockNumber && blockNrOrHash.TransactionIndex == nil {
 		nonce, err := api.b.GetPoolNonce(ctx, address)
 		if err != nil {
 			return nil, err
@@ -1633,10 +1859,12 @@ func (api *TransactionAPI) GetTransactionCount(ctx context.Context, address comm
 		return (*hexutil.Uint64)(&nonce), nil
 	}
 	// Resolve block number and use its state to ask for the nonce
-	state, _, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	state, _, release, err := stateAndHeaderByNumberOrHash(ctx, api.b, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	defer release()
+
 	nonce := state.GetNonce(address)
 	return (*hexutil.Uint64)(&nonce), state.Error()
 }
@@ -1679,7 +1907,17 @@ func (api *TransactionAPI) GetRawTransactionByHash(ctx context.Context, hash com
 }
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
-func (api *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+func (api *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (interface{}, error) {
+	var (
+		cache = api.b.ResponseCache()
+		key   common.Hash
+	)
+	if cache != nil {
+		key = ResponseCacheKey("eth_getTransactionReceipt", hash)
+		if cached, ok := cache.Get(key); ok {
+			return cached, nil
+		}
+	}
 	found, tx, blockHash, blockNumber, index, err := api.b.GetTransaction(ctx, hash)
 	if err != nil {
 		return nil, NewTxIndexingError() // transaction is not fully indexed
@@ -1702,11 +1940,15 @@ func (api *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash commo
 
 	// Derive the sender.
 	signer := types.MakeSigner(api.b.ChainConfig(), header.Number, header.Time)
-	return marshalReceipt(receipt, blockHash, blockNumber, signer, tx, int(index)), nil
+	response := MarshalReceipt(receipt, blockHash, blockNumber, signer, tx, int(index))
+	if cache.Immutable(ctx, api.b, blockNumber, blockHash) {
+		cache.Put(key, response)
+	}
+	return response, nil
 }
 
-// marshalReceipt marshals a transaction receipt into a JSON object.
-func marshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, txIndex int) map[string]interface{} {
+// MarshalReceipt marshals a transaction receipt into a JSON object.
+func MarshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, txIndex int) map[string]interface{} {
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
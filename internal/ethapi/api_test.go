@@ -37,14 +37,17 @@ import (
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/logindex"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -56,6 +59,7 @@ import (
 	"github.com/ethereum/go-ethereum/internal/blocktest"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
 )
 
 func testTransactionMarshal(t *testing.T, tests []txData, config *params.ChainConfig) {
@@ -432,6 +436,7 @@ type testBackend struct {
 	pending *types.Block
 	accman  *accounts.Manager
 	acc     accounts.Account
+	errors  *abi.ErrorRegistry
 }
 
 func newTestBackend(t *testing.T, n int, gspec *core.Genesis, engine consensus.Engine, generator func(i int, b *core.BlockGen)) *testBackend {
@@ -469,18 +474,30 @@ func (b testBackend) SyncProgress() ethereum.SyncProgress { return ethereum.Sync
 func (b testBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
 	return big.NewInt(0), nil
 }
-func (b testBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, []*big.Int, []float64, error) {
-	return nil, nil, nil, nil, nil, nil, nil
+func (b testBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, []*big.Int, []float64, [][]*big.Int, error) {
+	return nil, nil, nil, nil, nil, nil, nil, nil
 }
 func (b testBackend) BlobBaseFee(ctx context.Context) *big.Int { return new(big.Int) }
-func (b testBackend) ChainDb() ethdb.Database                  { return b.db }
-func (b testBackend) AccountManager() *accounts.Manager        { return b.accman }
-func (b testBackend) ExtRPCEnabled() bool                      { return false }
-func (b testBackend) RPCGasCap() uint64                        { return 10000000 }
-func (b testBackend) RPCEVMTimeout() time.Duration             { return time.Second }
-func (b testBackend) RPCTxFeeCap() float64                     { return 0 }
-func (b testBackend) UnprotectedAllowed() bool                 { return false }
-func (b testBackend) SetHead(number uint64)                    {}
+func (b testBackend) FeePrediction(ctx context.Context, blocks uint64) (*rpctypes.FeePredictionResult, error) {
+	return nil, nil
+}
+func (b testBackend) SuggestBlobFeeCap(ctx context.Context, horizon uint64) (*big.Int, error) {
+	head := b.chain.CurrentBlock()
+	if head.ExcessBlobGas == nil {
+		return big.NewInt(params.BlobTxMinBlobGasprice), nil
+	}
+	return eip4844.CalcBlobFee(eip4844.CalcExcessBlobGas(*head.ExcessBlobGas, *head.BlobGasUsed)), nil
+}
+func (b testBackend) ChainDb() ethdb.Database           { return b.db }
+func (b testBackend) AccountManager() *accounts.Manager { return b.accman }
+func (b testBackend) ExtRPCEnabled() bool               { return false }
+func (b testBackend) RPCGasCap() uint64                 { return 10000000 }
+func (b testBackend) RPCEVMTimeout() time.Duration      { return time.Second }
+func (b testBackend) RPCTxFeeCap() float64              { return 0 }
+func (b testBackend) UnprotectedAllowed() bool          { return false }
+func (b testBackend) ResponseCache() *ResponseCache     { return nil }
+func (b testBackend) ErrorRegistry() *abi.ErrorRegistry { return b.errors }
+func (b testBackend) SetHead(number uint64)             {}
 func (b testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
 	if number == rpc.LatestBlockNumber {
 		return b.chain.CurrentBlock(), nil
@@ -549,6 +566,32 @@ func (b testBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOr
 	}
 	panic("only implemented for number")
 }
+func (b testBackend) StateAndHeaderAtTransaction(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, txIndex int) (*state.StateDB, *types.Header, func(), error) {
+	block, err := b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, nil, nil, errors.New("block not found")
+	}
+	if txIndex >= len(block.Transactions()) {
+		return nil, nil, nil, fmt.Errorf("transaction index %d out of range", txIndex)
+	}
+	parent := b.chain.GetHeaderByHash(block.ParentHash())
+	statedb, err := b.chain.StateAt(parent.Root)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	signer := types.MakeSigner(b.chain.Config(), block.Number(), block.Time())
+	for i, tx := range block.Transactions()[:txIndex] {
+		msg, _ := core.TransactionToMessage(tx, signer, block.BaseFee())
+		blockCtx := core.NewEVMBlockContext(block.Header(), b.chain, nil)
+		evm := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, b.chain.Config(), vm.Config{})
+		statedb.SetTxContext(tx.Hash(), i)
+		if _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+			return nil, nil, nil, err
+		}
+		statedb.Finalise(true)
+	}
+	return statedb, block.Header(), func() {}, nil
+}
 func (b testBackend) Pending() (*types.Block, types.Receipts, *state.StateDB) { panic("implement me") }
 func (b testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	header, err := b.HeaderByHash(ctx, hash)
@@ -621,6 +664,7 @@ func (b testBackend) BloomStatus() (uint64, uint64) { panic("implement me") }
 func (b testBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	panic("implement me")
 }
+func (b testBackend) LogIndex() *logindex.Indexer { return nil }
 
 func TestEstimateGas(t *testing.T) {
 	t.Parallel()
@@ -647,11 +691,12 @@ func TestEstimateGas(t *testing.T) {
 		b.SetPoS()
 	}))
 	var testSuite = []struct {
-		blockNumber rpc.BlockNumber
-		call        TransactionArgs
-		overrides   StateOverride
-		expectErr   error
-		want        uint64
+		blockNumber    rpc.BlockNumber
+		call           TransactionArgs
+		overrides      StateOverride
+		blockOverrides *BlockOverrides
+		expectErr      error
+		want           uint64
 	}{
 		// simple transfer on latest block
 		{
@@ -756,9 +801,27 @@ func TestEstimateGas(t *testing.T) {
 			},
 			want: 21000,
 		},
+		// Create reverting below block 100, requiring a block override
+		{
+			blockNumber: rpc.LatestBlockNumber,
+			call: TransactionArgs{
+				From:  &accounts[0].addr,
+				Input: hex2Bytes("60644310600857005b600080fd"),
+			},
+			expectErr: vm.ErrExecutionReverted,
+		},
+		{
+			blockNumber: rpc.LatestBlockNumber,
+			call: TransactionArgs{
+				From:  &accounts[0].addr,
+				Input: hex2Bytes("60644310600857005b600080fd"),
+			},
+			blockOverrides: &BlockOverrides{Number: (*hexutil.Big)(big.NewInt(100))},
+			want:           53207,
+		},
 	}
 	for i, tc := range testSuite {
-		result, err := api.EstimateGas(context.Background(), tc.call, &rpc.BlockNumberOrHash{BlockNumber: &tc.blockNumber}, &tc.overrides)
+		result, err := api.EstimateGas(context.Background(), tc.call, &rpc.BlockNumberOrHash{BlockNumber: &tc.blockNumber}, &tc.overrides, tc.blockOverrides)
 		if tc.expectErr != nil {
 			if err == nil {
 				t.Errorf("test %d: want error %v, have nothing", i, tc.expectErr)
@@ -779,6 +842,123 @@ func TestEstimateGas(t *testing.T) {
 	}
 }
 
+func TestEstimateGasBreakdown(t *testing.T) {
+	t.Parallel()
+	// Initialize test accounts
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.MergedTestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		contract = common.HexToAddress("0xc0de")
+		other    = common.HexToAddress("0x07e4")
+		unused   = common.HexToAddress("0xdead")
+	)
+	api := NewBlockChainAPI(newTestBackend(t, 1, genesis, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) {
+		b.SetPoS()
+	}))
+	// The contract loads its first slot and the balance of another account:
+	// PUSH1 0 SLOAD POP PUSH20 other BALANCE POP STOP
+	code := hexutil.Bytes(append(append(common.FromHex("6000545073"), other.Bytes()...), common.FromHex("315000")...))
+	overrides := StateOverride{contract: OverrideAccount{Code: &code}}
+
+	var testSuite = []struct {
+		call           TransactionArgs
+		blockOverrides *BlockOverrides
+		config         *gasBreakdownConfig
+		want           *gasBreakdownResult
+	}{
+		// simple transfer
+		{
+			call: TransactionArgs{From: &accounts[0].addr, To: &accounts[1].addr, Value: (*hexutil.Big)(big.NewInt(1000))},
+			want: &gasBreakdownResult{Gas: 21000, GasUsed: 21000, IntrinsicGas: 21000},
+		},
+		// create limited by its own execution
+		{
+			call: TransactionArgs{
+				From:  &accounts[0].addr,
+				Input: hex2Bytes("60644310600857005b600080fd"),
+			},
+			blockOverrides: &BlockOverrides{Number: (*hexutil.Big)(big.NewInt(100))},
+			want: &gasBreakdownResult{
+				Gas:          53207,
+				GasUsed:      53207,
+				IntrinsicGas: 53186,
+				CalldataGas:  186,
+				ExecutionGas: 21,
+				LimitingFrame: &gasFrameResult{
+					Depth:   0,
+					Type:    "CREATE",
+					From:    accounts[0].addr,
+					To:      crypto.CreateAddress(accounts[0].addr, 0),
+					Gas:     20,
+					GasUsed: 20,
+					Error:   vm.ErrOutOfGas.Error(),
+				},
+			},
+		},
+		// cold accesses without access list
+		{
+			call: TransactionArgs{From: &accounts[0].addr, To: &contract},
+			want: &gasBreakdownResult{
+				Gas:          25710,
+				GasUsed:      25710,
+				IntrinsicGas: 21000,
+				ExecutionGas: 4710,
+				LimitingFrame: &gasFrameResult{
+					Type:    "CALL",
+					From:    accounts[0].addr,
+					To:      contract,
+					Gas:     4709,
+					GasUsed: 4709,
+					Error:   vm.ErrOutOfGas.Error(),
+				},
+			},
+		},
+		// optimized access list, dropping the entries which don't pay off
+		{
+			call: TransactionArgs{
+				From:       &accounts[0].addr,
+				To:         &contract,
+				AccessList: &types.AccessList{{Address: unused, StorageKeys: []common.Hash{{}}}},
+			},
+			config: &gasBreakdownConfig{OptimizeAccessList: true},
+			want: &gasBreakdownResult{
+				Gas:           25610,
+				GasUsed:       25610,
+				IntrinsicGas:  23400,
+				AccessListGas: 2400,
+				ExecutionGas:  2210,
+				LimitingFrame: &gasFrameResult{
+					Type:    "CALL",
+					From:    accounts[0].addr,
+					To:      contract,
+					Gas:     2209,
+					GasUsed: 2209,
+					Error:   vm.ErrOutOfGas.Error(),
+				},
+				AccessList: &types.AccessList{{Address: other, StorageKeys: []common.Hash{}}},
+			},
+		},
+	}
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	for i, tc := range testSuite {
+		result, err := api.EstimateGasBreakdown(context.Background(), tc.call, &latest, &overrides, tc.blockOverrides, tc.config)
+		if err != nil {
+			t.Errorf("test %d: want no error, have %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tc.want) {
+			have, _ := json.MarshalIndent(result, "", "  ")
+			want, _ := json.MarshalIndent(tc.want, "", "  ")
+			t.Errorf("test %d, result mismatch, have\n%s\n, want\n%s\n", i, have, want)
+		}
+	}
+}
+
 func TestCall(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts
@@ -975,6 +1155,127 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestCallRevertDecoding(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		contract = common.HexToAddress("0xc0de")
+		selector = crypto.Keccak256([]byte("InsufficientBalance(uint256,uint256)"))[:4]
+		// The contract reverts with InsufficientBalance(1, 2)
+		code = []byte{
+			byte(vm.PUSH4), selector[0], selector[1], selector[2], selector[3], byte(vm.PUSH1), 0xe0, byte(vm.SHL), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 1, byte(vm.PUSH1), 4, byte(vm.MSTORE), byte(vm.PUSH1), 2, byte(vm.PUSH1), 36, byte(vm.MSTORE),
+			byte(vm.PUSH1), 68, byte(vm.PUSH1), 0, byte(vm.REVERT),
+		}
+		genesis = &core.Genesis{
+			Config: params.MergedTestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+				contract:         {Balance: common.Big0, Code: code},
+			},
+		}
+		args = TransactionArgs{From: &accounts[0].addr, To: &contract}
+	)
+	backend := newTestBackend(t, 1, genesis, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) { b.SetPoS() })
+	revert := func(backend Backend) (callErr error, estimateErr error) {
+		api := NewBlockChainAPI(backend)
+		_, callErr = api.Call(context.Background(), args, nil, nil, nil)
+		_, estimateErr = api.EstimateGas(context.Background(), args, nil, nil, nil)
+		return callErr, estimateErr
+	}
+	// Without registry, the custom error is not decoded
+	callErr, estimateErr := revert(backend)
+	for _, err := range []error{callErr, estimateErr} {
+		if err == nil || err.Error() != "execution reverted" {
+			t.Errorf("undecoded revert mismatch: have %v", err)
+		}
+	}
+	// With the error registered, it is decoded but the revert data is retained
+	backend.errors = abi.NewErrorRegistry()
+	if err := backend.errors.AddSignature("InsufficientBalance(uint256,uint256)"); err != nil {
+		t.Fatal(err)
+	}
+	callErr, estimateErr = revert(backend)
+	for _, err := range []error{callErr, estimateErr} {
+		var rerr *revertError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("revert error expected, have %v", err)
+		}
+		if want := "execution reverted: InsufficientBalance(1, 2)"; rerr.Error() != want {
+			t.Errorf("decoded revert mismatch: have %q, want %q", rerr.Error(), want)
+		}
+		if len(common.FromHex(rerr.ErrorData().(string))) != len(selector)+64 {
+			t.Errorf("revert data mismatch: %v", rerr.ErrorData())
+		}
+	}
+}
+
+func TestTransactionScopedState(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(2)
+		genesis  = &core.Genesis{
+			Config: params.MergedTestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+				accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		signer = types.HomesteadSigner{}
+		reader = common.Address{0xbb}
+	)
+	backend := newTestBackend(t, 1, genesis, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) {
+		// Three transfers of 1000 wei from accounts[0] to accounts[1]
+		for nonce := uint64(0); nonce < 3; nonce++ {
+			tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: nonce, To: &accounts[1].addr, Value: big.NewInt(1000), Gas: params.TxGas, GasPrice: b.BaseFee()}), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+		b.SetPoS()
+	})
+	api := NewBlockChainAPI(backend)
+
+	// Contract returning the balance of accounts[1]
+	code := append(append([]byte{0x73}, accounts[1].addr.Bytes()...), 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+	overrides := StateOverride{reader: {Code: (*hexutil.Bytes)(&code)}}
+
+	block := rpc.BlockNumberOrHashWithNumber(1)
+	for i, want := range []int64{0, 1000, 2000, 3000} {
+		ref := block
+		if i < 3 {
+			index := hexutil.Uint(i)
+			ref.TransactionIndex = &index
+		}
+		balance, err := api.GetBalance(context.Background(), accounts[1].addr, ref)
+		if err != nil {
+			t.Fatalf("index %d: failed to get balance: %v", i, err)
+		}
+		expect := new(big.Int).Add(big.NewInt(params.Ether), big.NewInt(want))
+		if balance.ToInt().Cmp(expect) != 0 {
+			t.Errorf("index %d: wrong balance: have %v, want %v", i, balance.ToInt(), expect)
+		}
+		result, err := api.Call(context.Background(), TransactionArgs{To: &reader}, &ref, &overrides, nil)
+		if err != nil {
+			t.Fatalf("index %d: call failed: %v", i, err)
+		}
+		if have := new(big.Int).SetBytes(result); have.Cmp(expect) != 0 {
+			t.Errorf("index %d: wrong call result: have %v, want %v", i, have, expect)
+		}
+	}
+	// Indices beyond the block and proofs of intermediate states are rejected
+	index := hexutil.Uint(3)
+	ref := block
+	ref.TransactionIndex = &index
+	if _, err := api.GetBalance(context.Background(), accounts[1].addr, ref); err == nil {
+		t.Error("expected error for out of range transaction index")
+	}
+	index = 0
+	if _, err := api.GetProof(context.Background(), accounts[1].addr, nil, ref); err == nil {
+		t.Error("expected error for transaction-scoped proof")
+	}
+}
+
 func TestSignTransaction(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts
@@ -1741,7 +2042,7 @@ func TestRPCGetBlockOrHeader(t *testing.T) {
 
 	for i, tt := range testSuite {
 		var (
-			result map[string]interface{}
+			result interface{}
 			err    error
 			rpc    string
 		)
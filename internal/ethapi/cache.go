@@ -0,0 +1,319 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	responseCacheHitMeter       = metrics.NewRegisteredMeter("rpc/cache/hit", nil)
+	responseCacheDiskHitMeter   = metrics.NewRegisteredMeter("rpc/cache/hit/disk", nil)
+	responseCacheMissMeter      = metrics.NewRegisteredMeter("rpc/cache/miss", nil)
+	responseCacheStoreMeter     = metrics.NewRegisteredMeter("rpc/cache/store", nil)
+	responseCacheStoreSizeMeter = metrics.NewRegisteredMeter("rpc/cache/store/size", nil)
+	responseCachePruneMeter     = metrics.NewRegisteredMeter("rpc/cache/prune", nil)
+)
+
+// ResponseCacheDiskFactor is the size of the on-disk tier of the response cache
+// relative to the memory allowance.
+const ResponseCacheDiskFactor = 8
+
+// responseCacheVersion is the version of the encoding of cached responses. It
+// must be bumped whenever the encoding of any cached response changes, so that
+// responses persisted by older versions are dropped.
+const responseCacheVersion = 1
+
+// responseCacheMetaKey is the key of the metadata of the on-disk tier. It can't
+// collide with the keys of responses, which are hashes.
+var responseCacheMetaKey = []byte("meta")
+
+// responseCacheMeta is the metadata of the on-disk tier of the response cache.
+type responseCacheMeta struct {
+	Version uint64 // Encoding version of the persisted responses
+	Used    uint64 // Size of the persisted responses, keys included
+}
+
+// HeaderReader is the minimal chain access needed to decide whether a response
+// refers to immutable chain data. It is satisfied by both the ethapi and the
+// filters backends.
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
+}
+
+// ResponseCache stores the JSON encoding of RPC responses which refer to chain
+// data at or below the finalized checkpoint. Such responses can never change,
+// so they are kept until evicted by the size limits or explicitly flushed.
+//
+// The cache has an in-memory tier bounded by size and an optional on-disk tier
+// which is consulted on memory misses. The disk tier may grow up to
+// ResponseCacheDiskFactor times the memory allowance, after which a quarter of
+// it is pruned. Entries are keyed by the hash of the normalized request, see
+// ResponseCacheKey. Persisted entries of other encoding versions are dropped
+// when the cache is created.
+type ResponseCache struct {
+	mem     *lru.SizeConstrainedCache[common.Hash, []byte]
+	memSize uint64              // Memory allowance
+	disk    ethdb.KeyValueStore // Optional persistent tier, nil if disabled
+
+	diskLimit uint64     // Maximum size of the persisted entries
+	diskUsed  uint64     // Size of the persisted entries, keys included
+	diskLock  sync.Mutex // Protects diskUsed and pruning
+}
+
+// NewResponseCache creates a response cache with the given memory allowance in
+// bytes. If disk is non-nil, responses are also persisted there.
+func NewResponseCache(size uint64, disk ethdb.KeyValueStore) *ResponseCache {
+	c := &ResponseCache{
+		mem:       lru.NewSizeConstrainedCache[common.Hash, []byte](size),
+		memSize:   size,
+		disk:      disk,
+		diskLimit: size * ResponseCacheDiskFactor,
+	}
+	if disk != nil {
+		var meta responseCacheMeta
+		blob, _ := disk.Get(responseCacheMetaKey)
+		if len(blob) > 0 && rlp.DecodeBytes(blob, &meta) == nil && meta.Version == responseCacheVersion {
+			c.diskUsed = meta.Used
+		} else if err := c.clear(); err != nil {
+			log.Warn("Failed to drop outdated persisted responses", "err", err)
+		}
+	}
+	return c
+}
+
+// ResponseCacheKey derives the cache key of a request from a namespace-qualified
+// name and its normalized parameters. Callers must resolve any block tags into
+// concrete numbers or hashes before deriving the key.
+func ResponseCacheKey(method string, params ...interface{}) common.Hash {
+	blob, err := json.Marshal(append([]interface{}{method}, params...))
+	if err != nil {
+		// All parameters are plain RPC types, this should never happen.
+		log.Error("Failed to encode response cache key", "method", method, "err", err)
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(blob)
+}
+
+// MaxSize returns the size of the largest response which can be cached. It is
+// safe to call MaxSize on a nil cache.
+func (c *ResponseCache) MaxSize() uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.memSize
+}
+
+// Get retrieves the JSON encoding of the cached response for key, which can be
+// served as is. The returned flag reports whether the lookup was a hit. It is
+// safe to call Get on a nil cache.
+func (c *ResponseCache) Get(key common.Hash) (json.RawMessage, bool) {
+	if c == nil || key == (common.Hash{}) {
+		return nil, false
+	}
+	blob, ok := c.mem.Get(key)
+	if ok {
+		responseCacheHitMeter.Mark(1)
+	} else if c.disk != nil {
+		if blob, _ = c.disk.Get(key[:]); len(blob) > 0 {
+			ok = true
+			c.mem.Add(key, blob)
+			responseCacheDiskHitMeter.Mark(1)
+		}
+	}
+	if !ok {
+		responseCacheMissMeter.Mark(1)
+		return nil, false
+	}
+	return blob, true
+}
+
+// Put stores the response for key. It is the caller's responsibility to ensure
+// the response is immutable, see Immutable. It is safe to call Put on a nil cache.
+func (c *ResponseCache) Put(key common.Hash, response interface{}) {
+	if c == nil || key == (common.Hash{}) {
+		return
+	}
+	blob, err := json.Marshal(response)
+	if err != nil {
+		log.Warn("Failed to encode response for caching", "key", key, "err", err)
+		return
+	}
+	c.mem.Add(key, blob)
+	if c.disk != nil {
+		c.persist(key, blob)
+	}
+	responseCacheStoreMeter.Mark(1)
+	responseCacheStoreSizeMeter.Mark(int64(len(blob)))
+}
+
+// persist stores a response in the disk tier, pruning it if it grew too large.
+func (c *ResponseCache) persist(key common.Hash, blob []byte) {
+	c.diskLock.Lock()
+	defer c.diskLock.Unlock()
+
+	// Overwritten entries no longer take up their previous size
+	used := c.diskUsed
+	if old, _ := c.disk.Get(key[:]); len(old) > 0 {
+		used -= min(used, uint64(len(key)+len(old)))
+	}
+	used += uint64(len(key) + len(blob))
+
+	batch := c.disk.NewBatch()
+	if err := batch.Put(key[:], blob); err != nil {
+		log.Warn("Failed to persist cached response", "key", key, "err", err)
+		return
+	}
+	if err := writeResponseCacheMeta(batch, used); err != nil {
+		log.Warn("Failed to persist cached response", "key", key, "err", err)
+		return
+	}
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to persist cached response", "key", key, "err", err)
+		return
+	}
+	c.diskUsed = used
+	if c.diskUsed > c.diskLimit {
+		if err := c.prune(c.diskLimit / 4 * 3); err != nil {
+			log.Warn("Failed to prune persisted responses", "err", err)
+		}
+	}
+}
+
+// prune deletes persisted responses until the disk tier is at most target bytes
+// large. As the keys are hashes, the deleted entries are effectively random.
+func (c *ResponseCache) prune(target uint64) error {
+	deleted, err := c.deleteEntries(func(used uint64) bool { return used > target })
+	if err != nil {
+		return err
+	}
+	responseCachePruneMeter.Mark(int64(deleted))
+	log.Debug("Pruned persisted responses", "deleted", deleted, "size", common.StorageSize(c.diskUsed))
+	return nil
+}
+
+// clear deletes all persisted responses.
+func (c *ResponseCache) clear() error {
+	if _, err := c.deleteEntries(func(uint64) bool { return true }); err != nil {
+		return err
+	}
+	// Anything not deleted was unaccounted for
+	c.diskUsed = 0
+	return writeResponseCacheMeta(c.disk, 0)
+}
+
+// deleteEntries deletes persisted responses while cont returns true for the disk
+// usage left, returning the number of deleted responses. The tracked disk usage
+// only covers deletions which were written, and is stored along with them.
+func (c *ResponseCache) deleteEntries(cont func(used uint64) bool) (int, error) {
+	var (
+		it      = c.disk.NewIterator(nil, nil)
+		batch   = c.disk.NewBatch()
+		used    = c.diskUsed
+		deleted int
+	)
+	defer it.Release()
+
+	write := func() error {
+		if err := writeResponseCacheMeta(batch, used); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		c.diskUsed = used
+		batch.Reset()
+		return nil
+	}
+	for cont(used) && it.Next() {
+		if len(it.Key()) != common.HashLength {
+			continue // metadata
+		}
+		if err := batch.Delete(it.Key()); err != nil {
+			return deleted, err
+		}
+		used -= min(used, uint64(len(it.Key())+len(it.Value())))
+		deleted++
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := write(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := it.Error(); err != nil {
+		return deleted, err
+	}
+	return deleted, write()
+}
+
+// writeResponseCacheMeta stores the metadata of the on-disk tier.
+func writeResponseCacheMeta(w ethdb.KeyValueWriter, used uint64) error {
+	blob, err := rlp.EncodeToBytes(&responseCacheMeta{Version: responseCacheVersion, Used: used})
+	if err != nil {
+		return err
+	}
+	return w.Put(responseCacheMetaKey, blob)
+}
+
+// Immutable reports whether the canonical block with the given number is at or
+// below the finalized checkpoint, i.e. whether responses about it are safe to
+// cache. If hash is non-zero, it must match the canonical block at that height.
+func (c *ResponseCache) Immutable(ctx context.Context, b HeaderReader, number uint64, hash common.Hash) bool {
+	if c == nil {
+		return false
+	}
+	finalized, err := b.HeaderByNumber(ctx, rpc.FinalizedBlockNumber)
+	if err != nil || finalized == nil || finalized.Number.Uint64() < number {
+		return false
+	}
+	if hash == (common.Hash{}) {
+		return true
+	}
+	header, err := b.HeaderByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil || header == nil {
+		return false
+	}
+	return header.Hash() == hash
+}
+
+// Flush drops all cached responses, both in memory and on disk.
+func (c *ResponseCache) Flush() error {
+	if c == nil {
+		return nil
+	}
+	c.mem.Purge()
+	if c.disk == nil {
+		return nil
+	}
+	c.diskLock.Lock()
+	defer c.diskLock.Unlock()
+
+	return c.clear()
+}
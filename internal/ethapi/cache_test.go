@@ -0,0 +1,258 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// finalizedReader is a HeaderReader serving a linear chain of headers with a
+// configurable finalized block.
+type finalizedReader struct {
+	headers   []*types.Header
+	finalized uint64
+}
+
+func newFinalizedReader(n int, finalized uint64) *finalizedReader {
+	r := &finalizedReader{finalized: finalized}
+	for i := 0; i < n; i++ {
+		r.headers = append(r.headers, &types.Header{Number: big.NewInt(int64(i))})
+	}
+	return r
+}
+
+func (r *finalizedReader) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.FinalizedBlockNumber {
+		return r.headers[r.finalized], nil
+	}
+	if number < 0 || int(number) >= len(r.headers) {
+		return nil, nil
+	}
+	return r.headers[number], nil
+}
+
+func TestResponseCacheImmutable(t *testing.T) {
+	var (
+		cache  = NewResponseCache(1024, nil)
+		reader = newFinalizedReader(10, 5)
+	)
+	for i, tt := range []struct {
+		number uint64
+		hash   common.Hash
+		want   bool
+	}{
+		{number: 0, want: true},
+		{number: 5, want: true},
+		{number: 6, want: false},
+		{number: 3, hash: reader.headers[3].Hash(), want: true},
+		{number: 3, hash: common.Hash{0x01}, want: false},
+		{number: 7, hash: reader.headers[7].Hash(), want: false},
+	} {
+		if have := cache.Immutable(context.Background(), reader, tt.number, tt.hash); have != tt.want {
+			t.Errorf("test %d: immutable mismatch, have %v, want %v", i, have, tt.want)
+		}
+	}
+	// A nil cache never accepts responses
+	var nilcache *ResponseCache
+	if nilcache.Immutable(context.Background(), reader, 0, common.Hash{}) {
+		t.Error("nil cache reported response as cacheable")
+	}
+}
+
+func TestResponseCacheTiers(t *testing.T) {
+	var (
+		disk  = rawdb.NewMemoryDatabase()
+		cache = NewResponseCache(1024, disk)
+		key   = ResponseCacheKey("eth_getBlockByNumber", uint64(1), true)
+		want  = `{"hash":"0x0100000000000000000000000000000000000000000000000000000000000000","number":"0x1"}`
+	)
+	if _, ok := cache.Get(key); ok {
+		t.Fatal("cache hit on empty cache")
+	}
+	cache.Put(key, map[string]interface{}{"number": "0x1", "hash": common.Hash{0x01}})
+	have, ok := cache.Get(key)
+	if !ok {
+		t.Fatal("cache miss after insertion")
+	}
+	if string(have) != want {
+		t.Fatalf("cached response mismatch, have %s, want %s", have, want)
+	}
+	// A fresh cache on top of the same disk should serve the response too
+	if have, ok = NewResponseCache(1024, disk).Get(key); !ok {
+		t.Fatal("response not persisted on disk")
+	}
+	if string(have) != want {
+		t.Fatalf("persisted response mismatch, have %s, want %s", have, want)
+	}
+	// Flushing must drop both tiers
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("failed to flush cache: %v", err)
+	}
+	if _, ok := cache.Get(key); ok {
+		t.Fatal("cache hit after flush")
+	}
+	if ok, _ := disk.Has(key[:]); ok {
+		t.Fatal("response still on disk after flush")
+	}
+}
+
+func TestResponseCacheDiskLimit(t *testing.T) {
+	var (
+		disk  = rawdb.NewMemoryDatabase()
+		cache = NewResponseCache(128, disk)
+		limit = uint64(128 * ResponseCacheDiskFactor)
+	)
+	for i := 0; i < 100; i++ {
+		cache.Put(ResponseCacheKey("eth_getBlockByNumber", uint64(i), true), common.Hash{byte(i)})
+	}
+	var (
+		size uint64
+		it   = disk.NewIterator(nil, nil)
+	)
+	for it.Next() {
+		if len(it.Key()) == common.HashLength {
+			size += uint64(len(it.Key()) + len(it.Value()))
+		}
+	}
+	it.Release()
+
+	if size > limit {
+		t.Fatalf("persisted responses exceed limit: have %d, limit %d", size, limit)
+	}
+	if size != cache.diskUsed {
+		t.Fatalf("tracked disk usage mismatch: have %d, want %d", cache.diskUsed, size)
+	}
+	// The usage of existing entries must be picked up by new caches
+	if have := NewResponseCache(128, disk).diskUsed; have != size {
+		t.Fatalf("measured disk usage mismatch: have %d, want %d", have, size)
+	}
+}
+
+func TestResponseCacheOverwrite(t *testing.T) {
+	var (
+		disk  = rawdb.NewMemoryDatabase()
+		cache = NewResponseCache(1024, disk)
+		key   = ResponseCacheKey("eth_getBlockByNumber", uint64(1), true)
+	)
+	// Storing the same key again must only account for the latest response
+	cache.Put(key, common.Hash{0x01})
+	cache.Put(key, common.Hash{0x01})
+	cache.Put(key, "0x02")
+
+	blob, _ := disk.Get(key[:])
+	if want := uint64(len(key) + len(blob)); cache.diskUsed != want {
+		t.Fatalf("tracked disk usage mismatch: have %d, want %d", cache.diskUsed, want)
+	}
+}
+
+func TestResponseCacheVersion(t *testing.T) {
+	var (
+		disk = rawdb.NewMemoryDatabase()
+		key  = ResponseCacheKey("eth_getBlockByNumber", uint64(1), true)
+	)
+	NewResponseCache(1024, disk).Put(key, common.Hash{0x01})
+
+	// Responses persisted with the current encoding must be kept
+	if _, ok := NewResponseCache(1024, disk).Get(key); !ok {
+		t.Fatal("response of current version dropped")
+	}
+	// Responses of other versions must be dropped
+	blob, _ := rlp.EncodeToBytes(&responseCacheMeta{Version: responseCacheVersion - 1, Used: 1000})
+	disk.Put(responseCacheMetaKey, blob)
+
+	cache := NewResponseCache(1024, disk)
+	if _, ok := cache.Get(key); ok {
+		t.Fatal("response of outdated version served")
+	}
+	if cache.diskUsed != 0 {
+		t.Fatalf("disk usage not reset: %d", cache.diskUsed)
+	}
+}
+
+func TestResponseCacheKeyNormalization(t *testing.T) {
+	if ResponseCacheKey("eth_getBlockByNumber", uint64(1), true) == ResponseCacheKey("eth_getBlockByNumber", uint64(1), false) {
+		t.Error("different parameters yield same key")
+	}
+	if ResponseCacheKey("eth_getBlockByNumber", uint64(1), true) == ResponseCacheKey("eth_getBlockByHash", uint64(1), true) {
+		t.Error("different methods yield same key")
+	}
+}
+
+// cachingBackend is a test backend with a response cache, considering the head
+// block final.
+type cachingBackend struct {
+	*testBackend
+	cache *ResponseCache
+}
+
+func (b cachingBackend) ResponseCache() *ResponseCache { return b.cache }
+
+func (b cachingBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.FinalizedBlockNumber {
+		number = rpc.LatestBlockNumber
+	}
+	return b.testBackend.HeaderByNumber(ctx, number)
+}
+
+// Tests that cached responses are served as stored, matching the uncached ones.
+func TestResponseCacheServing(t *testing.T) {
+	t.Parallel()
+
+	backend, txHashes := setupReceiptBackend(t, 6)
+	var (
+		ctx     = context.Background()
+		cache   = NewResponseCache(1024*1024, nil)
+		chain   = NewBlockChainAPI(cachingBackend{backend, cache})
+		txs     = NewTransactionAPI(cachingBackend{backend, cache}, nil)
+		block   = backend.chain.GetBlockByNumber(1)
+		queries = map[string]func() (interface{}, error){
+			"eth_getBlockByNumber": func() (interface{}, error) { return chain.GetBlockByNumber(ctx, 1, true) },
+			"eth_getBlockByHash":   func() (interface{}, error) { return chain.GetBlockByHash(ctx, block.Hash(), true) },
+			"eth_getBlockReceipts": func() (interface{}, error) {
+				return chain.GetBlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(block.Hash(), false))
+			},
+			"eth_getTransactionReceipt": func() (interface{}, error) { return txs.GetTransactionReceipt(ctx, txHashes[0]) },
+		}
+	)
+	for name, query := range queries {
+		fresh, err := query()
+		if err != nil {
+			t.Fatalf("%s: query failed: %v", name, err)
+		}
+		cached, err := query()
+		if err != nil {
+			t.Fatalf("%s: cached query failed: %v", name, err)
+		}
+		if _, ok := cached.(json.RawMessage); !ok {
+			t.Fatalf("%s: response not served from the cache: %T", name, cached)
+		}
+		want, _ := json.Marshal(fresh)
+		have, _ := json.Marshal(cached)
+		if string(have) != string(want) {
+			t.Errorf("%s: cached response mismatch\nhave %s\nwant %s", name, have, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Bundle is a sequence of calls executed one after the other in the same block
+// context, with each call seeing the state changes of the previous ones.
+type Bundle struct {
+	Transactions  []TransactionArgs `json:"transactions"`
+	BlockOverride *BlockOverrides   `json:"blockOverride"`
+}
+
+// StateContext selects the state a sequence of bundles is executed on. If the
+// transaction index is set, the state right before that transaction of the block
+// is used, otherwise the state after the whole block.
+type StateContext struct {
+	BlockNumber      rpc.BlockNumberOrHash `json:"blockNumber"`
+	TransactionIndex *hexutil.Uint         `json:"transactionIndex"`
+}
+
+// CallManyResult is the outcome of a single call within a bundle.
+type CallManyResult struct {
+	Value   hexutil.Bytes  `json:"value,omitempty"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Error   *callError     `json:"error,omitempty"`
+}
+
+// stateAtContext retrieves the state and header selected by a state context. The
+// returned release function must be called once the state is no longer needed.
+func stateAtContext(ctx context.Context, b Backend, sc StateContext) (*state.StateDB, *types.Header, func(), error) {
+	blockNrOrHash := sc.BlockNumber
+	if sc.TransactionIndex != nil {
+		if blockNrOrHash.TransactionIndex != nil {
+			return nil, nil, nil, errors.New("transaction index specified twice")
+		}
+		blockNrOrHash.TransactionIndex = sc.TransactionIndex
+	}
+	statedb, header, release, err := stateAndHeaderByNumberOrHash(ctx, b, blockNrOrHash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if statedb == nil {
+		return nil, nil, nil, errors.New("state not found")
+	}
+	return statedb, header, release, nil
+}
+
+// CallMany executes a sequence of bundles on top of the state selected by the
+// state context. Every bundle runs in the block context of the selected block,
+// modified by its own block overrides, while the state overrides are applied
+// once before the first bundle. Failing calls are reported in their result and
+// do not abort the sequence.
+//
+// The gas cap of eth_call applies to every individual call, its timeout to the
+// whole sequence.
+func (api *BlockChainAPI) CallMany(ctx context.Context, bundles []Bundle, stateContext StateContext, overrides *StateOverride) ([][]CallManyResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call bundles finished", "runtime", time.Since(start)) }(time.Now())
+
+	statedb, header, release, err := stateAtContext(ctx, api.b, stateContext)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := overrides.Apply(statedb); err != nil {
+		return nil, err
+	}
+	timeout := api.b.RPCEVMTimeout()
+
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	results := make([][]CallManyResult, len(bundles))
+	for i, bundle := range bundles {
+		blockCtx := core.NewEVMBlockContext(header, NewChainContext(ctx, api.b), nil)
+		bundle.BlockOverride.Apply(&blockCtx)
+
+		results[i] = make([]CallManyResult, len(bundle.Transactions))
+		for j, args := range bundle.Transactions {
+			result, err := applyMessage(ctx, api.b, args, statedb, header, &blockCtx, timeout, api.b.RPCGasCap())
+			if err != nil {
+				// Running out of time aborts the whole sequence, anything else
+				// only invalidates the current call.
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("bundle %d, call %d: %w", i, j, err)
+				}
+				results[i][j].Error = &callError{Message: err.Error(), Code: errCodeInvalidCall}
+				continue
+			}
+			results[i][j] = newCallManyResult(result, api.b.ErrorRegistry())
+
+			// Commit the changes so the next call observes them
+			statedb.Finalise(api.b.ChainConfig().IsEIP158(blockCtx.BlockNumber))
+		}
+	}
+	return results, nil
+}
+
+// newCallManyResult converts the outcome of a call into its RPC representation,
+// decoding custom revert errors with the given registry.
+func newCallManyResult(result *core.ExecutionResult, registry *abi.ErrorRegistry) CallManyResult {
+	res := CallManyResult{
+		Value:   result.Return(),
+		GasUsed: hexutil.Uint64(result.UsedGas),
+	}
+	switch {
+	case errors.Is(result.Err, vm.ErrExecutionReverted):
+		revert := newRevertError(result.Revert(), registry)
+		res.Error = &callError{Message: revert.Error(), Code: revert.ErrorCode(), Data: revert.reason}
+	case result.Err != nil:
+		res.Error = &callError{Message: result.Err.Error(), Code: errCodeVMError}
+	}
+	return res
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestCallMany(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(3)
+		genesis  = &core.Genesis{
+			Config: params.MergedTestChainConfig,
+			Alloc: types.GenesisAlloc{
+				accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+				accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+				accounts[2].addr: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		signer = types.HomesteadSigner{}
+
+		// Contracts returning the balance of accounts[1], the block number and
+		// reverting unconditionally.
+		balanceReader = common.Address{0xbb}
+		numberReader  = common.Address{0xcc}
+		reverter      = common.Address{0xdd}
+	)
+	api := NewBlockChainAPI(newTestBackend(t, 1, genesis, beacon.New(ethash.NewFaker()), func(i int, b *core.BlockGen) {
+		// Two transfers of 1000 wei from accounts[0] to accounts[1]
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: nonce, To: &accounts[1].addr, Value: big.NewInt(1000), Gas: params.TxGas, GasPrice: b.BaseFee()}), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+		b.SetPoS()
+	}))
+	balanceCode := append(append([]byte{0x73}, accounts[1].addr.Bytes()...), 0x31, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+	overrides := StateOverride{
+		balanceReader: {Code: (*hexutil.Bytes)(&balanceCode)},
+		numberReader:  {Code: hex2Bytes("4360005260206000f3")},
+		reverter:      {Code: hex2Bytes("60006000fd")},
+	}
+	balance := func(extra int64) string {
+		return hexutil.Encode(common.BigToHash(new(big.Int).Add(big.NewInt(params.Ether), big.NewInt(extra))).Bytes())
+	}
+	var (
+		index       = hexutil.Uint(1)
+		blockNumber = (*hexutil.Big)(big.NewInt(100))
+	)
+	bundles := []Bundle{
+		{
+			Transactions: []TransactionArgs{
+				{To: &balanceReader},
+				{From: &accounts[2].addr, To: &accounts[1].addr, Value: (*hexutil.Big)(big.NewInt(5))},
+				{To: &balanceReader},
+				{To: &reverter},
+			},
+		},
+		{
+			Transactions:  []TransactionArgs{{To: &numberReader}},
+			BlockOverride: &BlockOverrides{Number: blockNumber},
+		},
+	}
+	// Execute right before the second transfer of the block
+	results, err := api.CallMany(context.Background(), bundles, StateContext{
+		BlockNumber:      rpc.BlockNumberOrHashWithNumber(1),
+		TransactionIndex: &index,
+	}, &overrides)
+	if err != nil {
+		t.Fatalf("call bundles failed: %v", err)
+	}
+	if len(results) != 2 || len(results[0]) != 4 || len(results[1]) != 1 {
+		t.Fatalf("wrong result shape: %v", results)
+	}
+	if have, want := results[0][0].Value.String(), balance(1000); have != want {
+		t.Errorf("wrong balance before transfer: have %s, want %s", have, want)
+	}
+	if results[0][1].Error != nil || results[0][1].GasUsed != hexutil.Uint64(params.TxGas) {
+		t.Errorf("transfer failed: %+v", results[0][1])
+	}
+	if have, want := results[0][2].Value.String(), balance(1005); have != want {
+		t.Errorf("wrong balance after transfer: have %s, want %s", have, want)
+	}
+	if results[0][3].Error == nil || results[0][3].Error.Code != 3 {
+		t.Errorf("missing revert error: %+v", results[0][3])
+	}
+	if have, want := results[1][0].Value.String(), hexutil.Encode(common.BigToHash(blockNumber.ToInt()).Bytes()); have != want {
+		t.Errorf("block override not applied: have %s, want %s", have, want)
+	}
+	// Execute at the end of the block
+	results, err = api.CallMany(context.Background(), bundles[:1], StateContext{
+		BlockNumber: rpc.BlockNumberOrHashWithNumber(1),
+	}, &overrides)
+	if err != nil {
+		t.Fatalf("call bundles failed: %v", err)
+	}
+	if have, want := results[0][0].Value.String(), balance(2000); have != want {
+		t.Errorf("wrong balance at end of block: have %s, want %s", have, want)
+	}
+}
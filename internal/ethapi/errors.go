@@ -42,11 +42,12 @@ func (e *revertError) ErrorData() interface{} {
 	return e.reason
 }
 
-// newRevertError creates a revertError instance with the provided revert data.
-func newRevertError(revert []byte) *revertError {
+// newRevertError creates a revertError instance with the provided revert data,
+// decoding custom errors with the given registry.
+func newRevertError(revert []byte, registry *abi.ErrorRegistry) *revertError {
 	err := vm.ErrExecutionReverted
 
-	reason, errUnpack := abi.UnpackRevert(revert)
+	reason, errUnpack := registry.Decode(revert)
 	if errUnpack == nil {
 		err = fmt.Errorf("%w: %v", vm.ErrExecutionReverted, reason)
 	}
@@ -56,6 +57,19 @@ func newRevertError(revert []byte) *revertError {
 	}
 }
 
+// callError is the error of an individual call within a request executing many
+// of them, reported alongside the results of the other calls.
+type callError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+const (
+	errCodeInvalidCall = -32000
+	errCodeVMError     = -32015
+)
+
 // TxIndexingError is an API error that indicates the transaction indexing is not
 // fully finished yet with JSON error code and a binary data blob.
 type TxIndexingError struct{}
@@ -26,18 +26,24 @@ import (
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/logindex"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
 )
 
 // TestSetFeeDefaults tests the logic for filling in default fee values works as expected.
@@ -215,7 +221,7 @@ func TestSetFeeDefaults(t *testing.T) {
 			errors.New("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified"),
 		},
 		{
-			"fill maxFeePerBlobGas",
+			"fill maxFeePerBlobGas with flat blob fee trend",
 			"cancun",
 			&TransactionArgs{BlobHashes: []common.Hash{}},
 			&TransactionArgs{BlobHashes: []common.Hash{}, BlobFeeCap: (*hexutil.Big)(big.NewInt(4)), MaxFeePerGas: maxFee, MaxPriorityFeePerGas: fortytwo},
@@ -254,9 +260,45 @@ func TestSetFeeDefaults(t *testing.T) {
 	}
 }
 
+// TestSetBlobFeeCapDefaults tests that the default blob fee cap keeps a minimum
+// slack over the current blob base fee and follows a rising blob fee trend.
+func TestSetBlobFeeCapDefaults(t *testing.T) {
+	b := newBackendMock()
+	if err := b.setFork("cancun"); err != nil {
+		t.Fatalf("failed to set fork: %v", err)
+	}
+	current := eip4844.CalcBlobFee(*b.current.ExcessBlobGas)
+
+	tests := []struct {
+		rate int64
+		want *big.Int
+	}{
+		// Flat and falling trends keep 2 times the current blob base fee
+		{0, new(big.Int).Mul(current, big.NewInt(2))},
+		{-100000, new(big.Int).Mul(current, big.NewInt(2))},
+		// A rising trend exceeding the slack is followed
+		{1000000, eip4844.CalcBlobFee(*b.current.ExcessBlobGas + 1000000*(gasprice.DefaultBlobFeeHorizon-1))},
+	}
+	for i, test := range tests {
+		b.blobRate = test.rate
+		args := &TransactionArgs{BlobHashes: []common.Hash{}, MaxFeePerGas: (*hexutil.Big)(big.NewInt(100)), MaxPriorityFeePerGas: (*hexutil.Big)(big.NewInt(1))}
+		if err := args.setFeeDefaults(context.Background(), b); err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if args.BlobFeeCap.ToInt().Cmp(test.want) != 0 {
+			t.Errorf("test %d: blob fee cap mismatch: have %v, want %v", i, args.BlobFeeCap, test.want)
+		}
+	}
+	// Sanity check that the rising case is above the minimum slack
+	if tests[2].want.Cmp(tests[0].want) <= 0 {
+		t.Fatalf("rising trend below minimum slack: %v <= %v", tests[2].want, tests[0].want)
+	}
+}
+
 type backendMock struct {
-	current *types.Header
-	config  *params.ChainConfig
+	current  *types.Header
+	config   *params.ChainConfig
+	blobRate int64 // Change of the excess blob gas per block
 }
 
 func newBackendMock() *backendMock {
@@ -314,15 +356,34 @@ func (b *backendMock) setFork(fork string) error {
 func (b *backendMock) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
 	return big.NewInt(42), nil
 }
-func (b *backendMock) BlobBaseFee(ctx context.Context) *big.Int { return big.NewInt(42) }
+func (b *backendMock) BlobBaseFee(ctx context.Context) *big.Int {
+	if b.current.ExcessBlobGas == nil {
+		return nil
+	}
+	return eip4844.CalcBlobFee(*b.current.ExcessBlobGas)
+}
+func (b *backendMock) FeePrediction(ctx context.Context, blocks uint64) (*rpctypes.FeePredictionResult, error) {
+	return nil, nil
+}
+func (b *backendMock) SuggestBlobFeeCap(ctx context.Context, horizon uint64) (*big.Int, error) {
+	if b.current.ExcessBlobGas == nil {
+		return eip4844.CalcBlobFee(0), nil
+	}
+	if horizon == 0 {
+		horizon = gasprice.DefaultBlobFeeHorizon
+	}
+	// Extrapolate the excess blob gas like the oracle, never below the current fee
+	excess := max(int64(*b.current.ExcessBlobGas)+b.blobRate*int64(horizon-1), 0)
+	return math.BigMax(eip4844.CalcBlobFee(uint64(excess)), b.BlobBaseFee(ctx)), nil
+}
 
 func (b *backendMock) CurrentHeader() *types.Header     { return b.current }
 func (b *backendMock) ChainConfig() *params.ChainConfig { return b.config }
 
 // Other methods needed to implement Backend interface.
 func (b *backendMock) SyncProgress() ethereum.SyncProgress { return ethereum.SyncProgress{} }
-func (b *backendMock) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, []*big.Int, []float64, error) {
-	return nil, nil, nil, nil, nil, nil, nil
+func (b *backendMock) FeeHistory(ctx context.Context, blockCount uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, []*big.Int, []float64, [][]*big.Int, error) {
+	return nil, nil, nil, nil, nil, nil, nil, nil
 }
 func (b *backendMock) ChainDb() ethdb.Database           { return nil }
 func (b *backendMock) AccountManager() *accounts.Manager { return nil }
@@ -331,6 +392,8 @@ func (b *backendMock) RPCGasCap() uint64                 { return 0 }
 func (b *backendMock) RPCEVMTimeout() time.Duration      { return time.Second }
 func (b *backendMock) RPCTxFeeCap() float64              { return 0 }
 func (b *backendMock) UnprotectedAllowed() bool          { return false }
+func (b *backendMock) ResponseCache() *ResponseCache     { return nil }
+func (b *backendMock) ErrorRegistry() *abi.ErrorRegistry { return nil }
 func (b *backendMock) SetHead(number uint64)             {}
 func (b *backendMock) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
 	return nil, nil
@@ -360,6 +423,9 @@ func (b *backendMock) StateAndHeaderByNumber(ctx context.Context, number rpc.Blo
 func (b *backendMock) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
 	return nil, nil, nil
 }
+func (b *backendMock) StateAndHeaderAtTransaction(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, txIndex int) (*state.StateDB, *types.Header, func(), error) {
+	return nil, nil, nil, nil
+}
 func (b *backendMock) Pending() (*types.Block, types.Receipts, *state.StateDB) { return nil, nil, nil }
 func (b *backendMock) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	return nil, nil
@@ -397,6 +463,7 @@ func (b *backendMock) TxPoolContentFrom(addr common.Address) ([]*types.Transacti
 func (b *backendMock) SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription      { return nil }
 func (b *backendMock) BloomStatus() (uint64, uint64)                                        { return 0, 0 }
 func (b *backendMock) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {}
+func (b *backendMock) LogIndex() *logindex.Indexer                                          { return nil }
 func (b *backendMock) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription         { return nil }
 func (b *backendMock) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return nil
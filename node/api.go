@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/rpctypes"
 )
 
 // apis returns the collection of built-in RPC APIs.
@@ -179,8 +180,9 @@ func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *stri
 		Vhosts:             api.node.config.HTTPVirtualHosts,
 		Modules:            api.node.config.HTTPModules,
 		rpcEndpointConfig: rpcEndpointConfig{
-			batchItemLimit:         api.node.config.BatchRequestLimit,
-			batchResponseSizeLimit: api.node.config.BatchResponseMaxSize,
+			batchItemLimit:          api.node.config.BatchRequestLimit,
+			batchResponseSizeLimit:  api.node.config.BatchResponseMaxSize,
+			streamResponseSizeLimit: api.node.config.StreamResponseMaxSize,
 		},
 	}
 	if cors != nil {
@@ -257,8 +259,9 @@ func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, ap
 		Origins: api.node.config.WSOrigins,
 		// ExposeAll: api.node.config.WSExposeAll,
 		rpcEndpointConfig: rpcEndpointConfig{
-			batchItemLimit:         api.node.config.BatchRequestLimit,
-			batchResponseSizeLimit: api.node.config.BatchResponseMaxSize,
+			batchItemLimit:          api.node.config.BatchRequestLimit,
+			batchResponseSizeLimit:  api.node.config.BatchResponseMaxSize,
+			streamResponseSizeLimit: api.node.config.StreamResponseMaxSize,
 		},
 	}
 	if apis != nil {
@@ -332,6 +335,12 @@ func (s *web3API) ClientVersion() string {
 	return s.stack.Server().Name
 }
 
+// ApiVersion returns the version of the types of the RPC API results, see
+// package rpctypes.
+func (s *web3API) ApiVersion() string {
+	return rpctypes.Version
+}
+
 // Sha3 applies the ethereum sha3 implementation on the input.
 // It assumes the input is hex encoded.
 func (s *web3API) Sha3(input hexutil.Bytes) hexutil.Bytes {
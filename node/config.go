@@ -24,6 +24,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -190,6 +191,24 @@ type Config struct {
 	// Requests using ip address directly are not affected
 	GraphQLVirtualHosts []string `toml:",omitempty"`
 
+	// GraphQLMaxDepth is the maximum nesting depth of GraphQL queries. Zero means
+	// no limit.
+	GraphQLMaxDepth int `toml:",omitempty"`
+
+	// GraphQLMaxCost is the maximum estimated cost of GraphQL queries, which is
+	// roughly the number of objects they resolve. Queries exceeding it are
+	// rejected before execution. Zero means no limit.
+	GraphQLMaxCost uint64 `toml:",omitempty"`
+
+	// GraphQLTimeout is the maximum execution time of GraphQL queries, and of every
+	// event delivered to WebSocket subscriptions. Queries over HTTP are limited by
+	// the HTTP write timeout regardless.
+	GraphQLTimeout time.Duration `toml:",omitempty"`
+
+	// GraphQLFieldCosts overrides the weights of GraphQL fields used for computing
+	// query costs. Keys are of the form "Type.field".
+	GraphQLFieldCosts map[string]uint64 `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -204,6 +223,10 @@ type Config struct {
 	// BatchResponseMaxSize is the maximum number of bytes returned from a batched rpc call.
 	BatchResponseMaxSize int `toml:",omitempty"`
 
+	// StreamResponseMaxSize is the maximum number of bytes returned from a single
+	// streamed rpc call. Zero means no limit.
+	StreamResponseMaxSize int `toml:",omitempty"`
+
 	// JWTSecret is the path to the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
 
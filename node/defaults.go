@@ -67,6 +67,8 @@ var DefaultConfig = Config{
 	BatchRequestLimit:    1000,
 	BatchResponseMaxSize: 25 * 1000 * 1000,
 	GraphQLVirtualHosts:  []string{"localhost"},
+	GraphQLMaxDepth:      20,
+	GraphQLMaxCost:       100000,
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   50,
@@ -105,6 +105,7 @@ func New(conf *Config) (*Node, error) {
 	}
 	server := rpc.NewServer()
 	server.SetBatchLimits(conf.BatchRequestLimit, conf.BatchResponseMaxSize)
+	server.SetStreamResponseLimit(conf.StreamResponseMaxSize)
 	node := &Node{
 		config:        conf,
 		inprocHandler: server,
@@ -403,8 +404,9 @@ func (n *Node) startRPC() error {
 	)
 
 	rpcConfig := rpcEndpointConfig{
-		batchItemLimit:         n.config.BatchRequestLimit,
-		batchResponseSizeLimit: n.config.BatchResponseMaxSize,
+		batchItemLimit:          n.config.BatchRequestLimit,
+		batchResponseSizeLimit:  n.config.BatchResponseMaxSize,
+		streamResponseSizeLimit: n.config.StreamResponseMaxSize,
 	}
 
 	initHttp := func(server *httpServer, port int) error {
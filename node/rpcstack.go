@@ -54,10 +54,11 @@ type wsConfig struct {
 }
 
 type rpcEndpointConfig struct {
-	jwtSecret              []byte // optional JWT secret
-	batchItemLimit         int
-	batchResponseSizeLimit int
-	httpBodyLimit          int
+	jwtSecret               []byte // optional JWT secret
+	batchItemLimit          int
+	batchResponseSizeLimit  int
+	streamResponseSizeLimit int
+	httpBodyLimit           int
 }
 
 type rpcHandler struct {
@@ -202,8 +203,13 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if ws != nil && isWebsocket(r) {
 		if checkPath(r, h.wsConfig.prefix) {
 			ws.ServeHTTP(w, r)
+			return
+		}
+		// Handlers registered via Node.RegisterHandler may accept websocket
+		// connections as well, let the mux route requests to their paths.
+		if _, pattern := h.mux.Handler(r); pattern == "" {
+			return
 		}
-		return
 	}
 
 	// if http-rpc is enabled, try to serve request
@@ -306,6 +312,7 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
 	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	srv.SetStreamResponseLimit(config.streamResponseSizeLimit)
 	if config.httpBodyLimit > 0 {
 		srv.SetHTTPBodyLimit(config.httpBodyLimit)
 	}
@@ -341,6 +348,7 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
 	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	srv.SetStreamResponseLimit(config.streamResponseSizeLimit)
 	if config.httpBodyLimit > 0 {
 		srv.SetHTTPBodyLimit(config.httpBodyLimit)
 	}
@@ -412,6 +420,13 @@ func NewWSHandlerStack(srv http.Handler, jwtSecret []byte) http.Handler {
 	return srv
 }
 
+// NewVHostHandler returns a handler which only serves requests addressed to the
+// given virtual hosts. Unlike NewHTTPHandlerStack, it leaves the response writer
+// untouched, so that WebSocket connections can still be hijacked.
+func NewVHostHandler(srv http.Handler, vhosts []string) http.Handler {
+	return newVHostHandler(vhosts, srv)
+}
+
 func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
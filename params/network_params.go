@@ -32,6 +32,10 @@ const (
 	// considered probably final and its rotated bits are calculated.
 	BloomConfirms = 256
 
+	// LogIndexEpochBlocks is the number of blocks covered by a single log index
+	// epoch, the unit in which the log index is stored in the database.
+	LogIndexEpochBlocks uint64 = 4096
+
 	// CHTFrequency is the block frequency for creating CHTs
 	CHTFrequency = 32768
 
@@ -85,9 +85,14 @@ type Client struct {
 	// This function, if non-nil, is called when the connection is lost.
 	reconnectFunc reconnectFunc
 
+	// multi is set for clients created by DialMulti. All requests are forwarded
+	// to the endpoint clients it manages.
+	multi *multiClient
+
 	// config fields
-	batchItemLimit       int
-	batchResponseMaxSize int
+	batchItemLimit        int
+	batchResponseMaxSize  int
+	streamResponseMaxSize int
 
 	// writeConn is used for writing to the connection on the caller's goroutine. It should
 	// only be accessed outside of dispatch, with the write lock held. The write lock is
@@ -119,7 +124,7 @@ func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, clientContextKey{}, c)
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, conn.peerInfo())
-	handler := newHandler(ctx, conn, c.idgen, c.services, c.batchItemLimit, c.batchResponseMaxSize)
+	handler := newHandler(ctx, conn, c.idgen, c.services, c.batchItemLimit, c.batchResponseMaxSize, c.streamResponseMaxSize)
 	return &clientConn{conn, handler}
 }
 
@@ -242,21 +247,22 @@ func newClient(initctx context.Context, cfg *clientConfig, connect reconnectFunc
 func initClient(conn ServerCodec, services *serviceRegistry, cfg *clientConfig) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
-		isHTTP:               isHTTP,
-		services:             services,
-		idgen:                cfg.idgen,
-		batchItemLimit:       cfg.batchItemLimit,
-		batchResponseMaxSize: cfg.batchResponseLimit,
-		writeConn:            conn,
-		close:                make(chan struct{}),
-		closing:              make(chan struct{}),
-		didClose:             make(chan struct{}),
-		reconnected:          make(chan ServerCodec),
-		readOp:               make(chan readOp),
-		readErr:              make(chan error),
-		reqInit:              make(chan *requestOp),
-		reqSent:              make(chan error, 1),
-		reqTimeout:           make(chan *requestOp),
+		isHTTP:                isHTTP,
+		services:              services,
+		idgen:                 cfg.idgen,
+		batchItemLimit:        cfg.batchItemLimit,
+		batchResponseMaxSize:  cfg.batchResponseLimit,
+		streamResponseMaxSize: cfg.streamResponseLimit,
+		writeConn:             conn,
+		close:                 make(chan struct{}),
+		closing:               make(chan struct{}),
+		didClose:              make(chan struct{}),
+		reconnected:           make(chan ServerCodec),
+		readOp:                make(chan readOp),
+		readErr:               make(chan error),
+		reqInit:               make(chan *requestOp),
+		reqSent:               make(chan error, 1),
+		reqTimeout:            make(chan *requestOp),
 	}
 
 	// Set defaults.
@@ -296,6 +302,10 @@ func (c *Client) SupportedModules() (map[string]string, error) {
 
 // Close closes the client, aborting any in-flight requests.
 func (c *Client) Close() {
+	if c.multi != nil {
+		c.multi.close()
+		return
+	}
 	if c.isHTTP {
 		return
 	}
@@ -310,6 +320,10 @@ func (c *Client) Close() {
 // This method only works for clients using HTTP, it doesn't have
 // any effect for clients using another transport.
 func (c *Client) SetHeader(key, value string) {
+	if c.multi != nil {
+		c.multi.setHeader(key, value)
+		return
+	}
 	if !c.isHTTP {
 		return
 	}
@@ -338,6 +352,9 @@ func (c *Client) CallContext(ctx context.Context, result interface{}, method str
 	if result != nil && reflect.TypeOf(result).Kind() != reflect.Ptr {
 		return fmt.Errorf("call result parameter must be pointer or nil interface: %v", result)
 	}
+	if c.multi != nil {
+		return c.multi.call(ctx, result, method, args...)
+	}
 	msg, err := c.newMessage(method, args...)
 	if err != nil {
 		return err
@@ -397,6 +414,9 @@ func (c *Client) BatchCall(b []BatchElem) error {
 //
 // Note that batch calls may not be executed atomically on the server side.
 func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
+	if c.multi != nil {
+		return c.multi.batchCall(ctx, b)
+	}
 	var (
 		msgs = make([]*jsonrpcMessage, len(b))
 		byID = make(map[string]int, len(b))
@@ -468,6 +488,9 @@ func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
 
 // Notify sends a notification, i.e. a method call that doesn't expect a response.
 func (c *Client) Notify(ctx context.Context, method string, args ...interface{}) error {
+	if c.multi != nil {
+		return c.multi.notify(ctx, method, args...)
+	}
 	op := new(requestOp)
 	msg, err := c.newMessage(method, args...)
 	if err != nil {
@@ -513,6 +536,9 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 	if chanVal.IsNil() {
 		panic("channel given to Subscribe must not be nil")
 	}
+	if c.multi != nil {
+		return c.multi.subscribe(ctx, c, namespace, chanVal, args...)
+	}
 	if c.isHTTP {
 		return nil, ErrNotificationsUnsupported
 	}
@@ -542,6 +568,9 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 // transport. When this returns false, Subscribe and related methods will return
 // ErrNotificationsUnsupported.
 func (c *Client) SupportsSubscriptions() bool {
+	if c.multi != nil {
+		return c.multi.supportsSubscriptions()
+	}
 	return !c.isHTTP
 }
 
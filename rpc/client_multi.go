@@ -0,0 +1,604 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// EndpointSelection is the strategy a multi-endpoint client uses to pick the
+// endpoint serving a request.
+type EndpointSelection int
+
+const (
+	// SelectRoundRobin cycles through all healthy endpoints.
+	SelectRoundRobin EndpointSelection = iota
+
+	// SelectLowestLatency sends requests to the healthy endpoint with the lowest
+	// observed response time.
+	SelectLowestLatency
+)
+
+const (
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultHealthCheckMethod   = "rpc_modules"
+	defaultFailoverRetries     = 2
+
+	// latencyWeight is the weight of a new sample in the per-endpoint moving
+	// average of response times.
+	latencyWeight = 0.2
+
+	// resubscribeBackoff is the delay between attempts to re-establish a
+	// subscription when no endpoint is able to serve it.
+	resubscribeBackoff = time.Second
+)
+
+// ErrNoEndpoint is returned by clients of multiple endpoints if none of them is
+// connected.
+var ErrNoEndpoint = errors.New("no available RPC endpoint")
+
+// idempotentPrefixes are the method name prefixes which are retried on another
+// endpoint by default when a transport error occurs.
+var idempotentPrefixes = []string{
+	"eth_get", "eth_call", "eth_estimateGas", "eth_createAccessList", "eth_blockNumber",
+	"eth_chainId", "eth_gasPrice", "eth_maxPriorityFeePerGas", "eth_feeHistory",
+	"eth_blobBaseFee", "eth_syncing", "net_", "web3_", "rpc_", "debug_trace", "txpool_",
+}
+
+// filterMethods are the methods operating on a filter installed on a single server.
+// They are always sent to the endpoint which created the filter and never retried
+// elsewhere.
+var filterMethods = map[string]bool{
+	"eth_getFilterChanges": true,
+	"eth_getFilterLogs":    true,
+	"eth_uninstallFilter":  true,
+}
+
+// filterCreators are the methods installing a filter, whose result is the filter ID.
+var filterCreators = map[string]bool{
+	"eth_newFilter":                   true,
+	"eth_newBlockFilter":              true,
+	"eth_newPendingTransactionFilter": true,
+}
+
+// DefaultIdempotentMethod reports whether method is considered free of side effects,
+// i.e. whether a multi-endpoint client may safely send it again to another endpoint.
+// Filter methods are not, as filters only exist on the server which created them.
+func DefaultIdempotentMethod(method string) bool {
+	if filterMethods[method] || filterCreators[method] {
+		return false
+	}
+	for _, prefix := range idempotentPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// endpoint is a single server of a multi-endpoint client.
+type endpoint struct {
+	url     string
+	client  atomic.Pointer[Client] // nil until the endpoint could be dialed
+	healthy atomic.Bool
+	latency atomic.Int64 // moving average of response times in nanoseconds
+}
+
+// observe records a successful request with the given response time.
+func (ep *endpoint) observe(elapsed time.Duration) {
+	ep.healthy.Store(true)
+	for {
+		old := ep.latency.Load()
+		avg := int64(elapsed)
+		if old != 0 {
+			avg = int64(float64(old)*(1-latencyWeight) + float64(elapsed)*latencyWeight)
+		}
+		if ep.latency.CompareAndSwap(old, avg) {
+			return
+		}
+	}
+}
+
+// fail marks the endpoint unhealthy until the next successful health check.
+func (ep *endpoint) fail(err error) {
+	if ep.healthy.Swap(false) {
+		log.Debug("RPC endpoint became unhealthy", "url", ep.url, "err", err)
+	}
+}
+
+// multiClient distributes requests of a Client across several endpoints.
+type multiClient struct {
+	endpoints  []*endpoint
+	options    []ClientOption
+	selection  EndpointSelection
+	retries    int
+	idempotent func(method string) bool
+
+	healthInterval time.Duration
+	healthMethod   string
+
+	next     atomic.Uint32 // round-robin counter
+	headerMu sync.Mutex
+	headers  http.Header // headers set after dialing, applied to endpoints dialed later
+	filterMu sync.Mutex
+	filters  map[string]*endpoint // endpoint which created each filter
+	quit     chan struct{}
+	quitOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// DialMulti creates a client which spreads requests over several RPC endpoints. All
+// URLs must be served by nodes of the same network. Use DialOptions for the
+// supported URL schemes; the given options apply to the connections of all endpoints.
+//
+// Endpoints are chosen according to the configured EndpointSelection and are
+// periodically health checked. Calls to idempotent methods are retried on another
+// endpoint if a transport error occurs. Filters created with eth_newFilter and
+// friends are tracked, and methods operating on them are sent to the endpoint which
+// created the filter. Subscriptions are re-established on another
+// endpoint when the serving connection is lost, which is signaled on
+// ClientSubscription.Resubscribed. Notifications emitted while the subscription is
+// being moved are lost.
+//
+// The returned client can be used like any other client, e.g. to create an
+// ethclient.Client. Dialing fails only if none of the endpoints is reachable.
+func DialMulti(ctx context.Context, urls []string, options ...ClientOption) (*Client, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no RPC endpoints given")
+	}
+	cfg := new(clientConfig)
+	for _, opt := range options {
+		opt.applyOption(cfg)
+	}
+	m := &multiClient{
+		options:        options,
+		selection:      cfg.endpointSelection,
+		retries:        defaultFailoverRetries,
+		idempotent:     cfg.idempotentMethod,
+		healthInterval: defaultHealthCheckInterval,
+		healthMethod:   defaultHealthCheckMethod,
+		headers:        make(http.Header),
+		filters:        make(map[string]*endpoint),
+		quit:           make(chan struct{}),
+	}
+	if cfg.failoverRetries != nil {
+		m.retries = *cfg.failoverRetries
+	}
+	if m.idempotent == nil {
+		m.idempotent = DefaultIdempotentMethod
+	}
+	if cfg.healthCheckInterval != 0 {
+		m.healthInterval = cfg.healthCheckInterval
+	}
+	if cfg.healthCheckMethod != "" {
+		m.healthMethod = cfg.healthCheckMethod
+	}
+	var (
+		lastErr error
+		dialed  int
+	)
+	for _, url := range urls {
+		ep := &endpoint{url: url}
+		if err := m.dial(ctx, ep); err != nil {
+			log.Warn("Failed to dial RPC endpoint", "url", url, "err", err)
+			lastErr = err
+		} else {
+			dialed++
+		}
+		m.endpoints = append(m.endpoints, ep)
+	}
+	if dialed == 0 {
+		return nil, lastErr
+	}
+	m.wg.Add(1)
+	go m.healthLoop()
+
+	return &Client{
+		multi:    m,
+		services: new(serviceRegistry),
+		idgen:    randomIDGenerator(),
+	}, nil
+}
+
+// dial connects to the endpoint and marks it healthy on success. Headers set on
+// the client so far are applied to the new connection.
+func (m *multiClient) dial(ctx context.Context, ep *endpoint) error {
+	m.headerMu.Lock()
+	options := append(slices.Clip(m.options), WithHeaders(m.headers.Clone()))
+	m.headerMu.Unlock()
+
+	client, err := DialOptions(ctx, ep.url, options...)
+	if err != nil {
+		return err
+	}
+	// Catch up with headers set while dialing.
+	m.headerMu.Lock()
+	for key := range m.headers {
+		client.SetHeader(key, m.headers.Get(key))
+	}
+	ep.client.Store(client)
+	m.headerMu.Unlock()
+
+	ep.healthy.Store(true)
+	return nil
+}
+
+// supportsSubscriptions reports whether any of the endpoints can carry
+// subscriptions.
+func (m *multiClient) supportsSubscriptions() bool {
+	for _, ep := range m.endpoints {
+		if !strings.HasPrefix(ep.url, "http") {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the dialed endpoints in order of preference: healthy ones
+// first, ordered according to the selection strategy, followed by unhealthy ones
+// as a last resort.
+func (m *multiClient) candidates(subscribe bool) []*endpoint {
+	var healthy, unhealthy []*endpoint
+	for _, ep := range m.endpoints {
+		client := ep.client.Load()
+		if client == nil || (subscribe && !client.SupportsSubscriptions()) {
+			continue
+		}
+		if ep.healthy.Load() {
+			healthy = append(healthy, ep)
+		} else {
+			unhealthy = append(unhealthy, ep)
+		}
+	}
+	switch m.selection {
+	case SelectLowestLatency:
+		slices.SortStableFunc(healthy, func(a, b *endpoint) int {
+			return cmp.Compare(a.latency.Load(), b.latency.Load())
+		})
+	default:
+		if n := len(healthy); n > 1 {
+			start := int(m.next.Add(1) % uint32(n))
+			healthy = append(healthy[start:], healthy[:start]...)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// do runs fn on the preferred endpoint. If fn fails with a transport error and the
+// request is retryable, it is repeated on the next candidate endpoint. Requests
+// pinned to an endpoint are only sent there.
+func (m *multiClient) do(ctx context.Context, pinned *endpoint, retryable bool, fn func(*endpoint) error) error {
+	candidates := m.candidates(false)
+	if pinned != nil {
+		candidates, retryable = []*endpoint{pinned}, false
+	}
+	err := ErrNoEndpoint
+	for i, ep := range candidates {
+		if i > 0 && (!retryable || i > m.retries) {
+			break
+		}
+		start := time.Now()
+		if err = fn(ep); !isTransportError(err) {
+			ep.observe(time.Since(start))
+			return err
+		}
+		// Don't blame the endpoint if the caller gave up on the request.
+		if ctx.Err() != nil {
+			return err
+		}
+		ep.fail(err)
+	}
+	return err
+}
+
+func (m *multiClient) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	pinned := m.filterEndpoint(method, args)
+	return m.do(ctx, pinned, m.idempotent(method), func(ep *endpoint) error {
+		err := ep.client.Load().CallContext(ctx, result, method, args...)
+		m.trackFilter(ep, method, args, result, err)
+		return err
+	})
+}
+
+func (m *multiClient) batchCall(ctx context.Context, b []BatchElem) error {
+	var (
+		retryable = true
+		pinned    *endpoint
+	)
+	for _, elem := range b {
+		retryable = retryable && m.idempotent(elem.Method)
+		if pinned == nil {
+			pinned = m.filterEndpoint(elem.Method, elem.Args)
+		}
+	}
+	return m.do(ctx, pinned, retryable, func(ep *endpoint) error {
+		err := ep.client.Load().BatchCallContext(ctx, b)
+		if err == nil {
+			for _, elem := range b {
+				m.trackFilter(ep, elem.Method, elem.Args, elem.Result, elem.Error)
+			}
+		}
+		return err
+	})
+}
+
+// filterEndpoint returns the endpoint a filter method must be sent to, or nil if
+// method doesn't refer to a known filter.
+func (m *multiClient) filterEndpoint(method string, args []interface{}) *endpoint {
+	if !filterMethods[method] || len(args) == 0 {
+		return nil
+	}
+	id, ok := filterID(args[0])
+	if !ok {
+		return nil
+	}
+	m.filterMu.Lock()
+	defer m.filterMu.Unlock()
+	return m.filters[id]
+}
+
+// trackFilter records the endpoint of newly created filters, and forgets filters
+// which were uninstalled or are no longer known to their endpoint.
+func (m *multiClient) trackFilter(ep *endpoint, method string, args []interface{}, result interface{}, err error) {
+	var (
+		id string
+		ok bool
+	)
+	switch {
+	case filterCreators[method] && err == nil:
+		if id, ok = filterID(result); ok {
+			m.filterMu.Lock()
+			m.filters[id] = ep
+			m.filterMu.Unlock()
+		}
+	case filterMethods[method] && len(args) > 0:
+		var rpcErr Error
+		if method != "eth_uninstallFilter" && !errors.As(err, &rpcErr) {
+			return
+		}
+		if id, ok = filterID(args[0]); ok {
+			m.filterMu.Lock()
+			delete(m.filters, id)
+			m.filterMu.Unlock()
+		}
+	}
+}
+
+// filterID returns the string form of a filter ID given as argument or result.
+func filterID(v interface{}) (string, bool) {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	var id string
+	if err := json.Unmarshal(blob, &id); err != nil || id == "" {
+		return "", false
+	}
+	return id, true
+}
+
+func (m *multiClient) notify(ctx context.Context, method string, args ...interface{}) error {
+	return m.do(ctx, nil, false, func(ep *endpoint) error {
+		return ep.client.Load().Notify(ctx, method, args...)
+	})
+}
+
+// subscribe creates a subscription which survives the loss of the endpoint it
+// was created on.
+func (m *multiClient) subscribe(ctx context.Context, parent *Client, namespace string, channel reflect.Value, args ...interface{}) (*ClientSubscription, error) {
+	var (
+		sub  = newClientSubscription(parent, namespace, channel)
+		feed = make(chan json.RawMessage)
+	)
+	inner, err := m.subscribeInner(ctx, namespace, feed, args)
+	if err != nil {
+		return nil, err
+	}
+	sub.resubscribed = make(chan struct{}, 1)
+	go sub.run()
+
+	m.wg.Add(1)
+	go m.relay(sub, inner, feed, args)
+	return sub, nil
+}
+
+// subscribeInner establishes the subscription on the first endpoint accepting it.
+func (m *multiClient) subscribeInner(ctx context.Context, namespace string, feed chan json.RawMessage, args []interface{}) (*ClientSubscription, error) {
+	var err error = ErrNotificationsUnsupported
+	for _, ep := range m.candidates(true) {
+		var sub *ClientSubscription
+		sub, err = ep.client.Load().Subscribe(ctx, namespace, feed, args...)
+		if err == nil {
+			return sub, nil
+		}
+		if !isTransportError(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		ep.fail(err)
+	}
+	return nil, err
+}
+
+// relay forwards notifications from the endpoint subscription to sub, moving the
+// subscription to another endpoint whenever the current one fails.
+func (m *multiClient) relay(sub *ClientSubscription, inner *ClientSubscription, feed chan json.RawMessage, args []interface{}) {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case msg := <-feed:
+			if !sub.deliver(msg) {
+				inner.Unsubscribe()
+				return
+			}
+		case err := <-inner.Err():
+			inner.Unsubscribe()
+			log.Debug("RPC subscription lost, resubscribing", "namespace", sub.namespace, "err", err)
+			if inner = m.resubscribe(sub, feed, args); inner == nil {
+				return
+			}
+			select {
+			case sub.resubscribed <- struct{}{}:
+			default:
+			}
+		case <-sub.forwardDone:
+			inner.Unsubscribe()
+			return
+		case <-m.quit:
+			inner.Unsubscribe()
+			sub.close(ErrClientQuit)
+			return
+		}
+	}
+}
+
+// resubscribe retries establishing the subscription until it succeeds, or until
+// either the subscription or the client is closed, in which case nil is returned.
+func (m *multiClient) resubscribe(sub *ClientSubscription, feed chan json.RawMessage, args []interface{}) *ClientSubscription {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+		inner, err := m.subscribeInner(ctx, sub.namespace, feed, args)
+		cancel()
+		if err == nil {
+			return inner
+		}
+		if !isTransportError(err) {
+			sub.close(err)
+			return nil
+		}
+		select {
+		case <-time.After(resubscribeBackoff):
+		case <-sub.forwardDone:
+			return nil
+		case <-m.quit:
+			sub.close(ErrClientQuit)
+			return nil
+		}
+	}
+}
+
+// healthLoop periodically probes all endpoints, re-dialing those which could
+// not be reached so far.
+func (m *multiClient) healthLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.checkHealth()
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+func (m *multiClient) checkHealth() {
+	var wg sync.WaitGroup
+	for _, ep := range m.endpoints {
+		wg.Add(1)
+		go func(ep *endpoint) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), m.healthInterval)
+			defer cancel()
+
+			client := ep.client.Load()
+			if client == nil {
+				if err := m.dial(ctx, ep); err != nil {
+					return
+				}
+				log.Info("Connected to RPC endpoint", "url", ep.url)
+				client = ep.client.Load()
+			}
+			var (
+				start  = time.Now()
+				result json.RawMessage
+			)
+			if err := client.CallContext(ctx, &result, m.healthMethod); err != nil && isTransportError(err) {
+				ep.fail(err)
+				return
+			}
+			ep.observe(time.Since(start))
+		}(ep)
+	}
+	wg.Wait()
+}
+
+// close stops the health checks and closes all endpoint connections.
+func (m *multiClient) close() {
+	m.quitOnce.Do(func() {
+		close(m.quit)
+		m.wg.Wait()
+		for _, ep := range m.endpoints {
+			if client := ep.client.Load(); client != nil {
+				client.Close()
+			}
+		}
+	})
+}
+
+// setHeader sets an HTTP header on all endpoint connections, including the ones
+// dialed later on.
+func (m *multiClient) setHeader(key, value string) {
+	m.headerMu.Lock()
+	defer m.headerMu.Unlock()
+
+	m.headers.Set(key, value)
+	for _, ep := range m.endpoints {
+		if client := ep.client.Load(); client != nil {
+			client.SetHeader(key, value)
+		}
+	}
+}
+
+// isTransportError reports whether err was caused by the connection to the server
+// rather than by the server processing the request.
+func isTransportError(err error) bool {
+	var (
+		rpcErr     Error
+		httpErr    HTTPError
+		syntaxErr  *json.SyntaxError
+		typeErr    *json.UnmarshalTypeError
+		invalidErr *json.InvalidUnmarshalError
+	)
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &httpErr):
+		return httpErr.StatusCode >= http.StatusInternalServerError || httpErr.StatusCode == http.StatusTooManyRequests
+	case errors.As(err, &rpcErr), errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.As(err, &invalidErr):
+		return false
+	case errors.Is(err, ErrNoResult), errors.Is(err, ErrNotificationsUnsupported), errors.Is(err, ErrClientQuit):
+		return false
+	}
+	return true
+}
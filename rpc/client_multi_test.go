@@ -0,0 +1,320 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// identService reports the identity of the server it is registered on.
+type identService struct{ id int }
+
+func (s *identService) Ident() int { return s.id }
+
+func (s *identService) Subscription(ctx context.Context) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	go notifier.Notify(sub.ID, s.id)
+	return sub, nil
+}
+
+// filterService mimics the filter API, with filters only known to the server which
+// created them.
+type filterService struct {
+	id      int
+	mu      sync.Mutex
+	filters map[ID]bool
+}
+
+func (s *filterService) NewFilter() ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := NewID()
+	s.filters[id] = true
+	return id
+}
+
+func (s *filterService) GetFilterChanges(id ID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.filters[id] {
+		return 0, errors.New("filter not found")
+	}
+	return s.id, nil
+}
+
+func (s *filterService) UninstallFilter(id ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := s.filters[id]
+	delete(s.filters, id)
+	return found
+}
+
+func newIdentServer(t *testing.T, id int, handler func(*Server) *httptest.Server) (*Server, *httptest.Server) {
+	srv := NewServer()
+	if err := srv.RegisterName("ident", &identService{id: id}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RegisterName("eth", &filterService{id: id, filters: make(map[ID]bool)}); err != nil {
+		t.Fatal(err)
+	}
+	return srv, handler(srv)
+}
+
+func TestMultiClientFailover(t *testing.T) {
+	var (
+		srv1, hs1 = newIdentServer(t, 1, func(s *Server) *httptest.Server { return httptest.NewServer(s) })
+		srv2, hs2 = newIdentServer(t, 2, func(s *Server) *httptest.Server { return httptest.NewServer(s) })
+	)
+	defer srv1.Stop()
+	defer srv2.Stop()
+	defer hs2.Close()
+
+	client, err := DialMulti(context.Background(), []string{hs1.URL, hs2.URL}, WithIdempotentMethods(func(method string) bool {
+		return method == "ident_ident"
+	}))
+	if err != nil {
+		t.Fatal("can't dial:", err)
+	}
+	defer client.Close()
+
+	// Round-robin selection should hit both endpoints.
+	seen := make(map[int]bool)
+	for i := 0; i < 4; i++ {
+		var id int
+		if err := client.Call(&id, "ident_ident"); err != nil {
+			t.Fatal("call failed:", err)
+		}
+		seen[id] = true
+	}
+	if !seen[1] || !seen[2] {
+		t.Fatalf("requests not balanced across endpoints: %v", seen)
+	}
+	// Take down the first endpoint, idempotent calls must keep working.
+	hs1.Close()
+	for i := 0; i < 4; i++ {
+		var id int
+		if err := client.Call(&id, "ident_ident"); err != nil {
+			t.Fatal("call failed after endpoint went down:", err)
+		}
+		if id != 2 {
+			t.Fatalf("wrong endpoint served request: have %d, want 2", id)
+		}
+	}
+}
+
+func TestMultiClientResubscribe(t *testing.T) {
+	wsServer := func(s *Server) *httptest.Server {
+		return httptest.NewServer(s.WebsocketHandler([]string{"*"}))
+	}
+	var (
+		srv1, hs1 = newIdentServer(t, 1, wsServer)
+		srv2, hs2 = newIdentServer(t, 2, wsServer)
+		servers   = map[int]*Server{1: srv1, 2: srv2}
+		listeners = map[int]*httptest.Server{1: hs1, 2: hs2}
+	)
+	defer srv1.Stop()
+	defer srv2.Stop()
+	defer hs1.Close()
+	defer hs2.Close()
+
+	urls := []string{strings.Replace(hs1.URL, "http", "ws", 1), strings.Replace(hs2.URL, "http", "ws", 1)}
+	client, err := DialMulti(context.Background(), urls)
+	if err != nil {
+		t.Fatal("can't dial:", err)
+	}
+	defer client.Close()
+
+	ch := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "ident", ch, "subscription")
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	defer sub.Unsubscribe()
+
+	var first int
+	select {
+	case first = <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification received")
+	}
+	// Take down the serving endpoint, the subscription must move to the other one.
+	listeners[first].Close()
+	servers[first].Stop()
+
+	select {
+	case <-sub.Resubscribed():
+	case err := <-sub.Err():
+		t.Fatal("subscription failed:", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription not re-established")
+	}
+	select {
+	case id := <-ch:
+		if id == first {
+			t.Fatalf("notification from dead endpoint %d", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification received after resubscribing")
+	}
+}
+
+func TestMultiClientNonIdempotent(t *testing.T) {
+	srv, hs := newIdentServer(t, 1, func(s *Server) *httptest.Server { return httptest.NewServer(s) })
+	defer srv.Stop()
+
+	client, err := DialMulti(context.Background(), []string{hs.URL, hs.URL}, WithIdempotentMethods(func(string) bool { return false }))
+	if err != nil {
+		t.Fatal("can't dial:", err)
+	}
+	defer client.Close()
+
+	hs.Close()
+	var id int
+	if err := client.Call(&id, "ident_ident"); err == nil {
+		t.Fatal("non-idempotent call succeeded with all endpoints down")
+	}
+}
+
+func TestMultiClientFilters(t *testing.T) {
+	var (
+		srv1, hs1 = newIdentServer(t, 1, func(s *Server) *httptest.Server { return httptest.NewServer(s) })
+		srv2, hs2 = newIdentServer(t, 2, func(s *Server) *httptest.Server { return httptest.NewServer(s) })
+	)
+	defer srv1.Stop()
+	defer srv2.Stop()
+	defer hs1.Close()
+	defer hs2.Close()
+
+	for _, method := range []string{"eth_newFilter", "eth_getFilterChanges", "eth_getFilterLogs", "eth_uninstallFilter"} {
+		if DefaultIdempotentMethod(method) {
+			t.Errorf("filter method %s considered idempotent", method)
+		}
+	}
+	client, err := DialMulti(context.Background(), []string{hs1.URL, hs2.URL})
+	if err != nil {
+		t.Fatal("can't dial:", err)
+	}
+	defer client.Close()
+
+	// Filters created on both endpoints must be served by their creator.
+	owners := make(map[ID]int)
+	for i := 0; i < 4; i++ {
+		var id ID
+		if err := client.Call(&id, "eth_newFilter"); err != nil {
+			t.Fatal("can't create filter:", err)
+		}
+		owners[id] = 0
+	}
+	seen := make(map[int]bool)
+	for i := 0; i < 4; i++ {
+		for id := range owners {
+			var owner int
+			if err := client.Call(&owner, "eth_getFilterChanges", id); err != nil {
+				t.Fatalf("filter %s sent to wrong endpoint: %v", id, err)
+			}
+			if owners[id] != 0 && owners[id] != owner {
+				t.Fatalf("filter %s served by endpoints %d and %d", id, owners[id], owner)
+			}
+			owners[id] = owner
+			seen[owner] = true
+		}
+	}
+	if !seen[1] || !seen[2] {
+		t.Fatalf("filters not created on both endpoints: %v", seen)
+	}
+	// Batches are pinned as well, and uninstalling forgets the filter.
+	for id := range owners {
+		var owner int
+		var found bool
+		batch := []BatchElem{
+			{Method: "eth_getFilterChanges", Args: []any{id}, Result: &owner},
+			{Method: "eth_uninstallFilter", Args: []any{id}, Result: &found},
+		}
+		if err := client.BatchCall(batch); err != nil || batch[0].Error != nil || batch[1].Error != nil {
+			t.Fatalf("batch failed: %v %v %v", err, batch[0].Error, batch[1].Error)
+		}
+		if owner != owners[id] || !found {
+			t.Fatalf("batch sent to wrong endpoint: have %d, want %d", owner, owners[id])
+		}
+	}
+	if n := len(client.multi.filters); n != 0 {
+		t.Fatalf("uninstalled filters still tracked: %d", n)
+	}
+}
+
+// Tests that headers set on the client are sent to endpoints dialed afterwards.
+func TestMultiClientHeaders(t *testing.T) {
+	var (
+		srv     = NewServer()
+		mu      sync.Mutex
+		headers []string
+	)
+	defer srv.Stop()
+	if err := srv.RegisterName("ident", &identService{id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Get("X-Auth"))
+		mu.Unlock()
+		srv.ServeHTTP(w, r)
+	}))
+	defer hs.Close()
+
+	client, err := DialMulti(context.Background(), []string{hs.URL})
+	if err != nil {
+		t.Fatal("can't dial:", err)
+	}
+	defer client.Close()
+	client.SetHeader("X-Auth", "secret")
+
+	// Redial the endpoint, as the health checks do after failures.
+	ep := client.multi.endpoints[0]
+	if err := client.multi.dial(context.Background(), ep); err != nil {
+		t.Fatal("can't redial:", err)
+	}
+	var id int
+	if err := client.Call(&id, "ident_ident"); err != nil {
+		t.Fatal("call failed:", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(headers) == 0 || headers[len(headers)-1] != "secret" {
+		t.Fatalf("header not sent by redialed endpoint: %q", headers)
+	}
+}
+
+// Tests that calls fail with a dedicated error if no endpoint is connected.
+func TestMultiClientNoEndpoint(t *testing.T) {
+	m := &multiClient{}
+	err := m.do(context.Background(), nil, true, func(*endpoint) error { return nil })
+	if !errors.Is(err, ErrNoEndpoint) {
+		t.Fatalf("wrong error: have %v, want %v", err, ErrNoEndpoint)
+	}
+}
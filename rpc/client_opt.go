@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -38,9 +39,17 @@ type clientConfig struct {
 	wsMessageSizeLimit *int64 // wsMessageSizeLimit nil = default, 0 = no limit
 
 	// RPC handler options
-	idgen              func() ID
-	batchItemLimit     int
-	batchResponseLimit int
+	idgen               func() ID
+	batchItemLimit      int
+	batchResponseLimit  int
+	streamResponseLimit int
+
+	// Multi-endpoint options
+	endpointSelection   EndpointSelection
+	failoverRetries     *int
+	idempotentMethod    func(method string) bool
+	healthCheckInterval time.Duration
+	healthCheckMethod   string
 }
 
 func (cfg *clientConfig) initHeaders() {
@@ -142,3 +151,51 @@ func WithBatchResponseSizeLimit(sizeLimit int) ClientOption {
 		cfg.batchResponseLimit = sizeLimit
 	})
 }
+
+// WithStreamResponseSizeLimit changes the maximum number of bytes that can be generated
+// for a single streamed method result. When this limit is reached, the response is
+// terminated with an error.
+//
+// Note: this option applies when processing incoming requests. It does not affect
+// responses received by the client.
+func WithStreamResponseSizeLimit(sizeLimit int) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.streamResponseLimit = sizeLimit
+	})
+}
+
+// WithEndpointSelection configures how a client created by DialMulti chooses the
+// endpoint serving a request. The default is SelectRoundRobin.
+func WithEndpointSelection(selection EndpointSelection) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.endpointSelection = selection
+	})
+}
+
+// WithFailoverRetries configures how many other endpoints a client created by
+// DialMulti tries when an idempotent request fails with a transport error.
+// Passing zero disables retries.
+func WithFailoverRetries(retries int) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.failoverRetries = &retries
+	})
+}
+
+// WithIdempotentMethods configures the predicate deciding which methods a client
+// created by DialMulti may send to another endpoint after a transport error.
+// The default is DefaultIdempotentMethod.
+func WithIdempotentMethods(idempotent func(method string) bool) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.idempotentMethod = idempotent
+	})
+}
+
+// WithHealthCheck configures the interval and the method used by a client created
+// by DialMulti to probe its endpoints. An empty method keeps the default, which
+// is rpc_modules.
+func WithHealthCheck(interval time.Duration, method string) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.healthCheckInterval = interval
+		cfg.healthCheckMethod = method
+	})
+}
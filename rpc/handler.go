@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
@@ -52,19 +53,20 @@ import (
 //		h.removeRequestOp(op) // timeout, etc.
 //	}
 type handler struct {
-	reg                  *serviceRegistry
-	unsubscribeCb        *callback
-	idgen                func() ID                      // subscription ID generator
-	respWait             map[string]*requestOp          // active client requests
-	clientSubs           map[string]*ClientSubscription // active client subscriptions
-	callWG               sync.WaitGroup                 // pending call goroutines
-	rootCtx              context.Context                // canceled by close()
-	cancelRoot           func()                         // cancel function for rootCtx
-	conn                 jsonWriter                     // where responses will be sent
-	log                  log.Logger
-	allowSubscribe       bool
-	batchRequestLimit    int
-	batchResponseMaxSize int
+	reg                   *serviceRegistry
+	unsubscribeCb         *callback
+	idgen                 func() ID                      // subscription ID generator
+	respWait              map[string]*requestOp          // active client requests
+	clientSubs            map[string]*ClientSubscription // active client subscriptions
+	callWG                sync.WaitGroup                 // pending call goroutines
+	rootCtx               context.Context                // canceled by close()
+	cancelRoot            func()                         // cancel function for rootCtx
+	conn                  jsonWriter                     // where responses will be sent
+	log                   log.Logger
+	allowSubscribe        bool
+	batchRequestLimit     int
+	batchResponseMaxSize  int
+	streamResponseMaxSize int
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -75,21 +77,22 @@ type callProc struct {
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, batchRequestLimit, batchResponseMaxSize int) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, batchRequestLimit, batchResponseMaxSize, streamResponseMaxSize int) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
-		reg:                  reg,
-		idgen:                idgen,
-		conn:                 conn,
-		respWait:             make(map[string]*requestOp),
-		clientSubs:           make(map[string]*ClientSubscription),
-		rootCtx:              rootCtx,
-		cancelRoot:           cancelRoot,
-		allowSubscribe:       true,
-		serverSubs:           make(map[ID]*Subscription),
-		log:                  log.Root(),
-		batchRequestLimit:    batchRequestLimit,
-		batchResponseMaxSize: batchResponseMaxSize,
+		reg:                   reg,
+		idgen:                 idgen,
+		conn:                  conn,
+		respWait:              make(map[string]*requestOp),
+		clientSubs:            make(map[string]*ClientSubscription),
+		rootCtx:               rootCtx,
+		cancelRoot:            cancelRoot,
+		allowSubscribe:        true,
+		serverSubs:            make(map[ID]*Subscription),
+		log:                   log.Root(),
+		batchRequestLimit:     batchRequestLimit,
+		batchResponseMaxSize:  batchResponseMaxSize,
+		streamResponseMaxSize: streamResponseMaxSize,
 	}
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
@@ -228,6 +231,18 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 				break
 			}
 			resp := h.handleCallMsg(cp, msg)
+			if resp != nil && resp.stream != nil {
+				// Streamed results are materialized in batches, but only up to the
+				// remaining response size allowance.
+				limit := 0
+				if h.batchResponseMaxSize != 0 {
+					limit = max(h.batchResponseMaxSize-responseBytes, 1)
+				}
+				if err := resp.collectStream(limit); err == errResponseTooLarge {
+					callBuffer.respondWithError(cp.ctx, h.conn, err)
+					break
+				}
+			}
 			callBuffer.pushResponse(resp)
 			if resp != nil && h.batchResponseMaxSize != 0 {
 				responseBytes += len(resp.Result)
@@ -297,6 +312,13 @@ func (h *handler) handleNonBatchCall(cp *callProc, msg *jsonrpcMessage) {
 	}
 
 	answer := h.handleCallMsg(cp, msg)
+	if answer != nil && answer.stream != nil {
+		// Streamed results are produced while writing, keep the timeout running
+		// until the response is out.
+		responded.Do(func() {
+			h.writeStream(cp.ctx, answer)
+		})
+	}
 	if timer != nil {
 		timer.Stop()
 	}
@@ -311,6 +333,24 @@ func (h *handler) handleNonBatchCall(cp *callProc, msg *jsonrpcMessage) {
 	}
 }
 
+// writeStream sends a response carrying a streamed result. If the connection can't
+// write responses incrementally, the result is collected into memory first.
+func (h *handler) writeStream(ctx context.Context, answer *jsonrpcMessage) {
+	if sw, ok := h.conn.(streamWriter); ok {
+		err := sw.writeStream(ctx, func(w io.Writer) error {
+			return answer.writeStream(w, h.streamResponseMaxSize)
+		})
+		if err != errStreamUnsupported {
+			if err != nil {
+				h.log.Debug("Failed to write streamed response", "reqid", idForLog{answer.ID}, "err", err)
+			}
+			return
+		}
+	}
+	answer.collectStream(h.streamResponseMaxSize)
+	h.conn.writeJSON(ctx, answer, answer.Error != nil)
+}
+
 // close cancels all requests except for inflightReq and waits for
 // call goroutines to shut down.
 func (h *handler) close(err error, inflightReq *requestOp) {
@@ -569,6 +609,9 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 	if err != nil {
 		return msg.errorResponse(err)
 	}
+	if s, ok := result.(streamResult); ok {
+		return &jsonrpcMessage{Version: vsn, ID: msg.ID, stream: s}
+	}
 	return msg.response(result)
 }
 
@@ -291,7 +291,37 @@ func (s *Server) newHTTPServerConn(r *http.Request, w http.ResponseWriter) Serve
 	dec := json.NewDecoder(conn)
 	dec.UseNumber()
 
-	return NewFuncCodec(conn, encoder, dec.Decode)
+	codec := NewFuncCodec(conn, encoder, dec.Decode).(*jsonCodec)
+	codec.stream = func(fn func(io.Writer) error) error {
+		// Streamed responses have no known length and are sent using chunked
+		// encoding, flushing every piece as it is produced.
+		fw := &flushWriter{w: w}
+		err := fn(fw)
+		if err != nil && fw.written {
+			// Drop the connection without terminating the body, so the client
+			// can't mistake the partial response for a complete one.
+			if conn, _, herr := http.NewResponseController(w).Hijack(); herr == nil {
+				conn.Close()
+			}
+		}
+		return err
+	}
+	return codec
+}
+
+// flushWriter flushes the HTTP response after every write.
+type flushWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	w.written = true
+	n, err := w.w.Write(p)
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
 }
 
 // Close does nothing and always returns nil.
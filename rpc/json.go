@@ -66,6 +66,8 @@ type jsonrpcMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Error   *jsonError      `json:"error,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
+
+	stream streamResult // result to be encoded incrementally, replaces Result
 }
 
 func (msg *jsonrpcMessage) isNotification() bool {
@@ -184,13 +186,35 @@ type jsonCodec struct {
 	decode  decodeFunc       // decoder to allow multiple transports
 	encMu   sync.Mutex       // guards the encoder
 	encode  encodeFunc       // encoder to allow multiple transports
+	stream  streamFunc       // incremental encoder, nil if unsupported by the transport
 	conn    deadlineCloser
+
+	// While a streamed message is in flight, encMu is only held for writing its
+	// chunks. Other messages can't be interleaved with it, so they are queued
+	// and written once the stream is done.
+	streaming bool             // whether a stream is in flight, guarded by encMu
+	pending   []pendingMessage // messages queued behind the stream, guarded by encMu
+	streamEnd *sync.Cond       // signalled when a stream is done, uses encMu
 }
 
+// pendingMessage is a message queued for writing after the current stream.
+type pendingMessage struct {
+	v               interface{}
+	isErrorResponse bool
+}
+
+// maxPendingMessages is the number of messages which can be queued behind a stream
+// before writers start blocking until the stream is done.
+const maxPendingMessages = 1000
+
 type encodeFunc = func(v interface{}, isErrorResponse bool) error
 
 type decodeFunc = func(v interface{}) error
 
+// streamFunc provides fn with a writer for a single message. The message is complete
+// when fn returns.
+type streamFunc = func(fn func(io.Writer) error) error
+
 // NewFuncCodec creates a codec which uses the given functions to read and write. If conn
 // implements ConnRemoteAddr, log messages will use it to include the remote address of
 // the connection.
@@ -201,6 +225,7 @@ func NewFuncCodec(conn deadlineCloser, encode encodeFunc, decode decodeFunc) Ser
 		decode:  decode,
 		conn:    conn,
 	}
+	codec.streamEnd = sync.NewCond(&codec.encMu)
 	if ra, ok := conn.(ConnRemoteAddr); ok {
 		codec.remote = ra.RemoteAddr()
 	}
@@ -217,7 +242,11 @@ func NewCodec(conn Conn) ServerCodec {
 	encode := func(v interface{}, isErrorResponse bool) error {
 		return enc.Encode(v)
 	}
-	return NewFuncCodec(conn, encode, dec.Decode)
+	codec := NewFuncCodec(conn, encode, dec.Decode).(*jsonCodec)
+	codec.stream = func(fn func(io.Writer) error) error {
+		return fn(conn)
+	}
+	return codec
 }
 
 func (c *jsonCodec) peerInfo() PeerInfo {
@@ -251,6 +280,13 @@ func (c *jsonCodec) writeJSON(ctx context.Context, v interface{}, isErrorRespons
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
 
+	for c.streaming {
+		if len(c.pending) < maxPendingMessages {
+			c.pending = append(c.pending, pendingMessage{v, isErrorResponse})
+			return nil
+		}
+		c.streamEnd.Wait()
+	}
 	deadline, ok := ctx.Deadline()
 	if !ok {
 		deadline = time.Now().Add(defaultWriteTimeout)
@@ -259,6 +295,48 @@ func (c *jsonCodec) writeJSON(ctx context.Context, v interface{}, isErrorRespons
 	return c.encode(v, isErrorResponse)
 }
 
+// writeStream writes a single message in pieces, as produced by fn. The write
+// deadline is extended on every write unless the context carries a deadline.
+//
+// The encoder lock is only taken while a piece is written, so fn may take its time
+// producing the message without holding up other writers on the connection. Once the
+// first piece is out, messages can't be interleaved with it anymore and are queued
+// until the stream is done.
+func (c *jsonCodec) writeStream(ctx context.Context, fn func(io.Writer) error) error {
+	if c.stream == nil {
+		return errStreamUnsupported
+	}
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+
+	dw := &deadlineWriter{ctx: ctx, codec: c}
+	err := c.stream(func(w io.Writer) error {
+		dw.w = w
+		c.encMu.Unlock()
+		defer c.encMu.Lock()
+		return fn(dw)
+	})
+	if !dw.started {
+		return err
+	}
+	c.streaming = false
+	if err != nil {
+		// The message is incomplete and nothing else can be sent after it.
+		c.close()
+	}
+
+	// Flush the messages queued up while the stream was written.
+	for _, msg := range c.pending {
+		c.conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+		if err := c.encode(msg.v, msg.isErrorResponse); err != nil {
+			break
+		}
+	}
+	c.pending = nil
+	c.streamEnd.Broadcast()
+	return err
+}
+
 func (c *jsonCodec) close() {
 	c.closer.Do(func() {
 		close(c.closeCh)
@@ -271,6 +349,34 @@ func (c *jsonCodec) closed() <-chan interface{} {
 	return c.closeCh
 }
 
+// deadlineWriter writes the pieces of a streamed message with the encoder lock held,
+// setting the write deadline of the connection before every write.
+type deadlineWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	codec   *jsonCodec
+	started bool // whether the stream owns the connection, guarded by encMu
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	c := w.codec
+	c.encMu.Lock()
+	defer c.encMu.Unlock()
+
+	if !w.started {
+		for c.streaming {
+			c.streamEnd.Wait()
+		}
+		c.streaming, w.started = true, true
+	}
+	deadline, ok := w.ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultWriteTimeout)
+	}
+	c.conn.SetWriteDeadline(deadline)
+	return w.w.Write(p)
+}
+
 // parseMessage parses raw bytes as a (batch of) JSON-RPC message(s). There are no error
 // checks in this function because the raw message has already been syntax-checked when it
 // is called. Any non-JSON-RPC messages in the input return the zero value of
@@ -46,12 +46,13 @@ type Server struct {
 	services serviceRegistry
 	idgen    func() ID
 
-	mutex              sync.Mutex
-	codecs             map[ServerCodec]struct{}
-	run                atomic.Bool
-	batchItemLimit     int
-	batchResponseLimit int
-	httpBodyLimit      int
+	mutex               sync.Mutex
+	codecs              map[ServerCodec]struct{}
+	run                 atomic.Bool
+	batchItemLimit      int
+	batchResponseLimit  int
+	streamResponseLimit int
+	httpBodyLimit       int
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -80,6 +81,16 @@ func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
 	s.batchResponseLimit = maxResponseSize
 }
 
+// SetStreamResponseLimit sets the maximum number of bytes a single streamed method
+// result may encode to. Zero means no limit. Streamed results exceeding the limit are
+// terminated with an error.
+//
+// This method should be called before processing any requests via ServeCodec, ServeHTTP,
+// ServeListener etc.
+func (s *Server) SetStreamResponseLimit(maxResponseSize int) {
+	s.streamResponseLimit = maxResponseSize
+}
+
 // SetHTTPBodyLimit sets the size limit for HTTP requests.
 //
 // This method should be called before processing any requests via ServeHTTP.
@@ -109,9 +120,10 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	defer s.untrackCodec(codec)
 
 	cfg := &clientConfig{
-		idgen:              s.idgen,
-		batchItemLimit:     s.batchItemLimit,
-		batchResponseLimit: s.batchResponseLimit,
+		idgen:               s.idgen,
+		batchItemLimit:      s.batchItemLimit,
+		batchResponseLimit:  s.batchResponseLimit,
+		streamResponseLimit: s.streamResponseLimit,
 	}
 	c := initClient(codec, &s.services, cfg)
 	<-codec.closed()
@@ -145,7 +157,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services, s.batchItemLimit, s.batchResponseLimit)
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.batchItemLimit, s.batchResponseLimit, s.streamResponseLimit)
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -0,0 +1,215 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// streamFlushThreshold is the amount of encoded data buffered before it is written
+// out to the connection. Responses which fail before reaching the threshold are
+// replaced by a regular error response.
+const streamFlushThreshold = 64 * 1024
+
+var (
+	errResponseTooLarge  = &internalServerError{errcodeResponseTooLarge, errMsgResponseTooLarge}
+	errStreamUnsupported = errors.New("streaming not supported by transport")
+)
+
+// Stream is a lazily produced list of results. RPC methods returning a Stream
+// have their result encoded as a JSON array element by element while the
+// producer runs, instead of building the whole response in memory first.
+//
+// The producer function must call yield for every element in order, and stop
+// as soon as yield returns an error, returning that error. Any error returned
+// by the producer terminates the response. If some elements have already been
+// sent to the client at that point, the connection is closed since the partial
+// response can't be completed anymore.
+//
+// A Stream is consumed at most once per response, on the goroutine serving the
+// request. Producers may therefore acquire resources in the producer function
+// and release them before it returns.
+type Stream[T any] func(yield func(T) error) error
+
+// SliceStream returns a stream yielding the given items.
+func SliceStream[T any](items []T) Stream[T] {
+	return func(yield func(T) error) error {
+		for _, item := range items {
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Collect runs the producer and gathers all elements into a slice.
+func (s Stream[T]) Collect() ([]T, error) {
+	var items []T
+	if s == nil {
+		return items, nil
+	}
+	err := s(func(item T) error {
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+// MarshalJSON implements json.Marshaler, encoding the stream as an array. It
+// is used when the stream is encoded outside of the RPC server.
+func (s Stream[T]) MarshalJSON() ([]byte, error) {
+	enc := new(streamEncoder)
+	if err := s.encodeStream(enc); err != nil {
+		return nil, err
+	}
+	return enc.buf.Bytes(), nil
+}
+
+func (s Stream[T]) encodeStream(enc *streamEncoder) error {
+	if s == nil {
+		return enc.write(null)
+	}
+	if err := enc.write([]byte{'['}); err != nil {
+		return err
+	}
+	first := true
+	err := s(func(item T) error {
+		blob, err := json.Marshal(item)
+		if err != nil {
+			return &internalServerError{errcodeMarshalError, err.Error()}
+		}
+		if !first {
+			if err := enc.write([]byte{','}); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.write(blob)
+	})
+	if err != nil {
+		// Terminate the array regardless of limits, so the partial response
+		// remains well-formed if it has to be sent.
+		enc.buf.WriteByte(']')
+		return err
+	}
+	return enc.write([]byte{']'})
+}
+
+// streamResult is implemented by method results which are encoded incrementally.
+type streamResult interface {
+	encodeStream(enc *streamEncoder) error
+}
+
+// streamWriter is implemented by codecs which can write a single message in
+// multiple pieces, rather than encoding it in one go.
+type streamWriter interface {
+	writeStream(ctx context.Context, fn func(io.Writer) error) error
+}
+
+// streamEncoder accumulates the encoding of a streamed result. If w is set, data
+// is written out whenever enough of it is buffered, otherwise everything is kept
+// in memory. The total encoded size is checked against limit as data is added.
+type streamEncoder struct {
+	buf     bytes.Buffer
+	w       io.Writer // destination of buffered data, nil to buffer everything
+	size    int       // number of result bytes encoded so far
+	limit   int       // maximum result size in bytes, 0 if unlimited
+	flushed bool      // whether any data was written to w
+	err     error     // sticky write error
+}
+
+// write appends result data to the encoder.
+func (enc *streamEncoder) write(data []byte) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	enc.size += len(data)
+	if enc.limit != 0 && enc.size > enc.limit {
+		return errResponseTooLarge
+	}
+	enc.buf.Write(data)
+	if enc.w != nil && enc.buf.Len() >= streamFlushThreshold {
+		return enc.flush()
+	}
+	return nil
+}
+
+// flush writes all buffered data to the destination writer.
+func (enc *streamEncoder) flush() error {
+	if enc.err != nil {
+		return enc.err
+	}
+	enc.flushed = true
+	if _, err := enc.w.Write(enc.buf.Bytes()); err != nil {
+		enc.err = err
+		return err
+	}
+	enc.buf.Reset()
+	return nil
+}
+
+// collectStream encodes the streamed result of msg into memory, turning it into
+// a regular response. If encoding fails, msg is turned into an error response
+// and the error is returned.
+func (msg *jsonrpcMessage) collectStream(limit int) error {
+	enc := &streamEncoder{limit: limit}
+	err := msg.stream.encodeStream(enc)
+	msg.stream = nil
+	if err != nil {
+		*msg = *msg.errorResponse(err)
+		return err
+	}
+	msg.Result = enc.buf.Bytes()
+	return nil
+}
+
+// writeStream encodes the response msg, which must carry a streamed result, to w.
+// Errors are reported to the client if nothing was written yet. The returned
+// error is non-nil if the response could not be written completely.
+func (msg *jsonrpcMessage) writeStream(w io.Writer, limit int) error {
+	enc := &streamEncoder{w: w, limit: limit}
+	enc.buf.WriteString(`{"jsonrpc":"2.0","id":`)
+	enc.buf.Write(msg.ID)
+	enc.buf.WriteString(`,"result":`)
+
+	err := msg.stream.encodeStream(enc)
+	switch {
+	case err == nil:
+		enc.buf.WriteString("}\n")
+
+	case enc.err != nil:
+		return enc.err
+
+	case !enc.flushed:
+		// Nothing was sent yet, replace the response with a plain error.
+		enc.buf.Reset()
+		blob, _ := json.Marshal(msg.errorResponse(err))
+		enc.buf.Write(blob)
+		enc.buf.WriteByte('\n')
+
+	default:
+		// Part of the result is already out and the response can't carry an
+		// error anymore. The connection is dropped instead.
+		return err
+	}
+	return enc.flush()
+}
@@ -0,0 +1,265 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamService produces streamed results of configurable length.
+type streamService struct {
+	started chan struct{} // signalled when Wait starts producing
+	release chan struct{} // closed to let Wait finish
+}
+
+// Range streams the numbers [0, n). If fail is non-negative, the stream aborts
+// with an error after producing that many elements.
+func (s *streamService) Range(n int, fail int) Stream[int] {
+	return func(yield func(int) error) error {
+		for i := 0; i < n; i++ {
+			if i == fail {
+				return errors.New("stream failed")
+			}
+			if err := yield(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Echo returns n, as a plain response.
+func (s *streamService) Echo(n int) int {
+	return n
+}
+
+// Wait streams the numbers [0, n), then waits for release before sending n.
+func (s *streamService) Wait(n int) Stream[int] {
+	return func(yield func(int) error) error {
+		for i := 0; i < n; i++ {
+			if err := yield(i); err != nil {
+				return err
+			}
+		}
+		s.started <- struct{}{}
+		<-s.release
+		return yield(n)
+	}
+}
+
+func newStreamServer(t *testing.T) *Server {
+	server := NewServer()
+	if err := server.RegisterName("stream", new(streamService)); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestStreamResponse(t *testing.T) {
+	server := newStreamServer(t)
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+	wssrv := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer wssrv.Close()
+
+	for _, transport := range []string{"inproc", "http", "ws"} {
+		var (
+			client *Client
+			err    error
+		)
+		switch transport {
+		case "inproc":
+			client = DialInProc(server)
+		case "http":
+			client, err = Dial(httpsrv.URL)
+		case "ws":
+			client, err = Dial(strings.Replace(wssrv.URL, "http", "ws", 1))
+		}
+		if err != nil {
+			t.Fatalf("%s: can't dial: %v", transport, err)
+		}
+		// Results spanning multiple flushes must arrive intact.
+		for _, n := range []int{0, 10, 100000} {
+			var result []int
+			if err := client.Call(&result, "stream_range", n, -1); err != nil {
+				t.Fatalf("%s: call with n=%d failed: %v", transport, n, err)
+			}
+			if len(result) != n {
+				t.Fatalf("%s: wrong result length: have %d, want %d", transport, len(result), n)
+			}
+			for i, v := range result {
+				if v != i {
+					t.Fatalf("%s: wrong element %d: %d", transport, i, v)
+				}
+			}
+		}
+		// Failures before data was flushed must surface as errors, failures
+		// after it must not yield a result.
+		var result []int
+		err = client.Call(&result, "stream_range", 100000, 5)
+		if err == nil || err.Error() != "stream failed" {
+			t.Fatalf("%s: wrong error for early failure: %v", transport, err)
+		}
+		if err := client.Call(&result, "stream_range", 100000, 90000); err == nil {
+			t.Fatalf("%s: no error for failure after partial response", transport)
+		}
+		client.Close()
+	}
+}
+
+// This test checks that other responses on the connection are not held up while a
+// streamed response is being produced, and are queued behind it once it has started.
+func TestStreamResponseConcurrent(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	service := &streamService{started: make(chan struct{}, 1)}
+	if err := server.RegisterName("stream", service); err != nil {
+		t.Fatal(err)
+	}
+	wssrv := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer wssrv.Close()
+
+	for _, transport := range []string{"inproc", "ws"} {
+		var (
+			client *Client
+			err    error
+		)
+		switch transport {
+		case "inproc":
+			client = DialInProc(server)
+		case "ws":
+			client, err = Dial(strings.Replace(wssrv.URL, "http", "ws", 1))
+		}
+		if err != nil {
+			t.Fatalf("%s: can't dial: %v", transport, err)
+		}
+		// n=0 blocks before anything is sent, n=100000 after the first flush.
+		for _, n := range []int{0, 100000} {
+			service.release = make(chan struct{})
+			waitErr := make(chan error, 1)
+			go func() {
+				var result []int
+				err := client.Call(&result, "stream_wait", n)
+				if err == nil && len(result) != n+1 {
+					err = fmt.Errorf("wrong result length %d", len(result))
+				}
+				waitErr <- err
+			}()
+			<-service.started
+
+			echoErr := make(chan error, 1)
+			go func() {
+				var result int
+				err := client.Call(&result, "stream_echo", 10)
+				if err == nil && result != 10 {
+					err = fmt.Errorf("wrong result %d", result)
+				}
+				echoErr <- err
+			}()
+			if n == 0 {
+				if err := <-echoErr; err != nil {
+					t.Fatalf("%s: call failed: %v", transport, err)
+				}
+				close(service.release)
+			} else {
+				select {
+				case err := <-echoErr:
+					t.Fatalf("%s: response interleaved with started stream: %v", transport, err)
+				case <-time.After(100 * time.Millisecond):
+				}
+				close(service.release)
+				if err := <-echoErr; err != nil {
+					t.Fatalf("%s: queued call failed: %v", transport, err)
+				}
+			}
+			if err := <-waitErr; err != nil {
+				t.Fatalf("%s: stream with n=%d failed: %v", transport, n, err)
+			}
+		}
+		client.Close()
+	}
+}
+
+func TestStreamResponseSizeLimit(t *testing.T) {
+	server := newStreamServer(t)
+	defer server.Stop()
+	server.SetStreamResponseLimit(1000)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	var result []int
+	if err := client.Call(&result, "stream_range", 10, -1); err != nil {
+		t.Fatal("small stream failed:", err)
+	}
+	err := client.Call(&result, "stream_range", 1000, -1)
+	if re, ok := err.(Error); !ok || re.ErrorCode() != errcodeResponseTooLarge {
+		t.Fatalf("wrong error for oversized stream: %v", err)
+	}
+}
+
+func TestStreamBatchResponseSizeLimit(t *testing.T) {
+	server := newStreamServer(t)
+	defer server.Stop()
+	server.SetBatchLimits(100, 1000)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := []BatchElem{
+		{Method: "stream_range", Args: []any{10, -1}, Result: new([]int)},
+		{Method: "stream_range", Args: []any{1000, -1}, Result: new([]int)},
+		{Method: "stream_range", Args: []any{10, -1}, Result: new([]int)},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal("error sending batch:", err)
+	}
+	if batch[0].Error != nil || len(*batch[0].Result.(*[]int)) != 10 {
+		t.Fatalf("first batch elem failed: %v", batch[0].Error)
+	}
+	for i := 1; i < len(batch); i++ {
+		if re, ok := batch[i].Error.(Error); !ok || re.ErrorCode() != errcodeResponseTooLarge {
+			t.Fatalf("batch elem %d has wrong error: %v", i, batch[i].Error)
+		}
+	}
+}
+
+func TestStreamMarshalJSON(t *testing.T) {
+	var s streamService
+	blob, err := json.Marshal(s.Range(3, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blob) != "[0,1,2]" {
+		t.Fatalf("wrong encoding: %s", blob)
+	}
+	if _, err := json.Marshal(s.Range(3, 1)); err == nil {
+		t.Fatal("expected error from failing stream")
+	}
+	items, err := s.Range(3, -1).Collect()
+	if err != nil || len(items) != 3 {
+		t.Fatalf("wrong collected items: %v, %v", items, err)
+	}
+}
@@ -223,6 +223,10 @@ type ClientSubscription struct {
 	quit        chan error
 	forwardDone chan struct{}
 	unsubDone   chan struct{}
+
+	// resubscribed is signaled when a multi-endpoint client has re-established
+	// the subscription on another endpoint. It is nil for other clients.
+	resubscribed chan struct{}
 }
 
 // This is the sentinel value sent on sub.quit when Unsubscribe is called.
@@ -255,6 +259,17 @@ func (sub *ClientSubscription) Err() <-chan error {
 	return sub.err
 }
 
+// Resubscribed returns a channel which receives a value whenever the subscription was
+// moved to another endpoint after its connection was lost. Notifications emitted in
+// the meantime are not delivered, so consumers may need to fill the gap, e.g. by
+// querying the missed blocks.
+//
+// Only subscriptions of clients created by DialMulti are re-established. For all
+// other subscriptions, the returned channel is nil and never receives a value.
+func (sub *ClientSubscription) Resubscribed() <-chan struct{} {
+	return sub.resubscribed
+}
+
 // Unsubscribe unsubscribes the notification and closes the error channel.
 // It can safely be called more than once.
 func (sub *ClientSubscription) Unsubscribe() {
@@ -370,6 +385,10 @@ func (sub *ClientSubscription) unmarshal(result json.RawMessage) (interface{}, e
 }
 
 func (sub *ClientSubscription) requestUnsubscribe() error {
+	if sub.client.multi != nil {
+		// The endpoint subscription is torn down by the multi-endpoint relay.
+		return nil
+	}
 	var result interface{}
 	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.subid)
 }
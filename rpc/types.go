@@ -147,6 +147,11 @@ type BlockNumberOrHash struct {
 	BlockNumber      *BlockNumber `json:"blockNumber,omitempty"`
 	BlockHash        *common.Hash `json:"blockHash,omitempty"`
 	RequireCanonical bool         `json:"requireCanonical,omitempty"`
+
+	// TransactionIndex optionally narrows the reference down to the state right
+	// before the transaction at the given position within the block. It can only
+	// be set using the object notation.
+	TransactionIndex *hexutil.Uint `json:"transactionIndex,omitempty"`
 }
 
 func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
@@ -160,6 +165,7 @@ func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
 		bnh.BlockNumber = e.BlockNumber
 		bnh.BlockHash = e.BlockHash
 		bnh.RequireCanonical = e.RequireCanonical
+		bnh.TransactionIndex = e.TransactionIndex
 		return nil
 	}
 	var input string
@@ -236,6 +242,14 @@ func (bnh *BlockNumberOrHash) Hash() (common.Hash, bool) {
 	return common.Hash{}, false
 }
 
+// TxIndex returns the transaction index the reference is scoped to, if any.
+func (bnh *BlockNumberOrHash) TxIndex() (int, bool) {
+	if bnh.TransactionIndex != nil {
+		return int(*bnh.TransactionIndex), true
+	}
+	return 0, false
+}
+
 func BlockNumberOrHashWithNumber(blockNr BlockNumber) BlockNumberOrHash {
 	return BlockNumberOrHash{
 		BlockNumber:      &blockNr,
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
 )
 
@@ -70,6 +71,10 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 }
 
 func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
+	var (
+		one = BlockNumber(1)
+		two = hexutil.Uint(2)
+	)
 	tests := []struct {
 		input    string
 		mustFail bool
@@ -105,6 +110,9 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		27: {`{"blockNumber":"safe"}`, false, BlockNumberOrHashWithNumber(SafeBlockNumber)},
 		28: {`{"blockNumber":"finalized"}`, false, BlockNumberOrHashWithNumber(FinalizedBlockNumber)},
 		29: {`{"blockNumber":"0x1", "blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000"}`, true, BlockNumberOrHash{}},
+		30: {`{"blockNumber":"0x1","transactionIndex":"0x2"}`, false, BlockNumberOrHash{BlockNumber: &one, TransactionIndex: &two}},
+		31: {`{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000","transactionIndex":"0x2"}`, false, BlockNumberOrHash{BlockHash: &common.Hash{}, TransactionIndex: &two}},
+		32: {`{"blockNumber":"0x1","transactionIndex":2}`, true, BlockNumberOrHash{}},
 	}
 
 	for i, test := range tests {
@@ -122,9 +130,12 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		expectedHash, expectedHashOk := test.expected.Hash()
 		num, numOk := bnh.Number()
 		expectedNum, expectedNumOk := test.expected.Number()
+		index, indexOk := bnh.TxIndex()
+		expectedIndex, expectedIndexOk := test.expected.TxIndex()
 		if bnh.RequireCanonical != test.expected.RequireCanonical ||
 			hash != expectedHash || hashOk != expectedHashOk ||
-			num != expectedNum || numOk != expectedNumOk {
+			num != expectedNum || numOk != expectedNumOk ||
+			index != expectedIndex || indexOk != expectedIndexOk {
 			t.Errorf("Test %d got unexpected value, want %v, got %v", i, test.expected, bnh)
 		}
 	}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -296,6 +297,19 @@ func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header, readL
 	encode := func(v interface{}, isErrorResponse bool) error {
 		return conn.WriteJSON(v)
 	}
+	stream := func(fn func(io.Writer) error) error {
+		mw := &wsMessageWriter{conn: conn}
+		err := fn(mw)
+		if mw.w == nil || err != nil {
+			// Incomplete messages are not finished, the connection is closed
+			// instead.
+			return err
+		}
+		if cerr := mw.w.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
 	wc := &websocketCodec{
 		jsonCodec:    NewFuncCodec(conn, encode, conn.ReadJSON).(*jsonCodec),
 		conn:         conn,
@@ -306,6 +320,7 @@ func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header, readL
 			RemoteAddr: conn.RemoteAddr().String(),
 		},
 	}
+	wc.jsonCodec.stream = stream
 	// Fill in connection details.
 	wc.info.HTTP.Host = host
 	wc.info.HTTP.Origin = req.Get("Origin")
@@ -344,6 +359,18 @@ func (wc *websocketCodec) writeJSON(ctx context.Context, v interface{}, isError
 	return err
 }
 
+func (wc *websocketCodec) writeStream(ctx context.Context, fn func(io.Writer) error) error {
+	err := wc.jsonCodec.writeStream(ctx, fn)
+	if err == nil {
+		// Notify pingLoop to delay the next idle ping.
+		select {
+		case wc.pingReset <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
 // pingLoop sends periodic ping frames when the connection is idle.
 func (wc *websocketCodec) pingLoop() {
 	var pingTimer = time.NewTimer(wsPingInterval)
@@ -362,11 +389,10 @@ func (wc *websocketCodec) pingLoop() {
 			pingTimer.Reset(wsPingInterval)
 
 		case <-pingTimer.C:
-			wc.jsonCodec.encMu.Lock()
-			wc.conn.SetWriteDeadline(time.Now().Add(wsPingWriteTimeout))
-			wc.conn.WriteMessage(websocket.PingMessage, nil)
+			// Control frames can be sent in between the pieces of a streamed
+			// message, which would be cut off by WriteMessage.
+			wc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPingWriteTimeout))
 			wc.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
-			wc.jsonCodec.encMu.Unlock()
 			pingTimer.Reset(wsPingInterval)
 
 		case <-wc.pongReceived:
@@ -374,3 +400,21 @@ func (wc *websocketCodec) pingLoop() {
 		}
 	}
 }
+
+// wsMessageWriter writes a single text message. The message is only started on the
+// first write, so other messages can still be sent while its content is produced.
+type wsMessageWriter struct {
+	conn *websocket.Conn
+	w    io.WriteCloser
+}
+
+func (mw *wsMessageWriter) Write(p []byte) (int, error) {
+	if mw.w == nil {
+		w, err := mw.conn.NextWriter(websocket.TextMessage)
+		if err != nil {
+			return 0, err
+		}
+		mw.w = w
+	}
+	return mw.w.Write(p)
+}
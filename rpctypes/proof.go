@@ -0,0 +1,129 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctypes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Verify checks the account proof against the given state root, and the storage
+// proofs against the storage root of the account. It returns an error if any of
+// the proofs is invalid or doesn't prove the values of the result.
+func (r *AccountResult) Verify(root common.Hash) error {
+	blob, err := verifyProof(root, crypto.Keccak256(r.Address[:]), r.AccountProof)
+	if err != nil {
+		return fmt.Errorf("invalid account proof: %w", err)
+	}
+	balance := new(big.Int)
+	if r.Balance != nil {
+		balance = r.Balance.ToInt()
+	}
+	storageRoot := r.StorageHash
+	if blob == nil {
+		// The account doesn't exist, all of its fields must be empty
+		if r.Nonce != 0 || balance.Sign() != 0 || (r.CodeHash != common.Hash{} && r.CodeHash != types.EmptyCodeHash) {
+			return errors.New("account proven to be absent")
+		}
+		if storageRoot != (common.Hash{}) && storageRoot != types.EmptyRootHash {
+			return errors.New("storage root of absent account")
+		}
+		storageRoot = types.EmptyRootHash
+	} else {
+		var account types.StateAccount
+		if err := rlp.DecodeBytes(blob, &account); err != nil {
+			return fmt.Errorf("invalid account: %w", err)
+		}
+		switch {
+		case account.Nonce != uint64(r.Nonce):
+			return fmt.Errorf("nonce mismatch: proven %d, have %d", account.Nonce, r.Nonce)
+		case account.Balance.ToBig().Cmp(balance) != 0:
+			return fmt.Errorf("balance mismatch: proven %v, have %v", account.Balance, balance)
+		case !bytes.Equal(account.CodeHash, r.CodeHash[:]):
+			return fmt.Errorf("code hash mismatch: proven %x, have %x", account.CodeHash, r.CodeHash)
+		case account.Root != storageRoot:
+			return fmt.Errorf("storage root mismatch: proven %x, have %x", account.Root, storageRoot)
+		}
+	}
+	for _, slot := range r.StorageProof {
+		if err := slot.Verify(storageRoot); err != nil {
+			return fmt.Errorf("storage slot %s: %w", slot.Key, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks the storage proof against the given storage root.
+func (r *StorageResult) Verify(root common.Hash) error {
+	// Keys are either quantities or 32 byte hashes, depending on the request
+	key, ok := new(big.Int).SetString(strings.TrimPrefix(r.Key, "0x"), 16)
+	if !ok || key.Sign() < 0 || key.BitLen() > 256 {
+		return fmt.Errorf("invalid key %q", r.Key)
+	}
+	value := new(big.Int)
+	if r.Value != nil {
+		value = r.Value.ToInt()
+	}
+	if root == types.EmptyRootHash || root == (common.Hash{}) {
+		if value.Sign() != 0 {
+			return errors.New("value in empty storage")
+		}
+		return nil
+	}
+	slot := common.BigToHash(key)
+	blob, err := verifyProof(root, crypto.Keccak256(slot[:]), r.Proof)
+	if err != nil {
+		return err
+	}
+	proven := new(big.Int)
+	if blob != nil {
+		_, content, _, err := rlp.Split(blob)
+		if err != nil {
+			return fmt.Errorf("invalid value: %w", err)
+		}
+		proven.SetBytes(content)
+	}
+	if proven.Cmp(value) != 0 {
+		return fmt.Errorf("value mismatch: proven %v, have %v", proven, value)
+	}
+	return nil
+}
+
+// verifyProof verifies a Merkle proof given as hex-encoded trie nodes, returning
+// the proven value, or nil if the key is proven to be absent.
+func verifyProof(root common.Hash, key []byte, proof []string) ([]byte, error) {
+	db := memorydb.New()
+	for _, node := range proof {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proof node: %w", err)
+		}
+		db.Put(crypto.Keccak256(blob), blob)
+	}
+	return trie.VerifyProof(root, key, db)
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rpctypes contains the result types of the JSON-RPC API served by geth.
+// The types are used by the server and the clients alike, so they are always in
+// sync with each other.
+//
+// The types carry a version of their own, see Version. Nodes serve it through
+// web3_apiVersion, so that clients can check whether they can decode the
+// results of a node before relying on them.
+package rpctypes
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// FeeHistoryResult is the result of eth_feeHistory.
+type FeeHistoryResult struct {
+	OldestBlock      *hexutil.Big     `json:"oldestBlock"`
+	Reward           [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee          []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio     []float64        `json:"gasUsedRatio"`
+	BlobBaseFee      []*hexutil.Big   `json:"baseFeePerBlobGas,omitempty"`
+	BlobGasUsedRatio []float64        `json:"blobGasUsedRatio,omitempty"`
+	BlobReward       [][]*hexutil.Big `json:"blobReward,omitempty"`
+}
+
+// FeePredictionResult is the result of eth_feePrediction.
+type FeePredictionResult struct {
+	Blocks         []BlockPredictionResult `json:"blocks"`
+	PendingGas     []TipBucketResult       `json:"pendingGasByTip"`
+	UnderpricedGas hexutil.Uint64          `json:"underpricedGas"`
+}
+
+// BlockPredictionResult is the predicted fee market of a future block.
+type BlockPredictionResult struct {
+	Number           hexutil.Uint64 `json:"number"`
+	BaseFee          FeeBandResult  `json:"baseFeePerGas"`
+	BlobBaseFee      FeeBandResult  `json:"baseFeePerBlobGas"`
+	GasUsedRatio     float64        `json:"gasUsedRatio"`
+	BlobGasUsedRatio float64        `json:"blobGasUsedRatio"`
+}
+
+// FeeBandResult is a predicted fee along with its confidence band.
+type FeeBandResult struct {
+	Low      *hexutil.Big `json:"low"`
+	Expected *hexutil.Big `json:"expected"`
+	High     *hexutil.Big `json:"high"`
+}
+
+// TipBucketResult is the pending demand paying at least a given effective tip.
+type TipBucketResult struct {
+	MinTip *hexutil.Big   `json:"minTip"`
+	Gas    hexutil.Uint64 `json:"gas"`
+	Txs    hexutil.Uint   `json:"transactions"`
+}
+
+// AccessListResult is the result of eth_createAccessList. It contains an error
+// if the transaction itself failed.
+type AccessListResult struct {
+	Accesslist *types.AccessList `json:"accessList"`
+	Error      string            `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64    `json:"gasUsed"`
+}
+
+// AccountResult is the result of eth_getProof.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the proof of a storage slot in the result of eth_getProof.
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
+type RPCTransaction struct {
+	BlockHash           *common.Hash      `json:"blockHash"`
+	BlockNumber         *hexutil.Big      `json:"blockNumber"`
+	From                common.Address    `json:"from"`
+	Gas                 hexutil.Uint64    `json:"gas"`
+	GasPrice            *hexutil.Big      `json:"gasPrice"`
+	GasFeeCap           *hexutil.Big      `json:"maxFeePerGas,omitempty"`
+	GasTipCap           *hexutil.Big      `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerBlobGas    *hexutil.Big      `json:"maxFeePerBlobGas,omitempty"`
+	Hash                common.Hash       `json:"hash"`
+	Input               hexutil.Bytes     `json:"input"`
+	Nonce               hexutil.Uint64    `json:"nonce"`
+	To                  *common.Address   `json:"to"`
+	TransactionIndex    *hexutil.Uint64   `json:"transactionIndex"`
+	Value               *hexutil.Big      `json:"value"`
+	Type                hexutil.Uint64    `json:"type"`
+	Accesses            *types.AccessList `json:"accessList,omitempty"`
+	ChainID             *hexutil.Big      `json:"chainId,omitempty"`
+	BlobVersionedHashes []common.Hash     `json:"blobVersionedHashes,omitempty"`
+	V                   *hexutil.Big      `json:"v"`
+	R                   *hexutil.Big      `json:"r"`
+	S                   *hexutil.Big      `json:"s"`
+	YParity             *hexutil.Uint64   `json:"yParity,omitempty"`
+}
+
+// TxPoolStatus is the result of txpool_status.
+type TxPoolStatus struct {
+	Pending hexutil.Uint `json:"pending"`
+	Queued  hexutil.Uint `json:"queued"`
+}
+
+// TxPoolContent is the result of txpool_content, with the transactions grouped
+// by sender and nonce.
+type TxPoolContent struct {
+	Pending map[common.Address]map[uint64]*RPCTransaction `json:"pending"`
+	Queued  map[common.Address]map[uint64]*RPCTransaction `json:"queued"`
+}
+
+// TxPoolContentFrom is the result of txpool_contentFrom, with the transactions
+// of the sender by nonce.
+type TxPoolContentFrom struct {
+	Pending map[uint64]*RPCTransaction `json:"pending"`
+	Queued  map[uint64]*RPCTransaction `json:"queued"`
+}
+
+// TxPoolInspect is the result of txpool_inspect, with textual summaries of the
+// transactions grouped by sender and nonce.
+type TxPoolInspect struct {
+	Pending map[common.Address]map[uint64]string `json:"pending"`
+	Queued  map[common.Address]map[uint64]string `json:"queued"`
+}
@@ -0,0 +1,305 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctypes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CallLog is a log emitted by a call, as reported by the callTracer.
+type CallLog struct {
+	Address common.Address `json:"address"`
+	Topics  []common.Hash  `json:"topics"`
+	Data    hexutil.Bytes  `json:"data"`
+	// Position of the log relative to the subcalls of the emitting call
+	Position hexutil.Uint `json:"position"`
+}
+
+// CallFrame is a call and its subcalls, as reported by the callTracer.
+type CallFrame struct {
+	Type         string          `json:"type"` // Opcode of the call, e.g. CALL or CREATE2
+	From         common.Address  `json:"from"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	To           *common.Address `json:"to,omitempty"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	RevertChain  []string        `json:"revertChain,omitempty"` // Revert reasons down to the root cause of the revert
+	Calls        []CallFrame     `json:"calls,omitempty"`
+	Logs         []CallLog       `json:"logs,omitempty"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+}
+
+// CallTracerConfig is the configuration of the callTracer.
+type CallTracerConfig struct {
+	OnlyTopCall bool `json:"onlyTopCall"` // If true, subcalls are not traced
+	WithLog     bool `json:"withLog"`     // If true, the logs of the calls are reported
+}
+
+// Failed returns whether the call failed.
+func (f *CallFrame) Failed() bool {
+	return f.Error != ""
+}
+
+// Walk traverses the call and its subcalls depth-first, in the order they were
+// made. The callback is invoked with each frame and its trace address, i.e. the
+// indices of the subcalls leading to it, which must not be retained. If the
+// callback returns false, the subcalls of the frame are skipped.
+func (f *CallFrame) Walk(fn func(frame *CallFrame, address []int) bool) {
+	f.walk(fn, nil)
+}
+
+func (f *CallFrame) walk(fn func(*CallFrame, []int) bool, address []int) {
+	if !fn(f, address) {
+		return
+	}
+	for i := range f.Calls {
+		f.Calls[i].walk(fn, append(address, i))
+	}
+}
+
+// Method looks up the method called by the frame in the given ABI.
+func (f *CallFrame) Method(contract *abi.ABI) (*abi.Method, error) {
+	if len(f.Input) < 4 {
+		return nil, errors.New("input too short for method selector")
+	}
+	return contract.MethodById(f.Input[:4])
+}
+
+// DecodeInput decodes the input of the call against the given ABI, returning the
+// called method and its arguments.
+func (f *CallFrame) DecodeInput(contract *abi.ABI) (*abi.Method, []interface{}, error) {
+	method, err := f.Method(contract)
+	if err != nil {
+		return nil, nil, err
+	}
+	args, err := method.Inputs.Unpack(f.Input[4:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s arguments: %w", method.Name, err)
+	}
+	return method, args, nil
+}
+
+// DecodeOutput decodes the return values of a successful call against the given
+// ABI.
+func (f *CallFrame) DecodeOutput(contract *abi.ABI) ([]interface{}, error) {
+	if f.Failed() {
+		return nil, fmt.Errorf("call failed: %s", f.Error)
+	}
+	method, err := f.Method(contract)
+	if err != nil {
+		return nil, err
+	}
+	values, err := method.Outputs.Unpack(f.Output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s results: %w", method.Name, err)
+	}
+	return values, nil
+}
+
+// DecodeRevert decodes the revert data of a reverted call. Custom errors are
+// looked up in the given ABI, which may be nil for decoding the builtin Error
+// and Panic reverts only. The returned error is nil for builtin reverts.
+func (f *CallFrame) DecodeRevert(contract *abi.ABI) (string, *abi.Error, []interface{}, error) {
+	if len(f.Output) < 4 {
+		return "", nil, nil, errors.New("no revert data")
+	}
+	if reason, err := abi.UnpackRevert(f.Output); err == nil {
+		return reason, nil, nil, nil
+	}
+	if contract == nil {
+		return "", nil, nil, fmt.Errorf("unknown revert selector %x", f.Output[:4])
+	}
+	abiErr, err := contract.ErrorByID([4]byte(f.Output[:4]))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	args, err := abiErr.Inputs.Unpack(f.Output[4:])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to decode %s arguments: %w", abiErr.Name, err)
+	}
+	return abiErr.Sig, abiErr, args, nil
+}
+
+// PrestateAccount is the state of an account, as reported by the prestateTracer.
+type PrestateAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+
+	DecodedStorage map[string]string `json:"decodedStorage,omitempty"` // Storage by variable, if the layout is known
+}
+
+// PrestateResult is the result of the prestateTracer, the state accessed by the
+// transaction before its execution.
+type PrestateResult map[common.Address]*PrestateAccount
+
+// PrestateDiffResult is the result of the prestateTracer in diff mode, the state
+// modified by the transaction before and after its execution.
+type PrestateDiffResult struct {
+	Post PrestateResult `json:"post"`
+	Pre  PrestateResult `json:"pre"`
+}
+
+// PrestateTracerConfig is the configuration of the prestateTracer.
+type PrestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // If true, the modified state is reported
+}
+
+// FourByteResult is the result of the 4byteTracer, counting the calls by method
+// selector and call data size, keyed as "<selector>-<size>".
+type FourByteResult map[string]int
+
+// FourByteCall is a key of the 4byteTracer result.
+type FourByteCall struct {
+	Selector [4]byte
+	Size     int // Size of the call data without the selector
+}
+
+// Calls returns the counts of the calls by selector and call data size.
+func (r FourByteResult) Calls() (map[FourByteCall]int, error) {
+	calls := make(map[FourByteCall]int, len(r))
+	for key, count := range r {
+		id, size, ok := strings.Cut(key, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid key %q", key)
+		}
+		selector, err := hexutil.Decode(id)
+		if err != nil || len(selector) != 4 {
+			return nil, fmt.Errorf("invalid selector in key %q", key)
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size in key %q", key)
+		}
+		calls[FourByteCall{Selector: [4]byte(selector), Size: n}] += count
+	}
+	return calls, nil
+}
+
+// FlatCallFrame is a call, as reported by the flatCallTracer in the format of
+// the trace_ namespace of Parity.
+type FlatCallFrame struct {
+	Action              FlatCallAction  `json:"action"`
+	BlockHash           *common.Hash    `json:"blockHash"`
+	BlockNumber         uint64          `json:"blockNumber"`
+	Error               string          `json:"error,omitempty"`
+	Result              *FlatCallResult `json:"result,omitempty"`
+	Subtraces           int             `json:"subtraces"`
+	TraceAddress        []int           `json:"traceAddress"`
+	TransactionHash     *common.Hash    `json:"transactionHash"`
+	TransactionPosition uint64          `json:"transactionPosition"`
+	Type                string          `json:"type"` // One of call, create, suicide and reward
+}
+
+// FlatCallAction is the action of a FlatCallFrame.
+type FlatCallAction struct {
+	Author         *common.Address `json:"author,omitempty"`
+	RewardType     string          `json:"rewardType,omitempty"`
+	SelfDestructed *common.Address `json:"address,omitempty"`
+	Balance        *hexutil.Big    `json:"balance,omitempty"`
+	CallType       string          `json:"callType,omitempty"`
+	CreationMethod string          `json:"creationMethod,omitempty"`
+	From           *common.Address `json:"from,omitempty"`
+	Gas            *hexutil.Uint64 `json:"gas,omitempty"`
+	Init           *hexutil.Bytes  `json:"init,omitempty"`
+	Input          *hexutil.Bytes  `json:"input,omitempty"`
+	RefundAddress  *common.Address `json:"refundAddress,omitempty"`
+	To             *common.Address `json:"to,omitempty"`
+	Value          *hexutil.Big    `json:"value,omitempty"`
+}
+
+// FlatCallResult is the result of a successful FlatCallFrame.
+type FlatCallResult struct {
+	Address *common.Address `json:"address,omitempty"`
+	Code    *hexutil.Bytes  `json:"code,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`
+}
+
+// TokenTransfer is a movement of ether or tokens, as reported by the
+// tokenTransferTracer.
+type TokenTransfer struct {
+	Standard     string          `json:"standard"`           // One of eth, erc20, erc721 and erc1155
+	Token        *common.Address `json:"token,omitempty"`    // Contract of the token, nil for ether
+	Operator     *common.Address `json:"operator,omitempty"` // Operator of ERC-1155 transfers
+	From         common.Address  `json:"from"`
+	To           common.Address  `json:"to"`
+	Value        *hexutil.Big    `json:"value,omitempty"`    // Amount transferred, nil for ERC-721
+	TokenID      *hexutil.Big    `json:"tokenId,omitempty"`  // Token transferred, for ERC-721 and ERC-1155
+	TraceAddress []int           `json:"traceAddress"`       // Call frame of the transfer
+	LogIndex     *hexutil.Uint   `json:"logIndex,omitempty"` // Index of the token transfer event in the block
+}
+
+// TxTokenTransfers are the transfers made by a transaction.
+type TxTokenTransfers struct {
+	TxHash    common.Hash     `json:"txHash"`
+	Transfers []TokenTransfer `json:"transfers"`
+}
+
+// LiveTracerInfo describes a live tracer attached to the node, as reported by
+// admin_liveTracers.
+type LiveTracerInfo struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config,omitempty"`
+	// Total time spent in the hooks of the tracer
+	Overhead time.Duration `json:"overhead"`
+}
+
+// StreamTraceConfig is the configuration of standard traces streamed by the
+// debug_subscribe traceTransaction and traceBlock subscriptions.
+type StreamTraceConfig struct {
+	EnableMemory     bool                `json:"enableMemory,omitempty"`     // If true, memory is captured
+	DisableStack     bool                `json:"disableStack,omitempty"`     // If true, the stack isn't captured
+	DisableStorage   bool                `json:"disableStorage,omitempty"`   // If true, storage isn't captured (struct logs only)
+	EnableReturnData bool                `json:"enableReturnData,omitempty"` // If true, return data is captured
+	Limit            int                 `json:"limit,omitempty"`            // Maximum number of struct logs per transaction, zero means unlimited
+	Overrides        *params.ChainConfig `json:"overrides,omitempty"`        // Chain rules to trace the block with, e.g. of future forks
+	Reexec           *uint64             `json:"reexec,omitempty"`           // Maximum number of blocks to re-execute for the missing state
+	TxHash           *common.Hash        `json:"txHash,omitempty"`           // Only trace this transaction of the block
+	Format           string              `json:"format,omitempty"`           // Either "json" (EIP-3155, default) or "structLogs"
+	BatchSize        int                 `json:"batchSize,omitempty"`        // Maximum number of entries per notification
+	Timeout          *string             `json:"timeout,omitempty"`          // Maximum duration of the stream, e.g. "5m"
+}
+
+// StreamedTrace is a batch of trace entries of a transaction, delivered by the
+// trace stream subscriptions. The last batch of a transaction is marked as
+// done, and its last entry is the execution summary. The stream itself ends with
+// a notification without entries marked as the end, carrying the error aborting
+// the stream, if any.
+type StreamedTrace struct {
+	TxIndex int               `json:"txIndex"`
+	TxHash  common.Hash       `json:"txHash"`
+	Entries []json.RawMessage `json:"entries"`
+	Done    bool              `json:"done"`            // Whether the transaction trace is complete
+	End     bool              `json:"end,omitempty"`   // Whether the stream is complete
+	Error   string            `json:"error,omitempty"` // Error aborting the transaction or stream
+}
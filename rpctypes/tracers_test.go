@@ -0,0 +1,128 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctypes
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testABI = `[
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}
+]`
+
+func TestCallFrameWalk(t *testing.T) {
+	frame := &CallFrame{Type: "CALL", Calls: []CallFrame{
+		{Type: "DELEGATECALL", Calls: []CallFrame{{Type: "STATICCALL"}, {Type: "CREATE"}}},
+		{Type: "CALL", Calls: []CallFrame{{Type: "CREATE2"}}},
+	}}
+	var (
+		types     []string
+		addresses [][]int
+	)
+	frame.Walk(func(f *CallFrame, address []int) bool {
+		types = append(types, f.Type)
+		addresses = append(addresses, append([]int{}, address...))
+		return f.Type != "CALL" || len(address) == 0 // Skip the subcalls of nested calls
+	})
+	if want := []string{"CALL", "DELEGATECALL", "STATICCALL", "CREATE", "CALL"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("walk order mismatch, want %v, got %v", want, types)
+	}
+	if want := [][]int{{}, {0}, {0, 0}, {0, 1}, {1}}; !reflect.DeepEqual(addresses, want) {
+		t.Errorf("trace address mismatch, want %v, got %v", want, addresses)
+	}
+}
+
+func TestCallFrameDecode(t *testing.T) {
+	contract, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0xdead")
+	input, err := contract.Pack("transfer", to, big.NewInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := contract.Methods["transfer"].Outputs.Pack(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := &CallFrame{Input: input, Output: output}
+	method, args, err := frame.DecodeInput(&contract)
+	if err != nil {
+		t.Fatalf("failed to decode input: %v", err)
+	}
+	if method.Name != "transfer" || args[0].(common.Address) != to || args[1].(*big.Int).Int64() != 100 {
+		t.Errorf("input mismatch: %s %v", method.Name, args)
+	}
+	values, err := frame.DecodeOutput(&contract)
+	if err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+	if len(values) != 1 || values[0] != true {
+		t.Errorf("output mismatch: %v", values)
+	}
+	// Custom errors are decoded from the ABI
+	id := contract.Errors["InsufficientBalance"].ID
+	revert := append(id[:4:4], make([]byte, 64)...)
+	revert[4+31], revert[4+63] = 1, 2
+
+	frame = &CallFrame{Input: input, Output: revert, Error: "execution reverted"}
+	if _, err := frame.DecodeOutput(&contract); err == nil {
+		t.Error("decoded output of failed call")
+	}
+	reason, abiErr, args, err := frame.DecodeRevert(&contract)
+	if err != nil {
+		t.Fatalf("failed to decode revert: %v", err)
+	}
+	if reason != "InsufficientBalance(uint256,uint256)" || abiErr.Name != "InsufficientBalance" || args[0].(*big.Int).Int64() != 1 || args[1].(*big.Int).Int64() != 2 {
+		t.Errorf("custom error mismatch: %s %v", reason, args)
+	}
+	if _, _, _, err := frame.DecodeRevert(nil); err == nil {
+		t.Error("decoded custom error without ABI")
+	}
+	// Builtin reverts are decoded without the ABI
+	frame.Output = common.FromHex("0x08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000036e6f700000000000000000000000000000000000000000000000000000000000")
+	if reason, abiErr, _, err := frame.DecodeRevert(nil); err != nil || reason != "nop" || abiErr != nil {
+		t.Errorf("revert reason mismatch: %q, %v", reason, err)
+	}
+}
+
+func TestFourByteCalls(t *testing.T) {
+	calls, err := FourByteResult{"0x27dc297e-128": 1, "0x38cc4831-0": 2}.Calls()
+	if err != nil {
+		t.Fatalf("failed to parse result: %v", err)
+	}
+	want := map[FourByteCall]int{
+		{Selector: [4]byte{0x27, 0xdc, 0x29, 0x7e}, Size: 128}: 1,
+		{Selector: [4]byte{0x38, 0xcc, 0x48, 0x31}, Size: 0}:   2,
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls mismatch, want %v, got %v", want, calls)
+	}
+	for _, key := range []string{"0x27dc297e", "0x27dc29-4", "0x27dc297e-x"} {
+		if _, err := (FourByteResult{key: 1}).Calls(); err == nil {
+			t.Errorf("parsed invalid key %q", key)
+		}
+	}
+}
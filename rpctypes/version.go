@@ -0,0 +1,52 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctypes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The types are versioned separately from the releases of geth. Minor versions
+// only add types and fields, whose results clients of older minor versions keep
+// decoding. Major versions change or remove existing types and fields. Nodes
+// report the version they serve through web3_apiVersion.
+const (
+	VersionMajor = 1 // Major version component, bumped on incompatible changes
+	VersionMinor = 0 // Minor version component, bumped on additions
+)
+
+// Version holds the textual version of the types.
+var Version = fmt.Sprintf("%d.%d", VersionMajor, VersionMinor)
+
+// CheckVersion checks that the results served with the given version of the
+// types can be decoded into the types of this version.
+func CheckVersion(version string) error {
+	major, _, ok := strings.Cut(version, ".")
+	if !ok {
+		return fmt.Errorf("invalid API version %q", version)
+	}
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return fmt.Errorf("invalid API version %q", version)
+	}
+	if n != VersionMajor {
+		return fmt.Errorf("incompatible API version %s, want %d.x", version, VersionMajor)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctypes
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		ok      bool
+	}{
+		{Version, true},
+		{fmt.Sprintf("%d.%d", VersionMajor, VersionMinor+1), true},
+		{fmt.Sprintf("%d.0", VersionMajor+1), false},
+		{"1", false},
+		{"v1.0", false},
+	} {
+		if err := CheckVersion(tt.version); (err == nil) != tt.ok {
+			t.Errorf("version %q: have error %v, want ok %t", tt.version, err, tt.ok)
+		}
+	}
+}